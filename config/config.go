@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
 
 	"go.uber.org/fx"
 )
@@ -23,10 +24,24 @@ type Config struct {
 	GrypeScannerToken      string
 	BackupLocation         string
 	BackupHelperImage      string
+	VolumeHelperImage      string
 	BackupPersistenceDir   string
 	MaxSignedBodyBytes     int64
 	MaxDownloadBytes       int64
 	MaxUploadBytes         int64
+	MaxChunkedUploadBytes  int64
+	StackQuotaBytes        int64
+	StackQuotas            map[string]int64
+	StackQuotaVolumes      bool
+	StackUsageCacheMinutes int
+	UploadSessionTTLHours  int
+	HistoryPersistenceDir  string
+	HistoryRetentionDays   int
+	FileHistoryMaxVersions int
+	TrashRetentionDays     int
+	SecretsKeyFile         string
+	SecretsPersistenceDir  string
+	SecretsRuntimeDir      string
 }
 
 func NewConfig() *Config {
@@ -46,10 +61,24 @@ func NewConfig() *Config {
 		GrypeScannerToken:      getEnv("GRYPE_SCANNER_TOKEN", ""),
 		BackupLocation:         getEnv("BACKUP_LOCATION", ""),
 		BackupHelperImage:      getEnv("BACKUP_HELPER_IMAGE", ""),
+		VolumeHelperImage:      getEnv("VOLUME_HELPER_IMAGE", ""),
 		MaxSignedBodyBytes:     int64(getEnvInt("MAX_SIGNED_BODY_MB", 128)) * 1024 * 1024,
 		MaxDownloadBytes:       int64(getEnvInt("MAX_DOWNLOAD_MB", 100)) * 1024 * 1024,
 		MaxUploadBytes:         int64(getEnvInt("MAX_UPLOAD_MB", 100)) * 1024 * 1024,
+		MaxChunkedUploadBytes:  int64(getEnvInt("MAX_CHUNKED_UPLOAD_MB", 20480)) * 1024 * 1024,
+		StackQuotaBytes:        int64(getEnvInt("STACK_QUOTA_MB", 0)) * 1024 * 1024,
+		StackQuotas:            getEnvQuotas("STACK_QUOTAS"),
+		StackQuotaVolumes:      getEnvBool("STACK_QUOTA_INCLUDE_VOLUMES", false),
+		StackUsageCacheMinutes: getEnvInt("STACK_USAGE_CACHE_MINUTES", 10),
+		UploadSessionTTLHours:  getEnvInt("UPLOAD_SESSION_TTL_HOURS", 24),
 		BackupPersistenceDir:   getEnv("BACKUP_PERSISTENCE_DIR", "/var/lib/berth-agent/backups"),
+		HistoryPersistenceDir:  getEnv("HISTORY_PERSISTENCE_DIR", "/var/lib/berth-agent/history"),
+		HistoryRetentionDays:   getEnvInt("HISTORY_RETENTION_DAYS", 30),
+		FileHistoryMaxVersions: getEnvInt("FILE_HISTORY_MAX_VERSIONS", 50),
+		TrashRetentionDays:     getEnvInt("TRASH_RETENTION_DAYS", 7),
+		SecretsKeyFile:         getEnv("SECRETS_KEY_FILE", "./ssl/secrets.key"),
+		SecretsPersistenceDir:  getEnv("SECRETS_PERSISTENCE_DIR", "/var/lib/berth-agent/secrets"),
+		SecretsRuntimeDir:      getEnv("SECRETS_RUNTIME_DIR", "/run/berth-agent/secrets"),
 	}
 }
 
@@ -90,6 +119,20 @@ func getEnvInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
+func getEnvQuotas(key string) map[string]int64 {
+	quotas := make(map[string]int64)
+	for _, entry := range strings.Split(os.Getenv(key), ",") {
+		name, value, found := strings.Cut(strings.TrimSpace(entry), "=")
+		if !found {
+			continue
+		}
+		if mb, err := strconv.Atoi(strings.TrimSpace(value)); err == nil && mb >= 0 {
+			quotas[strings.TrimSpace(name)] = int64(mb) * 1024 * 1024
+		}
+	}
+	return quotas
+}
+
 var Module = fx.Options(
 	fx.Provide(NewConfig),
 )
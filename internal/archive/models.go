@@ -2,6 +2,7 @@ package archive
 
 import (
 	"context"
+	"io"
 )
 
 type CreateOptions struct {
@@ -17,6 +18,7 @@ type ExtractOptions struct {
 	DestinationPath string
 	Overwrite       bool
 	CreateDirs      bool
+	MaxBytes        int64
 }
 
 type ProgressWriter interface {
@@ -27,5 +29,6 @@ type ProgressWriter interface {
 
 type ArchiveHandler interface {
 	Create(ctx context.Context, basePath string, opts CreateOptions, writer ProgressWriter) error
+	Write(ctx context.Context, basePath string, opts CreateOptions, out io.Writer, writer ProgressWriter) error
 	Extract(ctx context.Context, opts ExtractOptions, writer ProgressWriter) error
 }
@@ -2,12 +2,16 @@ package archive
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
 )
 
+var ErrSizeLimitExceeded = errors.New("extracted size exceeds the allowed limit")
+
 type Service struct {
 	handlers map[string]ArchiveHandler
 }
@@ -47,6 +51,25 @@ func (s *Service) CreateArchive(ctx context.Context, basePath string, opts Creat
 	return handler.Create(ctx, basePath, opts, writer)
 }
 
+func (s *Service) StreamArchive(ctx context.Context, basePath string, opts CreateOptions, out io.Writer, writer ProgressWriter) error {
+	handler, exists := s.handlers[opts.Format]
+	if !exists {
+		return fmt.Errorf("unsupported format: %s", opts.Format)
+	}
+
+	if len(opts.IncludePaths) == 0 {
+		opts.IncludePaths = []string{"."}
+	}
+
+	for _, includePath := range opts.IncludePaths {
+		if err := EnsureWithinStackPath(filepath.Join(basePath, includePath), basePath); err != nil {
+			return err
+		}
+	}
+
+	return handler.Write(ctx, basePath, opts, out, writer)
+}
+
 func (s *Service) ExtractArchive(ctx context.Context, basePath string, opts ExtractOptions, writer ProgressWriter) error {
 	if opts.ArchivePath == "" {
 		return fmt.Errorf("archive path is required")
@@ -100,3 +123,30 @@ func (s *Service) detectFormat(archivePath string) string {
 
 	return ext
 }
+
+func isExcluded(relPath string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := filepath.Match(pattern, relPath); matched {
+			return true
+		}
+	}
+	return false
+}
+
+func copyWithinLimit(dst io.Writer, src io.Reader, limit int64, total *int64) error {
+	if limit <= 0 {
+		written, err := io.Copy(dst, src)
+		*total += written
+		return err
+	}
+
+	written, err := io.Copy(dst, io.LimitReader(src, limit-*total+1))
+	*total += written
+	if err != nil {
+		return err
+	}
+	if *total > limit {
+		return ErrSizeLimitExceeded
+	}
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"archive/tar"
 	"compress/gzip"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -24,17 +25,24 @@ func (h *TarHandler) Create(ctx context.Context, basePath string, opts CreateOpt
 	}
 	defer tarFile.Close()
 
+	if err := h.Write(ctx, basePath, opts, tarFile, writer); err != nil {
+		return err
+	}
+
+	return tarFile.Close()
+}
+
+func (h *TarHandler) Write(ctx context.Context, basePath string, opts CreateOptions, out io.Writer, writer ProgressWriter) error {
+	var gzWriter *gzip.Writer
 	var tarWriter *tar.Writer
 	compress := opts.Compression == "gzip" || strings.HasSuffix(opts.Format, ".gz")
 
 	if compress {
-		gzWriter := gzip.NewWriter(tarFile)
-		defer gzWriter.Close()
+		gzWriter = gzip.NewWriter(out)
 		tarWriter = tar.NewWriter(gzWriter)
 	} else {
-		tarWriter = tar.NewWriter(tarFile)
+		tarWriter = tar.NewWriter(out)
 	}
-	defer tarWriter.Close()
 
 	fileCount := 0
 
@@ -54,24 +62,41 @@ func (h *TarHandler) Create(ctx context.Context, basePath string, opts CreateOpt
 			}
 
 			relPath, _ := filepath.Rel(basePath, path)
-			for _, pattern := range opts.ExcludePatterns {
-				if matched, _ := filepath.Match(pattern, relPath); matched {
+			if isExcluded(relPath, opts.ExcludePatterns) {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			var link string
+			switch {
+			case info.Mode()&os.ModeSymlink != 0:
+				link, err = os.Readlink(path)
+				if err != nil {
+					writer.WriteError(fmt.Sprintf("Error reading link %s: %v", path, err))
 					return nil
 				}
+			case !info.IsDir() && !info.Mode().IsRegular():
+				writer.WriteError(fmt.Sprintf("Skipping special file %s", relPath))
+				return nil
 			}
 
-			header, err := tar.FileInfoHeader(info, "")
+			header, err := tar.FileInfoHeader(info, link)
 			if err != nil {
 				return err
 			}
 
-			header.Name = relPath
+			header.Name = filepath.ToSlash(relPath)
+			if info.IsDir() {
+				header.Name += "/"
+			}
 
 			if err := tarWriter.WriteHeader(header); err != nil {
 				return err
 			}
 
-			if !info.IsDir() {
+			if info.Mode().IsRegular() {
 				file, err := os.Open(path)
 				if err != nil {
 					return err
@@ -97,6 +122,15 @@ func (h *TarHandler) Create(ctx context.Context, basePath string, opts CreateOpt
 		}
 	}
 
+	if err := tarWriter.Close(); err != nil {
+		return fmt.Errorf("failed to finalise tar archive: %w", err)
+	}
+	if gzWriter != nil {
+		if err := gzWriter.Close(); err != nil {
+			return fmt.Errorf("failed to finalise gzip stream: %w", err)
+		}
+	}
+
 	writer.WriteStdout(fmt.Sprintf("Archive created with %d files", fileCount))
 	return nil
 }
@@ -127,6 +161,7 @@ func (h *TarHandler) Extract(ctx context.Context, opts ExtractOptions, writer Pr
 	}
 
 	fileCount := 0
+	var extracted int64
 	for {
 		select {
 		case <-ctx.Done():
@@ -171,9 +206,13 @@ func (h *TarHandler) Extract(ctx context.Context, opts ExtractOptions, writer Pr
 			continue
 		}
 
-		_, err = io.Copy(outFile, tarReader)
+		err = copyWithinLimit(outFile, tarReader, opts.MaxBytes, &extracted)
 		outFile.Close()
 
+		if errors.Is(err, ErrSizeLimitExceeded) {
+			_ = os.Remove(path)
+			return fmt.Errorf("failed to extract file %s: %w", header.Name, err)
+		}
 		if err != nil {
 			writer.WriteError(fmt.Sprintf("Failed to extract file %s: %v", header.Name, err))
 			continue
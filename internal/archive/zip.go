@@ -3,6 +3,7 @@ package archive
 import (
 	"archive/zip"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -22,8 +23,15 @@ func (h *ZipHandler) Create(ctx context.Context, basePath string, opts CreateOpt
 	}
 	defer zipFile.Close()
 
-	zipWriter := zip.NewWriter(zipFile)
-	defer zipWriter.Close()
+	if err := h.Write(ctx, basePath, opts, zipFile, writer); err != nil {
+		return err
+	}
+
+	return zipFile.Close()
+}
+
+func (h *ZipHandler) Write(ctx context.Context, basePath string, opts CreateOptions, out io.Writer, writer ProgressWriter) error {
+	zipWriter := zip.NewWriter(out)
 
 	fileCount := 0
 
@@ -43,16 +51,30 @@ func (h *ZipHandler) Create(ctx context.Context, basePath string, opts CreateOpt
 			}
 
 			relPath, _ := filepath.Rel(basePath, path)
-			for _, pattern := range opts.ExcludePatterns {
-				if matched, _ := filepath.Match(pattern, relPath); matched {
-					return nil
+			if isExcluded(relPath, opts.ExcludePatterns) {
+				if info.IsDir() {
+					return filepath.SkipDir
 				}
+				return nil
 			}
 
 			if info.IsDir() {
 				return nil
 			}
 
+			var link string
+			switch {
+			case info.Mode()&os.ModeSymlink != 0:
+				link, err = os.Readlink(path)
+				if err != nil {
+					writer.WriteError(fmt.Sprintf("Error reading link %s: %v", path, err))
+					return nil
+				}
+			case !info.Mode().IsRegular():
+				writer.WriteError(fmt.Sprintf("Skipping special file %s", relPath))
+				return nil
+			}
+
 			relPath, err = filepath.Rel(basePath, path)
 			if err != nil {
 				return err
@@ -62,7 +84,7 @@ func (h *ZipHandler) Create(ctx context.Context, basePath string, opts CreateOpt
 			if err != nil {
 				return err
 			}
-			fileHeader.Name = relPath
+			fileHeader.Name = filepath.ToSlash(relPath)
 			fileHeader.Method = zip.Deflate
 
 			w, err := zipWriter.CreateHeader(fileHeader)
@@ -70,6 +92,11 @@ func (h *ZipHandler) Create(ctx context.Context, basePath string, opts CreateOpt
 				return err
 			}
 
+			if link != "" {
+				_, err = io.WriteString(w, link)
+				return err
+			}
+
 			file, err := os.Open(path)
 			if err != nil {
 				return err
@@ -94,6 +121,10 @@ func (h *ZipHandler) Create(ctx context.Context, basePath string, opts CreateOpt
 		}
 	}
 
+	if err := zipWriter.Close(); err != nil {
+		return fmt.Errorf("failed to finalise zip archive: %w", err)
+	}
+
 	writer.WriteStdout(fmt.Sprintf("Archive created with %d files", fileCount))
 	return nil
 }
@@ -112,6 +143,7 @@ func (h *ZipHandler) Extract(ctx context.Context, opts ExtractOptions, writer Pr
 	}
 
 	fileCount := 0
+	var extracted int64
 	for _, file := range reader.File {
 		select {
 		case <-ctx.Done():
@@ -155,10 +187,14 @@ func (h *ZipHandler) Extract(ctx context.Context, opts ExtractOptions, writer Pr
 			continue
 		}
 
-		_, err = io.Copy(outFile, reader)
+		err = copyWithinLimit(outFile, reader, opts.MaxBytes, &extracted)
 		reader.Close()
 		outFile.Close()
 
+		if errors.Is(err, ErrSizeLimitExceeded) {
+			_ = os.Remove(path)
+			return fmt.Errorf("failed to extract file %s: %w", file.Name, err)
+		}
 		if err != nil {
 			writer.WriteError(fmt.Sprintf("Failed to extract file %s: %v", file.Name, err))
 			continue
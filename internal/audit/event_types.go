@@ -13,6 +13,18 @@ const (
 	EventFileDownload = "file.download"
 	EventFileListDir  = "file.listdir"
 	EventFileDirStats = "file.dirstats"
+	EventFileSearch   = "file.search"
+	EventFileWatch    = "file.watch"
+
+	EventFileChangeset = "file.changeset"
+	EventFileUsage     = "file.usage"
+
+	EventFileTrashList    = "file.trash_list"
+	EventFileTrashRestore = "file.trash_restore"
+	EventFileTrashPurge   = "file.trash_purge"
+
+	EventFileUploadStart = "file.upload_start"
+	EventFileUploadAbort = "file.upload_abort"
 )
 
 const (
@@ -26,6 +38,22 @@ const (
 	EventStackGetImages     = "stack.get_images"
 	EventStackGetCompose    = "stack.get_compose"
 	EventStackUpdateCompose = "stack.update_compose"
+	EventStackGetDrift      = "stack.get_drift"
+	EventStackGetGraph      = "stack.get_graph"
+	EventStackGetHistory    = "stack.get_history"
+	EventStackGetPorts      = "stack.get_ports"
+	EventStackGetDotEnv     = "stack.get_dotenv"
+	EventStackRevealDotEnv  = "stack.reveal_dotenv"
+	EventStackUpdateDotEnv  = "stack.update_dotenv"
+	EventStackListSecrets   = "stack.list_secrets"
+	EventStackGetSecret     = "stack.get_secret"
+	EventStackSetSecret     = "stack.set_secret"
+	EventStackDeleteSecret  = "stack.delete_secret"
+
+	EventStackListRevisions  = "stack.list_revisions"
+	EventStackGetRevision    = "stack.get_revision"
+	EventStackDiffRevisions  = "stack.diff_revisions"
+	EventStackRevertRevision = "stack.revert_revision"
 )
 
 const (
@@ -45,6 +73,11 @@ const (
 	EventContainerLogs     = "container.logs"
 	EventContainerStats    = "container.stats"
 	EventImageCheckUpdates = "image.check_updates"
+
+	EventContainerFileList     = "container.file_list"
+	EventContainerFileDownload = "container.file_download"
+	EventContainerFileUpload   = "container.file_upload"
+	EventContainerFileCopy     = "container.file_copy"
 )
 
 const (
@@ -68,12 +101,20 @@ func GetEventCategory(eventType string) string {
 	switch eventType {
 	case EventFileRead, EventFileWrite, EventFileDelete, EventFileRename,
 		EventFileCopy, EventFileChmod, EventFileChown, EventFileMkdir,
-		EventFileUpload, EventFileDownload, EventFileListDir, EventFileDirStats:
+		EventFileUpload, EventFileDownload, EventFileListDir, EventFileDirStats,
+		EventFileSearch, EventFileTrashList, EventFileTrashRestore, EventFileTrashPurge,
+		EventFileUploadStart, EventFileUploadAbort, EventFileWatch, EventFileChangeset,
+		EventFileUsage:
 		return "file"
 
 	case EventStackList, EventStackCreate, EventStackGetDetails, EventStackGetSummary,
 		EventStackGetEnvVars, EventStackGetNetworks, EventStackGetVolumes,
-		EventStackGetImages, EventStackGetCompose, EventStackUpdateCompose:
+		EventStackGetImages, EventStackGetCompose, EventStackUpdateCompose,
+		EventStackGetDrift, EventStackGetGraph, EventStackGetHistory,
+		EventStackGetPorts, EventStackListRevisions, EventStackGetRevision,
+		EventStackDiffRevisions, EventStackRevertRevision, EventStackGetDotEnv,
+		EventStackRevealDotEnv, EventStackUpdateDotEnv, EventStackListSecrets,
+		EventStackGetSecret, EventStackSetSecret, EventStackDeleteSecret:
 		return "stack"
 
 	case EventOperationStarted, EventOperationCompleted, EventOperationFailed, EventOperationStreamed:
@@ -82,7 +123,9 @@ func GetEventCategory(eventType string) string {
 	case EventMaintenanceGetInfo, EventMaintenancePrune, EventMaintenanceDeleteResource:
 		return "maintenance"
 
-	case EventContainerLogs, EventContainerStats, EventImageCheckUpdates:
+	case EventContainerLogs, EventContainerStats, EventImageCheckUpdates,
+		EventContainerFileList, EventContainerFileDownload, EventContainerFileUpload,
+		EventContainerFileCopy:
 		return "container"
 
 	case EventVulnscanStarted, EventVulnscanCompleted, EventVulnscanRetrieved, EventVulnscanStatus:
@@ -102,24 +145,33 @@ func GetEventCategory(eventType string) string {
 func GetEventSeverity(eventType string) string {
 	switch eventType {
 
-	case EventFileDelete, EventMaintenancePrune, EventMaintenanceDeleteResource:
+	case EventFileDelete, EventMaintenancePrune, EventMaintenanceDeleteResource,
+		EventFileTrashPurge:
 		return "critical"
 
 	case EventFileWrite, EventFileRename, EventFileCopy, EventFileChmod, EventFileChown,
 		EventFileMkdir, EventFileUpload, EventStackCreate, EventStackUpdateCompose,
 		EventStackGetEnvVars, EventOperationStarted, EventOperationCompleted,
-		EventOperationFailed, EventTerminalConnected, EventAuthFailure:
+		EventOperationFailed, EventTerminalConnected, EventAuthFailure,
+		EventStackRevertRevision, EventStackRevealDotEnv, EventStackUpdateDotEnv,
+		EventStackSetSecret, EventStackDeleteSecret, EventFileTrashRestore,
+		EventContainerFileUpload, EventContainerFileCopy, EventFileChangeset:
 		return "high"
 
 	case EventFileRead, EventFileDownload, EventStackGetDetails, EventStackGetCompose,
-		EventVulnscanStarted, EventVulnscanCompleted:
+		EventVulnscanStarted, EventVulnscanCompleted, EventStackGetRevision,
+		EventStackDiffRevisions, EventStackGetDotEnv, EventStackGetSecret, EventFileSearch,
+		EventFileUploadStart, EventFileWatch, EventContainerFileDownload:
 		return "medium"
 
 	case EventStackList, EventStackGetSummary, EventStackGetNetworks, EventStackGetVolumes,
 		EventStackGetImages, EventFileListDir, EventFileDirStats, EventContainerLogs,
 		EventContainerStats, EventImageCheckUpdates, EventVulnscanRetrieved,
 		EventVulnscanStatus, EventMaintenanceGetInfo, EventOperationStreamed,
-		EventTerminalDisconnected, EventAuthSuccess:
+		EventTerminalDisconnected, EventAuthSuccess, EventStackGetDrift,
+		EventStackGetGraph, EventStackGetHistory, EventStackGetPorts,
+		EventStackListRevisions, EventStackListSecrets, EventFileTrashList,
+		EventFileUploadAbort, EventContainerFileList, EventFileUsage:
 		return "low"
 
 	default:
@@ -0,0 +1,425 @@
+package backup
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/mount"
+	"github.com/google/uuid"
+	"github.com/tech-arch1tect/berth-agent/internal/archive"
+	"github.com/tech-arch1tect/berth-agent/internal/docker"
+)
+
+const (
+	bundleFormatVersion  = 1
+	bundleManifestName   = "manifest.json"
+	bundleStackArchive   = "stack.tar"
+	bundleStagingPrefix  = ".berth-bundle-"
+	helperBundlePath     = "/berth-bundle"
+	helperBundleDataPath = "/berth-bundle-data"
+)
+
+type BundleManifest struct {
+	FormatVersion int            `json:"format_version"`
+	StackName     string         `json:"stack_name"`
+	ProjectName   string         `json:"project_name,omitempty"`
+	CreatedAt     time.Time      `json:"created_at"`
+	Stack         BundleArtifact `json:"stack"`
+	Volumes       []BundleVolume `json:"volumes"`
+	Images        []BundleImage  `json:"images"`
+}
+
+type BundleArtifact struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+type BundleVolume struct {
+	BundleArtifact
+	Key        string            `json:"key"`
+	Name       string            `json:"name"`
+	Driver     string            `json:"driver,omitempty"`
+	DriverOpts map[string]string `json:"driver_opts,omitempty"`
+	Labels     map[string]string `json:"labels,omitempty"`
+}
+
+type BundleImage struct {
+	BundleArtifact
+	ID          string   `json:"id"`
+	References  []string `json:"references"`
+	RepoDigests []string `json:"repo_digests,omitempty"`
+	Services    []string `json:"services"`
+}
+
+type ExportOptions struct {
+	OutputPath  string
+	StopStack   bool
+	SkipImages  bool
+	SkipVolumes bool
+}
+
+type BundleProgressWriter interface {
+	ProgressWriter
+	archive.ProgressWriter
+}
+
+func DefaultBundleName(stackName string, compress bool) string {
+	name := fmt.Sprintf("%s-bundle-%s.tar", stackName, time.Now().UTC().Format("20060102-150405"))
+	if compress {
+		name += ".gz"
+	}
+	return name
+}
+
+func (s *Service) ExportStack(ctx context.Context, stackName, stackPath string, opts ExportOptions, writer BundleProgressWriter) error {
+	ctx = context.WithoutCancel(ctx)
+
+	if opts.OutputPath == "" {
+		opts.OutputPath = DefaultBundleName(stackName, false)
+	}
+	outputPath := filepath.Join(stackPath, opts.OutputPath)
+	if err := archive.EnsureWithinStackPath(outputPath, stackPath); err != nil {
+		return err
+	}
+	if _, err := os.Stat(outputPath); err == nil {
+		return fmt.Errorf("export target %s already exists; choose another --output or remove it first", opts.OutputPath)
+	}
+	format := "tar"
+	if strings.HasSuffix(strings.ToLower(outputPath), ".tar.gz") {
+		format = "tar.gz"
+	}
+
+	writer.WriteProgress("Reading the stack's compose configuration...")
+	project, err := s.bundleComposeProject(stackName)
+	if err != nil {
+		return err
+	}
+
+	stagingName := bundleStagingPrefix + uuid.New().String()
+	stagingPath := filepath.Join(stackPath, stagingName)
+	if err := os.Mkdir(stagingPath, 0700); err != nil {
+		return fmt.Errorf("failed to create the export staging directory: %w", err)
+	}
+	defer os.RemoveAll(stagingPath)
+
+	manifest := &BundleManifest{
+		FormatVersion: bundleFormatVersion,
+		StackName:     stackName,
+		ProjectName:   project.Name,
+		CreatedAt:     time.Now().UTC(),
+		Volumes:       []BundleVolume{},
+		Images:        []BundleImage{},
+	}
+
+	if opts.StopStack {
+		writer.WriteProgress("Running docker compose stop before export...")
+		if err := s.runComposeLifecycle(ctx, stackPath, "stop", writer); err != nil {
+			return fmt.Errorf("failed to stop the stack before export: %w", err)
+		}
+		defer func() {
+			writer.WriteProgress("Running docker compose start after export...")
+			if err := s.runComposeLifecycle(ctx, stackPath, "start", writer); err != nil {
+				writer.WriteStderr(fmt.Sprintf("Failed to start the stack after export: %v", err))
+			}
+		}()
+	}
+
+	writer.WriteProgress("Archiving the stack directory...")
+	err = s.archives.CreateArchive(ctx, stackPath, archive.CreateOptions{
+		Format:          "tar",
+		OutputPath:      filepath.Join(stagingName, bundleStackArchive),
+		ExcludePatterns: bundleExcludePatterns(opts.OutputPath),
+	}, writer)
+	if err != nil {
+		return fmt.Errorf("failed to archive the stack directory: %w", err)
+	}
+	if manifest.Stack, err = describeBundleArtifact(stagingPath, bundleStackArchive); err != nil {
+		return err
+	}
+
+	if opts.SkipVolumes {
+		writer.WriteStdout("Skipping volume data (--skip-volumes)")
+	} else if err := s.exportBundleVolumes(ctx, stackName, stagingPath, project, manifest, writer); err != nil {
+		return err
+	}
+
+	if opts.SkipImages {
+		writer.WriteStdout("Skipping images (--skip-images)")
+	} else if err := s.exportBundleImages(ctx, stagingPath, project, manifest, writer); err != nil {
+		return err
+	}
+
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode the bundle manifest: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(stagingPath, bundleManifestName), manifestData, 0600); err != nil {
+		return fmt.Errorf("failed to write the bundle manifest: %w", err)
+	}
+
+	includes := []string{bundleManifestName, manifest.Stack.Path}
+	for _, volume := range manifest.Volumes {
+		includes = append(includes, volume.Path)
+	}
+	for _, image := range manifest.Images {
+		includes = append(includes, image.Path)
+	}
+
+	writer.WriteProgress("Writing the bundle...")
+	bundleName := "bundle." + format
+	err = s.archives.CreateArchive(ctx, stagingPath, archive.CreateOptions{
+		Format:       format,
+		OutputPath:   bundleName,
+		IncludePaths: includes,
+	}, writer)
+	if err != nil {
+		return fmt.Errorf("failed to write the bundle: %w", err)
+	}
+	if err := os.Chmod(filepath.Join(stagingPath, bundleName), 0600); err != nil {
+		return fmt.Errorf("failed to restrict bundle permissions: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+		return fmt.Errorf("failed to create the bundle's directory: %w", err)
+	}
+	if err := os.Rename(filepath.Join(stagingPath, bundleName), outputPath); err != nil {
+		return fmt.Errorf("failed to move the bundle into place: %w", err)
+	}
+
+	info, err := os.Stat(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to read the finished bundle: %w", err)
+	}
+	writer.WriteProgress(fmt.Sprintf("Export of stack %s completed: %s (%s, %d volume(s), %d image(s))",
+		stackName, opts.OutputPath, formatBytes(uint64(info.Size())), len(manifest.Volumes), len(manifest.Images)))
+	return nil
+}
+
+func bundleExcludePatterns(outputPath string) []string {
+	return []string{
+		".berth",
+		bundleStagingPrefix + "*",
+		"*-bundle-*.tar",
+		"*-bundle-*.tar.gz",
+		filepath.Clean(outputPath),
+	}
+}
+
+func (s *Service) bundleComposeProject(stackName string) (*composeProject, error) {
+	cmd, err := s.commandExec.ExecuteComposeCommand(stackName, "config", "--format", "json")
+	if err != nil {
+		return nil, err
+	}
+	output, err := cmd.Output()
+	if err != nil {
+		detail := ""
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			detail = ": " + strings.TrimSpace(string(exitErr.Stderr))
+		}
+		return nil, fmt.Errorf("failed to read the stack's compose configuration: %w%s", err, detail)
+	}
+	return parseComposeProject(output)
+}
+
+func (s *Service) exportBundleVolumes(ctx context.Context, stackName, stagingPath string, project *composeProject, manifest *BundleManifest, writer BundleProgressWriter) error {
+	keys := make([]string, 0, len(project.Volumes))
+	for key := range project.Volumes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var volumes []BundleVolume
+	for _, key := range keys {
+		config := project.Volumes[key]
+		if config.External {
+			writer.WriteStdout(fmt.Sprintf("Skipping external volume %s: it is not owned by this stack", key))
+			continue
+		}
+		if config.Name == "" {
+			return fmt.Errorf("volume %q has no resolved name in the compose configuration", key)
+		}
+		if config.Name != project.Name+"_"+key {
+			writer.WriteStdout(fmt.Sprintf("Skipping volume %s: its custom name cannot be mapped onto another stack", config.Name))
+			continue
+		}
+
+		vol, err := s.dockerClient.InspectVolume(ctx, config.Name)
+		if err != nil {
+			writer.WriteStdout(fmt.Sprintf("Skipping volume %s: it has not been created yet", config.Name))
+			continue
+		}
+
+		volume := BundleVolume{
+			Key:        key,
+			Name:       config.Name,
+			Driver:     vol.Driver,
+			DriverOpts: vol.Options,
+			Labels:     vol.Labels,
+		}
+		volume.Path = "volume-" + config.Name + ".tar"
+		volumes = append(volumes, volume)
+	}
+
+	if len(volumes) == 0 {
+		return nil
+	}
+
+	image, err := s.helperImage(ctx)
+	if err != nil {
+		return err
+	}
+	runID := filepath.Base(stagingPath)
+
+	for _, volume := range volumes {
+		writer.WriteProgress("Copying the contents of volume " + volume.Name + "...")
+		spec := docker.ContainerRunSpec{
+			Image:      image,
+			Entrypoint: []string{"tar"},
+			Cmd:        []string{"-C", helperBundleDataPath, "-cf", helperBundlePath + "/" + volume.Path, "."},
+			Env:        []string{},
+			Mounts: []mount.Mount{
+				{Type: mount.TypeVolume, Source: volume.Name, Target: helperBundleDataPath, ReadOnly: true},
+				{Type: mount.TypeBind, Source: stagingPath, Target: helperBundlePath},
+			},
+			Labels: s.helperLabels(stackName, runID),
+		}
+		if err := s.runBundleHelper(ctx, spec, "copy volume "+volume.Name); err != nil {
+			return err
+		}
+
+		artifact, err := describeBundleArtifact(stagingPath, volume.Path)
+		if err != nil {
+			return err
+		}
+		volume.BundleArtifact = artifact
+		manifest.Volumes = append(manifest.Volumes, volume)
+		writer.WriteStdout(fmt.Sprintf("Volume %s: %s", volume.Name, formatBytes(uint64(artifact.Size))))
+	}
+	return nil
+}
+
+func (s *Service) exportBundleImages(ctx context.Context, stagingPath string, project *composeProject, manifest *BundleManifest, writer BundleProgressWriter) error {
+	serviceNames := make([]string, 0, len(project.Services))
+	for name := range project.Services {
+		serviceNames = append(serviceNames, name)
+	}
+	sort.Strings(serviceNames)
+
+	byID := map[string]*BundleImage{}
+	var order []string
+	for _, serviceName := range serviceNames {
+		ref := serviceImageReference(project, serviceName)
+		if ref == "" {
+			return fmt.Errorf("service %q has no image to export", serviceName)
+		}
+
+		inspect, err := s.dockerClient.ImageInspect(ctx, ref)
+		if err != nil {
+			return fmt.Errorf("image %s of service %q is not present on this host; pull or build it before exporting: %w", ref, serviceName, err)
+		}
+
+		image, ok := byID[inspect.ID]
+		if !ok {
+			image = &BundleImage{ID: inspect.ID, RepoDigests: inspect.RepoDigests}
+			byID[inspect.ID] = image
+			order = append(order, inspect.ID)
+		}
+		image.References = appendUnique(image.References, ref)
+		image.Services = appendUnique(image.Services, serviceName)
+	}
+
+	for i, id := range order {
+		image := byID[id]
+		image.Path = fmt.Sprintf("image-%d.tar", i+1)
+		writer.WriteProgress("Saving image " + strings.Join(image.References, ", ") + "...")
+
+		if err := s.saveBundleImage(ctx, filepath.Join(stagingPath, image.Path), image.References); err != nil {
+			return err
+		}
+
+		artifact, err := describeBundleArtifact(stagingPath, image.Path)
+		if err != nil {
+			return err
+		}
+		image.BundleArtifact = artifact
+		manifest.Images = append(manifest.Images, *image)
+		writer.WriteStdout(fmt.Sprintf("Image %s: %s", strings.Join(image.References, ", "), formatBytes(uint64(artifact.Size))))
+	}
+	return nil
+}
+
+func (s *Service) saveBundleImage(ctx context.Context, path string, refs []string) error {
+	reader, err := s.dockerClient.ImageSave(ctx, refs)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create image archive: %w", err)
+	}
+	if _, err := io.Copy(file, reader); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to save images %s: %w", strings.Join(refs, ", "), err)
+	}
+	return file.Close()
+}
+
+func (s *Service) runBundleHelper(ctx context.Context, spec docker.ContainerRunSpec, action string) error {
+	var output strings.Builder
+	exitCode, err := s.dockerClient.RunContainer(ctx, spec, &output, &output)
+	if err != nil {
+		return fmt.Errorf("failed to %s in a helper container: %w", action, err)
+	}
+	if exitCode != 0 {
+		return fmt.Errorf("failed to %s: helper exited with code %d: %s", action, exitCode, strings.TrimSpace(output.String()))
+	}
+	return nil
+}
+
+func serviceImageReference(project *composeProject, serviceName string) string {
+	service := project.Services[serviceName]
+	if service.Image != "" {
+		return service.Image
+	}
+	if service.Build != nil && project.Name != "" {
+		return project.Name + "-" + serviceName
+	}
+	return ""
+}
+
+func describeBundleArtifact(dir, name string) (BundleArtifact, error) {
+	file, err := os.Open(filepath.Join(dir, name))
+	if err != nil {
+		return BundleArtifact{}, fmt.Errorf("failed to open %s: %w", name, err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return BundleArtifact{}, fmt.Errorf("failed to checksum %s: %w", name, err)
+	}
+
+	return BundleArtifact{Path: name, Size: size, SHA256: hex.EncodeToString(hash.Sum(nil))}, nil
+}
+
+func appendUnique(values []string, value string) []string {
+	for _, existing := range values {
+		if existing == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
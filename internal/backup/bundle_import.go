@@ -0,0 +1,352 @@
+package backup
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/compose-spec/compose-go/v2/loader"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/google/uuid"
+	"github.com/tech-arch1tect/berth-agent/internal/archive"
+	"github.com/tech-arch1tect/berth-agent/internal/docker"
+)
+
+type ImportOptions struct {
+	BundlePath  string
+	Overwrite   bool
+	SkipImages  bool
+	SkipVolumes bool
+}
+
+const importVolumeScript = `set -e
+if [ "$2" = "overwrite" ]; then
+  find "` + helperBundleDataPath + `" -mindepth 1 -maxdepth 1 -exec rm -rf {} +
+fi
+tar -C "` + helperBundleDataPath + `" -xpf "` + helperBundlePath + `/$1"`
+
+var bundleVolumeKeyPattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+func (s *Service) ImportStack(ctx context.Context, stackName, stackPath string, opts ImportOptions, writer BundleProgressWriter) error {
+	ctx = context.WithoutCancel(ctx)
+
+	if opts.BundlePath == "" {
+		return fmt.Errorf("a bundle is required")
+	}
+	bundlePath := filepath.Join(stackPath, opts.BundlePath)
+	if err := archive.EnsureWithinStackPath(bundlePath, stackPath); err != nil {
+		return err
+	}
+	if _, err := os.Stat(bundlePath); err != nil {
+		return fmt.Errorf("bundle %s not found", opts.BundlePath)
+	}
+
+	stagingName := bundleStagingPrefix + uuid.New().String()
+	stagingPath := filepath.Join(stackPath, stagingName)
+	if err := os.Mkdir(stagingPath, 0700); err != nil {
+		return fmt.Errorf("failed to create the import staging directory: %w", err)
+	}
+	if s.quota != nil {
+		defer s.quota.InvalidateUsage(stackName)
+	}
+	defer os.RemoveAll(stagingPath)
+
+	writer.WriteProgress("Unpacking bundle " + opts.BundlePath + "...")
+	err := s.extractWithinQuota(ctx, stackName, stackPath, archive.ExtractOptions{
+		ArchivePath:     opts.BundlePath,
+		DestinationPath: stagingName,
+		Overwrite:       true,
+	}, writer)
+	if err != nil {
+		return fmt.Errorf("failed to unpack the bundle: %w", err)
+	}
+
+	manifest, err := readBundleManifest(stagingPath)
+	if err != nil {
+		return err
+	}
+	writer.WriteStdout(fmt.Sprintf("Bundle of stack %s created %s: %d volume(s), %d image(s)",
+		manifest.StackName, manifest.CreatedAt.Format("2006-01-02 15:04:05 MST"), len(manifest.Volumes), len(manifest.Images)))
+
+	writer.WriteProgress("Checking bundle artifacts for corruption...")
+	if err := verifyBundleArtifacts(stagingPath, manifest, writer); err != nil {
+		return err
+	}
+
+	projectName := s.importProjectName(stackName)
+	volumes, err := s.importVolumeTargets(ctx, manifest, projectName, opts)
+	if err != nil {
+		return err
+	}
+	if err := s.checkImportTargets(ctx, stackName, stackPath, projectName, opts); err != nil {
+		return err
+	}
+
+	writer.WriteProgress("Restoring the stack directory...")
+	err = s.extractWithinQuota(ctx, stackName, stackPath, archive.ExtractOptions{
+		ArchivePath:     filepath.Join(stagingName, manifest.Stack.Path),
+		DestinationPath: ".",
+		Overwrite:       opts.Overwrite,
+	}, writer)
+	if err != nil {
+		return fmt.Errorf("failed to restore the stack directory: %w", err)
+	}
+
+	if name, ok := s.composeProjectName(stackName); ok && name != projectName {
+		writer.WriteStdout(fmt.Sprintf("The restored compose configuration names project %s; mapping volumes onto it", name))
+		if volumes, err = s.importVolumeTargets(ctx, manifest, name, opts); err != nil {
+			return err
+		}
+	}
+
+	if opts.SkipVolumes {
+		writer.WriteStdout("Skipping volume data (--skip-volumes)")
+	} else if err := s.importBundleVolumes(ctx, stackName, stagingPath, volumes, opts.Overwrite, writer); err != nil {
+		return err
+	}
+
+	if opts.SkipImages {
+		writer.WriteStdout("Skipping images (--skip-images)")
+	} else if err := s.importBundleImages(ctx, stagingPath, manifest, writer); err != nil {
+		return err
+	}
+
+	writer.WriteProgress(fmt.Sprintf("Import of stack %s completed. Run up to start it.", stackName))
+	return nil
+}
+
+func (s *Service) extractWithinQuota(ctx context.Context, stackName, stackPath string, opts archive.ExtractOptions, writer BundleProgressWriter) error {
+	if s.quota != nil {
+		remaining, limited, err := s.quota.QuotaRemaining(stackName)
+		if err != nil {
+			return err
+		}
+		if limited {
+			if remaining <= 0 {
+				return fmt.Errorf("stack disk quota exceeded")
+			}
+			opts.MaxBytes = remaining
+		}
+		defer s.quota.InvalidateUsage(stackName)
+	}
+
+	err := s.archives.ExtractArchive(ctx, stackPath, opts, writer)
+	if errors.Is(err, archive.ErrSizeLimitExceeded) {
+		return fmt.Errorf("stack disk quota exceeded: %w", err)
+	}
+	return err
+}
+
+func readBundleManifest(stagingPath string) (*BundleManifest, error) {
+	data, err := os.ReadFile(filepath.Join(stagingPath, bundleManifestName))
+	if err != nil {
+		return nil, fmt.Errorf("the bundle has no readable %s; it was not produced by export-stack: %w", bundleManifestName, err)
+	}
+
+	var manifest BundleManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("the bundle manifest is corrupt: %w", err)
+	}
+	if manifest.FormatVersion != bundleFormatVersion {
+		return nil, fmt.Errorf("unsupported bundle format version %d; this agent reads version %d", manifest.FormatVersion, bundleFormatVersion)
+	}
+	return &manifest, nil
+}
+
+func verifyBundleArtifacts(stagingPath string, manifest *BundleManifest, writer BundleProgressWriter) error {
+	artifacts := []BundleArtifact{manifest.Stack}
+	for _, volume := range manifest.Volumes {
+		artifacts = append(artifacts, volume.BundleArtifact)
+	}
+	for _, image := range manifest.Images {
+		artifacts = append(artifacts, image.BundleArtifact)
+	}
+
+	for _, expected := range artifacts {
+		if expected.Path == "" || filepath.Base(expected.Path) != expected.Path || expected.Path == bundleManifestName {
+			return fmt.Errorf("the bundle manifest lists an invalid entry %q", expected.Path)
+		}
+		actual, err := describeBundleArtifact(stagingPath, expected.Path)
+		if err != nil {
+			return fmt.Errorf("the bundle is missing %s: %w", expected.Path, err)
+		}
+		if actual.Size != expected.Size || actual.SHA256 != expected.SHA256 {
+			return fmt.Errorf("checksum mismatch for %s: expected sha256 %s (%d bytes), found %s (%d bytes); the bundle is damaged", expected.Path, expected.SHA256, expected.Size, actual.SHA256, actual.Size)
+		}
+		writer.WriteStdout("Verified " + expected.Path)
+	}
+	return nil
+}
+
+func (s *Service) importProjectName(stackName string) string {
+	if name, ok := s.composeProjectName(stackName); ok {
+		return name
+	}
+	return loader.NormalizeProjectName(stackName)
+}
+
+func (s *Service) checkImportTargets(ctx context.Context, stackName, stackPath, projectName string, opts ImportOptions) error {
+	containers, err := s.listStackContainers(ctx, stackIdentity{ProjectName: projectName, StackPath: filepath.Clean(stackPath)})
+	if err != nil {
+		return err
+	}
+	if active := len(activeContainers(containers)); active > 0 {
+		return fmt.Errorf("refusing to import while %d container(s) of stack %s are running, paused or restarting; stop the stack first", active, stackName)
+	}
+
+	if opts.Overwrite {
+		return nil
+	}
+
+	for _, filename := range []string{"docker-compose.yml", "docker-compose.yaml", "compose.yml", "compose.yaml"} {
+		if _, err := os.Stat(filepath.Join(stackPath, filename)); err == nil {
+			return fmt.Errorf("stack %s already has %s; import into an empty stack or request the import with --overwrite", stackName, filename)
+		}
+	}
+	return nil
+}
+
+func (s *Service) importVolumeTargets(ctx context.Context, manifest *BundleManifest, projectName string, opts ImportOptions) ([]BundleVolume, error) {
+	if opts.SkipVolumes {
+		return nil, nil
+	}
+	if projectName == "" {
+		return nil, fmt.Errorf("cannot determine the compose project of the target stack to map its volumes")
+	}
+
+	volumes := make([]BundleVolume, 0, len(manifest.Volumes))
+	for _, source := range manifest.Volumes {
+		if !bundleVolumeKeyPattern.MatchString(source.Key) {
+			return nil, fmt.Errorf("the bundle manifest lists volume %q with an invalid compose key %q", source.Name, source.Key)
+		}
+		if source.Name != manifest.ProjectName+"_"+source.Key {
+			return nil, fmt.Errorf("the bundle manifest lists volume %q, which does not belong to project %s", source.Name, manifest.ProjectName)
+		}
+		if source.Driver == "" || source.Driver == "local" {
+			if strings.Contains(source.DriverOpts["o"], "bind") {
+				return nil, fmt.Errorf("the bundle manifest lists volume %q as a bind of a host path; refusing to recreate it", source.Name)
+			}
+		}
+
+		volume := source
+		volume.Name = projectName + "_" + source.Key
+		volume.Labels = make(map[string]string, len(source.Labels)+2)
+		for key, value := range source.Labels {
+			volume.Labels[key] = value
+		}
+		volume.Labels[docker.LabelComposeProject] = projectName
+		volume.Labels[docker.LabelComposeVolume] = source.Key
+
+		existing, err := s.dockerClient.InspectVolume(ctx, volume.Name)
+		if err == nil {
+			if owner := existing.Labels[docker.LabelComposeProject]; owner != projectName {
+				return nil, fmt.Errorf("volume %s already exists and is not owned by project %s; refusing to import into it", volume.Name, projectName)
+			}
+			if !opts.Overwrite {
+				return nil, fmt.Errorf("volume %s already exists on this host; remove it or request the import with --overwrite to replace its contents", volume.Name)
+			}
+		}
+		volumes = append(volumes, volume)
+	}
+	return volumes, nil
+}
+
+func (s *Service) importBundleVolumes(ctx context.Context, stackName, stagingPath string, volumes []BundleVolume, overwrite bool, writer BundleProgressWriter) error {
+	if len(volumes) == 0 {
+		return nil
+	}
+
+	image, err := s.helperImage(ctx)
+	if err != nil {
+		return err
+	}
+	runID := filepath.Base(stagingPath)
+
+	mode := "merge"
+	if overwrite {
+		mode = "overwrite"
+	}
+
+	for _, volume := range volumes {
+		if _, err := s.dockerClient.InspectVolume(ctx, volume.Name); err != nil {
+			writer.WriteProgress("Creating volume " + volume.Name + "...")
+			if _, err := s.dockerClient.CreateVolume(ctx, volume.Name, volume.Driver, volume.DriverOpts, volume.Labels); err != nil {
+				return fmt.Errorf("failed to create volume %s: %w", volume.Name, err)
+			}
+			writer.WriteStdout("Created volume " + volume.Name)
+		}
+
+		writer.WriteProgress("Restoring the contents of volume " + volume.Name + "...")
+		spec := docker.ContainerRunSpec{
+			Image:      image,
+			Entrypoint: []string{"/bin/sh", "-c", importVolumeScript, "sh", volume.Path, mode},
+			Env:        []string{},
+			Mounts: []mount.Mount{
+				{Type: mount.TypeVolume, Source: volume.Name, Target: helperBundleDataPath},
+				{Type: mount.TypeBind, Source: stagingPath, Target: helperBundlePath, ReadOnly: true},
+			},
+			Labels: s.helperLabels(stackName, runID),
+		}
+		if err := s.runBundleHelper(ctx, spec, "restore volume "+volume.Name); err != nil {
+			return err
+		}
+		writer.WriteStdout("Restored volume " + volume.Name)
+	}
+	return nil
+}
+
+func (s *Service) importBundleImages(ctx context.Context, stagingPath string, manifest *BundleManifest, writer BundleProgressWriter) error {
+	for _, image := range manifest.Images {
+		writer.WriteProgress("Loading image " + strings.Join(image.References, ", ") + "...")
+		if err := s.loadBundleImage(ctx, filepath.Join(stagingPath, image.Path), writer); err != nil {
+			return err
+		}
+
+		loaded, err := s.dockerClient.ImageInspect(ctx, image.ID)
+		if err != nil {
+			return fmt.Errorf("image %s was not present after loading %s: %w", image.ID, image.Path, err)
+		}
+		if loaded.ID != image.ID {
+			return fmt.Errorf("image %s loaded with id %s, expected %s", strings.Join(image.References, ", "), loaded.ID, image.ID)
+		}
+	}
+	return nil
+}
+
+func (s *Service) loadBundleImage(ctx context.Context, path string, writer BundleProgressWriter) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open image archive: %w", err)
+	}
+	defer file.Close()
+
+	body, err := s.dockerClient.ImageLoad(ctx, file)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		var message struct {
+			Stream string `json:"stream"`
+			Error  string `json:"error"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &message); err != nil {
+			continue
+		}
+		if message.Error != "" {
+			return fmt.Errorf("failed to load %s: %s", filepath.Base(path), message.Error)
+		}
+		if line := strings.TrimSpace(message.Stream); line != "" {
+			writer.WriteStdout(line)
+		}
+	}
+	return scanner.Err()
+}
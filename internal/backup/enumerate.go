@@ -17,6 +17,8 @@ type composeVolumeEntry struct {
 }
 
 type composeServiceConfig struct {
+	Image   string               `json:"image"`
+	Build   any                  `json:"build"`
 	Volumes []composeVolumeEntry `json:"volumes"`
 }
 
@@ -1,6 +1,7 @@
 package backup
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
@@ -165,11 +166,51 @@ func (h *Handler) DownloadBackupFiles(c echo.Context) error {
 	}
 
 	if len(paths) == 1 && entries[0].Type == "file" {
+		entry := entries[0]
+		size := int64(entry.Size)
+		etag := backupFileETag(backupID, componentID, paths[0], entry)
+
 		response.Header().Set(echo.HeaderContentType, "application/octet-stream")
-		response.Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", entries[0].Name))
-		response.Header().Set(echo.HeaderContentLength, strconv.FormatUint(entries[0].Size, 10))
-		response.WriteHeader(http.StatusOK)
-		return h.service.DumpBackupFile(ctx, stackName, backupID, componentID, paths[0], password, response.Writer)
+		response.Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", entry.Name))
+		response.Header().Set("Accept-Ranges", "bytes")
+		response.Header().Set("ETag", etag)
+		if !entry.MTime.IsZero() {
+			response.Header().Set(echo.HeaderLastModified, entry.MTime.UTC().Format(http.TimeFormat))
+		}
+
+		request := c.Request()
+		if notModified(request, etag, entry.MTime) {
+			response.Header().Del(echo.HeaderContentType)
+			response.Header().Del(echo.HeaderContentDisposition)
+			response.WriteHeader(http.StatusNotModified)
+			return nil
+		}
+
+		start, length, status := int64(0), size, http.StatusOK
+		if rangeHeader := request.Header.Get("Range"); rangeHeader != "" && rangeApplies(request, etag, entry.MTime) {
+			rangeStart, rangeLength, err := parseByteRange(rangeHeader, size)
+			switch {
+			case errors.Is(err, errRangeNotSatisfiable):
+				response.Header().Del(echo.HeaderContentType)
+				response.Header().Del(echo.HeaderContentDisposition)
+				response.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+				return common.SendError(c, http.StatusRequestedRangeNotSatisfiable, "Requested range not satisfiable")
+			case err == nil:
+				start, length, status = rangeStart, rangeLength, http.StatusPartialContent
+				response.Header().Set("Content-Range", contentRange(start, length, size))
+			}
+		}
+
+		response.Header().Set(echo.HeaderContentLength, strconv.FormatInt(length, 10))
+		response.WriteHeader(status)
+
+		dumpCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		writer := newRangeWriter(response.Writer, start, length, cancel)
+		if err := h.service.DumpBackupFile(dumpCtx, stackName, backupID, componentID, paths[0], password, writer); err != nil && !(writer.complete() && dumpCtx.Err() != nil) {
+			return err
+		}
+		return nil
 	}
 
 	response.Header().Set(echo.HeaderContentType, "application/gzip")
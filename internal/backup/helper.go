@@ -5,7 +5,6 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"os"
 	"path/filepath"
 	"strings"
 
@@ -16,20 +15,7 @@ import (
 const helperHostProbeRoot = "/berth-backup/host"
 
 func (s *Service) helperImage(ctx context.Context) (string, error) {
-	if s.cfg.BackupHelperImage != "" {
-		return s.cfg.BackupHelperImage, nil
-	}
-
-	hostname, err := os.Hostname()
-	if err != nil {
-		return "", fmt.Errorf("could not read the agent's hostname to discover its container image: %w; set BACKUP_HELPER_IMAGE explicitly", err)
-	}
-
-	info, err := s.dockerClient.ContainerInspect(ctx, hostname)
-	if err != nil {
-		return "", fmt.Errorf("could not inspect the agent's own container %q to discover its image: %w; set BACKUP_HELPER_IMAGE explicitly", hostname, err)
-	}
-	return info.Image, nil
+	return s.dockerClient.HelperImage(ctx, s.cfg.BackupHelperImage, "BACKUP_HELPER_IMAGE")
 }
 
 func (s *Service) repoHostPath(stackName string) string {
@@ -5,6 +5,7 @@ import (
 
 	"github.com/tech-arch1tect/berth-agent/config"
 	"github.com/tech-arch1tect/berth-agent/internal/docker"
+	"github.com/tech-arch1tect/berth-agent/internal/files"
 	"github.com/tech-arch1tect/berth-agent/internal/logging"
 	"github.com/tech-arch1tect/berth-agent/internal/stack"
 
@@ -17,8 +18,8 @@ var Module = fx.Options(
 	fx.Invoke(RunStartupHygiene),
 )
 
-func NewServiceWithConfig(cfg *config.Config, logger *logging.Logger, dockerClient *docker.Client, stacks *stack.Service) (*Service, error) {
-	return NewService(cfg, logger, dockerClient, docker.NewCommandExecutor(cfg.StackLocation), stacks)
+func NewServiceWithConfig(cfg *config.Config, logger *logging.Logger, dockerClient *docker.Client, stacks *stack.Service, filesService *files.Service) (*Service, error) {
+	return NewService(cfg, logger, dockerClient, docker.NewCommandExecutor(cfg.StackLocation), stacks, filesService)
 }
 
 func RunStartupHygiene(lc fx.Lifecycle, service *Service) {
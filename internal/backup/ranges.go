@@ -0,0 +1,157 @@
+package backup
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	errRangeUnsupported    = errors.New("unsupported range")
+	errRangeNotSatisfiable = errors.New("range not satisfiable")
+)
+
+func backupFileETag(backupID, componentID, relPath string, entry FileEntry) string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{
+		backupID,
+		componentID,
+		relPath,
+		strconv.FormatUint(entry.Size, 10),
+		strconv.FormatInt(entry.MTime.UnixNano(), 10),
+	}, "\x00")))
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+func notModified(req *http.Request, etag string, modTime time.Time) bool {
+	if ifNoneMatch := req.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		return etagListMatches(ifNoneMatch, etag, true)
+	}
+	if ifModifiedSince := req.Header.Get("If-Modified-Since"); ifModifiedSince != "" && !modTime.IsZero() {
+		since, err := http.ParseTime(ifModifiedSince)
+		return err == nil && !modTime.Truncate(time.Second).After(since)
+	}
+	return false
+}
+
+func rangeApplies(req *http.Request, etag string, modTime time.Time) bool {
+	ifRange := req.Header.Get("If-Range")
+	if ifRange == "" {
+		return true
+	}
+	if strings.HasPrefix(ifRange, `"`) || strings.HasPrefix(ifRange, "W/") {
+		return etagListMatches(ifRange, etag, false)
+	}
+	since, err := http.ParseTime(ifRange)
+	return err == nil && !modTime.IsZero() && modTime.Truncate(time.Second).Equal(since)
+}
+
+func etagListMatches(header, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" && weak {
+			return true
+		}
+		if weak {
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+func parseByteRange(header string, size int64) (int64, int64, error) {
+	spec, found := strings.CutPrefix(header, "bytes=")
+	if !found || strings.Contains(spec, ",") {
+		return 0, 0, errRangeUnsupported
+	}
+	first, last, found := strings.Cut(strings.TrimSpace(spec), "-")
+	if !found {
+		return 0, 0, errRangeUnsupported
+	}
+
+	if first == "" {
+		suffix, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || suffix < 0 {
+			return 0, 0, errRangeUnsupported
+		}
+		if suffix == 0 || size == 0 {
+			return 0, 0, errRangeNotSatisfiable
+		}
+		suffix = min(suffix, size)
+		return size - suffix, suffix, nil
+	}
+
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 {
+		return 0, 0, errRangeUnsupported
+	}
+	if start >= size {
+		return 0, 0, errRangeNotSatisfiable
+	}
+
+	end := size - 1
+	if last != "" {
+		end, err = strconv.ParseInt(last, 10, 64)
+		if err != nil || end < start {
+			return 0, 0, errRangeUnsupported
+		}
+		end = min(end, size-1)
+	}
+
+	return start, end - start + 1, nil
+}
+
+func contentRange(start, length, size int64) string {
+	return fmt.Sprintf("bytes %d-%d/%d", start, start+length-1, size)
+}
+
+type rangeWriter struct {
+	writer    io.Writer
+	skip      int64
+	remaining int64
+	done      func()
+}
+
+func newRangeWriter(writer io.Writer, start, length int64, done func()) *rangeWriter {
+	return &rangeWriter{writer: writer, skip: start, remaining: length, done: done}
+}
+
+func (w *rangeWriter) complete() bool {
+	return w.remaining <= 0
+}
+
+func (w *rangeWriter) Write(payload []byte) (int, error) {
+	total := len(payload)
+
+	if w.skip > 0 {
+		skipped := min(w.skip, int64(len(payload)))
+		w.skip -= skipped
+		payload = payload[skipped:]
+	}
+	if w.remaining <= 0 || len(payload) == 0 {
+		return total, nil
+	}
+
+	if int64(len(payload)) > w.remaining {
+		payload = payload[:w.remaining]
+	}
+	consumed := total - len(payload)
+	written, err := w.writer.Write(payload)
+	w.remaining -= int64(written)
+	if err != nil {
+		return consumed + written, err
+	}
+	if w.remaining <= 0 && w.done != nil {
+		w.done()
+	}
+
+	return total, nil
+}
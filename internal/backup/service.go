@@ -12,6 +12,7 @@ import (
 	"github.com/docker/docker/api/types/mount"
 	"github.com/google/uuid"
 	"github.com/tech-arch1tect/berth-agent/config"
+	"github.com/tech-arch1tect/berth-agent/internal/archive"
 	"github.com/tech-arch1tect/berth-agent/internal/docker"
 	"github.com/tech-arch1tect/berth-agent/internal/logging"
 	"github.com/tech-arch1tect/berth-agent/internal/stack"
@@ -22,6 +23,11 @@ type stackLister interface {
 	ListStacks() ([]stack.Stack, error)
 }
 
+type quotaTracker interface {
+	QuotaRemaining(stackName string) (int64, bool, error)
+	InvalidateUsage(stackName string)
+}
+
 type Service struct {
 	cfg          *config.Config
 	logger       *logging.Logger
@@ -30,9 +36,11 @@ type Service struct {
 	stacks       stackLister
 	persistence  *RunPersistence
 	repoLocks    *repoLockTable
+	archives     *archive.Service
+	quota        quotaTracker
 }
 
-func NewService(cfg *config.Config, logger *logging.Logger, dockerClient *docker.Client, commandExec *docker.CommandExecutor, stacks stackLister) (*Service, error) {
+func NewService(cfg *config.Config, logger *logging.Logger, dockerClient *docker.Client, commandExec *docker.CommandExecutor, stacks stackLister, quota quotaTracker) (*Service, error) {
 	persistence, err := NewRunPersistence(cfg.BackupPersistenceDir, logger)
 	if err != nil {
 		return nil, err
@@ -45,6 +53,8 @@ func NewService(cfg *config.Config, logger *logging.Logger, dockerClient *docker
 		stacks:       stacks,
 		persistence:  persistence,
 		repoLocks:    newRepoLockTable(),
+		archives:     archive.NewService(),
+		quota:        quota,
 	}, nil
 }
 
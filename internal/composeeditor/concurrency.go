@@ -0,0 +1,393 @@
+package composeeditor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/tech-arch1tect/berth-agent/internal/revisions"
+
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+type ConflictError struct {
+	Message     string
+	CurrentETag string
+	Conflicts   []ComposeConflict
+}
+
+func (e *ConflictError) Error() string {
+	return e.Message
+}
+
+func composeETag(content []byte) string {
+	sum := sha256.Sum256(content)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+func parseIfMatch(header string) []string {
+	var etags []string
+	for _, part := range strings.Split(header, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if part == "*" {
+			etags = append(etags, part)
+			continue
+		}
+		part = strings.TrimPrefix(part, "W/")
+		etags = append(etags, `"`+strings.Trim(part, `"`)+`"`)
+	}
+	return etags
+}
+
+func (s *Service) rememberComposeVersion(stackName, composeFile string) {
+	if err := s.revisions.Capture(stackName, composeFile); err != nil {
+		s.logger.Warn("failed to record the loaded compose file as a merge base",
+			zap.String("stack", stackName),
+			zap.String("file", composeFile),
+			zap.Error(err),
+		)
+	}
+}
+
+func (s *Service) findComposeVersion(stackName, composeFile string, etags []string) ([]byte, bool) {
+	for _, etag := range etags {
+		if etag == "*" {
+			continue
+		}
+		content, err := s.revisions.FindContent(stackName, composeFile, strings.Trim(etag, `"`))
+		if err == nil {
+			return content, true
+		}
+		if !errors.Is(err, revisions.ErrRevisionNotFound) {
+			s.logger.Warn("failed to look up compose merge base",
+				zap.String("stack", stackName),
+				zap.String("file", composeFile),
+				zap.Error(err),
+			)
+		}
+	}
+	return nil, false
+}
+
+func (s *Service) prepareComposeUpdate(stackName, composeFile string, content []byte, changes ComposeChanges, ifMatch string) (string, bool, error) {
+	currentETag := composeETag(content)
+
+	etags := parseIfMatch(ifMatch)
+	if len(etags) == 0 || matchesETag(etags, currentETag) {
+		modified, err := s.renderComposeChanges(content, changes)
+		return modified, false, err
+	}
+
+	base, ok := s.findComposeVersion(stackName, composeFile, etags)
+	if !ok {
+		return "", false, &ConflictError{
+			Message:     "compose file has changed since it was loaded and the loaded version is no longer in the file history; reload it and reapply the changes",
+			CurrentETag: currentETag,
+		}
+	}
+
+	conflicts, err := s.detectComposeConflicts(base, content, changes)
+	if err != nil {
+		return "", false, err
+	}
+	if len(conflicts) > 0 {
+		return "", false, &ConflictError{
+			Message:     fmt.Sprintf("compose file was modified since it was loaded and %d change(s) overlap", len(conflicts)),
+			CurrentETag: currentETag,
+			Conflicts:   conflicts,
+		}
+	}
+
+	modified, err := s.renderComposeChanges(content, changes)
+	if err != nil {
+		return "", false, err
+	}
+	return modified, true, nil
+}
+
+func matchesETag(etags []string, current string) bool {
+	for _, etag := range etags {
+		if etag == "*" || etag == current {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Service) renderComposeChanges(content []byte, changes ComposeChanges) (string, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return "", fmt.Errorf("failed to parse compose file: %w", err)
+	}
+
+	if err := s.applyChangesToYaml(&doc, changes); err != nil {
+		return "", fmt.Errorf("failed to apply changes: %w", err)
+	}
+
+	var buf strings.Builder
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&doc); err != nil {
+		return "", fmt.Errorf("failed to encode yaml: %w", err)
+	}
+	encoder.Close()
+
+	return addBlankLinesBetweenSections(buf.String()), nil
+}
+
+func (s *Service) detectComposeConflicts(base, current []byte, changes ComposeChanges) ([]ComposeConflict, error) {
+	baseDoc, err := decodeComposeDocument(base)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the loaded compose file: %w", err)
+	}
+	currentDoc, err := decodeComposeDocument(current)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse compose file: %w", err)
+	}
+
+	withoutRenames := changes
+	withoutRenames.RenameServices = nil
+	oursYaml, err := s.renderComposeChanges(base, withoutRenames)
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply changes to the loaded compose file: %w", err)
+	}
+	oursDoc, err := decodeComposeDocument([]byte(oursYaml))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the changed compose file: %w", err)
+	}
+
+	var conflicts []ComposeConflict
+	for _, path := range composeChangePaths(changes) {
+		baseValue := composeValueAt(baseDoc, path)
+		currentValue := composeValueAt(currentDoc, path)
+		oursValue := composeValueAt(oursDoc, path)
+
+		if reflect.DeepEqual(baseValue, currentValue) || reflect.DeepEqual(oursValue, currentValue) {
+			continue
+		}
+		conflicts = append(conflicts, ComposeConflict{
+			Path:      strings.Join(path, "."),
+			Base:      baseValue,
+			Current:   currentValue,
+			Requested: oursValue,
+		})
+	}
+
+	for oldName, newName := range changes.RenameServices {
+		oldPath := []string{"services", oldName}
+		newPath := []string{"services", newName}
+
+		if composeValueAt(baseDoc, oldPath) != nil && composeValueAt(currentDoc, oldPath) == nil {
+			conflicts = append(conflicts, ComposeConflict{
+				Path:      strings.Join(oldPath, "."),
+				Base:      composeValueAt(baseDoc, oldPath),
+				Requested: "renamed to " + newName,
+			})
+		}
+		if composeValueAt(baseDoc, newPath) == nil && composeValueAt(currentDoc, newPath) != nil {
+			conflicts = append(conflicts, ComposeConflict{
+				Path:      strings.Join(newPath, "."),
+				Current:   composeValueAt(currentDoc, newPath),
+				Requested: "renamed from " + oldName,
+			})
+		}
+	}
+
+	sort.Slice(conflicts, func(i, j int) bool {
+		return conflicts[i].Path < conflicts[j].Path
+	})
+
+	return conflicts, nil
+}
+
+func composeChangePaths(changes ComposeChanges) [][]string {
+	var paths [][]string
+	add := func(path ...string) {
+		paths = append(paths, path)
+	}
+
+	for name, svc := range changes.ServiceChanges {
+		if svc.Image != nil {
+			add("services", name, "image")
+		}
+		if svc.Restart != nil {
+			add("services", name, "restart")
+		}
+		if svc.Ports != nil {
+			add("services", name, "ports")
+		}
+		for key := range svc.Environment {
+			add("services", name, "environment", key)
+		}
+		if svc.Volumes != nil {
+			add("services", name, "volumes")
+		}
+		if svc.Command != nil {
+			add("services", name, "command")
+		}
+		if svc.Entrypoint != nil {
+			add("services", name, "entrypoint")
+		}
+		for key := range svc.Labels {
+			add("services", name, "labels", key)
+		}
+		if svc.DependsOn != nil {
+			add("services", name, "depends_on")
+		}
+		if svc.Healthcheck != nil {
+			add("services", name, "healthcheck")
+		}
+		if svc.Deploy != nil {
+			add("services", name, "deploy")
+		}
+		if svc.Build != nil {
+			add("services", name, "build")
+		}
+		if svc.Networks != nil {
+			add("services", name, "networks")
+		}
+		for key := range svc.Ulimits {
+			add("services", name, "ulimits", key)
+		}
+		for key := range svc.Sysctls {
+			add("services", name, "sysctls", key)
+		}
+		if svc.CapAdd != nil {
+			add("services", name, "cap_add")
+		}
+		if svc.CapDrop != nil {
+			add("services", name, "cap_drop")
+		}
+		if svc.Logging != nil {
+			add("services", name, "logging")
+		}
+		if svc.ExtraHosts != nil {
+			add("services", name, "extra_hosts")
+		}
+		if svc.Devices != nil {
+			add("services", name, "devices")
+		}
+		if svc.User != nil {
+			add("services", name, "user")
+		}
+		if svc.WorkingDir != nil {
+			add("services", name, "working_dir")
+		}
+		if svc.EnvFile != nil {
+			add("services", name, "env_file")
+		}
+		if svc.SecurityOpt != nil {
+			add("services", name, "security_opt")
+		}
+		if svc.Tmpfs != nil {
+			add("services", name, "tmpfs")
+		}
+		if svc.StopGracePeriod != nil {
+			add("services", name, "stop_grace_period")
+		}
+		if svc.Profiles != nil {
+			add("services", name, "profiles")
+		}
+		if svc.Secrets != nil {
+			add("services", name, "secrets")
+		}
+		if svc.Configs != nil {
+			add("services", name, "configs")
+		}
+	}
+
+	for name := range changes.NetworkChanges {
+		add("networks", name)
+	}
+	for name := range changes.VolumeChanges {
+		add("volumes", name)
+	}
+	for name := range changes.SecretChanges {
+		add("secrets", name)
+	}
+	for name := range changes.ConfigChanges {
+		add("configs", name)
+	}
+	for _, name := range changes.DeleteServices {
+		add("services", name)
+	}
+	for name := range changes.AddServices {
+		add("services", name)
+	}
+
+	return paths
+}
+
+func decodeComposeDocument(content []byte) (map[string]any, error) {
+	var doc map[string]any
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return nil, err
+	}
+
+	if services, ok := doc["services"].(map[string]any); ok {
+		for _, service := range services {
+			serviceMap, ok := service.(map[string]any)
+			if !ok {
+				continue
+			}
+			for _, key := range []string{"environment", "labels", "sysctls"} {
+				if value, ok := serviceMap[key]; ok {
+					serviceMap[key] = normaliseKeyValues(value)
+				}
+			}
+		}
+	}
+
+	return doc, nil
+}
+
+func normaliseKeyValues(value any) any {
+	result := make(map[string]any)
+
+	switch v := value.(type) {
+	case []any:
+		for _, item := range v {
+			entry := fmt.Sprint(item)
+			if idx := strings.Index(entry, "="); idx > 0 {
+				result[entry[:idx]] = entry[idx+1:]
+			} else {
+				result[entry] = ""
+			}
+		}
+	case map[string]any:
+		for key, item := range v {
+			if item == nil {
+				result[key] = ""
+			} else {
+				result[key] = fmt.Sprint(item)
+			}
+		}
+	default:
+		return value
+	}
+
+	return result
+}
+
+func composeValueAt(doc map[string]any, path []string) any {
+	var current any = doc
+	for _, segment := range path {
+		node, ok := current.(map[string]any)
+		if !ok {
+			return nil
+		}
+		current, ok = node[segment]
+		if !ok {
+			return nil
+		}
+	}
+	return current
+}
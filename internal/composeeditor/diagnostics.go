@@ -0,0 +1,512 @@
+package composeeditor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/compose-spec/compose-go/v2/cli"
+	"github.com/compose-spec/compose-go/v2/dotenv"
+	"github.com/compose-spec/compose-go/v2/schema"
+	"github.com/compose-spec/compose-go/v2/template"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"github.com/santhosh-tekuri/jsonschema/v6/kind"
+	"github.com/tech-arch1tect/berth-agent/internal/validation"
+	"github.com/xhit/go-str2duration/v2"
+	"go.uber.org/zap"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+const (
+	DiagnosticSyntax             = "syntax"
+	DiagnosticSchema             = "schema"
+	DiagnosticUnknownKey         = "unknown_key"
+	DiagnosticInvalidDuration    = "invalid_duration"
+	DiagnosticUnresolvedVariable = "unresolved_variable"
+	DiagnosticRequiredVariable   = "required_variable"
+	DiagnosticObsoleteVersion    = "obsolete_version"
+	DiagnosticEnvFile            = "env_file"
+	DiagnosticLoad               = "load"
+)
+
+var durationPathPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`^services\.[^.]+\.stop_grace_period$`),
+	regexp.MustCompile(`^services\.[^.]+\.healthcheck\.(interval|timeout|start_period|start_interval)$`),
+	regexp.MustCompile(`^services\.[^.]+\.deploy\.(update_config|rollback_config)\.(delay|monitor)$`),
+	regexp.MustCompile(`^services\.[^.]+\.deploy\.restart_policy\.(delay|window)$`),
+}
+
+var yamlLineRegex = regexp.MustCompile(`line (\d+)`)
+
+var composePathRegex = regexp.MustCompile(`\b((?:services|networks|volumes|secrets|configs|models)(?:\.[A-Za-z0-9_\-]+)+)`)
+
+var composeServiceRegex = regexp.MustCompile(`service "([^"]+)"`)
+
+var (
+	composeSchema     *jsonschema.Schema
+	composeSchemaErr  error
+	composeSchemaOnce sync.Once
+)
+
+func compiledComposeSchema() (*jsonschema.Schema, error) {
+	composeSchemaOnce.Do(func() {
+		document, err := jsonschema.UnmarshalJSON(strings.NewReader(schema.Schema))
+		if err != nil {
+			composeSchemaErr = err
+			return
+		}
+		compiler := jsonschema.NewCompiler()
+		if err := compiler.AddResource("compose-spec.json", document); err != nil {
+			composeSchemaErr = err
+			return
+		}
+		composeSchema, composeSchemaErr = compiler.Compile("compose-spec.json")
+	})
+	return composeSchema, composeSchemaErr
+}
+
+type yamlLocation struct {
+	key   *yaml.Node
+	value *yaml.Node
+}
+
+type composeDiagnostics struct {
+	locations map[string]yamlLocation
+	errors    []ComposeDiagnostic
+	warnings  []ComposeDiagnostic
+	seen      map[string]bool
+}
+
+func (d *composeDiagnostics) add(severity, code, path, message string, node *yaml.Node) {
+	diagnostic := ComposeDiagnostic{
+		Severity: severity,
+		Code:     code,
+		Message:  message,
+		Path:     path,
+	}
+	if node != nil {
+		diagnostic.Line = node.Line
+		diagnostic.Column = node.Column
+	}
+
+	key := fmt.Sprintf("%s|%s|%s|%d|%d", code, path, message, diagnostic.Line, diagnostic.Column)
+	if d.seen[key] {
+		return
+	}
+	d.seen[key] = true
+
+	if severity == SeverityError {
+		d.errors = append(d.errors, diagnostic)
+	} else {
+		d.warnings = append(d.warnings, diagnostic)
+	}
+}
+
+func (d *composeDiagnostics) valueNode(path string) *yaml.Node {
+	for {
+		if location, ok := d.locations[path]; ok && location.value != nil {
+			return location.value
+		}
+		if path == "" {
+			return nil
+		}
+		path = parentPath(path)
+	}
+}
+
+func (d *composeDiagnostics) keyNode(path string) *yaml.Node {
+	if location, ok := d.locations[path]; ok && location.key != nil {
+		return location.key
+	}
+	return d.valueNode(path)
+}
+
+func (s *Service) ValidateCompose(ctx context.Context, stackName string, content *string) (*ValidateComposeResponse, error) {
+	stackPath, err := validation.SanitizeStackPath(s.stackLocation, stackName)
+	if err != nil {
+		return nil, fmt.Errorf("invalid stack name: %w", err)
+	}
+
+	if _, err := os.Stat(stackPath); os.IsNotExist(err) {
+		return nil, fmt.Errorf("stack not found: %s", stackName)
+	}
+
+	response := &ValidateComposeResponse{
+		Source:   "request",
+		Errors:   []ComposeDiagnostic{},
+		Warnings: []ComposeDiagnostic{},
+	}
+
+	composeFile, findErr := s.findComposeFile(stackPath)
+	if findErr == nil {
+		response.ComposeFile = filepath.Base(composeFile)
+	} else {
+		response.ComposeFile = "compose.yml"
+	}
+
+	var yamlContent string
+	if content != nil {
+		yamlContent = *content
+	} else {
+		if findErr != nil {
+			return nil, fmt.Errorf("failed to find compose file: %w", findErr)
+		}
+		data, err := os.ReadFile(composeFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read compose file: %w", err)
+		}
+		yamlContent = string(data)
+		response.Source = "file"
+	}
+
+	s.logger.Debug("validating compose content",
+		zap.String("stack", stackName),
+		zap.String("source", response.Source),
+	)
+
+	diagnostics := &composeDiagnostics{
+		locations: make(map[string]yamlLocation),
+		seen:      make(map[string]bool),
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(yamlContent), &doc); err != nil {
+		diagnostic := ComposeDiagnostic{
+			Severity: SeverityError,
+			Code:     DiagnosticSyntax,
+			Message:  strings.TrimPrefix(err.Error(), "yaml: "),
+		}
+		if match := yamlLineRegex.FindStringSubmatch(err.Error()); match != nil {
+			diagnostic.Line, _ = strconv.Atoi(match[1])
+		}
+		response.Errors = append(response.Errors, diagnostic)
+		return response, nil
+	}
+
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		response.Errors = append(response.Errors, ComposeDiagnostic{
+			Severity: SeverityError,
+			Code:     DiagnosticSyntax,
+			Message:  "top-level object must be a mapping",
+		})
+		return response, nil
+	}
+
+	root := doc.Content[0]
+	indexYamlLocations(root, "", nil, diagnostics.locations)
+
+	env := s.loadStackEnv(stackPath, diagnostics)
+
+	if version, ok := diagnostics.locations["version"]; ok {
+		diagnostics.add(SeverityWarning, DiagnosticObsoleteVersion, "version",
+			"the top-level version key is obsolete and ignored", version.key)
+	}
+
+	checkComposeVariables(diagnostics, env)
+	checkComposeDurations(diagnostics, env)
+	checkComposeSchema(root, diagnostics)
+
+	if len(diagnostics.errors) == 0 {
+		loadOptions := []cli.ProjectOptionsFn{cli.WithEnvFiles(), cli.WithDotEnv}
+		if err := s.loadComposeYaml(stackPath, yamlContent, response.ComposeFile, loadOptions...); err != nil {
+			path := composeErrorPath(err.Error())
+			diagnostics.add(SeverityError, DiagnosticLoad, path, err.Error(), diagnostics.valueNode(path))
+		}
+	}
+
+	sortDiagnostics(diagnostics.errors)
+	sortDiagnostics(diagnostics.warnings)
+
+	response.Errors = append(response.Errors, diagnostics.errors...)
+	response.Warnings = append(response.Warnings, diagnostics.warnings...)
+	response.Valid = len(response.Errors) == 0
+
+	return response, nil
+}
+
+func (s *Service) loadStackEnv(stackPath string, diagnostics *composeDiagnostics) map[string]string {
+	envPath := filepath.Join(stackPath, ".env")
+	if _, err := os.Stat(envPath); err != nil {
+		return map[string]string{}
+	}
+
+	env, err := dotenv.GetEnvFromFile(map[string]string{}, []string{envPath})
+	if err != nil {
+		diagnostics.add(SeverityError, DiagnosticEnvFile, "", fmt.Sprintf(".env: %s", strings.ReplaceAll(err.Error(), envPath, ".env")), nil)
+		return map[string]string{}
+	}
+
+	return env
+}
+
+func indexYamlLocations(node *yaml.Node, path string, key *yaml.Node, locations map[string]yamlLocation) {
+	if node.Kind == yaml.AliasNode && node.Alias != nil {
+		locations[path] = yamlLocation{key: key, value: node}
+		return
+	}
+
+	locations[path] = yamlLocation{key: key, value: node}
+
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			indexYamlLocations(node.Content[i+1], joinPath(path, node.Content[i].Value), node.Content[i], locations)
+		}
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			indexYamlLocations(item, joinPath(path, strconv.Itoa(i)), nil, locations)
+		}
+	}
+}
+
+func checkComposeVariables(diagnostics *composeDiagnostics, env map[string]string) {
+	for path, location := range diagnostics.locations {
+		node := location.value
+		if node == nil || node.Kind != yaml.ScalarNode || !strings.Contains(node.Value, "$") {
+			continue
+		}
+
+		variables := template.ExtractVariables(map[string]any{"value": node.Value}, template.DefaultPattern)
+		names := make([]string, 0, len(variables))
+		for name := range variables {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			variable := variables[name]
+			if _, ok := env[name]; ok {
+				continue
+			}
+			if variable.Required {
+				diagnostics.add(SeverityError, DiagnosticRequiredVariable, path,
+					fmt.Sprintf("required variable %s is not set in the stack's .env", name), node)
+				continue
+			}
+			if variable.DefaultValue == "" && variable.PresenceValue == "" {
+				diagnostics.add(SeverityWarning, DiagnosticUnresolvedVariable, path,
+					fmt.Sprintf("variable %s is not set in the stack's .env and defaults to a blank string", name), node)
+			}
+		}
+	}
+}
+
+func checkComposeDurations(diagnostics *composeDiagnostics, env map[string]string) {
+	lookup := func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+
+	for path, location := range diagnostics.locations {
+		node := location.value
+		if node == nil || node.Kind != yaml.ScalarNode || !isDurationPath(path) {
+			continue
+		}
+
+		value, err := template.Substitute(node.Value, lookup)
+		if err != nil || value == "" {
+			continue
+		}
+		if _, err := str2duration.ParseDuration(value); err != nil {
+			diagnostics.add(SeverityError, DiagnosticInvalidDuration, path,
+				fmt.Sprintf("%s: %q is not a valid duration (use values such as 30s, 1m30s or 2h)", path, value), node)
+		}
+	}
+}
+
+func isDurationPath(path string) bool {
+	for _, pattern := range durationPathPatterns {
+		if pattern.MatchString(path) {
+			return true
+		}
+	}
+	return false
+}
+
+func checkComposeSchema(root *yaml.Node, diagnostics *composeDiagnostics) {
+	compiled, err := compiledComposeSchema()
+	if err != nil {
+		diagnostics.add(SeverityError, DiagnosticSchema, "", fmt.Sprintf("failed to load the compose schema: %v", err), nil)
+		return
+	}
+
+	var decoded any
+	if err := root.Decode(&decoded); err != nil {
+		diagnostics.add(SeverityError, DiagnosticSchema, "", err.Error(), root)
+		return
+	}
+
+	marshaled, err := json.Marshal(stringifyYamlKeys(decoded))
+	if err != nil {
+		diagnostics.add(SeverityError, DiagnosticSchema, "", err.Error(), root)
+		return
+	}
+
+	instance, err := jsonschema.UnmarshalJSON(strings.NewReader(string(marshaled)))
+	if err != nil {
+		diagnostics.add(SeverityError, DiagnosticSchema, "", err.Error(), root)
+		return
+	}
+
+	var validationErr *jsonschema.ValidationError
+	if err := compiled.Validate(instance); !errors.As(err, &validationErr) {
+		return
+	}
+
+	printer := message.NewPrinter(language.English)
+	var leaves []*jsonschema.ValidationError
+	collectSchemaErrors(validationErr, &leaves)
+
+	for _, leaf := range leaves {
+		path := strings.Join(leaf.InstanceLocation, ".")
+
+		if additional, ok := leaf.ErrorKind.(*kind.AdditionalProperties); ok {
+			for _, property := range additional.Properties {
+				propertyPath := joinPath(path, property)
+				where := path
+				if where == "" {
+					where = "the top level"
+				}
+				diagnostics.add(SeverityError, DiagnosticUnknownKey, propertyPath,
+					fmt.Sprintf("unknown key %q in %s", property, where), diagnostics.keyNode(propertyPath))
+			}
+			continue
+		}
+
+		if node := diagnostics.valueNode(path); node != nil && node.Kind == yaml.ScalarNode && strings.Contains(node.Value, "$") {
+			continue
+		}
+
+		diagnostics.add(SeverityError, DiagnosticSchema, path, schemaErrorMessage(path, leaf, printer), diagnostics.valueNode(path))
+	}
+}
+
+func collectSchemaErrors(err *jsonschema.ValidationError, leaves *[]*jsonschema.ValidationError) {
+	switch err.ErrorKind.(type) {
+	case *kind.OneOf, *kind.AnyOf:
+		*leaves = append(*leaves, mostSpecificSchemaError(err))
+		return
+	}
+
+	if len(err.Causes) == 0 {
+		*leaves = append(*leaves, err)
+		return
+	}
+
+	for _, cause := range err.Causes {
+		collectSchemaErrors(cause, leaves)
+	}
+}
+
+func mostSpecificSchemaError(err *jsonschema.ValidationError) *jsonschema.ValidationError {
+	if len(err.Causes) == 0 {
+		return err
+	}
+
+	var best *jsonschema.ValidationError
+	bestScore := -1
+	for _, cause := range err.Causes {
+		candidate := mostSpecificSchemaError(cause)
+		score := len(candidate.InstanceLocation)
+		if _, ok := candidate.ErrorKind.(*kind.AdditionalProperties); ok {
+			score++
+		}
+		if score > bestScore {
+			best, bestScore = candidate, score
+		}
+	}
+	return best
+}
+
+func schemaErrorMessage(path string, err *jsonschema.ValidationError, printer *message.Printer) string {
+	if path == "" {
+		path = "compose file"
+	}
+
+	switch k := err.ErrorKind.(type) {
+	case *kind.Type:
+		want := make([]string, len(k.Want))
+		for i, w := range k.Want {
+			if w == "object" {
+				w = "mapping"
+			}
+			want[i] = w
+		}
+		sort.Strings(want)
+		return fmt.Sprintf("%s must be a %s", path, strings.Join(want, " or "))
+	case *kind.Required:
+		return fmt.Sprintf("%s is missing required key(s): %s", path, strings.Join(k.Missing, ", "))
+	}
+
+	return fmt.Sprintf("%s %s", path, err.ErrorKind.LocalizedString(printer))
+}
+
+func composeErrorPath(message string) string {
+	if match := composePathRegex.FindStringSubmatch(message); match != nil {
+		return match[1]
+	}
+	if match := composeServiceRegex.FindStringSubmatch(message); match != nil {
+		return joinPath("services", match[1])
+	}
+	return ""
+}
+
+func stringifyYamlKeys(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, item := range v {
+			v[key] = stringifyYamlKeys(item)
+		}
+		return v
+	case map[any]any:
+		converted := make(map[string]any, len(v))
+		for key, item := range v {
+			converted[fmt.Sprint(key)] = stringifyYamlKeys(item)
+		}
+		return converted
+	case []any:
+		for i, item := range v {
+			v[i] = stringifyYamlKeys(item)
+		}
+		return v
+	}
+	return value
+}
+
+func sortDiagnostics(diagnostics []ComposeDiagnostic) {
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		if diagnostics[i].Line != diagnostics[j].Line {
+			return diagnostics[i].Line < diagnostics[j].Line
+		}
+		return diagnostics[i].Column < diagnostics[j].Column
+	})
+}
+
+func joinPath(parent, child string) string {
+	if parent == "" {
+		return child
+	}
+	return parent + "." + child
+}
+
+func parentPath(path string) string {
+	if index := strings.LastIndex(path, "."); index >= 0 {
+		return path[:index]
+	}
+	return ""
+}
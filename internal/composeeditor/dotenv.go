@@ -0,0 +1,599 @@
+package composeeditor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/compose-spec/compose-go/v2/dotenv"
+	"github.com/compose-spec/compose-go/v2/template"
+	"github.com/tech-arch1tect/berth-agent/internal/revisions"
+	"github.com/tech-arch1tect/berth-agent/internal/validation"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	DotEnvOpSet    = "set"
+	DotEnvOpUnset  = "unset"
+	DotEnvOpRename = "rename"
+
+	DotEnvRevealAll = validation.RevealAll
+)
+
+const dotEnvFileName = ".env"
+
+var ErrInvalidDotEnvOperation = errors.New("invalid .env operation")
+
+var (
+	dotEnvKeyPattern        = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+	dotEnvPlainValuePattern = regexp.MustCompile(`^[A-Za-z0-9_./:@%+,=-]+$`)
+	dotEnvExportPattern     = regexp.MustCompile(`^export\s+`)
+)
+
+var composeReservedEnvPrefixes = []string{"COMPOSE_", "DOCKER_"}
+
+type dotEnvStatement struct {
+	start         int
+	end           int
+	key           string
+	keyStart      int
+	keyEnd        int
+	exported      bool
+	rawValue      string
+	inlineComment string
+	comments      []string
+}
+
+type dotEnvDocument struct {
+	lines            []string
+	statements       []dotEnvStatement
+	trailingComments []string
+}
+
+type variableReference struct {
+	variable template.Variable
+	usedBy   []string
+}
+
+func (s *Service) GetDotEnv(ctx context.Context, stackName string, reveal []string) (*DotEnvConfig, error) {
+	stackPath, err := validation.SanitizeStackPath(s.stackLocation, stackName)
+	if err != nil {
+		return nil, fmt.Errorf("invalid stack name: %w", err)
+	}
+
+	if _, err := os.Stat(stackPath); os.IsNotExist(err) {
+		return nil, fmt.Errorf("stack not found: %s", stackName)
+	}
+
+	envPath := filepath.Join(stackPath, dotEnvFileName)
+	content, err := os.ReadFile(envPath)
+	exists := err == nil
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read .env file: %w", err)
+	}
+
+	return s.buildDotEnvConfig(stackName, stackPath, content, exists, reveal)
+}
+
+func (s *Service) UpdateDotEnv(ctx context.Context, stackName string, operations []DotEnvOperation, actor revisions.Actor) (*DotEnvConfig, error) {
+	stackPath, err := validation.SanitizeStackPath(s.stackLocation, stackName)
+	if err != nil {
+		return nil, fmt.Errorf("invalid stack name: %w", err)
+	}
+
+	if _, err := os.Stat(stackPath); os.IsNotExist(err) {
+		return nil, fmt.Errorf("stack not found: %s", stackName)
+	}
+
+	if len(operations) == 0 {
+		return nil, fmt.Errorf("%w: at least one operation is required", ErrInvalidDotEnvOperation)
+	}
+
+	unlock := s.revisions.LockFiles(stackName, dotEnvFileName)
+	defer unlock()
+
+	envPath := filepath.Join(stackPath, dotEnvFileName)
+	var mode os.FileMode = 0600
+	content, err := os.ReadFile(envPath)
+	switch {
+	case err == nil:
+		if info, err := os.Stat(envPath); err == nil {
+			mode = info.Mode().Perm()
+		}
+	case os.IsNotExist(err):
+	default:
+		return nil, fmt.Errorf("failed to read .env file: %w", err)
+	}
+
+	doc, err := parseDotEnvDocument(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse .env file: %w", err)
+	}
+
+	for i, operation := range operations {
+		doc, err = applyDotEnvOperation(doc, operation)
+		if err != nil {
+			return nil, fmt.Errorf("%w: operation %d: %s", ErrInvalidDotEnvOperation, i, err.Error())
+		}
+	}
+
+	updated := doc.String()
+	values, err := dotenv.UnmarshalWithLookup(updated, nil)
+	if err != nil {
+		return nil, fmt.Errorf("updated .env file does not parse: %w", err)
+	}
+	for _, operation := range operations {
+		if operation.Op == DotEnvOpSet && values[operation.Key] != operation.Value {
+			return nil, fmt.Errorf("value for %s could not be written without changing its meaning", operation.Key)
+		}
+	}
+
+	s.logger.Debug("updating .env file",
+		zap.String("stack", stackName),
+		zap.Int("operations", len(operations)),
+	)
+
+	if err := s.revisions.Capture(stackName, dotEnvFileName); err != nil {
+		return nil, fmt.Errorf("failed to preserve the previous .env file: %w", err)
+	}
+
+	if err := os.WriteFile(envPath, []byte(updated), mode); err != nil {
+		return nil, fmt.Errorf("failed to write .env file: %w", err)
+	}
+
+	if _, err := s.revisions.Record(stackName, dotEnvFileName, []byte(updated), actor, revisions.SourceEnvEditor); err != nil {
+		s.logger.Warn("failed to record .env file revision",
+			zap.String("stack", stackName),
+			zap.Error(err),
+		)
+	}
+
+	return s.buildDotEnvConfig(stackName, stackPath, []byte(updated), true, nil)
+}
+
+func (s *Service) buildDotEnvConfig(stackName, stackPath string, content []byte, exists bool, reveal []string) (*DotEnvConfig, error) {
+	doc, err := parseDotEnvDocument(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse .env file: %w", err)
+	}
+
+	values, err := dotenv.UnmarshalBytesWithLookup(content, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse .env file: %w", err)
+	}
+
+	references, loadedByService, known := s.collectVariableReferences(stackName, stackPath)
+	for _, statement := range doc.statements {
+		extracted := template.ExtractVariables(map[string]any{"value": statement.rawValue}, template.DefaultPattern)
+		for name, variable := range extracted {
+			reference, ok := references[name]
+			if !ok {
+				reference = &variableReference{variable: variable}
+				references[name] = reference
+			}
+			reference.usedBy = append(reference.usedBy, dotEnvFileName+":"+statement.key)
+		}
+	}
+
+	revealAll := false
+	revealKeys := make(map[string]bool)
+	for _, key := range reveal {
+		if key == DotEnvRevealAll {
+			revealAll = true
+		}
+		revealKeys[key] = true
+	}
+
+	result := &DotEnvConfig{
+		File:             dotEnvFileName,
+		Exists:           exists,
+		Entries:          []DotEnvEntry{},
+		TrailingComments: doc.trailingComments,
+		Missing:          []DotEnvMissingVariable{},
+		Unused:           []string{},
+	}
+
+	defined := make(map[string]int)
+	for _, statement := range doc.statements {
+		defined[statement.key]++
+	}
+
+	revealed := make(map[string]bool)
+	unused := make(map[string]bool)
+	for _, statement := range doc.statements {
+		entry := DotEnvEntry{
+			Line:          statement.start + 1,
+			Key:           statement.key,
+			Value:         values[statement.key],
+			Exported:      statement.exported,
+			Comments:      statement.comments,
+			InlineComment: statement.inlineComment,
+			Duplicate:     defined[statement.key] > 1,
+			UsedBy:        []string{},
+		}
+
+		if reference, ok := references[statement.key]; ok {
+			entry.UsedBy = reference.usedBy
+		} else if known && !loadedByService && !isReservedComposeVariable(statement.key) {
+			entry.Unused = true
+			unused[statement.key] = true
+		}
+
+		if entry.Value != "" && isSecretVariableName(statement.key) {
+			if revealAll || revealKeys[statement.key] {
+				revealed[statement.key] = true
+			} else {
+				entry.Value = renderedSecretMask
+				entry.Masked = true
+			}
+		}
+
+		result.Entries = append(result.Entries, entry)
+	}
+
+	for name := range unused {
+		result.Unused = append(result.Unused, name)
+	}
+	sort.Strings(result.Unused)
+
+	for name := range revealed {
+		result.Revealed = append(result.Revealed, name)
+	}
+	sort.Strings(result.Revealed)
+
+	for name, reference := range references {
+		if defined[name] > 0 || isReservedComposeVariable(name) {
+			continue
+		}
+		if _, ok := os.LookupEnv(name); ok {
+			continue
+		}
+		usedInCompose := false
+		for _, path := range reference.usedBy {
+			if !strings.HasPrefix(path, dotEnvFileName+":") {
+				usedInCompose = true
+				break
+			}
+		}
+		if !usedInCompose {
+			continue
+		}
+		result.Missing = append(result.Missing, DotEnvMissingVariable{
+			Name:         name,
+			Required:     reference.variable.Required || reference.variable.DefaultValue == "",
+			DefaultValue: reference.variable.DefaultValue,
+			UsedBy:       reference.usedBy,
+		})
+	}
+	sort.Slice(result.Missing, func(i, j int) bool {
+		return result.Missing[i].Name < result.Missing[j].Name
+	})
+
+	return result, nil
+}
+
+func (s *Service) collectVariableReferences(stackName, stackPath string) (map[string]*variableReference, bool, bool) {
+	references := make(map[string]*variableReference)
+
+	composeFile, err := s.findComposeFile(stackPath)
+	if err != nil {
+		return references, false, false
+	}
+
+	content, err := os.ReadFile(composeFile)
+	if err != nil {
+		s.logger.Warn("failed to read compose file while inspecting .env usage",
+			zap.String("stack", stackName),
+			zap.Error(err),
+		)
+		return references, false, false
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil || len(doc.Content) == 0 {
+		return references, false, false
+	}
+
+	locations := make(map[string]yamlLocation)
+	indexYamlLocations(doc.Content[0], "", nil, locations)
+
+	paths := make([]string, 0, len(locations))
+	for path, location := range locations {
+		if location.value != nil && location.value.Kind == yaml.ScalarNode && strings.Contains(location.value.Value, "$") {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		extracted := template.ExtractVariables(map[string]any{"value": locations[path].value.Value}, template.DefaultPattern)
+		for name, variable := range extracted {
+			reference, ok := references[name]
+			if !ok {
+				reference = &variableReference{variable: variable}
+				references[name] = reference
+			}
+			if variable.Required {
+				reference.variable.Required = true
+			}
+			if reference.variable.DefaultValue == "" {
+				reference.variable.DefaultValue = variable.DefaultValue
+			}
+			reference.usedBy = append(reference.usedBy, path)
+		}
+	}
+
+	return references, s.composeLoadsDotEnv(doc.Content[0], stackPath), true
+}
+
+func (s *Service) composeLoadsDotEnv(root *yaml.Node, stackPath string) bool {
+	dotEnvPath := filepath.Join(stackPath, dotEnvFileName)
+	isDotEnv := func(path string) bool {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(stackPath, path)
+		}
+		return filepath.Clean(path) == dotEnvPath
+	}
+
+	services := s.findYamlKey(root, "services")
+	if services == nil || services.Kind != yaml.MappingNode {
+		return false
+	}
+
+	for i := 1; i < len(services.Content); i += 2 {
+		envFile := s.findYamlKey(services.Content[i], "env_file")
+		if envFile == nil {
+			continue
+		}
+		switch envFile.Kind {
+		case yaml.ScalarNode:
+			if isDotEnv(envFile.Value) {
+				return true
+			}
+		case yaml.SequenceNode:
+			for _, item := range envFile.Content {
+				path := item.Value
+				if item.Kind == yaml.MappingNode {
+					if pathNode := s.findYamlKey(item, "path"); pathNode != nil {
+						path = pathNode.Value
+					}
+				}
+				if path != "" && isDotEnv(path) {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+func isReservedComposeVariable(name string) bool {
+	for _, prefix := range composeReservedEnvPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func applyDotEnvOperation(doc *dotEnvDocument, operation DotEnvOperation) (*dotEnvDocument, error) {
+	if !dotEnvKeyPattern.MatchString(operation.Key) {
+		return nil, fmt.Errorf("invalid variable name '%s'", operation.Key)
+	}
+
+	lines := append([]string(nil), doc.lines...)
+	matches := doc.find(operation.Key)
+
+	switch operation.Op {
+	case DotEnvOpSet:
+		if operation.Value == renderedSecretMask {
+			return nil, fmt.Errorf("value for %s is the masked placeholder; reveal the variable before editing it", operation.Key)
+		}
+		if len(matches) == 0 {
+			if len(doc.trailingComments) > 0 {
+				lines = append(lines, "")
+			}
+			lines = append(lines, formatDotEnvLine(operation.Key, operation.Value, false, ""))
+			break
+		}
+		for i := len(matches) - 1; i >= 0; i-- {
+			statement := matches[i]
+			line := formatDotEnvLine(statement.key, operation.Value, statement.exported, statement.inlineComment)
+			lines = append(lines[:statement.start], append([]string{line}, lines[statement.end:]...)...)
+		}
+
+	case DotEnvOpUnset:
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("variable %s is not defined in .env", operation.Key)
+		}
+		for i := len(matches) - 1; i >= 0; i-- {
+			lines = append(lines[:matches[i].start], lines[matches[i].end:]...)
+		}
+
+	case DotEnvOpRename:
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("variable %s is not defined in .env", operation.Key)
+		}
+		if !dotEnvKeyPattern.MatchString(operation.NewKey) {
+			return nil, fmt.Errorf("invalid variable name '%s'", operation.NewKey)
+		}
+		if operation.NewKey == operation.Key {
+			return nil, fmt.Errorf("new name for %s must differ from the current name", operation.Key)
+		}
+		if len(doc.find(operation.NewKey)) > 0 {
+			return nil, fmt.Errorf("variable %s is already defined in .env", operation.NewKey)
+		}
+		for _, statement := range matches {
+			line := lines[statement.start]
+			lines[statement.start] = line[:statement.keyStart] + operation.NewKey + line[statement.keyEnd:]
+		}
+
+	default:
+		return nil, fmt.Errorf("unknown operation '%s': expected set, unset or rename", operation.Op)
+	}
+
+	return parseDotEnvLines(lines)
+}
+
+func (d *dotEnvDocument) find(key string) []dotEnvStatement {
+	var matches []dotEnvStatement
+	for _, statement := range d.statements {
+		if statement.key == key {
+			matches = append(matches, statement)
+		}
+	}
+	return matches
+}
+
+func (d *dotEnvDocument) String() string {
+	if len(d.lines) == 0 {
+		return ""
+	}
+	return strings.Join(d.lines, "\n") + "\n"
+}
+
+func parseDotEnvDocument(content string) (*dotEnvDocument, error) {
+	content = strings.TrimPrefix(content, "\ufeff")
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	if content == "" {
+		return &dotEnvDocument{}, nil
+	}
+	return parseDotEnvLines(strings.Split(strings.TrimSuffix(content, "\n"), "\n"))
+}
+
+func parseDotEnvLines(lines []string) (*dotEnvDocument, error) {
+	doc := &dotEnvDocument{lines: lines}
+
+	var comments []string
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+
+		if trimmed == "" {
+			comments = nil
+			continue
+		}
+		if strings.HasPrefix(trimmed, "#") {
+			comments = append(comments, strings.TrimSpace(strings.TrimPrefix(trimmed, "#")))
+			continue
+		}
+
+		statement := dotEnvStatement{
+			start:    i,
+			end:      i + 1,
+			keyStart: len(line) - len(strings.TrimLeft(line, " \t")),
+			comments: comments,
+		}
+		comments = nil
+
+		if match := dotEnvExportPattern.FindString(line[statement.keyStart:]); match != "" {
+			statement.exported = true
+			statement.keyStart += len(match)
+		}
+
+		rest := line[statement.keyStart:]
+		separator := strings.IndexAny(rest, "=:")
+		if separator < 0 {
+			statement.key = strings.TrimSpace(rest)
+			statement.keyEnd = statement.keyStart + len(strings.TrimRight(rest, " \t"))
+		} else {
+			statement.key = strings.TrimRight(rest[:separator], " \t")
+			statement.keyEnd = statement.keyStart + len(statement.key)
+		}
+		if !dotEnvKeyPattern.MatchString(statement.key) {
+			return nil, fmt.Errorf("line %d: invalid variable name '%s'", i+1, statement.key)
+		}
+
+		if separator >= 0 {
+			value := strings.TrimLeft(rest[separator+1:], " \t")
+			if value != "" && (value[0] == '"' || value[0] == '\'') {
+				end, raw, after, err := scanQuotedDotEnvValue(lines, i, value)
+				if err != nil {
+					return nil, err
+				}
+				statement.end = end + 1
+				statement.rawValue = raw
+				if after = strings.TrimSpace(after); strings.HasPrefix(after, "#") {
+					statement.inlineComment = strings.TrimSpace(strings.TrimPrefix(after, "#"))
+				}
+				i = end
+			} else {
+				raw, comment, found := strings.Cut(value, " #")
+				statement.rawValue = strings.TrimRight(raw, " \t")
+				if found {
+					statement.inlineComment = strings.TrimSpace(comment)
+				}
+			}
+		}
+
+		doc.statements = append(doc.statements, statement)
+	}
+	doc.trailingComments = comments
+
+	return doc, nil
+}
+
+func scanQuotedDotEnvValue(lines []string, start int, value string) (int, string, string, error) {
+	quote := value[0]
+	text := value[1:]
+	var raw strings.Builder
+
+	for i := start; i < len(lines); i++ {
+		escaped := false
+		for j := 0; j < len(text); j++ {
+			switch {
+			case escaped:
+				escaped = false
+			case text[j] == '\\':
+				escaped = true
+			case text[j] == quote:
+				raw.WriteString(text[:j])
+				return i, raw.String(), text[j+1:], nil
+			}
+		}
+		raw.WriteString(text)
+		raw.WriteByte('\n')
+		if i+1 < len(lines) {
+			text = lines[i+1]
+		}
+	}
+
+	return 0, "", "", fmt.Errorf("line %d: unterminated quoted value", start+1)
+}
+
+func formatDotEnvLine(key, value string, exported bool, inlineComment string) string {
+	line := key + "=" + quoteDotEnvValue(value)
+	if exported {
+		line = "export " + line
+	}
+	if inlineComment != "" {
+		line += " # " + inlineComment
+	}
+	return line
+}
+
+func quoteDotEnvValue(value string) string {
+	switch {
+	case value == "" || dotEnvPlainValuePattern.MatchString(value):
+		return value
+	case !strings.ContainsAny(value, "'\n\r") && !strings.HasSuffix(value, `\`):
+		return "'" + value + "'"
+	}
+
+	replacer := strings.NewReplacer(
+		`\`, `\\`,
+		`"`, `\"`,
+		"\n", `\n`,
+		"\r", `\r`,
+		"\t", `\t`,
+		`$`, `\$`,
+	)
+	return `"` + replacer.Replace(value) + `"`
+}
@@ -1,18 +1,25 @@
 package composeeditor
 
 import (
+	"errors"
 	"net/http"
 
+	"github.com/tech-arch1tect/berth-agent/internal/audit"
+	"github.com/tech-arch1tect/berth-agent/internal/revisions"
+	"github.com/tech-arch1tect/berth-agent/internal/validation"
+
 	"github.com/labstack/echo/v4"
 )
 
 type Handler struct {
-	service *Service
+	service      *Service
+	auditService *audit.Service
 }
 
-func NewHandler(service *Service) *Handler {
+func NewHandler(service *Service, auditService *audit.Service) *Handler {
 	return &Handler{
-		service: service,
+		service:      service,
+		auditService: auditService,
 	}
 }
 
@@ -24,6 +31,27 @@ func (h *Handler) GetComposeConfig(c echo.Context) error {
 		})
 	}
 
+	switch c.QueryParam("mode") {
+	case "", "raw":
+	case "rendered":
+		if err := validation.ValidateStackName(stackName); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "invalid stack name: " + err.Error(),
+			})
+		}
+		rendered, err := h.service.GetRenderedComposeConfig(c.Request().Context(), stackName)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": err.Error(),
+			})
+		}
+		return c.JSON(http.StatusOK, rendered)
+	default:
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "mode must be raw or rendered",
+		})
+	}
+
 	config, err := h.service.GetComposeConfig(c.Request().Context(), stackName)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{
@@ -31,6 +59,7 @@ func (h *Handler) GetComposeConfig(c echo.Context) error {
 		})
 	}
 
+	c.Response().Header().Set("ETag", config.ETag)
 	return c.JSON(http.StatusOK, config)
 }
 
@@ -49,28 +78,173 @@ func (h *Handler) UpdateCompose(c echo.Context) error {
 		})
 	}
 
+	ifMatch := c.Request().Header.Get("If-Match")
+
 	if req.Preview {
-		originalYaml, modifiedYaml, err := h.service.PreviewCompose(c.Request().Context(), stackName, req.Changes)
+		originalYaml, modifiedYaml, merged, err := h.service.PreviewCompose(c.Request().Context(), stackName, req.Changes, ifMatch)
 		if err != nil {
-			return c.JSON(http.StatusInternalServerError, map[string]string{
-				"error": err.Error(),
-			})
+			return composeUpdateError(c, err)
 		}
 		return c.JSON(http.StatusOK, UpdateComposeResponse{
 			Success:      true,
 			OriginalYaml: originalYaml,
 			ModifiedYaml: modifiedYaml,
+			Merged:       merged,
 		})
 	}
 
-	if err := h.service.UpdateCompose(c.Request().Context(), stackName, req.Changes); err != nil {
+	etag, merged, err := h.service.UpdateCompose(c.Request().Context(), stackName, req.Changes, ifMatch, revisions.ActorFromContext(c))
+	if err != nil {
+		return composeUpdateError(c, err)
+	}
+
+	message := "Compose file updated successfully"
+	if merged {
+		message = "Compose file updated successfully; changes were merged with a newer version of the file"
+	}
+
+	c.Response().Header().Set("ETag", etag)
+	return c.JSON(http.StatusOK, UpdateComposeResponse{
+		Success: true,
+		Message: message,
+		ETag:    etag,
+		Merged:  merged,
+	})
+}
+
+func composeUpdateError(c echo.Context, err error) error {
+	var conflictErr *ConflictError
+	if errors.As(err, &conflictErr) {
+		c.Response().Header().Set("ETag", conflictErr.CurrentETag)
+		return c.JSON(http.StatusConflict, ComposeConflictResponse{
+			Error:       conflictErr.Message,
+			CurrentETag: conflictErr.CurrentETag,
+			Conflicts:   conflictErr.Conflicts,
+		})
+	}
+
+	return c.JSON(http.StatusInternalServerError, map[string]string{
+		"error": err.Error(),
+	})
+}
+
+func (h *Handler) ValidateCompose(c echo.Context) error {
+	stackName := c.Param("name")
+	if err := validation.ValidateStackName(stackName); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "invalid stack name: " + err.Error(),
+		})
+	}
+
+	var req ValidateComposeRequest
+	if c.Request().ContentLength != 0 {
+		if err := c.Bind(&req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "invalid request body",
+			})
+		}
+	}
+
+	result, err := h.service.ValidateCompose(c.Request().Context(), stackName, req.Content)
+	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{
 			"error": err.Error(),
 		})
 	}
 
-	return c.JSON(http.StatusOK, UpdateComposeResponse{
-		Success: true,
-		Message: "Compose file updated successfully",
+	return c.JSON(http.StatusOK, result)
+}
+
+func (h *Handler) LintCompose(c echo.Context) error {
+	stackName := c.Param("name")
+	if err := validation.ValidateStackName(stackName); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "invalid stack name: " + err.Error(),
+		})
+	}
+
+	result, err := h.service.LintCompose(c.Request().Context(), stackName, c.QueryParam("fail_on"))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, result)
+}
+
+func (h *Handler) GetDotEnv(c echo.Context) error {
+	stackName := c.Param("name")
+	if err := validation.ValidateStackName(stackName); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "invalid stack name: " + err.Error(),
+		})
+	}
+
+	reveal := validation.ParseRevealParam(c.QueryParam("reveal"))
+
+	config, err := h.service.GetDotEnv(c.Request().Context(), stackName, reveal)
+	if err != nil {
+		h.auditService.LogStackEvent(audit.EventStackGetDotEnv, c.RealIP(), stackName, false, err.Error(), nil)
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	h.auditService.LogStackEvent(audit.EventStackGetDotEnv, c.RealIP(), stackName, true, "", map[string]any{
+		"entries": len(config.Entries),
+	})
+
+	if len(config.Revealed) > 0 {
+		h.auditService.LogStackEvent(audit.EventStackRevealDotEnv, c.RealIP(), stackName, true, "", map[string]any{
+			"variables": config.Revealed,
+			"actor":     revisions.ActorFromContext(c).Name,
+		})
+	}
+
+	return c.JSON(http.StatusOK, config)
+}
+
+func (h *Handler) UpdateDotEnv(c echo.Context) error {
+	stackName := c.Param("name")
+	if err := validation.ValidateStackName(stackName); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "invalid stack name: " + err.Error(),
+		})
+	}
+
+	var req UpdateDotEnvRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "invalid request body",
+		})
+	}
+
+	keys := make([]string, 0, len(req.Operations))
+	for _, operation := range req.Operations {
+		keys = append(keys, operation.Op+":"+operation.Key)
+	}
+
+	actor := revisions.ActorFromContext(c)
+	config, err := h.service.UpdateDotEnv(c.Request().Context(), stackName, req.Operations, actor)
+	if err != nil {
+		h.auditService.LogStackEvent(audit.EventStackUpdateDotEnv, c.RealIP(), stackName, false, err.Error(), map[string]any{
+			"operations": keys,
+			"actor":      actor.Name,
+		})
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrInvalidDotEnvOperation) {
+			status = http.StatusBadRequest
+		}
+		return c.JSON(status, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	h.auditService.LogStackEvent(audit.EventStackUpdateDotEnv, c.RealIP(), stackName, true, "", map[string]any{
+		"operations": keys,
+		"actor":      actor.Name,
 	})
+
+	return c.JSON(http.StatusOK, config)
 }
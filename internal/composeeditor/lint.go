@@ -0,0 +1,382 @@
+package composeeditor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/compose-spec/compose-go/v2/cli"
+	"github.com/compose-spec/compose-go/v2/types"
+	"github.com/tech-arch1tect/berth-agent/internal/validation"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	LintSeverityHigh   = "high"
+	LintSeverityMedium = "medium"
+	LintSeverityLow    = "low"
+)
+
+const (
+	LintRuleImageTag        = "image-unpinned-tag"
+	LintRuleRestartPolicy   = "missing-restart-policy"
+	LintRulePrivileged      = "privileged"
+	LintRuleHostNetwork     = "host-network"
+	LintRuleDockerSocket    = "docker-socket-mount"
+	LintRuleRuntimeDirMount = "host-runtime-dir-mount"
+	LintRuleHostBindMount   = "host-bind-outside-stack"
+	LintRuleHealthcheck     = "missing-healthcheck"
+	LintRuleMemoryLimit     = "missing-memory-limit"
+	LintRulePlaintextSecret = "plaintext-secret-env"
+)
+
+const (
+	lintIgnoreExtensionKey    = "x-berth"
+	lintIgnoreMetadataFile    = ".berth.yml"
+	defaultLintFailOnSeverity = LintSeverityHigh
+)
+
+var lintSeverityRank = map[string]int{
+	LintSeverityLow:    1,
+	LintSeverityMedium: 2,
+	LintSeverityHigh:   3,
+}
+
+var dockerSocketPaths = map[string]bool{
+	"/var/run/docker.sock": true,
+	"/run/docker.sock":     true,
+}
+
+var hostRuntimeDirs = map[string]bool{
+	"/var/run": true,
+	"/run":     true,
+}
+
+type composeLinter struct {
+	stackPath string
+	locations map[string]yamlLocation
+	findings  []LintFinding
+}
+
+func (l *composeLinter) add(rule, severity, service, path, message string) {
+	finding := LintFinding{
+		Rule:     rule,
+		Severity: severity,
+		Service:  service,
+		Message:  message,
+		Path:     path,
+	}
+
+	node := l.keyNode(path)
+	if node != nil {
+		finding.Line = node.Line
+		finding.Column = node.Column
+	}
+
+	l.findings = append(l.findings, finding)
+}
+
+func (l *composeLinter) keyNode(path string) *yaml.Node {
+	for path != "" {
+		if location, ok := l.locations[path]; ok {
+			if location.key != nil {
+				return location.key
+			}
+			if location.value != nil {
+				return location.value
+			}
+		}
+		path = parentPath(path)
+	}
+	return nil
+}
+
+func (s *Service) LintCompose(ctx context.Context, stackName, failOn string) (*LintComposeResponse, error) {
+	stackPath, err := validation.SanitizeStackPath(s.stackLocation, stackName)
+	if err != nil {
+		return nil, fmt.Errorf("invalid stack name: %w", err)
+	}
+
+	if _, err := os.Stat(stackPath); os.IsNotExist(err) {
+		return nil, fmt.Errorf("stack not found: %s", stackName)
+	}
+
+	if failOn == "" {
+		failOn = defaultLintFailOnSeverity
+	}
+	if _, ok := lintSeverityRank[failOn]; !ok && failOn != "none" {
+		return nil, fmt.Errorf("invalid fail_on severity '%s': expected high, medium, low or none", failOn)
+	}
+
+	composeFile, err := s.findComposeFile(stackPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find compose file: %w", err)
+	}
+
+	content, err := os.ReadFile(composeFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read compose file: %w", err)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse compose file: %w", err)
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("invalid compose document structure")
+	}
+
+	project, err := s.loadComposeProject(stackPath, string(content), filepath.Base(composeFile),
+		cli.WithEnvFiles(), cli.WithDotEnv, cli.WithProfiles([]string{"*"}))
+	if err != nil {
+		return nil, fmt.Errorf("compose file is invalid, fix validation errors before linting: %w", err)
+	}
+
+	s.logger.Debug("linting compose file",
+		zap.String("stack", stackName),
+		zap.String("file", composeFile),
+	)
+
+	linter := &composeLinter{
+		stackPath: filepath.Clean(stackPath),
+		locations: make(map[string]yamlLocation),
+	}
+	indexYamlLocations(doc.Content[0], "", nil, linter.locations)
+
+	names := make([]string, 0, len(project.Services))
+	for name := range project.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		linter.lintService(name, project.Services[name])
+	}
+
+	ignores := s.lintIgnores(doc.Content[0], stackPath, stackName)
+
+	response := &LintComposeResponse{
+		ComposeFile: filepath.Base(composeFile),
+		Passed:      true,
+		FailOn:      failOn,
+		Summary: map[string]int{
+			LintSeverityHigh:   0,
+			LintSeverityMedium: 0,
+			LintSeverityLow:    0,
+		},
+		Findings:   []LintFinding{},
+		Suppressed: []LintFinding{},
+	}
+
+	for _, finding := range linter.findings {
+		if ignores[finding.Rule] || ignores[finding.Rule+":"+finding.Service] {
+			response.Suppressed = append(response.Suppressed, finding)
+			continue
+		}
+		response.Findings = append(response.Findings, finding)
+		response.Summary[finding.Severity]++
+		if failOn != "none" && lintSeverityRank[finding.Severity] >= lintSeverityRank[failOn] {
+			response.Passed = false
+		}
+	}
+
+	sortLintFindings(response.Findings)
+	sortLintFindings(response.Suppressed)
+
+	return response, nil
+}
+
+func (l *composeLinter) lintService(name string, service types.ServiceConfig) {
+	servicePath := joinPath("services", name)
+
+	if service.Image != "" {
+		if reason := unpinnedImageReason(service.Image); reason != "" {
+			l.add(LintRuleImageTag, LintSeverityMedium, name, joinPath(servicePath, "image"),
+				fmt.Sprintf("image %s %s; pin a specific version so deploys are reproducible", service.Image, reason))
+		}
+	}
+
+	if service.Restart == "" && (service.Deploy == nil || service.Deploy.RestartPolicy == nil) {
+		l.add(LintRuleRestartPolicy, LintSeverityLow, name, servicePath,
+			"no restart policy is set, so the service will not come back after a crash or host reboot")
+	}
+
+	if service.Privileged {
+		l.add(LintRulePrivileged, LintSeverityHigh, name, joinPath(servicePath, "privileged"),
+			"privileged: true gives the container full access to the host; grant specific capabilities with cap_add instead")
+	}
+
+	if service.NetworkMode == "host" {
+		l.add(LintRuleHostNetwork, LintSeverityMedium, name, joinPath(servicePath, "network_mode"),
+			"network_mode: host shares the host network stack and bypasses port isolation")
+	}
+
+	for i, volume := range service.Volumes {
+		if volume.Type != types.VolumeTypeBind || volume.Source == "" {
+			continue
+		}
+		volumePath := joinPath(joinPath(servicePath, "volumes"), strconv.Itoa(i))
+
+		source := volume.Source
+		if !filepath.IsAbs(source) {
+			source = filepath.Join(l.stackPath, source)
+		}
+		source = filepath.Clean(source)
+
+		if dockerSocketPaths[source] || filepath.Base(source) == "docker.sock" {
+			l.add(LintRuleDockerSocket, LintSeverityHigh, name, volumePath,
+				fmt.Sprintf("%s is mounted into the container, which grants root-equivalent control of the host", volume.Source))
+			continue
+		}
+
+		if hostRuntimeDirs[source] {
+			l.add(LintRuleRuntimeDirMount, LintSeverityHigh, name, volumePath,
+				fmt.Sprintf("%s is mounted into the container and exposes host runtime sockets, including the Docker socket when present", volume.Source))
+			continue
+		}
+
+		if rel, err := filepath.Rel(l.stackPath, source); err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+			l.add(LintRuleHostBindMount, LintSeverityMedium, name, volumePath,
+				fmt.Sprintf("bind mount %s is outside the stack directory; prefer a named volume or a path inside the stack", volume.Source))
+		}
+	}
+
+	switch {
+	case service.HealthCheck == nil:
+		l.add(LintRuleHealthcheck, LintSeverityLow, name, servicePath,
+			"no healthcheck is defined in the compose file, so failures are only detected when the process exits")
+	case service.HealthCheck.Disable:
+		l.add(LintRuleHealthcheck, LintSeverityLow, name, joinPath(servicePath, "healthcheck"),
+			"the healthcheck is disabled, so failures are only detected when the process exits")
+	}
+
+	hasMemoryLimit := service.MemLimit > 0
+	if service.Deploy != nil && service.Deploy.Resources.Limits != nil && service.Deploy.Resources.Limits.MemoryBytes > 0 {
+		hasMemoryLimit = true
+	}
+	if !hasMemoryLimit {
+		l.add(LintRuleMemoryLimit, LintSeverityLow, name, servicePath,
+			"no memory limit is set, so a leak in this service can exhaust host memory")
+	}
+
+	l.lintEnvironmentSecrets(name, joinPath(servicePath, "environment"))
+}
+
+func (l *composeLinter) lintEnvironmentSecrets(service, envPath string) {
+	location, ok := l.locations[envPath]
+	if !ok || location.value == nil {
+		return
+	}
+	envNode := location.value
+
+	check := func(path, key, value string) {
+		if value == "" || strings.Contains(value, "$") || strings.HasSuffix(strings.ToUpper(key), "_FILE") {
+			return
+		}
+		if isSecretVariableName(key) {
+			l.add(LintRulePlaintextSecret, LintSeverityHigh, service, path,
+				fmt.Sprintf("%s holds a literal value in the compose file; move it to .env or a secret", key))
+		}
+	}
+
+	switch envNode.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(envNode.Content); i += 2 {
+			key := envNode.Content[i].Value
+			value := envNode.Content[i+1]
+			if value.Kind != yaml.ScalarNode || value.Tag == "!!null" {
+				continue
+			}
+			check(joinPath(envPath, key), key, value.Value)
+		}
+	case yaml.SequenceNode:
+		for i, item := range envNode.Content {
+			key, value, found := strings.Cut(item.Value, "=")
+			if !found {
+				continue
+			}
+			check(joinPath(envPath, strconv.Itoa(i)), key, value)
+		}
+	}
+}
+
+func unpinnedImageReason(image string) string {
+	if strings.Contains(image, "@") {
+		return ""
+	}
+
+	name := image
+	if idx := strings.LastIndex(name, "/"); idx >= 0 {
+		name = name[idx+1:]
+	}
+
+	_, tag, found := strings.Cut(name, ":")
+	switch {
+	case !found:
+		return "has no tag and resolves to latest"
+	case tag == "latest":
+		return "uses the latest tag"
+	}
+	return ""
+}
+
+func (s *Service) lintIgnores(root *yaml.Node, stackPath, stackName string) map[string]bool {
+	ignores := make(map[string]bool)
+
+	collect := func(node *yaml.Node) {
+		lintNode := s.findYamlKey(node, "lint")
+		ignoreNode := s.findYamlKey(lintNode, "ignore")
+		if ignoreNode == nil {
+			return
+		}
+		switch ignoreNode.Kind {
+		case yaml.SequenceNode:
+			for _, item := range ignoreNode.Content {
+				if rule := strings.TrimSpace(item.Value); rule != "" {
+					ignores[rule] = true
+				}
+			}
+		case yaml.ScalarNode:
+			if rule := strings.TrimSpace(ignoreNode.Value); rule != "" {
+				ignores[rule] = true
+			}
+		}
+	}
+
+	collect(s.findYamlKey(root, lintIgnoreExtensionKey))
+
+	content, err := os.ReadFile(filepath.Join(stackPath, lintIgnoreMetadataFile))
+	if err == nil {
+		var metadata yaml.Node
+		if err := yaml.Unmarshal(content, &metadata); err != nil {
+			s.logger.Warn("failed to parse stack metadata file for lint suppressions",
+				zap.String("stack", stackName),
+				zap.Error(err),
+			)
+		} else if len(metadata.Content) > 0 {
+			collect(metadata.Content[0])
+		}
+	}
+
+	return ignores
+}
+
+func sortLintFindings(findings []LintFinding) {
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].Severity != findings[j].Severity {
+			return lintSeverityRank[findings[i].Severity] > lintSeverityRank[findings[j].Severity]
+		}
+		if findings[i].Service != findings[j].Service {
+			return findings[i].Service < findings[j].Service
+		}
+		if findings[i].Line != findings[j].Line {
+			return findings[i].Line < findings[j].Line
+		}
+		return findings[i].Rule < findings[j].Rule
+	})
+}
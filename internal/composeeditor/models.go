@@ -8,6 +8,12 @@ type (
 	NewServiceConfig      = types.NewServiceConfig
 	ServiceChanges        = types.ServiceChanges
 	ServiceNetworkConfig  = types.ServiceNetworkConfig
+	UlimitConfig          = types.UlimitConfig
+	LoggingConfig         = types.LoggingConfig
+	ExtraHost             = types.ExtraHost
+	DeviceMapping         = types.DeviceMapping
+	EnvFileConfig         = types.EnvFileConfig
+	ServiceFileReference  = types.ServiceFileReference
 	PortMapping           = types.PortMapping
 	VolumeMount           = types.VolumeMount
 	CommandConfig         = types.CommandConfig
@@ -29,4 +35,24 @@ type (
 	ConfigConfig          = types.ConfigConfig
 	UpdateComposeRequest  = types.UpdateComposeRequest
 	UpdateComposeResponse = types.UpdateComposeResponse
+
+	ComposeConflict         = types.ComposeConflict
+	ComposeConflictResponse = types.ComposeConflictResponse
+
+	ValidateComposeRequest  = types.ValidateComposeRequest
+	ValidateComposeResponse = types.ValidateComposeResponse
+	ComposeDiagnostic       = types.ComposeDiagnostic
+
+	LintFinding         = types.LintFinding
+	LintComposeResponse = types.LintComposeResponse
+
+	RenderedComposeConfig = types.RenderedComposeConfig
+	RenderedVariable      = types.RenderedVariable
+	InterpolatedValue     = types.InterpolatedValue
+
+	DotEnvConfig          = types.DotEnvConfig
+	DotEnvEntry           = types.DotEnvEntry
+	DotEnvMissingVariable = types.DotEnvMissingVariable
+	DotEnvOperation       = types.DotEnvOperation
+	UpdateDotEnvRequest   = types.UpdateDotEnvRequest
 )
@@ -2,6 +2,9 @@ package composeeditor
 
 import (
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
 
 	"gopkg.in/yaml.v3"
 )
@@ -350,3 +353,132 @@ func (s *Service) buildServiceNetworksNode(networks map[string]*ServiceNetworkCo
 
 	return node
 }
+
+func (s *Service) buildStringListNode(values []string) *yaml.Node {
+	node := createSequenceNode()
+	for _, v := range values {
+		node.Content = append(node.Content, createScalarNode(v))
+	}
+	return node
+}
+
+func (s *Service) buildUlimitNode(ulimit *UlimitConfig) *yaml.Node {
+	if ulimit.Single != nil {
+		return &yaml.Node{Kind: yaml.ScalarNode, Value: strconv.Itoa(*ulimit.Single), Tag: "!!int"}
+	}
+
+	node := createMappingNode()
+	if ulimit.Soft != nil {
+		appendIntPair(node, "soft", *ulimit.Soft)
+	}
+	if ulimit.Hard != nil {
+		appendIntPair(node, "hard", *ulimit.Hard)
+	}
+	return node
+}
+
+func (s *Service) buildLoggingNode(logging *LoggingConfig) *yaml.Node {
+	node := createMappingNode()
+
+	if logging.Driver != "" {
+		appendScalarPair(node, "driver", logging.Driver)
+	}
+
+	if len(logging.Options) > 0 {
+		keys := make([]string, 0, len(logging.Options))
+		for k := range logging.Options {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		optionsNode := createMappingNode()
+		for _, k := range keys {
+			optionsNode.Content = append(optionsNode.Content,
+				createScalarNode(k),
+				createStringNode(logging.Options[k]),
+			)
+		}
+		appendNodePair(node, "options", optionsNode)
+	}
+
+	return node
+}
+
+func (s *Service) buildExtraHostsNode(hosts []ExtraHost) *yaml.Node {
+	node := createSequenceNode()
+	for _, h := range hosts {
+		separator := ":"
+		if strings.Contains(h.IP, ":") {
+			separator = "="
+		}
+		node.Content = append(node.Content, createScalarNode(h.Hostname+separator+h.IP))
+	}
+	return node
+}
+
+func (s *Service) buildDevicesNode(devices []DeviceMapping) *yaml.Node {
+	node := createSequenceNode()
+	for _, d := range devices {
+		device := d.Source
+		target := d.Target
+		if target == "" && d.Permissions != "" {
+			target = d.Source
+		}
+		if target != "" {
+			device += ":" + target
+		}
+		if d.Permissions != "" {
+			device += ":" + d.Permissions
+		}
+		node.Content = append(node.Content, createScalarNode(device))
+	}
+	return node
+}
+
+func (s *Service) buildEnvFileNode(envFiles []EnvFileConfig) *yaml.Node {
+	node := createSequenceNode()
+	for _, f := range envFiles {
+		if f.Required == nil && f.Format == "" {
+			node.Content = append(node.Content, createScalarNode(f.Path))
+			continue
+		}
+
+		entryNode := createMappingNode()
+		appendScalarPair(entryNode, "path", f.Path)
+		if f.Required != nil {
+			appendBoolPair(entryNode, "required", *f.Required)
+		}
+		if f.Format != "" {
+			appendScalarPair(entryNode, "format", f.Format)
+		}
+		node.Content = append(node.Content, entryNode)
+	}
+	return node
+}
+
+func (s *Service) buildServiceFileReferencesNode(refs []ServiceFileReference) *yaml.Node {
+	node := createSequenceNode()
+	for _, ref := range refs {
+		if ref.Target == "" && ref.UID == "" && ref.GID == "" && ref.Mode == nil {
+			node.Content = append(node.Content, createScalarNode(ref.Source))
+			continue
+		}
+
+		refNode := createMappingNode()
+		appendScalarPair(refNode, "source", ref.Source)
+		if ref.Target != "" {
+			appendScalarPair(refNode, "target", ref.Target)
+		}
+		if ref.UID != "" {
+			appendNodePair(refNode, "uid", createStringNode(ref.UID))
+		}
+		if ref.GID != "" {
+			appendNodePair(refNode, "gid", createStringNode(ref.GID))
+		}
+		if ref.Mode != nil {
+			appendNodePair(refNode, "mode", &yaml.Node{Kind: yaml.ScalarNode, Value: fmt.Sprintf("0%o", *ref.Mode), Tag: "!!int"})
+		}
+		node.Content = append(node.Content, refNode)
+	}
+	return node
+}
@@ -0,0 +1,332 @@
+package composeeditor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/compose-spec/compose-go/v2/cli"
+	"github.com/compose-spec/compose-go/v2/dotenv"
+	"github.com/compose-spec/compose-go/v2/template"
+	"github.com/tech-arch1tect/berth-agent/internal/validation"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	VariableSourceDotEnv      = ".env"
+	VariableSourceEnvironment = "environment"
+	VariableSourceDefault     = "default"
+	VariableSourceUnset       = "unset"
+
+	ValueSourceVariable = "variable"
+	ValueSourceEnvFile  = "env_file"
+)
+
+const (
+	renderedSecretMask   = validation.SecretMask
+	minMaskedValueLength = 4
+)
+
+func (s *Service) GetRenderedComposeConfig(ctx context.Context, stackName string) (*RenderedComposeConfig, error) {
+	stackPath, err := validation.SanitizeStackPath(s.stackLocation, stackName)
+	if err != nil {
+		return nil, fmt.Errorf("invalid stack name: %w", err)
+	}
+
+	if _, err := os.Stat(stackPath); os.IsNotExist(err) {
+		return nil, fmt.Errorf("stack not found: %s", stackName)
+	}
+
+	composeFile, err := s.findComposeFile(stackPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find compose file: %w", err)
+	}
+
+	content, err := os.ReadFile(composeFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read compose file: %w", err)
+	}
+
+	s.logger.Debug("rendering compose file",
+		zap.String("stack", stackName),
+		zap.String("file", composeFile),
+	)
+
+	project, err := s.loadComposeProject(stackPath, string(content), filepath.Base(composeFile),
+		cli.WithEnvFiles(), cli.WithDotEnv, cli.WithProfiles([]string{"*"}), cli.WithResolvedPaths(true))
+	if err != nil {
+		return nil, fmt.Errorf("failed to render compose file: %w", err)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse compose file: %w", err)
+	}
+	locations := make(map[string]yamlLocation)
+	if len(doc.Content) > 0 {
+		indexYamlLocations(doc.Content[0], "", nil, locations)
+	}
+
+	dotEnv := map[string]string{}
+	if envPath := filepath.Join(stackPath, ".env"); fileExists(envPath) {
+		if values, err := dotenv.GetEnvFromFile(map[string]string{}, []string{envPath}); err == nil {
+			dotEnv = values
+		}
+	}
+
+	secrets := make(map[string]bool)
+	addSecret := func(value string) {
+		if len(value) >= minMaskedValueLength {
+			secrets[value] = true
+		}
+	}
+
+	lookup := func(name string) (string, bool) {
+		value, ok := project.Environment[name]
+		return value, ok
+	}
+
+	variables := make(map[string]*RenderedVariable)
+	result := &RenderedComposeConfig{
+		ComposeFile:  filepath.Base(composeFile),
+		ProjectName:  project.Name,
+		Variables:    []RenderedVariable{},
+		Interpolated: []InterpolatedValue{},
+	}
+
+	paths := make([]string, 0, len(locations))
+	for path, location := range locations {
+		if location.value != nil && location.value.Kind == yaml.ScalarNode && strings.Contains(location.value.Value, "$") {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		raw := locations[path].value.Value
+		extracted := template.ExtractVariables(map[string]any{"value": raw}, template.DefaultPattern)
+		if len(extracted) == 0 {
+			continue
+		}
+
+		names := make([]string, 0, len(extracted))
+		for name := range extracted {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		masked := false
+		for _, name := range names {
+			variable, ok := variables[name]
+			if !ok {
+				variable = describeVariable(name, extracted[name], project.Environment, dotEnv)
+				variables[name] = variable
+			}
+			variable.UsedBy = append(variable.UsedBy, path)
+			if variable.Masked {
+				masked = true
+				addSecret(variable.Value)
+			}
+		}
+
+		value, err := template.Substitute(raw, lookup)
+		if err != nil {
+			value = ""
+		}
+		if masked {
+			addSecret(value)
+			value = renderedSecretMask
+		}
+
+		result.Interpolated = append(result.Interpolated, InterpolatedValue{
+			Path:      path,
+			Template:  raw,
+			Value:     value,
+			Variables: names,
+			Source:    ValueSourceVariable,
+			Masked:    masked,
+		})
+	}
+
+	serviceNames := make([]string, 0, len(project.Services))
+	for name := range project.Services {
+		serviceNames = append(serviceNames, name)
+	}
+	sort.Strings(serviceNames)
+
+	for _, serviceName := range serviceNames {
+		service := project.Services[serviceName]
+
+		for key, value := range service.Environment {
+			if value != nil && isSecretVariableName(key) {
+				addSecret(*value)
+			}
+		}
+
+		for _, envFile := range service.EnvFiles {
+			if !fileExists(envFile.Path) {
+				continue
+			}
+			values, err := dotenv.GetEnvFromFile(project.Environment, []string{envFile.Path})
+			if err != nil {
+				s.logger.Warn("failed to read env_file while rendering compose file",
+					zap.String("stack", stackName),
+					zap.String("service", serviceName),
+					zap.String("env_file", envFile.Path),
+					zap.Error(err),
+				)
+				continue
+			}
+
+			displayPath := envFile.Path
+			if rel, err := filepath.Rel(stackPath, envFile.Path); err == nil && !strings.HasPrefix(rel, "..") {
+				displayPath = rel
+			}
+
+			keys := make([]string, 0, len(values))
+			for key := range values {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+
+			for _, key := range keys {
+				resolved, ok := service.Environment[key]
+				if !ok || resolved == nil || *resolved != values[key] {
+					continue
+				}
+				value := values[key]
+				masked := isSecretVariableName(key)
+				if masked {
+					addSecret(value)
+					value = renderedSecretMask
+				}
+				result.Interpolated = append(result.Interpolated, InterpolatedValue{
+					Path:   joinPath(joinPath(joinPath("services", serviceName), "environment"), key),
+					Value:  value,
+					Source: ValueSourceEnvFile,
+					File:   displayPath,
+					Masked: masked,
+				})
+			}
+		}
+	}
+
+	names := make([]string, 0, len(variables))
+	for name := range variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		variable := *variables[name]
+		if variable.Masked {
+			variable.Value = renderedSecretMask
+		}
+		result.Variables = append(result.Variables, variable)
+	}
+
+	data, err := project.MarshalJSON()
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode rendered project: %w", err)
+	}
+	var rendered map[string]any
+	if err := json.Unmarshal(data, &rendered); err != nil {
+		return nil, fmt.Errorf("failed to encode rendered project: %w", err)
+	}
+	maskRenderedEnvironment(rendered)
+	result.Rendered = maskRenderedValue(rendered, secrets).(map[string]any)
+
+	var buf strings.Builder
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(result.Rendered); err != nil {
+		return nil, fmt.Errorf("failed to encode rendered project: %w", err)
+	}
+	encoder.Close()
+	result.RenderedYaml = buf.String()
+
+	return result, nil
+}
+
+func describeVariable(name string, extracted template.Variable, environment map[string]string, dotEnv map[string]string) *RenderedVariable {
+	variable := &RenderedVariable{
+		Name:   name,
+		Masked: isSecretVariableName(name),
+		UsedBy: []string{},
+	}
+
+	value, ok := environment[name]
+	dotEnvValue, inDotEnv := dotEnv[name]
+	switch {
+	case ok && inDotEnv && dotEnvValue == value:
+		variable.Value = value
+		variable.Source = VariableSourceDotEnv
+	case ok:
+		variable.Value = value
+		variable.Source = VariableSourceEnvironment
+	case extracted.DefaultValue != "":
+		variable.Value = extracted.DefaultValue
+		variable.Source = VariableSourceDefault
+	default:
+		variable.Source = VariableSourceUnset
+	}
+
+	return variable
+}
+
+func isSecretVariableName(name string) bool {
+	return validation.IsSecretVariableName(name)
+}
+
+func maskRenderedEnvironment(rendered map[string]any) {
+	services, ok := rendered["services"].(map[string]any)
+	if !ok {
+		return
+	}
+	for _, service := range services {
+		serviceMap, ok := service.(map[string]any)
+		if !ok {
+			continue
+		}
+		environment, ok := serviceMap["environment"].(map[string]any)
+		if !ok {
+			continue
+		}
+		for key, value := range environment {
+			if str, ok := value.(string); ok && str != "" && isSecretVariableName(key) {
+				environment[key] = renderedSecretMask
+			}
+		}
+	}
+}
+
+func maskRenderedValue(value any, secrets map[string]bool) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, item := range v {
+			v[key] = maskRenderedValue(item, secrets)
+		}
+		return v
+	case []any:
+		for i, item := range v {
+			v[i] = maskRenderedValue(item, secrets)
+		}
+		return v
+	case string:
+		for secret := range secrets {
+			v = strings.ReplaceAll(v, secret, renderedSecretMask)
+		}
+		return v
+	default:
+		return value
+	}
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
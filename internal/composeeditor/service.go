@@ -1,11 +1,11 @@
 package composeeditor
 
 import (
-	"bytes"
 	"context"
 	"fmt"
 	"github.com/tech-arch1tect/berth-agent/config"
 	"github.com/tech-arch1tect/berth-agent/internal/logging"
+	"github.com/tech-arch1tect/berth-agent/internal/revisions"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -18,12 +18,14 @@ import (
 type Service struct {
 	stackLocation string
 	logger        *logging.Logger
+	revisions     *revisions.Service
 }
 
-func NewService(cfg *config.Config, logger *logging.Logger) *Service {
+func NewService(cfg *config.Config, logger *logging.Logger, revisionsService *revisions.Service) *Service {
 	return &Service{
 		stackLocation: cfg.StackLocation,
 		logger:        logger,
+		revisions:     revisionsService,
 	}
 }
 
@@ -56,7 +58,9 @@ func (s *Service) GetComposeConfig(ctx context.Context, stackName string) (*RawC
 
 	result := &RawComposeConfig{
 		ComposeFile: filepath.Base(composeFile),
+		ETag:        composeETag(content),
 	}
+	s.rememberComposeVersion(stackName, filepath.Base(composeFile))
 
 	if services, ok := rawConfig["services"].(map[string]any); ok {
 		result.Services = services
@@ -95,16 +99,16 @@ func (s *Service) findComposeFile(stackPath string) (string, error) {
 	return "", fmt.Errorf("no compose file found in %s", stackPath)
 }
 
-func (s *Service) UpdateCompose(ctx context.Context, stackName string, changes ComposeChanges) error {
+func (s *Service) UpdateCompose(ctx context.Context, stackName string, changes ComposeChanges, ifMatch string, actor revisions.Actor) (etag string, merged bool, err error) {
 	stackPath := filepath.Join(s.stackLocation, stackName)
 
 	if _, err := os.Stat(stackPath); os.IsNotExist(err) {
-		return fmt.Errorf("stack not found: %s", stackName)
+		return "", false, fmt.Errorf("stack not found: %s", stackName)
 	}
 
 	composeFile, err := s.findComposeFile(stackPath)
 	if err != nil {
-		return fmt.Errorf("failed to find compose file: %w", err)
+		return "", false, fmt.Errorf("failed to find compose file: %w", err)
 	}
 
 	s.logger.Debug("updating compose file",
@@ -112,80 +116,72 @@ func (s *Service) UpdateCompose(ctx context.Context, stackName string, changes C
 		zap.String("file", composeFile),
 	)
 
+	unlock := s.revisions.LockFiles(stackName, filepath.Base(composeFile))
+	defer unlock()
+
 	content, err := os.ReadFile(composeFile)
 	if err != nil {
-		return fmt.Errorf("failed to read compose file: %w", err)
+		return "", false, fmt.Errorf("failed to read compose file: %w", err)
 	}
 
-	var doc yaml.Node
-	if err := yaml.Unmarshal(content, &doc); err != nil {
-		return fmt.Errorf("failed to parse compose file: %w", err)
+	yamlContent, merged, err := s.prepareComposeUpdate(stackName, filepath.Base(composeFile), content, changes, ifMatch)
+	if err != nil {
+		return "", false, err
 	}
 
-	if err := s.applyChangesToYaml(&doc, changes); err != nil {
-		return fmt.Errorf("failed to apply changes: %w", err)
+	if err := s.validateComposeYaml(stackPath, yamlContent); err != nil {
+		return "", false, fmt.Errorf("validation failed: %w", err)
 	}
 
-	var buf bytes.Buffer
-	encoder := yaml.NewEncoder(&buf)
-	encoder.SetIndent(2)
-	if err := encoder.Encode(&doc); err != nil {
-		return fmt.Errorf("failed to encode yaml: %w", err)
+	if err := s.revisions.Capture(stackName, filepath.Base(composeFile)); err != nil {
+		return "", false, fmt.Errorf("failed to preserve the previous compose file: %w", err)
 	}
-	encoder.Close()
 
-	yamlContent := addBlankLinesBetweenSections(buf.String())
+	if err := os.WriteFile(composeFile, []byte(yamlContent), 0644); err != nil {
+		return "", false, fmt.Errorf("failed to write compose file: %w", err)
+	}
 
-	if err := s.validateComposeYaml(stackPath, yamlContent); err != nil {
-		return fmt.Errorf("validation failed: %w", err)
+	if _, err := s.revisions.Record(stackName, filepath.Base(composeFile), []byte(yamlContent), actor, revisions.SourceComposeEditor); err != nil {
+		s.logger.Warn("failed to record compose file revision",
+			zap.String("stack", stackName),
+			zap.String("file", composeFile),
+			zap.Error(err),
+		)
 	}
 
-	if err := os.WriteFile(composeFile, []byte(yamlContent), 0644); err != nil {
-		return fmt.Errorf("failed to write compose file: %w", err)
+	if merged {
+		s.logger.Info("merged compose changes into a concurrently modified file",
+			zap.String("stack", stackName),
+			zap.String("file", composeFile),
+		)
 	}
 
-	return nil
+	return composeETag([]byte(yamlContent)), merged, nil
 }
 
-func (s *Service) PreviewCompose(ctx context.Context, stackName string, changes ComposeChanges) (originalYaml, modifiedYaml string, err error) {
+func (s *Service) PreviewCompose(ctx context.Context, stackName string, changes ComposeChanges, ifMatch string) (originalYaml, modifiedYaml string, merged bool, err error) {
 	stackPath := filepath.Join(s.stackLocation, stackName)
 
 	if _, err := os.Stat(stackPath); os.IsNotExist(err) {
-		return "", "", fmt.Errorf("stack not found: %s", stackName)
+		return "", "", false, fmt.Errorf("stack not found: %s", stackName)
 	}
 
 	composeFile, err := s.findComposeFile(stackPath)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to find compose file: %w", err)
+		return "", "", false, fmt.Errorf("failed to find compose file: %w", err)
 	}
 
 	content, err := os.ReadFile(composeFile)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to read compose file: %w", err)
-	}
-
-	originalYaml = string(content)
-
-	var doc yaml.Node
-	if err := yaml.Unmarshal(content, &doc); err != nil {
-		return "", "", fmt.Errorf("failed to parse compose file: %w", err)
+		return "", "", false, fmt.Errorf("failed to read compose file: %w", err)
 	}
 
-	if err := s.applyChangesToYaml(&doc, changes); err != nil {
-		return "", "", fmt.Errorf("failed to apply changes: %w", err)
-	}
-
-	var buf bytes.Buffer
-	encoder := yaml.NewEncoder(&buf)
-	encoder.SetIndent(2)
-	if err := encoder.Encode(&doc); err != nil {
-		return "", "", fmt.Errorf("failed to encode yaml: %w", err)
+	modifiedYaml, merged, err = s.prepareComposeUpdate(stackName, filepath.Base(composeFile), content, changes, ifMatch)
+	if err != nil {
+		return "", "", false, err
 	}
-	encoder.Close()
 
-	modifiedYaml = addBlankLinesBetweenSections(buf.String())
-
-	return originalYaml, modifiedYaml, nil
+	return string(content), modifiedYaml, merged, nil
 }
 
 var serviceDefRegex = regexp.MustCompile(`(?m)^  [a-zA-Z][a-zA-Z0-9_-]*:\s*$`)
@@ -281,6 +277,10 @@ func (s *Service) applyServiceChangesToYaml(root *yaml.Node, serviceChanges map[
 			return fmt.Errorf("service not found: %s", serviceName)
 		}
 
+		if err := validateServiceChanges(serviceName, svcChanges); err != nil {
+			return err
+		}
+
 		if svcChanges.Image != nil {
 			s.setYamlValue(serviceNode, "image", *svcChanges.Image)
 		}
@@ -324,6 +324,78 @@ func (s *Service) applyServiceChangesToYaml(root *yaml.Node, serviceChanges map[
 		if svcChanges.Networks != nil {
 			s.setYamlNode(serviceNode, "networks", s.buildServiceNetworksNode(svcChanges.Networks))
 		}
+		if svcChanges.CapAdd != nil {
+			s.setOrDeleteYamlList(serviceNode, "cap_add", *svcChanges.CapAdd)
+		}
+		if svcChanges.CapDrop != nil {
+			s.setOrDeleteYamlList(serviceNode, "cap_drop", *svcChanges.CapDrop)
+		}
+		if svcChanges.Ulimits != nil {
+			s.applyUlimitsChanges(serviceNode, svcChanges.Ulimits)
+		}
+		if svcChanges.Logging != nil {
+			if svcChanges.Logging.Driver == "" && len(svcChanges.Logging.Options) == 0 {
+				s.deleteYamlKey(serviceNode, "logging")
+			} else {
+				s.setYamlNode(serviceNode, "logging", s.buildLoggingNode(svcChanges.Logging))
+			}
+		}
+		if svcChanges.ExtraHosts != nil {
+			if len(*svcChanges.ExtraHosts) == 0 {
+				s.deleteYamlKey(serviceNode, "extra_hosts")
+			} else {
+				s.setYamlNode(serviceNode, "extra_hosts", s.buildExtraHostsNode(*svcChanges.ExtraHosts))
+			}
+		}
+		if svcChanges.Devices != nil {
+			if len(*svcChanges.Devices) == 0 {
+				s.deleteYamlKey(serviceNode, "devices")
+			} else {
+				s.setYamlNode(serviceNode, "devices", s.buildDevicesNode(*svcChanges.Devices))
+			}
+		}
+		if svcChanges.Sysctls != nil {
+			s.applySysctlsChanges(serviceNode, svcChanges.Sysctls)
+		}
+		if svcChanges.User != nil {
+			s.setOrDeleteYamlString(serviceNode, "user", *svcChanges.User)
+		}
+		if svcChanges.WorkingDir != nil {
+			s.setOrDeleteYamlString(serviceNode, "working_dir", *svcChanges.WorkingDir)
+		}
+		if svcChanges.EnvFile != nil {
+			if len(*svcChanges.EnvFile) == 0 {
+				s.deleteYamlKey(serviceNode, "env_file")
+			} else {
+				s.setYamlNode(serviceNode, "env_file", s.buildEnvFileNode(*svcChanges.EnvFile))
+			}
+		}
+		if svcChanges.SecurityOpt != nil {
+			s.setOrDeleteYamlList(serviceNode, "security_opt", *svcChanges.SecurityOpt)
+		}
+		if svcChanges.Tmpfs != nil {
+			s.setOrDeleteYamlList(serviceNode, "tmpfs", *svcChanges.Tmpfs)
+		}
+		if svcChanges.StopGracePeriod != nil {
+			s.setOrDeleteYamlString(serviceNode, "stop_grace_period", *svcChanges.StopGracePeriod)
+		}
+		if svcChanges.Profiles != nil {
+			s.setOrDeleteYamlList(serviceNode, "profiles", *svcChanges.Profiles)
+		}
+		if svcChanges.Secrets != nil {
+			if len(*svcChanges.Secrets) == 0 {
+				s.deleteYamlKey(serviceNode, "secrets")
+			} else {
+				s.setYamlNode(serviceNode, "secrets", s.buildServiceFileReferencesNode(*svcChanges.Secrets))
+			}
+		}
+		if svcChanges.Configs != nil {
+			if len(*svcChanges.Configs) == 0 {
+				s.deleteYamlKey(serviceNode, "configs")
+			} else {
+				s.setYamlNode(serviceNode, "configs", s.buildServiceFileReferencesNode(*svcChanges.Configs))
+			}
+		}
 	}
 
 	return nil
@@ -372,3 +444,45 @@ func (s *Service) applyLabelsChanges(serviceNode *yaml.Node, labelChanges map[st
 	}
 	return nil
 }
+
+func (s *Service) applySysctlsChanges(serviceNode *yaml.Node, sysctlChanges map[string]*string) {
+	sysctlsNode := s.findYamlKey(serviceNode, "sysctls")
+	if sysctlsNode == nil {
+		sysctlsNode = createMappingNode()
+		s.setYamlNode(serviceNode, "sysctls", sysctlsNode)
+	}
+	if sysctlsNode.Kind == yaml.SequenceNode {
+		sysctlsNode.Content = convertSequenceContent(sysctlsNode.Content, "=")
+		sysctlsNode.Kind = yaml.MappingNode
+		sysctlsNode.Tag = ""
+		sysctlsNode.Style = 0
+	}
+	for key, val := range sysctlChanges {
+		if val == nil {
+			s.deleteYamlKey(sysctlsNode, key)
+		} else {
+			s.setYamlValue(sysctlsNode, key, *val)
+		}
+	}
+	if len(sysctlsNode.Content) == 0 {
+		s.deleteYamlKey(serviceNode, "sysctls")
+	}
+}
+
+func (s *Service) applyUlimitsChanges(serviceNode *yaml.Node, ulimitChanges map[string]*UlimitConfig) {
+	ulimitsNode := s.findYamlKey(serviceNode, "ulimits")
+	if ulimitsNode == nil || ulimitsNode.Kind != yaml.MappingNode {
+		ulimitsNode = createMappingNode()
+		s.setYamlNode(serviceNode, "ulimits", ulimitsNode)
+	}
+	for name, ulimit := range ulimitChanges {
+		if ulimit == nil {
+			s.deleteYamlKey(ulimitsNode, name)
+		} else {
+			s.setYamlNode(ulimitsNode, name, s.buildUlimitNode(ulimit))
+		}
+	}
+	if len(ulimitsNode.Content) == 0 {
+		s.deleteYamlKey(serviceNode, "ulimits")
+	}
+}
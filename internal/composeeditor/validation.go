@@ -2,27 +2,45 @@ package composeeditor
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strings"
 	"sync"
 
 	"github.com/compose-spec/compose-go/v2/cli"
+	"github.com/compose-spec/compose-go/v2/types"
+	"github.com/xhit/go-str2duration/v2"
 )
 
 var wdMutex sync.Mutex
 
 func (s *Service) validateComposeYaml(stackPath, yamlContent string) error {
+	if err := s.loadComposeYaml(stackPath, yamlContent, "compose file", cli.WithDiscardEnvFile); err != nil {
+		return fmt.Errorf("invalid compose file: %w", err)
+	}
+	return nil
+}
+
+func (s *Service) loadComposeYaml(stackPath, yamlContent, displayName string, extraOptions ...cli.ProjectOptionsFn) error {
+	_, err := s.loadComposeProject(stackPath, yamlContent, displayName, extraOptions...)
+	return err
+}
+
+func (s *Service) loadComposeProject(stackPath, yamlContent, displayName string, extraOptions ...cli.ProjectOptionsFn) (*types.Project, error) {
 	tempFile, err := os.CreateTemp(stackPath, "compose-validate-*.yml")
 	if err != nil {
-		return fmt.Errorf("failed to create temp file: %w", err)
+		return nil, fmt.Errorf("failed to create temp file: %w", err)
 	}
 	tempPath := tempFile.Name()
 	defer os.Remove(tempPath)
 
 	if _, err := tempFile.WriteString(yamlContent); err != nil {
 		tempFile.Close()
-		return fmt.Errorf("failed to write temp file: %w", err)
+		return nil, fmt.Errorf("failed to write temp file: %w", err)
 	}
 	tempFile.Close()
 
@@ -33,28 +51,224 @@ func (s *Service) validateComposeYaml(stackPath, yamlContent string) error {
 
 	originalWd, err := os.Getwd()
 	if err != nil {
-		return fmt.Errorf("failed to get working directory: %w", err)
+		return nil, fmt.Errorf("failed to get working directory: %w", err)
 	}
 
 	if err := os.Chdir(stackPath); err != nil {
-		return fmt.Errorf("failed to change to stack directory: %w", err)
+		return nil, fmt.Errorf("failed to change to stack directory: %w", err)
 	}
 	defer os.Chdir(originalWd)
 
-	options, err := cli.NewProjectOptions(
-		[]string{tempFilename},
+	projectOptions := append([]cli.ProjectOptionsFn{
 		cli.WithWorkingDirectory(stackPath),
 		cli.WithResolvedPaths(false),
-		cli.WithDiscardEnvFile,
-	)
+	}, extraOptions...)
+
+	options, err := cli.NewProjectOptions([]string{tempFilename}, projectOptions...)
 	if err != nil {
-		return fmt.Errorf("invalid compose configuration: %w", err)
+		return nil, fmt.Errorf("invalid compose configuration: %w", err)
 	}
 
-	_, err = cli.ProjectFromOptions(context.Background(), options)
+	project, err := cli.ProjectFromOptions(context.Background(), options)
 	if err != nil {
-		return fmt.Errorf("invalid compose file: %w", err)
+		message := strings.ReplaceAll(err.Error(), tempPath, displayName)
+		return nil, errors.New(strings.ReplaceAll(message, tempFilename, displayName))
+	}
+
+	return project, nil
+}
+
+var (
+	capabilityPattern  = regexp.MustCompile(`^(CAP_)?[A-Z][A-Z0-9_]*$`)
+	ulimitNamePattern  = regexp.MustCompile(`^[a-z]+$`)
+	sysctlKeyPattern   = regexp.MustCompile(`^[a-zA-Z0-9_][a-zA-Z0-9_.\-/]*$`)
+	profileNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+	resourceRefPattern = regexp.MustCompile(`^[a-zA-Z0-9._-]+$`)
+	hostnamePattern    = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9.-]*[a-zA-Z0-9])?$`)
+	userPattern        = regexp.MustCompile(`^[a-zA-Z0-9_.$-]+(:[a-zA-Z0-9_.$-]+)?$`)
+	logDriverPattern   = regexp.MustCompile(`^[a-zA-Z0-9_./:-]+$`)
+)
+
+func validateServiceChanges(serviceName string, changes ServiceChanges) error {
+	if err := validateCapabilities(changes.CapAdd); err != nil {
+		return fmt.Errorf("service %s: cap_add: %w", serviceName, err)
+	}
+	if err := validateCapabilities(changes.CapDrop); err != nil {
+		return fmt.Errorf("service %s: cap_drop: %w", serviceName, err)
+	}
+
+	for name, ulimit := range changes.Ulimits {
+		if err := validateUlimit(name, ulimit); err != nil {
+			return fmt.Errorf("service %s: ulimits: %w", serviceName, err)
+		}
+	}
+
+	if changes.Logging != nil {
+		if changes.Logging.Driver != "" && !logDriverPattern.MatchString(changes.Logging.Driver) {
+			return fmt.Errorf("service %s: logging: invalid driver '%s'", serviceName, changes.Logging.Driver)
+		}
+		for key := range changes.Logging.Options {
+			if strings.TrimSpace(key) == "" {
+				return fmt.Errorf("service %s: logging: option names cannot be empty", serviceName)
+			}
+		}
+	}
+
+	if changes.ExtraHosts != nil {
+		for _, host := range *changes.ExtraHosts {
+			if !hostnamePattern.MatchString(host.Hostname) {
+				return fmt.Errorf("service %s: extra_hosts: invalid hostname '%s'", serviceName, host.Hostname)
+			}
+			if host.IP != "host-gateway" && net.ParseIP(host.IP) == nil {
+				return fmt.Errorf("service %s: extra_hosts: invalid address '%s' for %s", serviceName, host.IP, host.Hostname)
+			}
+		}
+	}
+
+	if changes.Devices != nil {
+		for _, device := range *changes.Devices {
+			if err := validateDevice(device); err != nil {
+				return fmt.Errorf("service %s: devices: %w", serviceName, err)
+			}
+		}
+	}
+
+	for key := range changes.Sysctls {
+		if !sysctlKeyPattern.MatchString(key) {
+			return fmt.Errorf("service %s: sysctls: invalid key '%s'", serviceName, key)
+		}
+	}
+
+	if changes.User != nil && *changes.User != "" && !userPattern.MatchString(*changes.User) {
+		return fmt.Errorf("service %s: user: '%s' must be user, uid, user:group or uid:gid", serviceName, *changes.User)
+	}
+
+	if changes.WorkingDir != nil && *changes.WorkingDir != "" && !isAbsoluteContainerPath(*changes.WorkingDir) {
+		return fmt.Errorf("service %s: working_dir: '%s' must be an absolute path", serviceName, *changes.WorkingDir)
+	}
+
+	if changes.EnvFile != nil {
+		for _, envFile := range *changes.EnvFile {
+			if strings.TrimSpace(envFile.Path) == "" || strings.ContainsRune(envFile.Path, 0) {
+				return fmt.Errorf("service %s: env_file: path is required", serviceName)
+			}
+			if envFile.Format != "" && envFile.Format != "raw" {
+				return fmt.Errorf("service %s: env_file: unsupported format '%s'", serviceName, envFile.Format)
+			}
+		}
+	}
+
+	if changes.SecurityOpt != nil {
+		for _, opt := range *changes.SecurityOpt {
+			if opt == "" || strings.ContainsAny(opt, " \t\n") {
+				return fmt.Errorf("service %s: security_opt: invalid option '%s'", serviceName, opt)
+			}
+		}
+	}
+
+	if changes.Tmpfs != nil {
+		for _, tmpfs := range *changes.Tmpfs {
+			target, _, _ := strings.Cut(tmpfs, ":")
+			if !isAbsoluteContainerPath(target) {
+				return fmt.Errorf("service %s: tmpfs: '%s' must start with an absolute path", serviceName, tmpfs)
+			}
+		}
 	}
 
+	if changes.StopGracePeriod != nil && *changes.StopGracePeriod != "" {
+		if _, err := str2duration.ParseDuration(*changes.StopGracePeriod); err != nil {
+			return fmt.Errorf("service %s: stop_grace_period: invalid duration '%s'", serviceName, *changes.StopGracePeriod)
+		}
+	}
+
+	if changes.Profiles != nil {
+		for _, profile := range *changes.Profiles {
+			if !profileNamePattern.MatchString(profile) {
+				return fmt.Errorf("service %s: profiles: invalid profile name '%s'", serviceName, profile)
+			}
+		}
+	}
+
+	if err := validateServiceFileReferences(changes.Secrets); err != nil {
+		return fmt.Errorf("service %s: secrets: %w", serviceName, err)
+	}
+	if err := validateServiceFileReferences(changes.Configs); err != nil {
+		return fmt.Errorf("service %s: configs: %w", serviceName, err)
+	}
+
+	return nil
+}
+
+func validateCapabilities(capabilities *[]string) error {
+	if capabilities == nil {
+		return nil
+	}
+	for _, capability := range *capabilities {
+		if capability != "ALL" && !capabilityPattern.MatchString(capability) {
+			return fmt.Errorf("invalid capability '%s'", capability)
+		}
+	}
+	return nil
+}
+
+func validateUlimit(name string, ulimit *UlimitConfig) error {
+	if !ulimitNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid ulimit name '%s'", name)
+	}
+	if ulimit == nil {
+		return nil
+	}
+
+	if ulimit.Single != nil {
+		if ulimit.Soft != nil || ulimit.Hard != nil {
+			return fmt.Errorf("%s: set either a single value or soft and hard limits", name)
+		}
+		if *ulimit.Single < -1 {
+			return fmt.Errorf("%s: value must be -1 (unlimited) or greater", name)
+		}
+		return nil
+	}
+
+	if ulimit.Soft == nil || ulimit.Hard == nil {
+		return fmt.Errorf("%s: both soft and hard limits are required", name)
+	}
+	if *ulimit.Soft < -1 || *ulimit.Hard < -1 {
+		return fmt.Errorf("%s: limits must be -1 (unlimited) or greater", name)
+	}
+	if *ulimit.Hard != -1 && (*ulimit.Soft == -1 || *ulimit.Soft > *ulimit.Hard) {
+		return fmt.Errorf("%s: soft limit cannot exceed the hard limit", name)
+	}
+	return nil
+}
+
+func validateDevice(device DeviceMapping) error {
+	if !isAbsoluteContainerPath(device.Source) || strings.Contains(device.Source, ":") {
+		return fmt.Errorf("invalid device source '%s'", device.Source)
+	}
+	if device.Target != "" && (!isAbsoluteContainerPath(device.Target) || strings.Contains(device.Target, ":")) {
+		return fmt.Errorf("invalid device target '%s'", device.Target)
+	}
+	if strings.Trim(device.Permissions, "rwm") != "" {
+		return fmt.Errorf("invalid permissions '%s' for device %s: use a combination of r, w and m", device.Permissions, device.Source)
+	}
 	return nil
 }
+
+func validateServiceFileReferences(refs *[]ServiceFileReference) error {
+	if refs == nil {
+		return nil
+	}
+	for _, ref := range *refs {
+		if !resourceRefPattern.MatchString(ref.Source) {
+			return fmt.Errorf("invalid source '%s'", ref.Source)
+		}
+		if ref.Mode != nil && *ref.Mode > 0o7777 {
+			return fmt.Errorf("%s: invalid mode %o", ref.Source, *ref.Mode)
+		}
+	}
+	return nil
+}
+
+func isAbsoluteContainerPath(path string) bool {
+	return strings.HasPrefix(path, "/") && !strings.ContainsAny(path, "\x00\n")
+}
@@ -64,6 +64,22 @@ func (s *Service) setYamlNode(node *yaml.Node, key string, valueNode *yaml.Node)
 	node.Content = append(node.Content, keyNode, valueNode)
 }
 
+func (s *Service) setOrDeleteYamlString(node *yaml.Node, key, value string) {
+	if value == "" {
+		s.deleteYamlKey(node, key)
+		return
+	}
+	s.setYamlNode(node, key, createStringNode(value))
+}
+
+func (s *Service) setOrDeleteYamlList(node *yaml.Node, key string, values []string) {
+	if len(values) == 0 {
+		s.deleteYamlKey(node, key)
+		return
+	}
+	s.setYamlNode(node, key, s.buildStringListNode(values))
+}
+
 func (s *Service) deleteYamlKey(node *yaml.Node, key string) {
 	if node == nil || node.Kind != yaml.MappingNode {
 		return
@@ -128,6 +144,10 @@ func createScalarNode(value string) *yaml.Node {
 	return &yaml.Node{Kind: yaml.ScalarNode, Value: value}
 }
 
+func createStringNode(value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Value: value, Tag: "!!str"}
+}
+
 func createNullNode() *yaml.Node {
 	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null"}
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"github.com/tech-arch1tect/berth-agent/internal/logging"
+	"io"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/build"
@@ -20,8 +21,11 @@ import (
 const (
 	LabelComposeProject         = "com.docker.compose.project"
 	LabelComposeService         = "com.docker.compose.service"
+	LabelComposeVolume          = "com.docker.compose.volume"
 	LabelComposeContainerNumber = "com.docker.compose.container-number"
 	LabelComposeWorkingDir      = "com.docker.compose.project.working_dir"
+	LabelComposeConfigHash      = "com.docker.compose.config-hash"
+	LabelComposeOneoff          = "com.docker.compose.oneoff"
 )
 
 type Client struct {
@@ -181,6 +185,14 @@ func (c *Client) SystemDiskUsage(ctx context.Context) (types.DiskUsage, error) {
 	return diskUsage, nil
 }
 
+func (c *Client) VolumeDiskUsage(ctx context.Context) ([]*volume.Volume, error) {
+	diskUsage, err := c.cli.DiskUsage(ctx, types.DiskUsageOptions{Types: []types.DiskUsageObject{types.VolumeObject}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get volume disk usage: %w", err)
+	}
+	return diskUsage.Volumes, nil
+}
+
 func (c *Client) ImageInspect(ctx context.Context, imageID string) (image.InspectResponse, error) {
 	imageInfo, _, err := c.cli.ImageInspectWithRaw(ctx, imageID)
 	if err != nil {
@@ -319,6 +331,22 @@ func (c *Client) ImageRemove(ctx context.Context, imageID string) ([]image.Delet
 	return responses, nil
 }
 
+func (c *Client) ImageSave(ctx context.Context, imageRefs []string) (io.ReadCloser, error) {
+	reader, err := c.cli.ImageSave(ctx, imageRefs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save images %v: %w", imageRefs, err)
+	}
+	return reader, nil
+}
+
+func (c *Client) ImageLoad(ctx context.Context, input io.Reader) (io.ReadCloser, error) {
+	response, err := c.cli.ImageLoad(ctx, input, client.ImageLoadWithQuiet(true))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load images: %w", err)
+	}
+	return response.Body, nil
+}
+
 func (c *Client) ContainerStop(ctx context.Context, containerID string) error {
 	if err := c.cli.ContainerStop(ctx, containerID, container.StopOptions{}); err != nil {
 		return fmt.Errorf("failed to stop container %s: %w", containerID, err)
@@ -345,6 +373,32 @@ func (c *Client) ContainerRemove(ctx context.Context, containerID string, remove
 	return nil
 }
 
+func (c *Client) ContainerStatPath(ctx context.Context, containerID, path string) (container.PathStat, error) {
+	stat, err := c.cli.ContainerStatPath(ctx, containerID, path)
+	if err != nil {
+		return container.PathStat{}, fmt.Errorf("failed to stat %s in container %s: %w", path, containerID, err)
+	}
+	return stat, nil
+}
+
+func (c *Client) CopyFromContainer(ctx context.Context, containerID, srcPath string) (io.ReadCloser, error) {
+	reader, _, err := c.cli.CopyFromContainer(ctx, containerID, srcPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy %s from container %s: %w", srcPath, containerID, err)
+	}
+	return reader, nil
+}
+
+func (c *Client) CopyToContainer(ctx context.Context, containerID, dstPath string, content io.Reader, copyUIDGID bool) error {
+	err := c.cli.CopyToContainer(ctx, containerID, dstPath, content, container.CopyToContainerOptions{
+		CopyUIDGID: copyUIDGID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to copy to %s in container %s: %w", dstPath, containerID, err)
+	}
+	return nil
+}
+
 func (c *Client) VolumeRemove(ctx context.Context, volumeID string) error {
 	err := c.cli.VolumeRemove(ctx, volumeID, false)
 	if err != nil {
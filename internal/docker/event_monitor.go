@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"io"
 	"os/exec"
+	"strconv"
 	"strings"
 	"time"
 
@@ -17,11 +18,18 @@ import (
 type EventMonitor struct {
 	hub           *websocket.Hub
 	stackLocation string
+	recorder      StatusRecorder
+	oomKilled     map[string]bool
 	ctx           context.Context
 	cancel        context.CancelFunc
 	logger        *logging.Logger
 }
 
+type StatusRecorder interface {
+	RecordContainerStatus(event websocket.ContainerStatusEvent, exitCode *int, oomKilled bool)
+	RecordStackStatus(event websocket.StackStatusEvent)
+}
+
 type DockerEvent struct {
 	Type     string           `json:"Type"`
 	Action   string           `json:"Action"`
@@ -35,12 +43,14 @@ type DockerEventActor struct {
 	Attributes map[string]string `json:"Attributes"`
 }
 
-func NewEventMonitor(hub *websocket.Hub, stackLocation string, logger *logging.Logger) *EventMonitor {
+func NewEventMonitor(hub *websocket.Hub, stackLocation string, recorder StatusRecorder, logger *logging.Logger) *EventMonitor {
 	ctx, cancel := context.WithCancel(context.Background())
 	logger.Debug("creating docker event monitor", zap.String("stack_location", stackLocation))
 	return &EventMonitor{
 		hub:           hub,
 		stackLocation: stackLocation,
+		recorder:      recorder,
+		oomKilled:     make(map[string]bool),
 		ctx:           ctx,
 		cancel:        cancel,
 		logger:        logger,
@@ -156,9 +166,36 @@ func (em *EventMonitor) handleDockerEvent(event DockerEvent) {
 
 	em.hub.BroadcastContainerStatus(containerEvent)
 
+	em.recordContainerStatus(event, containerEvent)
+
 	em.checkAndBroadcastStackStatus(stackName)
 }
 
+func (em *EventMonitor) recordContainerStatus(event DockerEvent, containerEvent websocket.ContainerStatusEvent) {
+	if em.recorder == nil {
+		return
+	}
+
+	switch {
+	case event.Action == "oom":
+		em.oomKilled[event.Actor.ID] = true
+	case event.Action == "die":
+		var exitCode *int
+		if value, err := strconv.Atoi(event.Actor.Attributes["exitCode"]); err == nil {
+			exitCode = &value
+		}
+		oomKilled := em.oomKilled[event.Actor.ID]
+		delete(em.oomKilled, event.Actor.ID)
+		em.recorder.RecordContainerStatus(containerEvent, exitCode, oomKilled)
+	case event.Action == "destroy":
+		delete(em.oomKilled, event.Actor.ID)
+		em.recorder.RecordContainerStatus(containerEvent, nil, false)
+	case event.Action == "start", event.Action == "pause", event.Action == "unpause",
+		containerEvent.Health == "healthy", containerEvent.Health == "unhealthy":
+		em.recorder.RecordContainerStatus(containerEvent, nil, false)
+	}
+}
+
 func (em *EventMonitor) parseContainerName(containerName string) (stackName, serviceName string) {
 	parts := strings.Split(containerName, "-")
 	if len(parts) < 3 {
@@ -276,6 +313,10 @@ func (em *EventMonitor) checkAndBroadcastStackStatus(stackName string) {
 		}
 
 		em.hub.BroadcastStackStatus(stackEvent)
+
+		if em.recorder != nil {
+			em.recorder.RecordStackStatus(stackEvent)
+		}
 	}()
 }
 
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"os"
 
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/mount"
@@ -19,9 +20,27 @@ type ContainerRunSpec struct {
 	Mounts     []mount.Mount
 	Labels     map[string]string
 	WorkingDir string
+	User       string
 }
 
-func (c *Client) RunContainer(ctx context.Context, spec ContainerRunSpec, stdout, stderr io.Writer) (int, error) {
+func (c *Client) HelperImage(ctx context.Context, configured, setting string) (string, error) {
+	if configured != "" {
+		return configured, nil
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		return "", fmt.Errorf("could not read the agent's hostname to discover its container image: %w; set %s explicitly", err, setting)
+	}
+
+	info, err := c.cli.ContainerInspect(ctx, hostname)
+	if err != nil {
+		return "", fmt.Errorf("could not inspect the agent's own container %q to discover its image: %w; set %s explicitly", hostname, err, setting)
+	}
+	return info.Image, nil
+}
+
+func (c *Client) CreateContainer(ctx context.Context, spec ContainerRunSpec) (string, error) {
 	created, err := c.cli.ContainerCreate(ctx,
 		&container.Config{
 			Image:      spec.Image,
@@ -30,15 +49,23 @@ func (c *Client) RunContainer(ctx context.Context, spec ContainerRunSpec, stdout
 			Env:        spec.Env,
 			Labels:     spec.Labels,
 			WorkingDir: spec.WorkingDir,
+			User:       spec.User,
 		},
 		&container.HostConfig{
 			Mounts: spec.Mounts,
 		},
 		nil, nil, "")
 	if err != nil {
-		return -1, fmt.Errorf("failed to create container from image %s: %w", spec.Image, err)
+		return "", fmt.Errorf("failed to create container from image %s: %w", spec.Image, err)
+	}
+	return created.ID, nil
+}
+
+func (c *Client) RunContainer(ctx context.Context, spec ContainerRunSpec, stdout, stderr io.Writer) (int, error) {
+	containerID, err := c.CreateContainer(ctx, spec)
+	if err != nil {
+		return -1, err
 	}
-	containerID := created.ID
 
 	defer func() {
 		removeCtx := context.WithoutCancel(ctx)
@@ -50,6 +77,10 @@ func (c *Client) RunContainer(ctx context.Context, spec ContainerRunSpec, stdout
 		}
 	}()
 
+	return c.RunCreatedContainer(ctx, containerID, stdout, stderr)
+}
+
+func (c *Client) RunCreatedContainer(ctx context.Context, containerID string, stdout, stderr io.Writer) (int, error) {
 	attached, err := c.cli.ContainerAttach(ctx, containerID, container.AttachOptions{
 		Stream: true,
 		Stdout: true,
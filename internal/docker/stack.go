@@ -0,0 +1,28 @@
+package docker
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+var ErrContainerStackMismatch = errors.New("stack mismatch")
+
+func ValidateContainerStack(inspect container.InspectResponse, expectedStackName string) error {
+	if inspect.Config == nil {
+		return fmt.Errorf("%w: container has no Docker Compose project label", ErrContainerStackMismatch)
+	}
+
+	actualStackName, exists := inspect.Config.Labels[LabelComposeProject]
+	if !exists {
+		return fmt.Errorf("%w: container has no Docker Compose project label", ErrContainerStackMismatch)
+	}
+
+	if actualStackName != expectedStackName {
+		return fmt.Errorf("%w: container belongs to '%s' but claimed stack is '%s'",
+			ErrContainerStackMismatch, actualStackName, expectedStackName)
+	}
+
+	return nil
+}
@@ -0,0 +1,167 @@
+package files
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/tech-arch1tect/berth-agent/internal/archive"
+	"github.com/tech-arch1tect/berth-agent/internal/logging"
+
+	"go.uber.org/zap"
+)
+
+const (
+	ArchiveFormatZip   = "zip"
+	ArchiveFormatTar   = "tar"
+	ArchiveFormatTarGz = "tar.gz"
+)
+
+var archiveContentTypes = map[string]string{
+	ArchiveFormatZip:   "application/zip",
+	ArchiveFormatTar:   "application/x-tar",
+	ArchiveFormatTarGz: "application/gzip",
+}
+
+type ArchiveDownload struct {
+	Path        string
+	Format      string
+	Filename    string
+	ContentType string
+	FileCount   int
+	TotalSize   int64
+
+	stackName string
+	basePath  string
+	entry     string
+	excludes  []string
+}
+
+func (s *Service) PrepareArchive(stackName, path, format string) (*ArchiveDownload, error) {
+	if format == "" {
+		format = ArchiveFormatZip
+	}
+	contentType, ok := archiveContentTypes[format]
+	if !ok {
+		return nil, fmt.Errorf("unsupported archive format '%s': expected zip, tar or tar.gz", format)
+	}
+
+	realStackPath, err := s.validateStackPath(stackName, "")
+	if err != nil {
+		return nil, err
+	}
+	fullPath, err := s.validateStackPath(stackName, path)
+	if err != nil {
+		return nil, err
+	}
+
+	stat, err := os.Stat(fullPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("directory not found: %s", path)
+		}
+		return nil, fmt.Errorf("cannot access directory: %w", err)
+	}
+	if !stat.IsDir() {
+		return nil, fmt.Errorf("path is not a directory: %s", path)
+	}
+
+	entry := filepath.Base(fullPath)
+	name := entry
+	if fullPath == realStackPath {
+		name = stackName
+	}
+
+	download := &ArchiveDownload{
+		Path:        path,
+		Format:      format,
+		Filename:    name + "." + format,
+		ContentType: contentType,
+		stackName:   stackName,
+		basePath:    filepath.Dir(fullPath),
+		entry:       entry,
+		excludes:    []string{filepath.Join(entry, ".berth")},
+	}
+
+	err = filepath.WalkDir(fullPath, func(walkPath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() && walkPath == filepath.Join(download.basePath, download.excludes[0]) {
+			return filepath.SkipDir
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		download.FileCount++
+		download.TotalSize += info.Size()
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot read directory: %w", err)
+	}
+
+	if s.maxDownload > 0 && download.TotalSize > s.maxDownload {
+		s.logger.Warn("directory too large to download",
+			zap.String("operation", "download_archive"),
+			zap.String("stack", stackName),
+			zap.String("path", path),
+			zap.Int64("size", download.TotalSize),
+			zap.Int64("max_size", s.maxDownload),
+		)
+		return nil, fmt.Errorf("directory too large (>%dMB)", s.maxDownload/(1024*1024))
+	}
+
+	return download, nil
+}
+
+func (s *Service) WriteArchive(ctx context.Context, download *ArchiveDownload, out io.Writer) error {
+	opts := archive.CreateOptions{
+		Format:          download.Format,
+		IncludePaths:    []string{download.entry},
+		ExcludePatterns: download.excludes,
+	}
+
+	progress := &archiveLogWriter{
+		logger: s.logger.With(
+			zap.String("operation", "download_archive"),
+			zap.String("stack", download.stackName),
+			zap.String("path", download.Path),
+		),
+	}
+
+	if err := s.archive.StreamArchive(ctx, download.basePath, opts, out, progress); err != nil {
+		progress.logger.Error("archive download interrupted", zap.Error(err))
+		return err
+	}
+
+	progress.logger.Info("archive downloaded",
+		zap.String("format", download.Format),
+		zap.Int("file_count", download.FileCount),
+		zap.Int64("size", download.TotalSize),
+	)
+	return nil
+}
+
+type archiveLogWriter struct {
+	logger *logging.Logger
+}
+
+func (w *archiveLogWriter) WriteMessage(msgType string, data string) {
+	w.logger.Debug(data, zap.String("type", msgType))
+}
+
+func (w *archiveLogWriter) WriteError(data string) {
+	w.logger.Warn(data)
+}
+
+func (w *archiveLogWriter) WriteStdout(data string) {
+	w.logger.Debug(data)
+}
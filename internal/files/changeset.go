@@ -0,0 +1,448 @@
+package files
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/tech-arch1tect/berth-agent/internal/revisions"
+
+	"go.uber.org/zap"
+)
+
+const (
+	changesetDirName       = ".berth/changesets"
+	maxChangesetOperations = 500
+)
+
+const (
+	ChangesetOpWrite  = "write"
+	ChangesetOpDelete = "delete"
+	ChangesetOpRename = "rename"
+	ChangesetOpChmod  = "chmod"
+)
+
+type changesetStep struct {
+	op      ChangesetOperation
+	content []byte
+	mode    os.FileMode
+	staged  string
+	backup  string
+	delta   int64
+	tracked bool
+}
+
+func (s *Service) ApplyChangeset(stackName string, req ChangesetRequest, actor revisions.Actor) (*ChangesetResult, error) {
+	if len(req.Operations) == 0 {
+		return nil, errors.New("changeset contains no operations")
+	}
+	if len(req.Operations) > maxChangesetOperations {
+		return nil, fmt.Errorf("changeset exceeds the limit of %d operations", maxChangesetOperations)
+	}
+
+	realStackPath, err := s.validateStackPath(stackName, "")
+	if err != nil {
+		return nil, err
+	}
+
+	s.changesetMu.Lock()
+	defer s.changesetMu.Unlock()
+
+	id, err := newUploadID()
+	if err != nil {
+		return nil, err
+	}
+
+	workDir := filepath.Join(realStackPath, changesetDirName, id)
+	if err := os.MkdirAll(workDir, 0700); err != nil {
+		return nil, fmt.Errorf("cannot create changeset staging directory: %w", err)
+	}
+	keepWorkDir := false
+	defer func() {
+		if keepWorkDir {
+			return
+		}
+		if err := os.RemoveAll(workDir); err != nil {
+			s.logger.Warn("cannot remove changeset staging directory",
+				zap.String("operation", "changeset"),
+				zap.String("stack", stackName),
+				zap.String("changeset_id", id),
+				zap.Error(err),
+			)
+		}
+	}()
+
+	steps := make([]*changesetStep, len(req.Operations))
+	for i, op := range req.Operations {
+		step, err := s.stageChangesetOperation(stackName, realStackPath, workDir, i, op)
+		if err != nil {
+			s.logger.Error("changeset validation failed",
+				zap.String("operation", "changeset"),
+				zap.String("stack", stackName),
+				zap.String("changeset_id", id),
+				zap.Int("index", i),
+				zap.String("op", op.Op),
+				zap.String("path", op.Path),
+				zap.Error(err),
+			)
+			return nil, fmt.Errorf("operation %d (%s %s): %w", i+1, op.Op, op.Path, err)
+		}
+		steps[i] = step
+	}
+
+	var trackedPaths []string
+	for _, step := range steps {
+		if step.tracked {
+			trackedPaths = append(trackedPaths, step.op.Path)
+		}
+	}
+	unlock := s.revisions.LockFiles(stackName, trackedPaths...)
+	defer unlock()
+
+	var delta int64
+	for _, step := range steps {
+		delta += step.delta
+	}
+	release, err := s.reserveQuota(stackName, delta)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	for _, step := range steps {
+		if !step.tracked {
+			continue
+		}
+		if err := s.revisions.Capture(stackName, step.op.Path); err != nil {
+			s.logger.Error("cannot preserve previous version",
+				zap.String("operation", "changeset"),
+				zap.String("stack", stackName),
+				zap.String("path", step.op.Path),
+				zap.Error(err),
+			)
+			return nil, fmt.Errorf("cannot preserve previous version of %s: %w", step.op.Path, err)
+		}
+	}
+
+	s.trashMu.Lock()
+	defer s.trashMu.Unlock()
+
+	result := &ChangesetResult{
+		ID:         id,
+		Operations: make([]ChangesetOperationResult, 0, len(steps)),
+	}
+
+	journal, err := openChangesetJournal(realStackPath, workDir)
+	if err != nil {
+		return nil, err
+	}
+	defer journal.close()
+
+	for i, step := range steps {
+		opResult, err := s.applyChangesetStep(stackName, realStackPath, step, journal, actor)
+		if err != nil {
+			rollbackErr := journal.rollback()
+			if rollbackErr != nil {
+				keepWorkDir = true
+				s.logger.Error("changeset rollback incomplete",
+					zap.String("operation", "changeset"),
+					zap.String("stack", stackName),
+					zap.String("changeset_id", id),
+					zap.Int("index", i),
+					zap.Error(rollbackErr),
+				)
+				return nil, fmt.Errorf("operation %d (%s %s): %w; rollback incomplete: %v", i+1, step.op.Op, step.op.Path, err, rollbackErr)
+			}
+
+			s.logger.Error("changeset rolled back",
+				zap.String("operation", "changeset"),
+				zap.String("stack", stackName),
+				zap.String("changeset_id", id),
+				zap.Int("index", i),
+				zap.String("op", step.op.Op),
+				zap.String("path", step.op.Path),
+				zap.Error(err),
+			)
+			return nil, fmt.Errorf("operation %d (%s %s): %w", i+1, step.op.Op, step.op.Path, err)
+		}
+		result.Operations = append(result.Operations, opResult)
+	}
+
+	if err := journal.commit(); err != nil {
+		s.logger.Warn("cannot mark changeset as committed",
+			zap.String("operation", "changeset"),
+			zap.String("stack", stackName),
+			zap.String("changeset_id", id),
+			zap.Error(err),
+		)
+	}
+
+	for _, step := range steps {
+		if !step.tracked {
+			continue
+		}
+		if _, err := s.revisions.Record(stackName, step.op.Path, step.content, actor, revisions.SourceFileEditor); err != nil {
+			s.logger.Warn("cannot record file revision",
+				zap.String("operation", "changeset"),
+				zap.String("stack", stackName),
+				zap.String("path", step.op.Path),
+				zap.Error(err),
+			)
+		}
+	}
+
+	s.recordUsage(stackName, delta)
+	result.Applied = len(result.Operations)
+
+	s.logger.Info("changeset applied",
+		zap.String("operation", "changeset"),
+		zap.String("stack", stackName),
+		zap.String("changeset_id", id),
+		zap.Int("operations", result.Applied),
+	)
+
+	return result, nil
+}
+
+func (s *Service) stageChangesetOperation(stackName, realStackPath, workDir string, index int, op ChangesetOperation) (*changesetStep, error) {
+	if op.Path == "" {
+		return nil, errors.New("path is required")
+	}
+
+	fullPath, err := s.validateChangesetPath(stackName, realStackPath, op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	step := &changesetStep{
+		op:     op,
+		backup: filepath.Join(workDir, "backup-"+strconv.Itoa(index)),
+	}
+
+	switch op.Op {
+	case ChangesetOpWrite:
+		if info, err := os.Stat(fullPath); err == nil && info.IsDir() {
+			return nil, errors.New("path is a directory")
+		}
+
+		if op.Encoding == "base64" {
+			decoded, err := base64.StdEncoding.DecodeString(op.Content)
+			if err != nil {
+				return nil, fmt.Errorf("invalid base64 content: %w", err)
+			}
+			step.content = decoded
+		} else {
+			step.content = []byte(op.Content)
+		}
+
+		step.mode = 0644
+		if op.Mode != nil {
+			parsed, err := parseFileMode(*op.Mode)
+			if err != nil {
+				return nil, fmt.Errorf("invalid file mode: %w", err)
+			}
+			step.mode = parsed
+		}
+
+		step.staged = filepath.Join(workDir, "staged-"+strconv.Itoa(index))
+		if err := os.WriteFile(step.staged, step.content, step.mode); err != nil {
+			return nil, fmt.Errorf("cannot stage file: %w", err)
+		}
+
+		if op.OwnerID != nil || op.GroupID != nil {
+			uid, gid := -1, -1
+			if op.OwnerID != nil {
+				uid = int(*op.OwnerID)
+			}
+			if op.GroupID != nil {
+				gid = int(*op.GroupID)
+			}
+			if err := os.Chown(step.staged, uid, gid); err != nil {
+				return nil, fmt.Errorf("cannot change ownership: %w", err)
+			}
+		}
+
+		step.delta = int64(len(step.content)) - existingFileSize(fullPath)
+		step.tracked = revisions.IsTracked(op.Path)
+
+	case ChangesetOpDelete:
+		if info, err := os.Lstat(fullPath); err == nil && (op.Permanent || !s.trashable(realStackPath, fullPath)) {
+			step.delta = -pathSize(fullPath, info)
+		}
+
+	case ChangesetOpRename:
+		if op.NewPath == "" {
+			return nil, errors.New("new_path is required")
+		}
+		if _, err := s.validateChangesetPath(stackName, realStackPath, op.NewPath); err != nil {
+			return nil, fmt.Errorf("invalid destination path: %w", err)
+		}
+
+	case ChangesetOpChmod:
+		if op.Mode == nil {
+			return nil, errors.New("mode is required")
+		}
+		parsed, err := parseFileMode(*op.Mode)
+		if err != nil {
+			return nil, fmt.Errorf("invalid file mode '%s': %w", *op.Mode, err)
+		}
+		step.mode = parsed
+
+	default:
+		return nil, fmt.Errorf("unsupported operation '%s'", op.Op)
+	}
+
+	return step, nil
+}
+
+func (s *Service) applyChangesetStep(stackName, realStackPath string, step *changesetStep, journal *changesetJournal, actor revisions.Actor) (ChangesetOperationResult, error) {
+	op := step.op
+	opResult := ChangesetOperationResult{Op: op.Op, Path: op.Path, NewPath: op.NewPath}
+
+	fullPath, err := s.validateChangesetPath(stackName, realStackPath, op.Path)
+	if err != nil {
+		return opResult, err
+	}
+
+	switch op.Op {
+	case ChangesetOpWrite:
+		info, err := os.Lstat(fullPath)
+		if err != nil && !os.IsNotExist(err) {
+			return opResult, fmt.Errorf("cannot access path: %w", err)
+		}
+		if err == nil && info.IsDir() {
+			return opResult, errors.New("path is a directory")
+		}
+
+		if err := ensureChangesetParent(filepath.Dir(fullPath), journal); err != nil {
+			return opResult, err
+		}
+
+		if info != nil {
+			if err := journal.undoRename(step.backup, fullPath); err != nil {
+				return opResult, err
+			}
+			if err := os.Rename(fullPath, step.backup); err != nil {
+				return opResult, fmt.Errorf("cannot preserve existing file: %w", err)
+			}
+		} else if err := journal.undoRemove(fullPath); err != nil {
+			return opResult, err
+		}
+
+		if err := os.Rename(step.staged, fullPath); err != nil {
+			return opResult, fmt.Errorf("cannot move file into place: %w", err)
+		}
+		return opResult, nil
+
+	case ChangesetOpDelete:
+		info, err := os.Lstat(fullPath)
+		if os.IsNotExist(err) {
+			return opResult, nil
+		}
+		if err != nil {
+			return opResult, fmt.Errorf("cannot access path: %w", err)
+		}
+
+		if !op.Permanent && s.trashable(realStackPath, fullPath) {
+			item, err := s.moveToTrashLocked(stackName, realStackPath, fullPath, info, actor)
+			if err != nil {
+				return opResult, err
+			}
+			opResult.TrashID = item.ID
+			itemDir := filepath.Join(realStackPath, trashDirName, item.ID)
+			if err := journal.undoRemoveAll(itemDir); err != nil {
+				return opResult, err
+			}
+			return opResult, journal.undoRename(filepath.Join(itemDir, trashDataName), fullPath)
+		}
+
+		if err := journal.undoRename(step.backup, fullPath); err != nil {
+			return opResult, err
+		}
+		if err := os.Rename(fullPath, step.backup); err != nil {
+			return opResult, fmt.Errorf("cannot delete: %w", err)
+		}
+		return opResult, nil
+
+	case ChangesetOpRename:
+		newFullPath, err := s.validateChangesetPath(stackName, realStackPath, op.NewPath)
+		if err != nil {
+			return opResult, fmt.Errorf("invalid destination path: %w", err)
+		}
+		if _, err := os.Lstat(fullPath); err != nil {
+			return opResult, fmt.Errorf("source path not found: %s", op.Path)
+		}
+		if _, err := os.Lstat(newFullPath); err == nil {
+			return opResult, fmt.Errorf("destination path already exists: %s", op.NewPath)
+		}
+
+		if err := ensureChangesetParent(filepath.Dir(newFullPath), journal); err != nil {
+			return opResult, err
+		}
+
+		if err := journal.undoRename(newFullPath, fullPath); err != nil {
+			return opResult, err
+		}
+		if err := os.Rename(fullPath, newFullPath); err != nil {
+			return opResult, fmt.Errorf("cannot rename: %w", err)
+		}
+		return opResult, nil
+
+	case ChangesetOpChmod:
+		info, err := os.Stat(fullPath)
+		if err != nil {
+			return opResult, fmt.Errorf("path not found: %s", op.Path)
+		}
+		if err := journal.undoChmod(fullPath, info.Mode().Perm()); err != nil {
+			return opResult, err
+		}
+		if err := os.Chmod(fullPath, step.mode); err != nil {
+			return opResult, fmt.Errorf("cannot change permissions: %w", err)
+		}
+		return opResult, nil
+	}
+
+	return opResult, fmt.Errorf("unsupported operation '%s'", op.Op)
+}
+
+func (s *Service) validateChangesetPath(stackName, realStackPath, path string) (string, error) {
+	fullPath, err := s.validateStackPath(stackName, path)
+	if err != nil {
+		return "", err
+	}
+	if fullPath == realStackPath || isWithinDirectory(fullPath, filepath.Join(realStackPath, ".berth")) {
+		return "", errors.New("cannot modify the stack root or the .berth directory")
+	}
+	return fullPath, nil
+}
+
+func ensureChangesetParent(dir string, journal *changesetJournal) error {
+	var missing []string
+	for current := dir; ; current = filepath.Dir(current) {
+		if _, err := os.Lstat(current); err == nil {
+			break
+		}
+		missing = append(missing, current)
+		if filepath.Dir(current) == current {
+			break
+		}
+	}
+
+	if len(missing) == 0 {
+		return nil
+	}
+
+	for i := len(missing) - 1; i >= 0; i-- {
+		if err := journal.undoRemove(missing[i]); err != nil {
+			return err
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("cannot create directory: %w", err)
+	}
+	return nil
+}
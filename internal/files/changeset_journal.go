@@ -0,0 +1,268 @@
+package files
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"go.uber.org/zap"
+)
+
+const changesetJournalName = "journal.jsonl"
+
+const (
+	changesetUndoRename    = "rename"
+	changesetUndoRemove    = "remove"
+	changesetUndoRemoveAll = "remove_all"
+	changesetUndoChmod     = "chmod"
+	changesetCommitted     = "commit"
+)
+
+type changesetJournalEntry struct {
+	Action string      `json:"action"`
+	From   string      `json:"from,omitempty"`
+	To     string      `json:"to,omitempty"`
+	Path   string      `json:"path,omitempty"`
+	Mode   os.FileMode `json:"mode,omitempty"`
+}
+
+type changesetJournal struct {
+	stackPath string
+	file      *os.File
+	entries   []changesetJournalEntry
+}
+
+func openChangesetJournal(stackPath, workDir string) (*changesetJournal, error) {
+	file, err := os.OpenFile(filepath.Join(workDir, changesetJournalName), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create changeset journal: %w", err)
+	}
+	return &changesetJournal{stackPath: stackPath, file: file}, nil
+}
+
+func (j *changesetJournal) undoRename(from, to string) error {
+	return j.append(changesetJournalEntry{Action: changesetUndoRename, From: j.relative(from), To: j.relative(to)})
+}
+
+func (j *changesetJournal) undoRemove(path string) error {
+	return j.append(changesetJournalEntry{Action: changesetUndoRemove, Path: j.relative(path)})
+}
+
+func (j *changesetJournal) undoRemoveAll(path string) error {
+	return j.append(changesetJournalEntry{Action: changesetUndoRemoveAll, Path: j.relative(path)})
+}
+
+func (j *changesetJournal) undoChmod(path string, mode os.FileMode) error {
+	return j.append(changesetJournalEntry{Action: changesetUndoChmod, Path: j.relative(path), Mode: mode})
+}
+
+func (j *changesetJournal) commit() error {
+	return j.append(changesetJournalEntry{Action: changesetCommitted})
+}
+
+func (j *changesetJournal) rollback() error {
+	return rollbackChangesetEntries(j.stackPath, j.entries)
+}
+
+func (j *changesetJournal) close() {
+	_ = j.file.Close()
+}
+
+func (j *changesetJournal) append(entry changesetJournalEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if _, err := j.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("cannot write changeset journal: %w", err)
+	}
+	if err := j.file.Sync(); err != nil {
+		return fmt.Errorf("cannot sync changeset journal: %w", err)
+	}
+	if entry.Action != changesetCommitted {
+		j.entries = append(j.entries, entry)
+	}
+	return nil
+}
+
+func (j *changesetJournal) relative(path string) string {
+	rel, err := filepath.Rel(j.stackPath, path)
+	if err != nil {
+		return path
+	}
+	return rel
+}
+
+func readChangesetJournal(workDir string) ([]changesetJournalEntry, bool, error) {
+	file, err := os.Open(filepath.Join(workDir, changesetJournalName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	defer file.Close()
+
+	var entries []changesetJournalEntry
+	committed := false
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry changesetJournalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			break
+		}
+		if entry.Action == changesetCommitted {
+			committed = true
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, false, err
+	}
+	return entries, committed, nil
+}
+
+func rollbackChangesetEntries(stackPath string, entries []changesetJournalEntry) error {
+	var errs []error
+	for i := len(entries) - 1; i >= 0; i-- {
+		if err := undoChangesetEntry(stackPath, entries[i]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func undoChangesetEntry(stackPath string, entry changesetJournalEntry) error {
+	resolve := func(rel string) (string, error) {
+		path := filepath.Join(stackPath, rel)
+		if rel == "" || filepath.IsAbs(rel) || path == stackPath || !isWithinDirectory(path, stackPath) {
+			return "", fmt.Errorf("changeset journal path '%s' is outside the stack", rel)
+		}
+		return path, nil
+	}
+
+	switch entry.Action {
+	case changesetUndoRename:
+		from, err := resolve(entry.From)
+		if err != nil {
+			return err
+		}
+		to, err := resolve(entry.To)
+		if err != nil {
+			return err
+		}
+		if _, err := os.Lstat(from); os.IsNotExist(err) {
+			return nil
+		}
+		return os.Rename(from, to)
+
+	case changesetUndoRemove:
+		path, err := resolve(entry.Path)
+		if err != nil {
+			return err
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+
+	case changesetUndoRemoveAll:
+		path, err := resolve(entry.Path)
+		if err != nil {
+			return err
+		}
+		if !isWithinDirectory(path, filepath.Join(stackPath, trashDirName)) {
+			return fmt.Errorf("refusing to remove '%s' outside the trash", entry.Path)
+		}
+		return os.RemoveAll(path)
+
+	case changesetUndoChmod:
+		path, err := resolve(entry.Path)
+		if err != nil {
+			return err
+		}
+		if err := os.Chmod(path, entry.Mode); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	return fmt.Errorf("unknown changeset journal action '%s'", entry.Action)
+}
+
+func (s *Service) recoverChangesets() {
+	entries, err := os.ReadDir(s.stackLocation)
+	if err != nil {
+		s.logger.Warn("failed to list stacks for changeset recovery", zap.Error(err))
+		return
+	}
+
+	s.changesetMu.Lock()
+	defer s.changesetMu.Unlock()
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		s.recoverStackChangesets(entry.Name(), filepath.Join(s.stackLocation, entry.Name()))
+	}
+}
+
+func (s *Service) recoverStackChangesets(stackName, stackPath string) {
+	changesetsPath := filepath.Join(stackPath, changesetDirName)
+	entries, err := os.ReadDir(changesetsPath)
+	if err != nil {
+		return
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		workDir := filepath.Join(changesetsPath, entry.Name())
+
+		journal, committed, err := readChangesetJournal(workDir)
+		if err != nil {
+			s.logger.Error("cannot read interrupted changeset journal",
+				zap.String("operation", "changeset_recovery"),
+				zap.String("stack", stackName),
+				zap.String("changeset_id", entry.Name()),
+				zap.Error(err),
+			)
+			continue
+		}
+
+		if !committed && len(journal) > 0 {
+			if err := rollbackChangesetEntries(stackPath, journal); err != nil {
+				s.logger.Error("cannot roll back interrupted changeset; its backups were kept",
+					zap.String("operation", "changeset_recovery"),
+					zap.String("stack", stackName),
+					zap.String("changeset_id", entry.Name()),
+					zap.String("directory", workDir),
+					zap.Error(err),
+				)
+				continue
+			}
+			s.InvalidateUsage(stackName)
+			s.logger.Warn("rolled back interrupted changeset",
+				zap.String("operation", "changeset_recovery"),
+				zap.String("stack", stackName),
+				zap.String("changeset_id", entry.Name()),
+				zap.Int("steps", len(journal)),
+			)
+		}
+
+		if err := os.RemoveAll(workDir); err != nil {
+			s.logger.Warn("cannot remove leftover changeset directory",
+				zap.String("operation", "changeset_recovery"),
+				zap.String("stack", stackName),
+				zap.String("changeset_id", entry.Name()),
+				zap.Error(err),
+			)
+		}
+	}
+}
@@ -0,0 +1,718 @@
+package files
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/tech-arch1tect/berth-agent/internal/archive"
+	"github.com/tech-arch1tect/berth-agent/internal/docker"
+
+	"go.uber.org/zap"
+)
+
+const (
+	containerListScanLimit  = 256 * 1024 * 1024
+	containerListEntryLimit = 10000
+)
+
+var containerArchiveContentTypes = map[string]string{
+	ArchiveFormatTar:   "application/x-tar",
+	ArchiveFormatTarGz: "application/gzip",
+}
+
+var (
+	ErrContainerNotFound      = errors.New("container not found")
+	ErrContainerNotRunning    = errors.New("container is not running")
+	ErrContainerStackMismatch = docker.ErrContainerStackMismatch
+	ErrContainerPathConflict  = errors.New("destination already exists")
+)
+
+type ContainerDownload struct {
+	Path        string
+	Filename    string
+	ContentType string
+	Size        int64
+	ModTime     time.Time
+	IsDirectory bool
+	Format      string
+
+	stackName   string
+	containerID string
+}
+
+func (s *Service) ListContainerDirectory(ctx context.Context, stackName, containerName, path string) (*ContainerDirectoryListing, error) {
+	containerID, err := s.stackContainer(ctx, stackName, containerName)
+	if err != nil {
+		return nil, err
+	}
+
+	target, stat, err := s.statContainerPath(ctx, containerID, path)
+	if err != nil {
+		return nil, err
+	}
+	if !stat.Mode.IsDir() {
+		return nil, fmt.Errorf("path is not a directory: %s", target)
+	}
+
+	reader, err := s.dockerClient.CopyFromContainer(ctx, containerID, target)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = reader.Close() }()
+
+	prefix := ""
+	if base := filepath.Base(target); base != "/" {
+		prefix = base + "/"
+	}
+
+	listing := &ContainerDirectoryListing{
+		Container: containerName,
+		Path:      target,
+		Entries:   []FileEntry{},
+	}
+
+	limited := &io.LimitedReader{R: reader, N: containerListScanLimit}
+	tarReader := tar.NewReader(limited)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if limited.N <= 0 {
+				listing.Truncated = true
+				break
+			}
+			return nil, fmt.Errorf("cannot read directory: %w", err)
+		}
+
+		name := strings.TrimPrefix(filepath.Clean("/"+header.Name), "/")
+		relative, found := strings.CutPrefix(name, prefix)
+		if !found || relative == "" || strings.Contains(relative, "/") {
+			continue
+		}
+
+		info := header.FileInfo()
+		entry := FileEntry{
+			Name:        relative,
+			Path:        filepath.Join(target, relative),
+			Size:        header.Size,
+			IsDirectory: info.IsDir(),
+			ModTime:     header.ModTime,
+			Mode:        info.Mode().String(),
+			Owner:       header.Uname,
+			Group:       header.Gname,
+			OwnerID:     uint32(header.Uid),
+			GroupID:     uint32(header.Gid),
+		}
+		if !entry.IsDirectory {
+			if ext := filepath.Ext(entry.Name); ext != "" {
+				entry.Extension = strings.ToLower(ext[1:])
+			}
+		}
+		listing.Entries = append(listing.Entries, entry)
+
+		if len(listing.Entries) >= containerListEntryLimit {
+			listing.Truncated = true
+			break
+		}
+	}
+
+	sort.Slice(listing.Entries, func(i, j int) bool {
+		if listing.Entries[i].IsDirectory != listing.Entries[j].IsDirectory {
+			return listing.Entries[i].IsDirectory
+		}
+		return listing.Entries[i].Name < listing.Entries[j].Name
+	})
+
+	s.logger.Debug("listing container directory",
+		zap.String("operation", "list_container_directory"),
+		zap.String("stack", stackName),
+		zap.String("container", containerName),
+		zap.String("path", target),
+		zap.Int("entry_count", len(listing.Entries)),
+		zap.Bool("truncated", listing.Truncated),
+	)
+
+	return listing, nil
+}
+
+func (s *Service) PrepareContainerDownload(ctx context.Context, stackName, containerName, path, format string) (*ContainerDownload, error) {
+	containerID, err := s.stackContainer(ctx, stackName, containerName)
+	if err != nil {
+		return nil, err
+	}
+
+	target, stat, err := s.statContainerPath(ctx, containerID, path)
+	if err != nil {
+		return nil, err
+	}
+
+	download := &ContainerDownload{
+		Path:        target,
+		Filename:    filepath.Base(target),
+		ContentType: "application/octet-stream",
+		Size:        stat.Size,
+		ModTime:     stat.Mtime,
+		stackName:   stackName,
+		containerID: containerID,
+	}
+
+	switch {
+	case stat.Mode.IsDir():
+		if format == "" {
+			format = ArchiveFormatTar
+		}
+		contentType, ok := containerArchiveContentTypes[format]
+		if !ok {
+			return nil, fmt.Errorf("unsupported archive format '%s': expected tar or tar.gz", format)
+		}
+		if download.Filename == "/" {
+			download.Filename = containerName
+		}
+		download.Filename += "." + format
+		download.ContentType = contentType
+		download.IsDirectory = true
+		download.Format = format
+		download.Size = -1
+
+	case stat.Mode.IsRegular():
+		if s.maxDownload > 0 && stat.Size > s.maxDownload {
+			s.logger.Warn("file too large to download",
+				zap.String("operation", "download_container_file"),
+				zap.String("stack", stackName),
+				zap.String("container", containerName),
+				zap.String("path", target),
+				zap.Int64("size", stat.Size),
+				zap.Int64("max_size", s.maxDownload),
+			)
+			return nil, fmt.Errorf("file too large (>%dMB)", s.maxDownload/(1024*1024))
+		}
+
+	default:
+		return nil, fmt.Errorf("path is not a regular file or directory: %s", target)
+	}
+
+	return download, nil
+}
+
+func (s *Service) WriteContainerDownload(ctx context.Context, download *ContainerDownload, out io.Writer) error {
+	reader, err := s.dockerClient.CopyFromContainer(ctx, download.containerID, download.Path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = reader.Close() }()
+
+	if !download.IsDirectory {
+		tarReader := tar.NewReader(reader)
+		header, err := tarReader.Next()
+		if err != nil {
+			return fmt.Errorf("cannot read file: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			return fmt.Errorf("path is not a regular file: %s", download.Path)
+		}
+		written, err := io.Copy(out, io.LimitReader(tarReader, download.Size))
+		if err != nil {
+			return err
+		}
+		if written < download.Size {
+			return errors.New("file changed while it was being read")
+		}
+		return nil
+	}
+
+	source := io.Reader(reader)
+	if s.maxDownload > 0 {
+		source = io.LimitReader(reader, s.maxDownload+1)
+	}
+
+	var sink io.Writer = out
+	var gzipWriter *gzip.Writer
+	if download.Format == ArchiveFormatTarGz {
+		gzipWriter = gzip.NewWriter(out)
+		sink = gzipWriter
+	}
+
+	written, err := io.Copy(sink, source)
+	if err != nil {
+		return err
+	}
+	if s.maxDownload > 0 && written > s.maxDownload {
+		s.logger.Warn("directory too large to download",
+			zap.String("operation", "download_container_file"),
+			zap.String("stack", download.stackName),
+			zap.String("path", download.Path),
+			zap.Int64("max_size", s.maxDownload),
+		)
+		return fmt.Errorf("directory too large (>%dMB)", s.maxDownload/(1024*1024))
+	}
+	if gzipWriter != nil {
+		return gzipWriter.Close()
+	}
+	return nil
+}
+
+func (s *Service) UploadToContainer(ctx context.Context, stackName, containerName string, req ContainerUploadRequest, src io.Reader, size int64) (string, error) {
+	if s.maxUpload > 0 && size > s.maxUpload {
+		return "", fmt.Errorf("file too large (>%dMB)", s.maxUpload/(1024*1024))
+	}
+
+	containerID, err := s.stackContainer(ctx, stackName, containerName)
+	if err != nil {
+		return "", err
+	}
+
+	directory, stat, err := s.statContainerPath(ctx, containerID, req.Path)
+	if err != nil {
+		return "", err
+	}
+	if !stat.Mode.IsDir() {
+		return "", fmt.Errorf("path is not a directory: %s", directory)
+	}
+
+	if req.Extract {
+		if err := s.dockerClient.CopyToContainer(ctx, containerID, directory, src, req.CopyUIDGID); err != nil {
+			return "", err
+		}
+		s.logger.Info("archive extracted into container",
+			zap.String("operation", "upload_container_file"),
+			zap.String("stack", stackName),
+			zap.String("container", containerName),
+			zap.String("path", directory),
+			zap.Int64("size", size),
+		)
+		return directory, nil
+	}
+
+	if req.Filename == "" || req.Filename == "." || req.Filename == ".." || strings.ContainsAny(req.Filename, "/\x00") {
+		return "", fmt.Errorf("invalid filename: %s", req.Filename)
+	}
+
+	fileMode := os.FileMode(0644)
+	if req.Mode != nil {
+		parsed, err := parseFileMode(*req.Mode)
+		if err != nil {
+			return "", fmt.Errorf("invalid file mode: %w", err)
+		}
+		fileMode = parsed
+	}
+
+	staged, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(writeSingleFileTar(writer, req.Filename, fileMode, src, size))
+	}()
+	err = s.dockerClient.CopyToContainer(ctx, containerID, directory, staged, req.CopyUIDGID)
+	_ = staged.CloseWithError(io.ErrClosedPipe)
+	if err != nil {
+		return "", err
+	}
+
+	target := filepath.Join(directory, req.Filename)
+	s.logger.Info("file uploaded into container",
+		zap.String("operation", "upload_container_file"),
+		zap.String("stack", stackName),
+		zap.String("container", containerName),
+		zap.String("path", target),
+		zap.Int64("size", size),
+	)
+	return target, nil
+}
+
+func (s *Service) CopyContainerToStack(ctx context.Context, stackName, containerName string, req ContainerCopyRequest) (*ContainerCopyResult, error) {
+	containerID, err := s.stackContainer(ctx, stackName, containerName)
+	if err != nil {
+		return nil, err
+	}
+
+	source, stat, err := s.statContainerPath(ctx, containerID, req.ContainerPath)
+	if err != nil {
+		return nil, err
+	}
+	if source == "/" {
+		return nil, errors.New("cannot copy the container root directory")
+	}
+
+	realStackPath, err := s.validateStackPath(stackName, "")
+	if err != nil {
+		return nil, err
+	}
+	destination, err := s.validateStackPath(stackName, req.StackPath)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(destination); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("destination is not a directory: %s", req.StackPath)
+	}
+	if isWithinDirectory(destination, filepath.Join(realStackPath, ".berth")) {
+		return nil, errors.New("cannot copy into the internal .berth directory")
+	}
+
+	name := filepath.Base(source)
+	target := filepath.Join(destination, name)
+	if existing, err := os.Lstat(target); err == nil {
+		if !req.Overwrite {
+			return nil, fmt.Errorf("%w: %s", ErrContainerPathConflict, stackRelativePath(realStackPath, target))
+		}
+		if existing.IsDir() != stat.Mode.IsDir() {
+			return nil, fmt.Errorf("cannot replace %s with a different file type", stackRelativePath(realStackPath, target))
+		}
+	}
+
+	remaining, limited, err := s.QuotaRemaining(stackName)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := s.dockerClient.CopyFromContainer(ctx, containerID, source)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = reader.Close() }()
+	defer s.InvalidateUsage(stackName)
+
+	stage, err := os.MkdirTemp(destination, ".berth-copy-*")
+	if err != nil {
+		return nil, fmt.Errorf("cannot create staging directory: %w", err)
+	}
+	defer func() { _ = os.RemoveAll(stage) }()
+
+	result := &ContainerCopyResult{
+		Source:      source,
+		Destination: stackRelativePath(realStackPath, target),
+	}
+
+	tarReader := tar.NewReader(reader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot read container archive: %w", err)
+		}
+
+		relative := filepath.Clean(strings.TrimPrefix(header.Name, "/"))
+		if relative != name && !strings.HasPrefix(relative, name+"/") {
+			result.Skipped++
+			continue
+		}
+
+		entryPath, err := s.resolveAndValidatePath(filepath.Join(stage, relative), stage)
+		if err != nil {
+			s.logger.Warn("skipping container entry outside stack directory",
+				zap.String("operation", "copy_container_to_stack"),
+				zap.String("stack", stackName),
+				zap.String("entry", header.Name),
+				zap.Error(err),
+			)
+			result.Skipped++
+			continue
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(entryPath, header.FileInfo().Mode().Perm()|0700); err != nil {
+				return nil, fmt.Errorf("cannot create directory: %w", err)
+			}
+			result.Directories++
+
+		case tar.TypeReg:
+			if s.maxChunkedUpload > 0 && result.Bytes+header.Size > s.maxChunkedUpload {
+				return nil, fmt.Errorf("copy too large (>%dMB)", s.maxChunkedUpload/(1024*1024))
+			}
+			if limited && result.Bytes+header.Size > remaining {
+				return nil, fmt.Errorf("%w: copy needs more than the %d bytes remaining", ErrQuotaExceeded, max(remaining, 0))
+			}
+			written, err := writeContainerEntry(entryPath, tarReader, header.FileInfo().Mode().Perm())
+			if err != nil {
+				return nil, err
+			}
+			result.Files++
+			result.Bytes += written
+
+		case tar.TypeSymlink:
+			finalPath := filepath.Join(destination, relative)
+			if filepath.IsAbs(header.Linkname) || !isWithinDirectory(filepath.Join(filepath.Dir(finalPath), header.Linkname), realStackPath) {
+				result.Skipped++
+				continue
+			}
+			if err := os.MkdirAll(filepath.Dir(entryPath), 0755); err != nil {
+				return nil, fmt.Errorf("cannot create directory: %w", err)
+			}
+			_ = os.Remove(entryPath)
+			if err := os.Symlink(header.Linkname, entryPath); err != nil {
+				return nil, fmt.Errorf("cannot create symlink: %w", err)
+			}
+			result.Files++
+
+		default:
+			result.Skipped++
+		}
+	}
+
+	staged := filepath.Join(stage, name)
+	if _, err := os.Lstat(staged); err == nil {
+		if err := mergeStagedPath(staged, target); err != nil {
+			return nil, err
+		}
+	}
+
+	s.logger.Info("copied from container into stack",
+		zap.String("operation", "copy_container_to_stack"),
+		zap.String("stack", stackName),
+		zap.String("container", containerName),
+		zap.String("source", source),
+		zap.String("destination", result.Destination),
+		zap.Int("files", result.Files),
+		zap.Int64("bytes", result.Bytes),
+		zap.Int("skipped", result.Skipped),
+	)
+
+	return result, nil
+}
+
+func (s *Service) CopyStackToContainer(ctx context.Context, stackName, containerName string, req ContainerCopyRequest) (*ContainerCopyResult, error) {
+	containerID, err := s.stackContainer(ctx, stackName, containerName)
+	if err != nil {
+		return nil, err
+	}
+
+	realStackPath, err := s.validateStackPath(stackName, "")
+	if err != nil {
+		return nil, err
+	}
+	source, err := s.validateStackPath(stackName, req.StackPath)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(source); err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("path not found: %s", req.StackPath)
+		}
+		return nil, fmt.Errorf("cannot access path: %w", err)
+	}
+	if isWithinDirectory(source, filepath.Join(realStackPath, ".berth")) {
+		return nil, errors.New("cannot copy the internal .berth directory")
+	}
+
+	directory, stat, err := s.statContainerPath(ctx, containerID, req.ContainerPath)
+	if err != nil {
+		return nil, err
+	}
+	if !stat.Mode.IsDir() {
+		return nil, fmt.Errorf("path is not a directory: %s", directory)
+	}
+
+	name := filepath.Base(source)
+	target := filepath.Join(directory, name)
+	if !req.Overwrite {
+		if _, err := s.dockerClient.ContainerStatPath(ctx, containerID, target); err == nil {
+			return nil, fmt.Errorf("%w: %s", ErrContainerPathConflict, target)
+		}
+	}
+
+	result := &ContainerCopyResult{
+		Source:      stackRelativePath(realStackPath, source),
+		Destination: target,
+	}
+	excluded := filepath.Join(source, ".berth")
+	err = filepath.WalkDir(source, func(walkPath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		switch {
+		case entry.IsDir() && walkPath == excluded:
+			return filepath.SkipDir
+		case entry.IsDir():
+			result.Directories++
+		case entry.Type().IsRegular():
+			if info, err := entry.Info(); err == nil {
+				result.Files++
+				result.Bytes += info.Size()
+			}
+		case entry.Type()&fs.ModeSymlink != 0:
+			result.Files++
+		default:
+			result.Skipped++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot read source: %w", err)
+	}
+
+	progress := &archiveLogWriter{
+		logger: s.logger.With(
+			zap.String("operation", "copy_stack_to_container"),
+			zap.String("stack", stackName),
+			zap.String("container", containerName),
+		),
+	}
+	opts := archive.CreateOptions{
+		Format:          ArchiveFormatTar,
+		IncludePaths:    []string{name},
+		ExcludePatterns: []string{filepath.Join(name, ".berth")},
+	}
+
+	staged, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(s.archive.StreamArchive(ctx, filepath.Dir(source), opts, writer, progress))
+	}()
+	err = s.dockerClient.CopyToContainer(ctx, containerID, directory, staged, req.CopyUIDGID)
+	_ = staged.CloseWithError(io.ErrClosedPipe)
+	if err != nil {
+		return nil, err
+	}
+
+	progress.logger.Info("copied from stack into container",
+		zap.String("source", result.Source),
+		zap.String("destination", result.Destination),
+		zap.Int("files", result.Files),
+		zap.Int64("bytes", result.Bytes),
+	)
+
+	return result, nil
+}
+
+func (s *Service) stackContainer(ctx context.Context, stackName, containerName string) (string, error) {
+	if _, err := s.validateStackPath(stackName, ""); err != nil {
+		return "", err
+	}
+	if containerName == "" {
+		return "", fmt.Errorf("%w: container name is required", ErrContainerNotFound)
+	}
+
+	inspect, err := s.dockerClient.ContainerInspect(ctx, containerName)
+	if err != nil {
+		s.logger.Debug("container lookup failed",
+			zap.String("stack", stackName),
+			zap.String("container", containerName),
+			zap.Error(err),
+		)
+		return "", fmt.Errorf("%w: %s", ErrContainerNotFound, containerName)
+	}
+
+	if err := docker.ValidateContainerStack(inspect, stackName); err != nil {
+		s.logger.Warn("container outside stack requested",
+			zap.String("stack", stackName),
+			zap.String("container", containerName),
+			zap.Error(err),
+		)
+		return "", err
+	}
+
+	if inspect.ContainerJSONBase == nil || inspect.State == nil || !inspect.State.Running {
+		return "", fmt.Errorf("%w: %s", ErrContainerNotRunning, containerName)
+	}
+
+	return inspect.ID, nil
+}
+
+func (s *Service) statContainerPath(ctx context.Context, containerID, path string) (string, container.PathStat, error) {
+	if strings.ContainsRune(path, 0) {
+		return "", container.PathStat{}, errors.New("invalid path")
+	}
+	target := filepath.Clean("/" + path)
+
+	stat, err := s.dockerClient.ContainerStatPath(ctx, containerID, target)
+	if err != nil {
+		return "", container.PathStat{}, fmt.Errorf("path not found: %s", target)
+	}
+
+	if stat.Mode&os.ModeSymlink != 0 && stat.LinkTarget != "" {
+		target = stat.LinkTarget
+		stat, err = s.dockerClient.ContainerStatPath(ctx, containerID, target)
+		if err != nil {
+			return "", container.PathStat{}, fmt.Errorf("path not found: %s", target)
+		}
+	}
+
+	return target, stat, nil
+}
+
+func stackRelativePath(realStackPath, path string) string {
+	relative, err := filepath.Rel(realStackPath, path)
+	if err != nil {
+		return path
+	}
+	return filepath.ToSlash(relative)
+}
+
+func mergeStagedPath(staged, target string) error {
+	stagedInfo, err := os.Lstat(staged)
+	if err != nil {
+		return err
+	}
+
+	existing, err := os.Lstat(target)
+	if os.IsNotExist(err) {
+		if err := os.Rename(staged, target); err != nil {
+			return fmt.Errorf("cannot move copied files into place: %w", err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("cannot access %s: %w", target, err)
+	}
+
+	if !stagedInfo.IsDir() || !existing.IsDir() {
+		if stagedInfo.IsDir() != existing.IsDir() {
+			return fmt.Errorf("cannot replace %s with a different file type", filepath.Base(target))
+		}
+		if err := os.Rename(staged, target); err != nil {
+			return fmt.Errorf("cannot move copied file into place: %w", err)
+		}
+		return nil
+	}
+
+	entries, err := os.ReadDir(staged)
+	if err != nil {
+		return fmt.Errorf("cannot read staged directory: %w", err)
+	}
+	for _, entry := range entries {
+		if err := mergeStagedPath(filepath.Join(staged, entry.Name()), filepath.Join(target, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeContainerEntry(path string, src io.Reader, mode os.FileMode) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return 0, fmt.Errorf("cannot create directory: %w", err)
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(path), ".berth-copy-*")
+	if err != nil {
+		return 0, fmt.Errorf("cannot create file: %w", err)
+	}
+	defer func() { _ = os.Remove(temp.Name()) }()
+
+	written, err := io.Copy(temp, src)
+	if err != nil {
+		_ = temp.Close()
+		return 0, fmt.Errorf("cannot write file: %w", err)
+	}
+	if err := temp.Close(); err != nil {
+		return 0, fmt.Errorf("cannot close file: %w", err)
+	}
+	if err := os.Chmod(temp.Name(), mode); err != nil {
+		return 0, fmt.Errorf("cannot set file mode: %w", err)
+	}
+	if err := os.Rename(temp.Name(), path); err != nil {
+		return 0, fmt.Errorf("cannot move file into place: %w", err)
+	}
+	return written, nil
+}
@@ -1,9 +1,18 @@
 package files
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/tech-arch1tect/berth-agent/internal/agentsign"
 	"github.com/tech-arch1tect/berth-agent/internal/audit"
+	"github.com/tech-arch1tect/berth-agent/internal/common"
+	"github.com/tech-arch1tect/berth-agent/internal/revisions"
+	"io"
+	"mime"
 	"net/http"
+	"os"
 	"strconv"
 	"strings"
 
@@ -89,11 +98,30 @@ func (h *Handler) WriteFile(c echo.Context) error {
 		})
 	}
 
-	if err := h.service.WriteFile(stackName, req); err != nil {
-		h.auditService.LogFileEvent(audit.EventFileWrite, c.RealIP(), stackName, req.Path, false, err.Error(), nil)
-		return c.JSON(http.StatusBadRequest, ErrorResponse{
-			Error: err.Error(),
-			Code:  "WRITE_FILE_ERROR",
+	if err := h.service.WriteFile(stackName, req, revisions.ActorFromContext(c)); err != nil {
+		var syntaxErr *SyntaxError
+		if errors.As(err, &syntaxErr) {
+			if !req.ValidateOnly {
+				h.auditService.LogFileEvent(audit.EventFileWrite, c.RealIP(), stackName, req.Path, false, err.Error(), map[string]any{
+					"format": syntaxErr.Format,
+				})
+			}
+			return c.JSON(http.StatusUnprocessableEntity, SyntaxErrorResponse{
+				Error:  err.Error(),
+				Code:   "SYNTAX_ERROR",
+				Syntax: syntaxErr,
+			})
+		}
+		if !req.ValidateOnly {
+			h.auditService.LogFileEvent(audit.EventFileWrite, c.RealIP(), stackName, req.Path, false, err.Error(), nil)
+		}
+		return quotaError(c, err, "WRITE_FILE_ERROR")
+	}
+
+	if req.ValidateOnly {
+		return c.JSON(http.StatusOK, map[string]string{
+			"status": "valid",
+			"format": syntaxFormat(req.Path),
 		})
 	}
 
@@ -153,7 +181,8 @@ func (h *Handler) Delete(c echo.Context) error {
 		})
 	}
 
-	if err := h.service.Delete(stackName, req); err != nil {
+	result, err := h.service.Delete(stackName, req, revisions.ActorFromContext(c))
+	if err != nil {
 		h.auditService.LogFileEvent(audit.EventFileDelete, c.RealIP(), stackName, req.Path, false, err.Error(), nil)
 		return c.JSON(http.StatusBadRequest, ErrorResponse{
 			Error: err.Error(),
@@ -161,9 +190,17 @@ func (h *Handler) Delete(c echo.Context) error {
 		})
 	}
 
-	h.auditService.LogFileEvent(audit.EventFileDelete, c.RealIP(), stackName, req.Path, true, "", nil)
+	h.auditService.LogFileEvent(audit.EventFileDelete, c.RealIP(), stackName, req.Path, true, "", map[string]any{
+		"permanent": result.Permanent,
+		"trash_id":  result.TrashID,
+	})
 
-	return c.JSON(http.StatusOK, map[string]string{"status": "success"})
+	return c.JSON(http.StatusOK, map[string]any{
+		"status":     "success",
+		"permanent":  result.Permanent,
+		"trash_id":   result.TrashID,
+		"expires_at": result.ExpiresAt,
+	})
 }
 
 func (h *Handler) Rename(c echo.Context) error {
@@ -201,6 +238,51 @@ func (h *Handler) Rename(c echo.Context) error {
 	return c.JSON(http.StatusOK, map[string]string{"status": "success"})
 }
 
+func (h *Handler) ApplyChangeset(c echo.Context) error {
+	stackName := c.Param("stackName")
+
+	var req ChangesetRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "Invalid request body",
+			Code:  "INVALID_REQUEST",
+		})
+	}
+
+	if len(req.Operations) == 0 {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "operations are required",
+			Code:  "MISSING_OPERATIONS",
+		})
+	}
+
+	operations := make([]string, 0, len(req.Operations))
+	for _, op := range req.Operations {
+		summary := op.Op + " " + op.Path
+		if op.NewPath != "" {
+			summary += " -> " + op.NewPath
+		}
+		operations = append(operations, summary)
+	}
+
+	result, err := h.service.ApplyChangeset(stackName, req, revisions.ActorFromContext(c))
+	if err != nil {
+		h.auditService.LogFileEvent(audit.EventFileChangeset, c.RealIP(), stackName, "", false, err.Error(), map[string]any{
+			"operation_count": len(req.Operations),
+			"operations":      operations,
+		})
+		return quotaError(c, err, "CHANGESET_ERROR")
+	}
+
+	h.auditService.LogFileEvent(audit.EventFileChangeset, c.RealIP(), stackName, "", true, "", map[string]any{
+		"changeset_id":    result.ID,
+		"operation_count": result.Applied,
+		"operations":      operations,
+	})
+
+	return c.JSON(http.StatusOK, result)
+}
+
 func (h *Handler) Copy(c echo.Context) error {
 	stackName := c.Param("stackName")
 
@@ -223,10 +305,7 @@ func (h *Handler) Copy(c echo.Context) error {
 		h.auditService.LogFileEvent(audit.EventFileCopy, c.RealIP(), stackName, req.SourcePath, false, err.Error(), map[string]any{
 			"target_path": req.TargetPath,
 		})
-		return c.JSON(http.StatusBadRequest, ErrorResponse{
-			Error: err.Error(),
-			Code:  "COPY_ERROR",
-		})
+		return quotaError(c, err, "COPY_ERROR")
 	}
 
 	h.auditService.LogFileEvent(audit.EventFileCopy, c.RealIP(), stackName, req.SourcePath, true, "", map[string]any{
@@ -291,10 +370,7 @@ func (h *Handler) UploadFile(c echo.Context) error {
 			"filename": file.Filename,
 			"size":     file.Size,
 		})
-		return c.JSON(http.StatusBadRequest, ErrorResponse{
-			Error: err.Error(),
-			Code:  "UPLOAD_FILE_ERROR",
-		})
+		return quotaError(c, err, "UPLOAD_FILE_ERROR")
 	}
 
 	h.auditService.LogFileEvent(audit.EventFileUpload, c.RealIP(), stackName, path, true, "", map[string]any{
@@ -326,10 +402,6 @@ func (h *Handler) DownloadFile(c echo.Context) error {
 	}
 	defer func() { _ = file.Close() }()
 
-	h.auditService.LogFileEvent(audit.EventFileDownload, c.RealIP(), stackName, path, true, "", map[string]any{
-		"size": stat.Size(),
-	})
-
 	filename := c.QueryParam("filename")
 	if filename == "" {
 		filename = path
@@ -338,10 +410,98 @@ func (h *Handler) DownloadFile(c echo.Context) error {
 		}
 	}
 
-	c.Response().Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
-	c.Response().Header().Set("Content-Length", strconv.FormatInt(stat.Size(), 10))
+	c.Response().Header().Set("Content-Disposition", attachmentDisposition(filename))
+	c.Response().Header().Set(echo.HeaderContentType, "application/octet-stream")
+	c.Response().Header().Set("ETag", fileETag(stat))
+
+	http.ServeContent(c.Response(), c.Request(), filename, stat.ModTime(), file)
+
+	status := c.Response().Status
+	metadata := map[string]any{
+		"size":    stat.Size(),
+		"status":  status,
+		"outcome": downloadOutcome(status),
+	}
+	if rangeHeader := c.Request().Header.Get("Range"); rangeHeader != "" {
+		metadata["range"] = rangeHeader
+	}
+	if status == http.StatusPartialContent {
+		metadata["content_range"] = c.Response().Header().Get("Content-Range")
+	}
+	if status >= http.StatusBadRequest {
+		h.auditService.LogFileEvent(audit.EventFileDownload, c.RealIP(), stackName, path, false, http.StatusText(status), metadata)
+		return nil
+	}
+	h.auditService.LogFileEvent(audit.EventFileDownload, c.RealIP(), stackName, path, true, "", metadata)
+	return nil
+}
+
+func downloadOutcome(status int) string {
+	switch status {
+	case http.StatusOK:
+		return "full"
+	case http.StatusPartialContent:
+		return "partial"
+	case http.StatusNotModified:
+		return "not_modified"
+	case http.StatusPreconditionFailed:
+		return "precondition_failed"
+	case http.StatusRequestedRangeNotSatisfiable:
+		return "range_not_satisfiable"
+	default:
+		return "error"
+	}
+}
+
+func attachmentDisposition(filename string) string {
+	if disposition := mime.FormatMediaType("attachment", map[string]string{"filename": filename}); disposition != "" {
+		return disposition
+	}
+	return "attachment"
+}
+
+func fileETag(stat os.FileInfo) string {
+	return fmt.Sprintf(`"%x-%x"`, stat.ModTime().UnixNano(), stat.Size())
+}
+
+func (h *Handler) DownloadArchive(c echo.Context) error {
+	stackName := c.Param("stackName")
+	path := c.QueryParam("path")
+	format := c.QueryParam("format")
+
+	download, err := h.service.PrepareArchive(stackName, path, format)
+	if err != nil {
+		h.auditService.LogFileEvent(audit.EventFileDownload, c.RealIP(), stackName, path, false, err.Error(), map[string]any{
+			"archive_format": format,
+		})
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: err.Error(),
+			Code:  "DOWNLOAD_ARCHIVE_ERROR",
+		})
+	}
+
+	filename := c.QueryParam("filename")
+	if filename == "" {
+		filename = download.Filename
+	}
+
+	c.Response().Header().Set("Content-Disposition", attachmentDisposition(filename))
+	c.Response().Header().Set(echo.HeaderContentType, download.ContentType)
+	c.Response().WriteHeader(http.StatusOK)
+
+	err = h.service.WriteArchive(c.Request().Context(), download, c.Response())
 
-	return c.Stream(http.StatusOK, "application/octet-stream", file)
+	metadata := map[string]any{
+		"archive_format": download.Format,
+		"file_count":     download.FileCount,
+		"size":           download.TotalSize,
+	}
+	if err != nil {
+		h.auditService.LogFileEvent(audit.EventFileDownload, c.RealIP(), stackName, path, false, err.Error(), metadata)
+		return err
+	}
+	h.auditService.LogFileEvent(audit.EventFileDownload, c.RealIP(), stackName, path, true, "", metadata)
+	return nil
 }
 
 func (h *Handler) GetDirectoryStats(c echo.Context) error {
@@ -367,6 +527,71 @@ func (h *Handler) GetDirectoryStats(c echo.Context) error {
 	return c.JSON(http.StatusOK, stats)
 }
 
+func (h *Handler) GetStackUsage(c echo.Context) error {
+	stackName := c.Param("stackName")
+
+	refresh, err := parseRefreshParam(c)
+	if err != nil {
+		return err
+	}
+
+	usage, err := h.service.GetStackUsage(c.Request().Context(), stackName, refresh)
+	if err != nil {
+		h.auditService.LogFileEvent(audit.EventFileUsage, c.RealIP(), stackName, "", false, err.Error(), nil)
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: err.Error(),
+			Code:  "USAGE_ERROR",
+		})
+	}
+
+	h.auditService.LogFileEvent(audit.EventFileUsage, c.RealIP(), stackName, "", true, "", map[string]any{
+		"used_bytes":  usage.UsedBytes,
+		"quota_bytes": usage.QuotaBytes,
+		"refresh":     refresh,
+	})
+
+	return c.JSON(http.StatusOK, usage)
+}
+
+func (h *Handler) GetUsageReport(c echo.Context) error {
+	refresh, err := parseRefreshParam(c)
+	if err != nil {
+		return err
+	}
+
+	report, err := h.service.GetUsageReport(c.Request().Context(), refresh)
+	if err != nil {
+		h.auditService.LogFileEvent(audit.EventFileUsage, c.RealIP(), "", "", false, err.Error(), nil)
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error: err.Error(),
+			Code:  "USAGE_ERROR",
+		})
+	}
+
+	h.auditService.LogFileEvent(audit.EventFileUsage, c.RealIP(), "", "", true, "", map[string]any{
+		"stack_count":    len(report.Stacks),
+		"exceeded_count": report.ExceededCount,
+		"refresh":        refresh,
+	})
+
+	return c.JSON(http.StatusOK, report)
+}
+
+func parseRefreshParam(c echo.Context) (bool, error) {
+	value := c.QueryParam("refresh")
+	if value == "" {
+		return false, nil
+	}
+	refresh, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "refresh must be a boolean",
+			Code:  "INVALID_REQUEST",
+		})
+	}
+	return refresh, nil
+}
+
 func (h *Handler) Chmod(c echo.Context) error {
 	stackName := c.Param("stackName")
 
@@ -456,3 +681,915 @@ func (h *Handler) Chown(c echo.Context) error {
 
 	return c.JSON(http.StatusOK, map[string]string{"status": "success"})
 }
+
+func (h *Handler) SearchFiles(c echo.Context) error {
+	stackName := c.Param("stackName")
+
+	req := SearchRequest{
+		Path:    c.QueryParam("path"),
+		Mode:    c.QueryParam("mode"),
+		Pattern: c.QueryParam("pattern"),
+		Include: c.QueryParam("include"),
+	}
+
+	for param, target := range map[string]*bool{
+		"ignore_case": &req.IgnoreCase,
+		"literal":     &req.Literal,
+	} {
+		if value := c.QueryParam(param); value != "" {
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				return c.JSON(http.StatusBadRequest, ErrorResponse{
+					Error: param + " must be a boolean",
+					Code:  "INVALID_SEARCH_PARAMETER",
+				})
+			}
+			*target = parsed
+		}
+	}
+
+	for param, target := range map[string]*int{
+		"offset": &req.Offset,
+		"limit":  &req.Limit,
+	} {
+		if value := c.QueryParam(param); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil || parsed < 0 {
+				return c.JSON(http.StatusBadRequest, ErrorResponse{
+					Error: param + " must be a non-negative integer",
+					Code:  "INVALID_SEARCH_PARAMETER",
+				})
+			}
+			*target = parsed
+		}
+	}
+
+	if value := c.QueryParam("context"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error: "context must be an integer",
+				Code:  "INVALID_SEARCH_PARAMETER",
+			})
+		}
+		req.Context = &parsed
+	}
+
+	result, err := h.service.Search(c.Request().Context(), stackName, req)
+	if err != nil {
+		h.auditService.LogFileEvent(audit.EventFileSearch, c.RealIP(), stackName, req.Path, false, err.Error(), map[string]any{
+			"mode":    req.Mode,
+			"pattern": req.Pattern,
+		})
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: err.Error(),
+			Code:  "SEARCH_FILES_ERROR",
+		})
+	}
+
+	h.auditService.LogFileEvent(audit.EventFileSearch, c.RealIP(), stackName, req.Path, true, "", map[string]any{
+		"mode":          result.Mode,
+		"pattern":       result.Pattern,
+		"match_count":   len(result.Matches),
+		"files_scanned": result.FilesScanned,
+		"truncated":     result.Truncated,
+	})
+
+	return c.JSON(http.StatusOK, result)
+}
+
+func (h *Handler) WatchFiles(c echo.Context) error {
+	stackName := c.Param("stackName")
+
+	req := WatchRequest{
+		Path: c.QueryParam("path"),
+	}
+
+	if value := c.QueryParam("recursive"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error: "recursive must be a boolean",
+				Code:  "INVALID_WATCH_PARAMETER",
+			})
+		}
+		req.Recursive = parsed
+	}
+
+	if value := c.QueryParam("lines"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error: "lines must be an integer",
+				Code:  "INVALID_WATCH_PARAMETER",
+			})
+		}
+		req.Lines = &parsed
+	}
+
+	frames, _, err := agentsign.SessionFor(c, agentsign.DirectionToBerth)
+	if err != nil {
+		return common.SendBadRequest(c, "Stream session key could not be agreed")
+	}
+
+	watch, err := h.service.OpenWatch(stackName, req)
+	if err != nil {
+		h.auditService.LogFileEvent(audit.EventFileWatch, c.RealIP(), stackName, req.Path, false, err.Error(), map[string]any{
+			"recursive": req.Recursive,
+		})
+		status := http.StatusBadRequest
+		if errors.Is(err, ErrTooManyWatchers) {
+			status = http.StatusServiceUnavailable
+		}
+		return c.JSON(status, ErrorResponse{
+			Error: err.Error(),
+			Code:  "WATCH_FILES_ERROR",
+		})
+	}
+	defer watch.Close()
+
+	h.auditService.LogFileEvent(audit.EventFileWatch, c.RealIP(), stackName, req.Path, true, "", map[string]any{
+		"recursive":    req.Recursive,
+		"is_directory": watch.IsDirectory(),
+	})
+
+	c.Response().Header().Set("Content-Type", "text/event-stream")
+	c.Response().Header().Set("Cache-Control", "no-cache")
+	c.Response().Header().Set("Connection", "keep-alive")
+	c.Response().Header().Set("Access-Control-Allow-Origin", "*")
+	c.Response().Header().Set("Transfer-Encoding", "chunked")
+
+	c.Response().Header().Del("Content-Length")
+
+	c.Response().WriteHeader(http.StatusOK)
+
+	if flusher, ok := c.Response().Writer.(http.Flusher); ok {
+		flusher.Flush()
+	}
+
+	return watch.Run(c.Request().Context(), func(event WatchEvent) error {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(c.Response().Writer, "data: %s\n\n", base64.StdEncoding.EncodeToString(frames.Wrap(payload))); err != nil {
+			return err
+		}
+		if flusher, ok := c.Response().Writer.(http.Flusher); ok {
+			flusher.Flush()
+		}
+		return nil
+	})
+}
+
+func (h *Handler) ListTrash(c echo.Context) error {
+	stackName := c.Param("stackName")
+
+	result, err := h.service.ListTrash(stackName)
+	if err != nil {
+		h.auditService.LogFileEvent(audit.EventFileTrashList, c.RealIP(), stackName, trashDirName, false, err.Error(), nil)
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: err.Error(),
+			Code:  "LIST_TRASH_ERROR",
+		})
+	}
+
+	h.auditService.LogFileEvent(audit.EventFileTrashList, c.RealIP(), stackName, trashDirName, true, "", map[string]any{
+		"item_count": len(result.Items),
+	})
+
+	return c.JSON(http.StatusOK, result)
+}
+
+func (h *Handler) RestoreTrash(c echo.Context) error {
+	stackName := c.Param("stackName")
+	trashID := c.Param("trashId")
+
+	var req RestoreTrashRequest
+	if c.Request().ContentLength != 0 {
+		if err := c.Bind(&req); err != nil {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error: "Invalid request body",
+				Code:  "INVALID_REQUEST",
+			})
+		}
+	}
+
+	result, err := h.service.RestoreTrash(stackName, trashID, req, revisions.ActorFromContext(c))
+	if err != nil {
+		h.auditService.LogFileEvent(audit.EventFileTrashRestore, c.RealIP(), stackName, req.TargetPath, false, err.Error(), map[string]any{
+			"trash_id": trashID,
+		})
+		status, code := http.StatusBadRequest, "RESTORE_TRASH_ERROR"
+		switch {
+		case errors.Is(err, ErrTrashItemNotFound):
+			status, code = http.StatusNotFound, "TRASH_ITEM_NOT_FOUND"
+		case errors.Is(err, ErrRestoreConflict):
+			status, code = http.StatusConflict, "RESTORE_CONFLICT"
+		case errors.Is(err, ErrQuotaExceeded):
+			status, code = http.StatusInsufficientStorage, "QUOTA_EXCEEDED"
+		}
+		return c.JSON(status, ErrorResponse{
+			Error: err.Error(),
+			Code:  code,
+		})
+	}
+
+	h.auditService.LogFileEvent(audit.EventFileTrashRestore, c.RealIP(), stackName, result.Path, true, "", map[string]any{
+		"trash_id":          trashID,
+		"renamed":           result.Renamed,
+		"replaced_trash_id": result.ReplacedTrashID,
+	})
+
+	return c.JSON(http.StatusOK, result)
+}
+
+func (h *Handler) PurgeTrash(c echo.Context) error {
+	stackName := c.Param("stackName")
+	trashID := c.Param("trashId")
+
+	purged, err := h.service.PurgeTrash(stackName, trashID)
+	if err != nil {
+		h.auditService.LogFileEvent(audit.EventFileTrashPurge, c.RealIP(), stackName, trashDirName, false, err.Error(), map[string]any{
+			"trash_id": trashID,
+		})
+		status, code := http.StatusBadRequest, "PURGE_TRASH_ERROR"
+		if errors.Is(err, ErrTrashItemNotFound) {
+			status, code = http.StatusNotFound, "TRASH_ITEM_NOT_FOUND"
+		}
+		return c.JSON(status, ErrorResponse{
+			Error: err.Error(),
+			Code:  code,
+		})
+	}
+
+	h.auditService.LogFileEvent(audit.EventFileTrashPurge, c.RealIP(), stackName, trashDirName, true, "", map[string]any{
+		"trash_id":     trashID,
+		"purged_count": purged,
+	})
+
+	return c.JSON(http.StatusOK, map[string]any{
+		"status": "success",
+		"purged": purged,
+	})
+}
+
+func (h *Handler) ListUploads(c echo.Context) error {
+	stackName := c.Param("stackName")
+
+	sessions, err := h.service.ListUploads(stackName)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: err.Error(),
+			Code:  "LIST_UPLOADS_ERROR",
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]any{
+		"uploads": sessions,
+	})
+}
+
+func (h *Handler) CreateUpload(c echo.Context) error {
+	stackName := c.Param("stackName")
+
+	var req CreateUploadRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "Invalid request body",
+			Code:  "INVALID_REQUEST",
+		})
+	}
+
+	if req.Path == "" {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "path is required",
+			Code:  "MISSING_PATH",
+		})
+	}
+
+	session, err := h.service.CreateUpload(stackName, req, revisions.ActorFromContext(c))
+	if err != nil {
+		h.auditService.LogFileEvent(audit.EventFileUploadStart, c.RealIP(), stackName, req.Path, false, err.Error(), map[string]any{
+			"size": req.Size,
+		})
+		status, code := http.StatusBadRequest, "CREATE_UPLOAD_ERROR"
+		switch {
+		case errors.Is(err, ErrInsufficientStorage):
+			status, code = http.StatusInsufficientStorage, "INSUFFICIENT_STORAGE"
+		case errors.Is(err, ErrQuotaExceeded):
+			status, code = http.StatusInsufficientStorage, "QUOTA_EXCEEDED"
+		}
+		return c.JSON(status, ErrorResponse{
+			Error: err.Error(),
+			Code:  code,
+		})
+	}
+
+	h.auditService.LogFileEvent(audit.EventFileUploadStart, c.RealIP(), stackName, req.Path, true, "", map[string]any{
+		"upload_id": session.ID,
+		"size":      session.Size,
+	})
+
+	setUploadOffset(c, session)
+	return c.JSON(http.StatusCreated, session)
+}
+
+func (h *Handler) GetUpload(c echo.Context) error {
+	stackName := c.Param("stackName")
+
+	session, err := h.service.GetUpload(stackName, c.Param("uploadId"))
+	if err != nil {
+		return uploadError(c, err, "GET_UPLOAD_ERROR")
+	}
+
+	setUploadOffset(c, session)
+	return c.JSON(http.StatusOK, session)
+}
+
+func (h *Handler) WriteUploadChunk(c echo.Context) error {
+	stackName := c.Param("stackName")
+
+	offsetParam := c.QueryParam("offset")
+	if offsetParam == "" {
+		offsetParam = c.Request().Header.Get("Upload-Offset")
+	}
+	offset, err := strconv.ParseInt(offsetParam, 10, 64)
+	if err != nil || offset < 0 {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "offset must be a non-negative integer",
+			Code:  "INVALID_OFFSET",
+		})
+	}
+
+	session, err := h.service.WriteUploadChunk(stackName, c.Param("uploadId"), offset, c.Request().Body)
+	if err != nil {
+		setUploadOffset(c, session)
+		return uploadError(c, err, "UPLOAD_CHUNK_ERROR")
+	}
+
+	setUploadOffset(c, session)
+	return c.JSON(http.StatusOK, session)
+}
+
+func (h *Handler) CompleteUpload(c echo.Context) error {
+	stackName := c.Param("stackName")
+	uploadID := c.Param("uploadId")
+
+	var req CompleteUploadRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "Invalid request body",
+			Code:  "INVALID_REQUEST",
+		})
+	}
+
+	session, err := h.service.CompleteUpload(stackName, uploadID, req)
+	if err != nil {
+		path := ""
+		if session != nil {
+			path = session.Path
+			setUploadOffset(c, session)
+		}
+		h.auditService.LogFileEvent(audit.EventFileUpload, c.RealIP(), stackName, path, false, err.Error(), map[string]any{
+			"upload_id": uploadID,
+			"chunked":   true,
+		})
+		return uploadError(c, err, "COMPLETE_UPLOAD_ERROR")
+	}
+
+	h.auditService.LogFileEvent(audit.EventFileUpload, c.RealIP(), stackName, session.Path, true, "", map[string]any{
+		"upload_id": uploadID,
+		"chunked":   true,
+		"size":      session.Size,
+		"checksum":  session.Checksum,
+	})
+
+	return c.JSON(http.StatusOK, session)
+}
+
+func (h *Handler) AbortUpload(c echo.Context) error {
+	stackName := c.Param("stackName")
+	uploadID := c.Param("uploadId")
+
+	if err := h.service.AbortUpload(stackName, uploadID); err != nil {
+		h.auditService.LogFileEvent(audit.EventFileUploadAbort, c.RealIP(), stackName, "", false, err.Error(), map[string]any{
+			"upload_id": uploadID,
+		})
+		return uploadError(c, err, "ABORT_UPLOAD_ERROR")
+	}
+
+	h.auditService.LogFileEvent(audit.EventFileUploadAbort, c.RealIP(), stackName, "", true, "", map[string]any{
+		"upload_id": uploadID,
+	})
+
+	return c.JSON(http.StatusOK, map[string]string{"status": "success"})
+}
+
+func setUploadOffset(c echo.Context, session *UploadSession) {
+	if session != nil {
+		c.Response().Header().Set("Upload-Offset", strconv.FormatInt(session.Offset, 10))
+	}
+}
+
+func uploadError(c echo.Context, err error, code string) error {
+	status := http.StatusBadRequest
+	switch {
+	case errors.Is(err, ErrUploadNotFound):
+		status, code = http.StatusNotFound, "UPLOAD_NOT_FOUND"
+	case errors.Is(err, ErrUploadOffsetMismatch):
+		status, code = http.StatusConflict, "UPLOAD_OFFSET_MISMATCH"
+	case errors.Is(err, ErrUploadBusy):
+		status, code = http.StatusConflict, "UPLOAD_BUSY"
+	case errors.Is(err, ErrUploadIncomplete):
+		status, code = http.StatusConflict, "UPLOAD_INCOMPLETE"
+	case errors.Is(err, ErrChecksumMismatch):
+		status, code = http.StatusUnprocessableEntity, "CHECKSUM_MISMATCH"
+	}
+	return c.JSON(status, ErrorResponse{
+		Error: err.Error(),
+		Code:  code,
+	})
+}
+
+func (h *Handler) ListVolumeDirectory(c echo.Context) error {
+	stackName := c.Param("stackName")
+	volumeName := c.Param("volumeName")
+	path := c.QueryParam("path")
+
+	result, err := h.service.ListVolumeDirectory(c.Request().Context(), stackName, volumeName, path)
+	if err != nil {
+		h.auditService.LogFileEvent(audit.EventFileListDir, c.RealIP(), stackName, path, false, err.Error(), map[string]any{
+			"volume": volumeName,
+		})
+		return volumeError(c, err, "LIST_DIRECTORY_ERROR")
+	}
+
+	h.auditService.LogFileEvent(audit.EventFileListDir, c.RealIP(), stackName, path, true, "", map[string]any{
+		"volume":      volumeName,
+		"entry_count": len(result.Entries),
+	})
+
+	return c.JSON(http.StatusOK, result)
+}
+
+func (h *Handler) ReadVolumeFile(c echo.Context) error {
+	stackName := c.Param("stackName")
+	volumeName := c.Param("volumeName")
+	path := c.QueryParam("path")
+
+	if path == "" {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "path parameter is required",
+			Code:  "MISSING_PATH",
+		})
+	}
+
+	result, err := h.service.ReadVolumeFile(c.Request().Context(), stackName, volumeName, path)
+	if err != nil {
+		h.auditService.LogFileEvent(audit.EventFileRead, c.RealIP(), stackName, path, false, err.Error(), map[string]any{
+			"volume": volumeName,
+		})
+		return volumeError(c, err, "READ_FILE_ERROR")
+	}
+
+	h.auditService.LogFileEvent(audit.EventFileRead, c.RealIP(), stackName, path, true, "", map[string]any{
+		"volume": volumeName,
+		"size":   result.Size,
+	})
+
+	return c.JSON(http.StatusOK, result)
+}
+
+func (h *Handler) DownloadVolumeFile(c echo.Context) error {
+	stackName := c.Param("stackName")
+	volumeName := c.Param("volumeName")
+	path := c.QueryParam("path")
+
+	if path == "" {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "path parameter is required",
+			Code:  "MISSING_PATH",
+		})
+	}
+
+	filename := c.QueryParam("filename")
+	if filename == "" {
+		filename = path
+		if idx := strings.LastIndex(filename, "/"); idx >= 0 {
+			filename = filename[idx+1:]
+		}
+	}
+
+	started := false
+	err := h.service.DownloadVolumeFile(c.Request().Context(), stackName, volumeName, path, func(info VolumeFileInfo) (io.Writer, error) {
+		started = true
+
+		h.auditService.LogFileEvent(audit.EventFileDownload, c.RealIP(), stackName, path, true, "", map[string]any{
+			"volume": volumeName,
+			"size":   info.Size,
+		})
+
+		c.Response().Header().Set("Content-Disposition", attachmentDisposition(filename))
+		c.Response().Header().Set(echo.HeaderContentType, "application/octet-stream")
+		c.Response().Header().Set(echo.HeaderContentLength, strconv.FormatInt(info.Size, 10))
+		c.Response().Header().Set(echo.HeaderLastModified, info.ModTime.UTC().Format(http.TimeFormat))
+		c.Response().WriteHeader(http.StatusOK)
+		return c.Response(), nil
+	})
+	if err != nil && !started {
+		h.auditService.LogFileEvent(audit.EventFileDownload, c.RealIP(), stackName, path, false, err.Error(), map[string]any{
+			"volume": volumeName,
+		})
+		return volumeError(c, err, "READ_FILE_ERROR")
+	}
+
+	return err
+}
+
+func (h *Handler) WriteVolumeFile(c echo.Context) error {
+	stackName := c.Param("stackName")
+	volumeName := c.Param("volumeName")
+
+	var req WriteFileRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "Invalid request body",
+			Code:  "INVALID_REQUEST",
+		})
+	}
+
+	if req.Path == "" {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "path is required",
+			Code:  "MISSING_PATH",
+		})
+	}
+
+	if err := h.service.WriteVolumeFile(c.Request().Context(), stackName, volumeName, req); err != nil {
+		h.auditService.LogFileEvent(audit.EventFileWrite, c.RealIP(), stackName, req.Path, false, err.Error(), map[string]any{
+			"volume": volumeName,
+		})
+		return volumeError(c, err, "WRITE_FILE_ERROR")
+	}
+
+	h.auditService.LogFileEvent(audit.EventFileWrite, c.RealIP(), stackName, req.Path, true, "", map[string]any{
+		"volume":       volumeName,
+		"content_size": len(req.Content),
+	})
+
+	return c.JSON(http.StatusOK, map[string]string{"status": "success"})
+}
+
+func (h *Handler) UploadVolumeFile(c echo.Context) error {
+	stackName := c.Param("stackName")
+	volumeName := c.Param("volumeName")
+	path := c.FormValue("path")
+
+	if path == "" {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "path parameter is required",
+			Code:  "MISSING_PATH",
+		})
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "file parameter is required",
+			Code:  "MISSING_FILE",
+		})
+	}
+
+	var mode *string
+	var ownerID *uint32
+	var groupID *uint32
+
+	if modeStr := c.FormValue("mode"); modeStr != "" {
+		mode = &modeStr
+	}
+
+	if ownerStr := c.FormValue("owner_id"); ownerStr != "" {
+		if parsed, err := strconv.ParseUint(ownerStr, 10, 32); err == nil {
+			uid := uint32(parsed)
+			ownerID = &uid
+		}
+	}
+
+	if groupStr := c.FormValue("group_id"); groupStr != "" {
+		if parsed, err := strconv.ParseUint(groupStr, 10, 32); err == nil {
+			gid := uint32(parsed)
+			groupID = &gid
+		}
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error: "cannot open uploaded file",
+			Code:  "OPEN_FILE_ERROR",
+		})
+	}
+	defer src.Close()
+
+	if err := h.service.WriteVolumeUploadedFile(c.Request().Context(), stackName, volumeName, path, src, file.Size, mode, ownerID, groupID); err != nil {
+		h.auditService.LogFileEvent(audit.EventFileUpload, c.RealIP(), stackName, path, false, err.Error(), map[string]any{
+			"volume":   volumeName,
+			"filename": file.Filename,
+			"size":     file.Size,
+		})
+		return volumeError(c, err, "UPLOAD_FILE_ERROR")
+	}
+
+	h.auditService.LogFileEvent(audit.EventFileUpload, c.RealIP(), stackName, path, true, "", map[string]any{
+		"volume":   volumeName,
+		"filename": file.Filename,
+		"size":     file.Size,
+	})
+
+	return c.JSON(http.StatusOK, map[string]string{"status": "success"})
+}
+
+func (h *Handler) DeleteVolumePath(c echo.Context) error {
+	stackName := c.Param("stackName")
+	volumeName := c.Param("volumeName")
+
+	var req DeleteRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "Invalid request body",
+			Code:  "INVALID_REQUEST",
+		})
+	}
+
+	if req.Path == "" {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "path is required",
+			Code:  "MISSING_PATH",
+		})
+	}
+
+	if err := h.service.DeleteVolumePath(c.Request().Context(), stackName, volumeName, req.Path); err != nil {
+		h.auditService.LogFileEvent(audit.EventFileDelete, c.RealIP(), stackName, req.Path, false, err.Error(), map[string]any{
+			"volume": volumeName,
+		})
+		return volumeError(c, err, "DELETE_ERROR")
+	}
+
+	h.auditService.LogFileEvent(audit.EventFileDelete, c.RealIP(), stackName, req.Path, true, "", map[string]any{
+		"volume":    volumeName,
+		"permanent": true,
+	})
+
+	return c.JSON(http.StatusOK, map[string]any{
+		"status":    "success",
+		"permanent": true,
+	})
+}
+
+func quotaError(c echo.Context, err error, code string) error {
+	status := http.StatusBadRequest
+	if errors.Is(err, ErrQuotaExceeded) {
+		status, code = http.StatusInsufficientStorage, "QUOTA_EXCEEDED"
+	}
+	return c.JSON(status, ErrorResponse{
+		Error: err.Error(),
+		Code:  code,
+	})
+}
+
+func volumeError(c echo.Context, err error, code string) error {
+	status := http.StatusBadRequest
+	switch {
+	case errors.Is(err, ErrVolumeNotFound):
+		status, code = http.StatusNotFound, "VOLUME_NOT_FOUND"
+	case errors.Is(err, ErrQuotaExceeded):
+		status, code = http.StatusInsufficientStorage, "QUOTA_EXCEEDED"
+	}
+	return c.JSON(status, ErrorResponse{
+		Error: err.Error(),
+		Code:  code,
+	})
+}
+
+func (h *Handler) ListContainerDirectory(c echo.Context) error {
+	stackName := c.Param("stackName")
+	containerName := c.Param("containerName")
+	path := c.QueryParam("path")
+
+	result, err := h.service.ListContainerDirectory(c.Request().Context(), stackName, containerName, path)
+	if err != nil {
+		h.auditService.LogFileEvent(audit.EventContainerFileList, c.RealIP(), stackName, path, false, err.Error(), map[string]any{
+			"container": containerName,
+		})
+		return containerError(c, err, "LIST_DIRECTORY_ERROR")
+	}
+
+	h.auditService.LogFileEvent(audit.EventContainerFileList, c.RealIP(), stackName, result.Path, true, "", map[string]any{
+		"container":   containerName,
+		"entry_count": len(result.Entries),
+		"truncated":   result.Truncated,
+	})
+
+	return c.JSON(http.StatusOK, result)
+}
+
+func (h *Handler) DownloadContainerFile(c echo.Context) error {
+	stackName := c.Param("stackName")
+	containerName := c.Param("containerName")
+	path := c.QueryParam("path")
+	format := c.QueryParam("format")
+
+	if path == "" {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "path parameter is required",
+			Code:  "MISSING_PATH",
+		})
+	}
+
+	download, err := h.service.PrepareContainerDownload(c.Request().Context(), stackName, containerName, path, format)
+	if err != nil {
+		h.auditService.LogFileEvent(audit.EventContainerFileDownload, c.RealIP(), stackName, path, false, err.Error(), map[string]any{
+			"container": containerName,
+		})
+		return containerError(c, err, "READ_FILE_ERROR")
+	}
+
+	metadata := map[string]any{
+		"container":    containerName,
+		"is_directory": download.IsDirectory,
+	}
+	if download.IsDirectory {
+		metadata["archive_format"] = download.Format
+	} else {
+		metadata["size"] = download.Size
+	}
+	h.auditService.LogFileEvent(audit.EventContainerFileDownload, c.RealIP(), stackName, download.Path, true, "", metadata)
+
+	filename := c.QueryParam("filename")
+	if filename == "" {
+		filename = download.Filename
+	}
+
+	c.Response().Header().Set("Content-Disposition", attachmentDisposition(filename))
+	c.Response().Header().Set(echo.HeaderContentType, download.ContentType)
+	if !download.IsDirectory {
+		c.Response().Header().Set(echo.HeaderContentLength, strconv.FormatInt(download.Size, 10))
+		c.Response().Header().Set(echo.HeaderLastModified, download.ModTime.UTC().Format(http.TimeFormat))
+	}
+	c.Response().WriteHeader(http.StatusOK)
+
+	return h.service.WriteContainerDownload(c.Request().Context(), download, c.Response())
+}
+
+func (h *Handler) UploadContainerFile(c echo.Context) error {
+	stackName := c.Param("stackName")
+	containerName := c.Param("containerName")
+
+	req := ContainerUploadRequest{
+		Path:     c.FormValue("path"),
+		Filename: c.FormValue("filename"),
+	}
+
+	if req.Path == "" {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "path parameter is required",
+			Code:  "MISSING_PATH",
+		})
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "file parameter is required",
+			Code:  "MISSING_FILE",
+		})
+	}
+
+	if req.Filename == "" {
+		req.Filename = file.Filename
+	}
+	if modeStr := c.FormValue("mode"); modeStr != "" {
+		req.Mode = &modeStr
+	}
+	for param, target := range map[string]*bool{
+		"extract":      &req.Extract,
+		"copy_uid_gid": &req.CopyUIDGID,
+	} {
+		if value := c.FormValue(param); value != "" {
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				return c.JSON(http.StatusBadRequest, ErrorResponse{
+					Error: param + " must be a boolean",
+					Code:  "INVALID_REQUEST",
+				})
+			}
+			*target = parsed
+		}
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error: "cannot open uploaded file",
+			Code:  "OPEN_FILE_ERROR",
+		})
+	}
+	defer src.Close()
+
+	target, err := h.service.UploadToContainer(c.Request().Context(), stackName, containerName, req, src, file.Size)
+	if err != nil {
+		h.auditService.LogFileEvent(audit.EventContainerFileUpload, c.RealIP(), stackName, req.Path, false, err.Error(), map[string]any{
+			"container": containerName,
+			"filename":  file.Filename,
+			"size":      file.Size,
+			"extract":   req.Extract,
+		})
+		return containerError(c, err, "UPLOAD_FILE_ERROR")
+	}
+
+	h.auditService.LogFileEvent(audit.EventContainerFileUpload, c.RealIP(), stackName, target, true, "", map[string]any{
+		"container": containerName,
+		"filename":  file.Filename,
+		"size":      file.Size,
+		"extract":   req.Extract,
+	})
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"status": "success",
+		"path":   target,
+	})
+}
+
+func (h *Handler) CopyContainerToStack(c echo.Context) error {
+	return h.copyContainerFiles(c, "to_stack")
+}
+
+func (h *Handler) CopyStackToContainer(c echo.Context) error {
+	return h.copyContainerFiles(c, "to_container")
+}
+
+func (h *Handler) copyContainerFiles(c echo.Context, direction string) error {
+	stackName := c.Param("stackName")
+	containerName := c.Param("containerName")
+
+	var req ContainerCopyRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "Invalid request body",
+			Code:  "INVALID_REQUEST",
+		})
+	}
+
+	if req.ContainerPath == "" {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "container_path is required",
+			Code:  "MISSING_PATH",
+		})
+	}
+
+	var result *ContainerCopyResult
+	var err error
+	if direction == "to_stack" {
+		result, err = h.service.CopyContainerToStack(c.Request().Context(), stackName, containerName, req)
+	} else {
+		result, err = h.service.CopyStackToContainer(c.Request().Context(), stackName, containerName, req)
+	}
+
+	metadata := map[string]any{
+		"container":      containerName,
+		"direction":      direction,
+		"container_path": req.ContainerPath,
+		"overwrite":      req.Overwrite,
+	}
+	if err != nil {
+		h.auditService.LogFileEvent(audit.EventContainerFileCopy, c.RealIP(), stackName, req.StackPath, false, err.Error(), metadata)
+		return containerError(c, err, "COPY_ERROR")
+	}
+
+	metadata["files"] = result.Files
+	metadata["bytes"] = result.Bytes
+	h.auditService.LogFileEvent(audit.EventContainerFileCopy, c.RealIP(), stackName, req.StackPath, true, "", metadata)
+
+	return c.JSON(http.StatusOK, result)
+}
+
+func containerError(c echo.Context, err error, code string) error {
+	status := http.StatusBadRequest
+	switch {
+	case errors.Is(err, ErrContainerNotFound):
+		status, code = http.StatusNotFound, "CONTAINER_NOT_FOUND"
+	case errors.Is(err, ErrContainerStackMismatch):
+		status, code = http.StatusForbidden, "CONTAINER_STACK_MISMATCH"
+	case errors.Is(err, ErrContainerNotRunning):
+		status, code = http.StatusConflict, "CONTAINER_NOT_RUNNING"
+	case errors.Is(err, ErrContainerPathConflict):
+		status, code = http.StatusConflict, "PATH_CONFLICT"
+	case errors.Is(err, ErrQuotaExceeded):
+		status, code = http.StatusInsufficientStorage, "QUOTA_EXCEEDED"
+	}
+	return c.JSON(status, ErrorResponse{
+		Error: err.Error(),
+		Code:  code,
+	})
+}
@@ -31,12 +31,27 @@ type FileContent struct {
 }
 
 type WriteFileRequest struct {
-	Path     string  `json:"path" validate:"required"`
-	Content  string  `json:"content"`
-	Encoding string  `json:"encoding,omitempty"`
-	Mode     *string `json:"mode,omitempty"`
-	OwnerID  *uint32 `json:"owner_id,omitempty"`
-	GroupID  *uint32 `json:"group_id,omitempty"`
+	Path         string  `json:"path" validate:"required"`
+	Content      string  `json:"content"`
+	Encoding     string  `json:"encoding,omitempty"`
+	Mode         *string `json:"mode,omitempty"`
+	OwnerID      *uint32 `json:"owner_id,omitempty"`
+	GroupID      *uint32 `json:"group_id,omitempty"`
+	Validate     bool    `json:"validate,omitempty"`
+	ValidateOnly bool    `json:"validate_only,omitempty"`
+}
+
+type SyntaxError struct {
+	Format  string `json:"format"`
+	Message string `json:"message"`
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+}
+
+type SyntaxErrorResponse struct {
+	Error  string       `json:"error"`
+	Code   string       `json:"code"`
+	Syntax *SyntaxError `json:"syntax"`
 }
 
 type CreateDirectoryRequest struct {
@@ -47,7 +62,48 @@ type CreateDirectoryRequest struct {
 }
 
 type DeleteRequest struct {
-	Path string `json:"path" validate:"required"`
+	Path      string `json:"path" validate:"required"`
+	Permanent bool   `json:"permanent,omitempty"`
+}
+
+type DeleteResult struct {
+	Path      string     `json:"path"`
+	Permanent bool       `json:"permanent"`
+	TrashID   string     `json:"trash_id,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+type TrashItem struct {
+	ID           string    `json:"id"`
+	OriginalPath string    `json:"original_path"`
+	Name         string    `json:"name"`
+	IsDirectory  bool      `json:"is_directory"`
+	Size         int64     `json:"size"`
+	Mode         string    `json:"mode"`
+	OwnerID      uint32    `json:"owner_id"`
+	GroupID      uint32    `json:"group_id"`
+	DeletedAt    time.Time `json:"deleted_at"`
+	DeletedBy    string    `json:"deleted_by,omitempty"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+type TrashListing struct {
+	StackName     string      `json:"stack_name"`
+	RetentionDays int         `json:"retention_days"`
+	TotalSize     int64       `json:"total_size"`
+	Items         []TrashItem `json:"items"`
+}
+
+type RestoreTrashRequest struct {
+	TargetPath string `json:"target_path,omitempty"`
+	Conflict   string `json:"conflict,omitempty"`
+}
+
+type RestoreTrashResult struct {
+	ID              string `json:"id"`
+	Path            string `json:"path"`
+	Renamed         bool   `json:"renamed"`
+	ReplacedTrashID string `json:"replaced_trash_id,omitempty"`
 }
 
 type RenameRequest struct {
@@ -86,6 +142,175 @@ type DirectoryStats struct {
 	GroupName       string `json:"group_name,omitempty"`
 }
 
+type SearchRequest struct {
+	Path       string `json:"path,omitempty"`
+	Mode       string `json:"mode"`
+	Pattern    string `json:"pattern"`
+	Include    string `json:"include,omitempty"`
+	IgnoreCase bool   `json:"ignore_case,omitempty"`
+	Literal    bool   `json:"literal,omitempty"`
+	Context    *int   `json:"context,omitempty"`
+	Offset     int    `json:"offset,omitempty"`
+	Limit      int    `json:"limit,omitempty"`
+}
+
+type SearchContextLine struct {
+	Line int    `json:"line"`
+	Text string `json:"text"`
+}
+
+type SearchMatch struct {
+	Path        string              `json:"path"`
+	IsDirectory bool                `json:"is_directory,omitempty"`
+	Size        int64               `json:"size,omitempty"`
+	ModTime     time.Time           `json:"mod_time,omitzero"`
+	Line        int                 `json:"line,omitempty"`
+	Column      int                 `json:"column,omitempty"`
+	Text        string              `json:"text,omitempty"`
+	Before      []SearchContextLine `json:"before,omitempty"`
+	After       []SearchContextLine `json:"after,omitempty"`
+}
+
+type SearchResult struct {
+	Mode            string        `json:"mode"`
+	Pattern         string        `json:"pattern"`
+	Path            string        `json:"path"`
+	Matches         []SearchMatch `json:"matches"`
+	Offset          int           `json:"offset"`
+	Limit           int           `json:"limit"`
+	NextOffset      *int          `json:"next_offset,omitempty"`
+	FilesScanned    int           `json:"files_scanned"`
+	FilesSkipped    int           `json:"files_skipped"`
+	BinarySkipped   int           `json:"binary_skipped"`
+	Truncated       bool          `json:"truncated"`
+	TruncatedReason string        `json:"truncated_reason,omitempty"`
+}
+
+type CreateUploadRequest struct {
+	Path    string  `json:"path" validate:"required"`
+	Size    int64   `json:"size"`
+	Mode    *string `json:"mode,omitempty"`
+	OwnerID *uint32 `json:"owner_id,omitempty"`
+	GroupID *uint32 `json:"group_id,omitempty"`
+}
+
+type CompleteUploadRequest struct {
+	Checksum string `json:"checksum" validate:"required"`
+}
+
+type UploadSession struct {
+	ID           string    `json:"id"`
+	Path         string    `json:"path"`
+	Size         int64     `json:"size"`
+	Offset       int64     `json:"offset"`
+	Mode         *string   `json:"mode,omitempty"`
+	OwnerID      *uint32   `json:"owner_id,omitempty"`
+	GroupID      *uint32   `json:"group_id,omitempty"`
+	MaxChunkSize int64     `json:"max_chunk_size"`
+	Checksum     string    `json:"checksum,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	CreatedBy    string    `json:"created_by,omitempty"`
+}
+
+type WatchRequest struct {
+	Path      string `json:"path,omitempty"`
+	Recursive bool   `json:"recursive,omitempty"`
+	Lines     *int   `json:"lines,omitempty"`
+}
+
+type WatchEvent struct {
+	Type        string    `json:"type"`
+	Op          string    `json:"op,omitempty"`
+	Path        string    `json:"path,omitempty"`
+	IsDirectory bool      `json:"is_directory,omitempty"`
+	Data        string    `json:"data,omitempty"`
+	Encoding    string    `json:"encoding,omitempty"`
+	Offset      int64     `json:"offset,omitempty"`
+	Timestamp   time.Time `json:"timestamp"`
+}
+
+type ContainerDirectoryListing struct {
+	Container string      `json:"container"`
+	Path      string      `json:"path"`
+	Entries   []FileEntry `json:"entries"`
+	Truncated bool        `json:"truncated,omitempty"`
+}
+
+type ContainerUploadRequest struct {
+	Path       string  `json:"path"`
+	Filename   string  `json:"filename,omitempty"`
+	Mode       *string `json:"mode,omitempty"`
+	Extract    bool    `json:"extract,omitempty"`
+	CopyUIDGID bool    `json:"copy_uid_gid,omitempty"`
+}
+
+type ContainerCopyRequest struct {
+	ContainerPath string `json:"container_path" validate:"required"`
+	StackPath     string `json:"stack_path"`
+	Overwrite     bool   `json:"overwrite,omitempty"`
+	CopyUIDGID    bool   `json:"copy_uid_gid,omitempty"`
+}
+
+type ContainerCopyResult struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	Files       int    `json:"files"`
+	Directories int    `json:"directories"`
+	Bytes       int64  `json:"bytes"`
+	Skipped     int    `json:"skipped,omitempty"`
+}
+
+type ChangesetOperation struct {
+	Op        string  `json:"op" validate:"required"`
+	Path      string  `json:"path" validate:"required"`
+	NewPath   string  `json:"new_path,omitempty"`
+	Content   string  `json:"content,omitempty"`
+	Encoding  string  `json:"encoding,omitempty"`
+	Mode      *string `json:"mode,omitempty"`
+	OwnerID   *uint32 `json:"owner_id,omitempty"`
+	GroupID   *uint32 `json:"group_id,omitempty"`
+	Permanent bool    `json:"permanent,omitempty"`
+}
+
+type ChangesetRequest struct {
+	Operations []ChangesetOperation `json:"operations" validate:"required"`
+}
+
+type ChangesetOperationResult struct {
+	Op      string `json:"op"`
+	Path    string `json:"path"`
+	NewPath string `json:"new_path,omitempty"`
+	TrashID string `json:"trash_id,omitempty"`
+}
+
+type ChangesetResult struct {
+	ID         string                     `json:"id"`
+	Applied    int                        `json:"applied"`
+	Operations []ChangesetOperationResult `json:"operations"`
+}
+
+type StackUsage struct {
+	StackName       string    `json:"stack_name"`
+	StackBytes      int64     `json:"stack_bytes"`
+	VolumeBytes     int64     `json:"volume_bytes"`
+	VolumesIncluded bool      `json:"volumes_included"`
+	UsedBytes       int64     `json:"used_bytes"`
+	QuotaBytes      int64     `json:"quota_bytes"`
+	UsagePercent    float64   `json:"usage_percent,omitempty"`
+	Exceeded        bool      `json:"exceeded"`
+	ComputedAt      time.Time `json:"computed_at"`
+}
+
+type UsageReport struct {
+	Stacks          []StackUsage `json:"stacks"`
+	TotalBytes      int64        `json:"total_bytes"`
+	ExceededCount   int          `json:"exceeded_count"`
+	VolumesIncluded bool         `json:"volumes_included"`
+	GeneratedAt     time.Time    `json:"generated_at"`
+}
+
 type ErrorResponse struct {
 	Error   string `json:"error"`
 	Code    string `json:"code,omitempty"`
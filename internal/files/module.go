@@ -1,6 +1,8 @@
 package files
 
 import (
+	"context"
+
 	"go.uber.org/fx"
 )
 
@@ -9,4 +11,18 @@ var Module = fx.Options(
 		NewService,
 		NewHandler,
 	),
+	fx.Invoke(StartHousekeeping),
 )
+
+func StartHousekeeping(lc fx.Lifecycle, service *Service) {
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			service.Start()
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			service.Stop()
+			return nil
+		},
+	})
+}
@@ -0,0 +1,360 @@
+package files
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+)
+
+const (
+	SearchModeName    = "name"
+	SearchModeContent = "content"
+)
+
+const (
+	searchTimeout        = 10 * time.Second
+	searchMaxFileBytes   = 5 * 1024 * 1024
+	searchMaxTotalBytes  = 256 * 1024 * 1024
+	searchDefaultLimit   = 100
+	searchMaxLimit       = 1000
+	searchDefaultContext = 2
+	searchMaxContext     = 10
+	searchMaxLineLength  = 500
+	searchMaxPattern     = 1024
+	searchBinarySniff    = 8000
+)
+
+var searchSkippedDirectories = map[string]bool{
+	".git":   true,
+	".berth": true,
+}
+
+var errSearchPageFull = errors.New("search page full")
+
+type searcher struct {
+	req          SearchRequest
+	boundary     string
+	nameMatch    func(name, relativePath string) bool
+	includeMatch func(name, relativePath string) bool
+	content      *regexp.Regexp
+	result       *SearchResult
+	seen         int
+	bytesScanned int64
+}
+
+func (s *Service) Search(ctx context.Context, stackName string, req SearchRequest) (*SearchResult, error) {
+	if req.Mode == "" {
+		req.Mode = SearchModeContent
+	}
+	if req.Pattern == "" {
+		return nil, errors.New("pattern is required")
+	}
+	if len(req.Pattern) > searchMaxPattern {
+		return nil, fmt.Errorf("pattern is too long (max %d characters)", searchMaxPattern)
+	}
+	if req.Offset < 0 {
+		return nil, errors.New("offset must not be negative")
+	}
+	if req.Limit <= 0 {
+		req.Limit = searchDefaultLimit
+	}
+	req.Limit = min(req.Limit, searchMaxLimit)
+	if req.Context == nil {
+		contextLines := searchDefaultContext
+		req.Context = &contextLines
+	}
+	if *req.Context < 0 || *req.Context > searchMaxContext {
+		return nil, fmt.Errorf("context must be between 0 and %d lines", searchMaxContext)
+	}
+
+	realStackPath, err := s.validateStackPath(stackName, "")
+	if err != nil {
+		return nil, err
+	}
+	root, err := s.validateStackPath(stackName, req.Path)
+	if err != nil {
+		return nil, err
+	}
+	stat, err := os.Stat(root)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("path not found: %s", req.Path)
+		}
+		return nil, fmt.Errorf("cannot access path: %w", err)
+	}
+	if !stat.IsDir() {
+		return nil, fmt.Errorf("path is not a directory: %s", req.Path)
+	}
+
+	search := &searcher{
+		req:      req,
+		boundary: realStackPath,
+		result: &SearchResult{
+			Mode:    req.Mode,
+			Pattern: req.Pattern,
+			Path:    req.Path,
+			Offset:  req.Offset,
+			Limit:   req.Limit,
+			Matches: []SearchMatch{},
+		},
+	}
+
+	switch req.Mode {
+	case SearchModeName:
+		search.nameMatch, err = globMatcher(req.Pattern, req.IgnoreCase)
+		if err != nil {
+			return nil, err
+		}
+	case SearchModeContent:
+		expression := req.Pattern
+		if req.Literal {
+			expression = regexp.QuoteMeta(expression)
+		}
+		if req.IgnoreCase {
+			expression = "(?i)" + expression
+		}
+		search.content, err = regexp.Compile(expression)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression: %w", err)
+		}
+		if req.Include != "" {
+			search.includeMatch, err = globMatcher(req.Include, false)
+			if err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, fmt.Errorf("invalid search mode '%s': expected name or content", req.Mode)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, searchTimeout)
+	defer cancel()
+
+	start := time.Now()
+	walkErr := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			search.truncate("time limit reached")
+			return ctxErr
+		}
+		if err != nil {
+			search.result.FilesSkipped++
+			if entry != nil && entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if path == root {
+			return nil
+		}
+		return search.visit(path, entry)
+	})
+	if walkErr != nil && !errors.Is(walkErr, errSearchPageFull) && !errors.Is(walkErr, context.DeadlineExceeded) {
+		if errors.Is(walkErr, context.Canceled) {
+			return nil, walkErr
+		}
+		return nil, fmt.Errorf("search failed: %w", walkErr)
+	}
+
+	s.logger.Debug("search completed",
+		zap.String("operation", "search"),
+		zap.String("stack", stackName),
+		zap.String("mode", req.Mode),
+		zap.Int("matches", len(search.result.Matches)),
+		zap.Int("files_scanned", search.result.FilesScanned),
+		zap.Duration("duration", time.Since(start)),
+	)
+
+	return search.result, nil
+}
+
+func (sr *searcher) visit(path string, entry fs.DirEntry) error {
+	relativePath, err := filepath.Rel(sr.boundary, path)
+	if err != nil {
+		return nil
+	}
+	relativePath = filepath.ToSlash(relativePath)
+
+	if entry.IsDir() {
+		if searchSkippedDirectories[entry.Name()] {
+			return filepath.SkipDir
+		}
+		if sr.nameMatch != nil && sr.nameMatch(entry.Name(), relativePath) {
+			return sr.addNameMatch(relativePath, entry, path)
+		}
+		return nil
+	}
+
+	target := path
+	if entry.Type()&fs.ModeSymlink != 0 {
+		resolved, err := filepath.EvalSymlinks(path)
+		if err != nil || !isWithinDirectory(resolved, sr.boundary) {
+			sr.result.FilesSkipped++
+			return nil
+		}
+		target = resolved
+	} else if !entry.Type().IsRegular() {
+		return nil
+	}
+
+	if sr.nameMatch != nil {
+		if sr.nameMatch(entry.Name(), relativePath) {
+			return sr.addNameMatch(relativePath, entry, target)
+		}
+		return nil
+	}
+
+	if sr.includeMatch != nil && !sr.includeMatch(entry.Name(), relativePath) {
+		return nil
+	}
+	return sr.searchFile(target, relativePath)
+}
+
+func (sr *searcher) addNameMatch(relativePath string, entry fs.DirEntry, target string) error {
+	sr.result.FilesScanned++
+
+	match := SearchMatch{
+		Path:        relativePath,
+		IsDirectory: entry.IsDir(),
+	}
+	if info, err := os.Stat(target); err == nil {
+		match.Size = info.Size()
+		match.ModTime = info.ModTime()
+		match.IsDirectory = info.IsDir()
+	}
+	return sr.add(match)
+}
+
+func (sr *searcher) searchFile(path, relativePath string) error {
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		sr.result.FilesSkipped++
+		return nil
+	}
+	if info.Size() > searchMaxFileBytes {
+		sr.result.FilesSkipped++
+		return nil
+	}
+	if sr.bytesScanned+info.Size() > searchMaxTotalBytes {
+		sr.truncate("scan size limit reached")
+		return errSearchPageFull
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		sr.result.FilesSkipped++
+		return nil
+	}
+	sr.bytesScanned += int64(len(content))
+
+	if isBinaryContent(content) {
+		sr.result.BinarySkipped++
+		return nil
+	}
+	sr.result.FilesScanned++
+
+	lines := strings.Split(string(content), "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	contextLines := *sr.req.Context
+	for i, line := range lines {
+		line = strings.TrimSuffix(line, "\r")
+		location := sr.content.FindStringIndex(line)
+		if location == nil {
+			continue
+		}
+
+		match := SearchMatch{
+			Path:   relativePath,
+			Line:   i + 1,
+			Column: utf8.RuneCountInString(line[:location[0]]) + 1,
+			Text:   truncateSearchLine(line),
+		}
+		for j := max(i-contextLines, 0); j < i; j++ {
+			match.Before = append(match.Before, SearchContextLine{Line: j + 1, Text: truncateSearchLine(strings.TrimSuffix(lines[j], "\r"))})
+		}
+		for j := i + 1; j < len(lines) && j <= i+contextLines; j++ {
+			match.After = append(match.After, SearchContextLine{Line: j + 1, Text: truncateSearchLine(strings.TrimSuffix(lines[j], "\r"))})
+		}
+
+		if err := sr.add(match); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (sr *searcher) add(match SearchMatch) error {
+	sr.seen++
+	if sr.seen <= sr.req.Offset {
+		return nil
+	}
+	if len(sr.result.Matches) == sr.req.Limit {
+		next := sr.req.Offset + sr.req.Limit
+		sr.result.NextOffset = &next
+		return errSearchPageFull
+	}
+	sr.result.Matches = append(sr.result.Matches, match)
+	return nil
+}
+
+func (sr *searcher) truncate(reason string) {
+	if sr.result.Truncated {
+		return
+	}
+	sr.result.Truncated = true
+	sr.result.TruncatedReason = reason
+}
+
+func globMatcher(pattern string, ignoreCase bool) (func(name, relativePath string) bool, error) {
+	if ignoreCase {
+		pattern = strings.ToLower(pattern)
+	}
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid glob pattern: %w", err)
+	}
+
+	matchPath := strings.Contains(pattern, "/")
+	return func(name, relativePath string) bool {
+		candidate := name
+		if matchPath {
+			candidate = relativePath
+		}
+		if ignoreCase {
+			candidate = strings.ToLower(candidate)
+		}
+		matched, _ := filepath.Match(pattern, candidate)
+		return matched
+	}, nil
+}
+
+func isBinaryContent(content []byte) bool {
+	sniff := content[:min(len(content), searchBinarySniff)]
+	if bytes.IndexByte(sniff, 0) >= 0 {
+		return true
+	}
+	return !utf8.Valid(content)
+}
+
+func truncateSearchLine(line string) string {
+	if len(line) <= searchMaxLineLength {
+		return line
+	}
+	line = line[:searchMaxLineLength]
+	for !utf8.ValidString(line) {
+		line = line[:len(line)-1]
+	}
+	return line + "…"
+}
@@ -1,6 +1,7 @@
 package files
 
 import (
+	"context"
 	"encoding/base64"
 	"errors"
 	"fmt"
@@ -11,29 +12,99 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall"
+	"time"
 	"unicode/utf8"
 
 	"github.com/tech-arch1tect/berth-agent/config"
+	"github.com/tech-arch1tect/berth-agent/internal/archive"
+	"github.com/tech-arch1tect/berth-agent/internal/docker"
 	"github.com/tech-arch1tect/berth-agent/internal/logging"
+	"github.com/tech-arch1tect/berth-agent/internal/revisions"
 	"github.com/tech-arch1tect/berth-agent/internal/validation"
 
 	"go.uber.org/zap"
 )
 
 type Service struct {
-	stackLocation string
-	maxDownload   int64
-	maxUpload     int64
-	logger        *logging.Logger
+	stackLocation     string
+	maxDownload       int64
+	maxUpload         int64
+	maxChunkedUpload  int64
+	maxChunk          int64
+	trashRetention    time.Duration
+	uploadTTL         time.Duration
+	logger            *logging.Logger
+	revisions         *revisions.Service
+	archive           *archive.Service
+	dockerClient      *docker.Client
+	volumeHelperImage string
+	quotaDefault      int64
+	quotaOverrides    map[string]int64
+	quotaVolumes      bool
+	usageTTL          time.Duration
+	trashMu           sync.Mutex
+	uploadMu          sync.Mutex
+	changesetMu       sync.Mutex
+	usageMu           sync.Mutex
+	volumeRefreshMu   sync.Mutex
+	uploadsBusy       map[string]bool
+	usage             map[string]cachedUsage
+	reserved          map[string]int64
+	volumeUsageBytes  map[string]int64
+	volumeUsageAt     time.Time
+	watchSlots        chan struct{}
+	ctx               context.Context
+	cancel            context.CancelFunc
 }
 
-func NewService(cfg *config.Config, logger *logging.Logger) *Service {
+func NewService(cfg *config.Config, logger *logging.Logger, revisionsService *revisions.Service, dockerClient *docker.Client) *Service {
+	retentionDays := cfg.TrashRetentionDays
+	if retentionDays <= 0 {
+		retentionDays = 7
+	}
+
+	uploadTTLHours := cfg.UploadSessionTTLHours
+	if uploadTTLHours <= 0 {
+		uploadTTLHours = 24
+	}
+
+	maxChunk := cfg.MaxUploadBytes
+	if cfg.MaxSignedBodyBytes > 0 && (maxChunk <= 0 || cfg.MaxSignedBodyBytes < maxChunk) {
+		maxChunk = cfg.MaxSignedBodyBytes
+	}
+
+	usageCacheMinutes := cfg.StackUsageCacheMinutes
+	if usageCacheMinutes <= 0 {
+		usageCacheMinutes = 10
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
 	return &Service{
-		stackLocation: cfg.StackLocation,
-		maxDownload:   cfg.MaxDownloadBytes,
-		maxUpload:     cfg.MaxUploadBytes,
-		logger:        logger.With(zap.String("service", "files")),
+		stackLocation:     cfg.StackLocation,
+		maxDownload:       cfg.MaxDownloadBytes,
+		maxUpload:         cfg.MaxUploadBytes,
+		maxChunkedUpload:  cfg.MaxChunkedUploadBytes,
+		maxChunk:          maxChunk,
+		trashRetention:    time.Duration(retentionDays) * 24 * time.Hour,
+		uploadTTL:         time.Duration(uploadTTLHours) * time.Hour,
+		logger:            logger.With(zap.String("service", "files")),
+		revisions:         revisionsService,
+		archive:           archive.NewService(),
+		dockerClient:      dockerClient,
+		volumeHelperImage: cfg.VolumeHelperImage,
+		quotaDefault:      cfg.StackQuotaBytes,
+		quotaOverrides:    cfg.StackQuotas,
+		quotaVolumes:      cfg.StackQuotaVolumes,
+		usageTTL:          time.Duration(usageCacheMinutes) * time.Minute,
+		uploadsBusy:       make(map[string]bool),
+		usage:             make(map[string]cachedUsage),
+		reserved:          make(map[string]int64),
+		watchSlots:        make(chan struct{}, maxWatchSessions),
+		ctx:               ctx,
+		cancel:            cancel,
 	}
 }
 
@@ -91,6 +162,15 @@ func (s *Service) validateStackPath(stackName, relativePath string) (string, err
 		return "", errors.New("path resolves outside stack directory")
 	}
 
+	if isWithinDirectory(resolvedPath, filepath.Join(realStackPath, ".berth")) {
+		s.logger.Warn("internal directory access attempted",
+			zap.String("stack", stackName),
+			zap.String("requested_path", relativePath),
+			zap.String("full_path", resolvedPath),
+		)
+		return "", errors.New("the .berth directory is managed by the agent and cannot be accessed directly")
+	}
+
 	return resolvedPath, nil
 }
 
@@ -190,8 +270,13 @@ func (s *Service) ListDirectory(stackName, path string) (*DirectoryListing, erro
 		zap.Int("entry_count", len(entries)),
 	)
 
+	atRoot := filepath.Clean(strings.TrimPrefix(path, "/")) == "."
+
 	var fileEntries []FileEntry
 	for _, entry := range entries {
+		if atRoot && entry.Name() == ".berth" {
+			continue
+		}
 		info, err := entry.Info()
 		if err != nil {
 			continue
@@ -369,7 +454,7 @@ func (s *Service) OpenFile(stackName, path string) (*os.File, os.FileInfo, error
 	return file, stat, nil
 }
 
-func (s *Service) WriteFile(stackName string, req WriteFileRequest) error {
+func (s *Service) WriteFile(stackName string, req WriteFileRequest, actor revisions.Actor) error {
 	fullPath, err := s.validateStackPath(stackName, req.Path)
 	if err != nil {
 		return err
@@ -392,6 +477,35 @@ func (s *Service) WriteFile(stackName string, req WriteFileRequest) error {
 		content = []byte(req.Content)
 	}
 
+	if req.Validate || req.ValidateOnly {
+		if syntaxErr := validateSyntax(syntaxFormat(req.Path), content); syntaxErr != nil {
+			s.logger.Warn("file content failed syntax validation",
+				zap.String("operation", "write_file"),
+				zap.String("stack", stackName),
+				zap.String("path", req.Path),
+				zap.String("format", syntaxErr.Format),
+				zap.Int("line", syntaxErr.Line),
+				zap.Int("column", syntaxErr.Column),
+				zap.String("error", syntaxErr.Message),
+			)
+			return syntaxErr
+		}
+	}
+
+	if req.ValidateOnly {
+		return nil
+	}
+
+	unlock := s.revisions.LockFiles(stackName, req.Path)
+	defer unlock()
+
+	delta := int64(len(content)) - existingFileSize(fullPath)
+	release, err := s.reserveQuota(stackName, delta)
+	if err != nil {
+		return err
+	}
+	defer release()
+
 	dir := filepath.Dir(fullPath)
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		s.logger.Error("cannot create parent directory",
@@ -420,6 +534,19 @@ func (s *Service) WriteFile(stackName string, req WriteFileRequest) error {
 		fileMode = parsed
 	}
 
+	tracked := revisions.IsTracked(req.Path)
+	if tracked {
+		if err := s.revisions.Capture(stackName, req.Path); err != nil {
+			s.logger.Error("cannot preserve previous version",
+				zap.String("operation", "write_file"),
+				zap.String("stack", stackName),
+				zap.String("path", req.Path),
+				zap.Error(err),
+			)
+			return fmt.Errorf("cannot preserve previous version: %w", err)
+		}
+	}
+
 	tempPath := fullPath + ".tmp"
 	if err := os.WriteFile(tempPath, content, fileMode); err != nil {
 		s.logger.Error("cannot write file",
@@ -444,6 +571,18 @@ func (s *Service) WriteFile(stackName string, req WriteFileRequest) error {
 		)
 		return fmt.Errorf("cannot move file into place: %w", err)
 	}
+	s.recordUsage(stackName, delta)
+
+	if tracked {
+		if _, err := s.revisions.Record(stackName, req.Path, content, actor, revisions.SourceFileEditor); err != nil {
+			s.logger.Warn("cannot record file revision",
+				zap.String("operation", "write_file"),
+				zap.String("stack", stackName),
+				zap.String("path", req.Path),
+				zap.Error(err),
+			)
+		}
+	}
 
 	if req.OwnerID != nil || req.GroupID != nil {
 		uid := -1
@@ -623,10 +762,25 @@ func (s *Service) GetDirectoryStats(stackName string, req DirectoryStatsRequest)
 	}, nil
 }
 
-func (s *Service) Delete(stackName string, req DeleteRequest) error {
+func (s *Service) Delete(stackName string, req DeleteRequest, actor revisions.Actor) (*DeleteResult, error) {
 	fullPath, err := s.validateStackPath(stackName, req.Path)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	realStackPath, err := s.validateStackPath(stackName, "")
+	if err != nil {
+		return nil, err
+	}
+
+	if !s.trashable(realStackPath, fullPath) {
+		s.logger.Warn("refusing to delete protected path",
+			zap.String("operation", "delete"),
+			zap.String("stack", stackName),
+			zap.String("path", req.Path),
+			zap.String("full_path", fullPath),
+		)
+		return nil, ErrNotDeletable
 	}
 
 	stat, err := os.Stat(fullPath)
@@ -638,7 +792,7 @@ func (s *Service) Delete(stackName string, req DeleteRequest) error {
 				zap.String("path", req.Path),
 				zap.String("full_path", fullPath),
 			)
-			return nil
+			return &DeleteResult{Path: req.Path}, nil
 		}
 		s.logger.Error("cannot access path for deletion",
 			zap.String("operation", "delete"),
@@ -647,11 +801,28 @@ func (s *Service) Delete(stackName string, req DeleteRequest) error {
 			zap.String("full_path", fullPath),
 			zap.Error(err),
 		)
-		return fmt.Errorf("cannot access path: %w", err)
+		return nil, fmt.Errorf("cannot access path: %w", err)
 	}
 
 	isDirectory := stat.IsDir()
 
+	if !req.Permanent {
+		s.trashMu.Lock()
+		item, err := s.moveToTrashLocked(stackName, realStackPath, fullPath, stat, actor)
+		s.trashMu.Unlock()
+		if err != nil {
+			s.logger.Error("cannot move path to trash",
+				zap.String("operation", "delete"),
+				zap.String("stack", stackName),
+				zap.String("path", req.Path),
+				zap.String("full_path", fullPath),
+				zap.Error(err),
+			)
+			return nil, err
+		}
+		return &DeleteResult{Path: req.Path, TrashID: item.ID, ExpiresAt: &item.ExpiresAt}, nil
+	}
+
 	if isDirectory {
 		if err := os.RemoveAll(fullPath); err != nil {
 			s.logger.Error("cannot delete directory",
@@ -661,7 +832,7 @@ func (s *Service) Delete(stackName string, req DeleteRequest) error {
 				zap.String("full_path", fullPath),
 				zap.Error(err),
 			)
-			return fmt.Errorf("cannot delete directory: %w", err)
+			return nil, fmt.Errorf("cannot delete directory: %w", err)
 		}
 	} else {
 		if err := os.Remove(fullPath); err != nil {
@@ -672,10 +843,12 @@ func (s *Service) Delete(stackName string, req DeleteRequest) error {
 				zap.String("full_path", fullPath),
 				zap.Error(err),
 			)
-			return fmt.Errorf("cannot delete file: %w", err)
+			return nil, fmt.Errorf("cannot delete file: %w", err)
 		}
 	}
 
+	s.InvalidateUsage(stackName)
+
 	s.logger.Info("deleted successfully",
 		zap.String("operation", "delete"),
 		zap.String("stack", stackName),
@@ -684,7 +857,7 @@ func (s *Service) Delete(stackName string, req DeleteRequest) error {
 		zap.Bool("is_directory", isDirectory),
 	)
 
-	return nil
+	return &DeleteResult{Path: req.Path, Permanent: true}, nil
 }
 
 func (s *Service) Rename(stackName string, req RenameRequest) error {
@@ -797,6 +970,13 @@ func (s *Service) Copy(stackName string, req CopyRequest) error {
 		return fmt.Errorf("target path already exists: %s", req.TargetPath)
 	}
 
+	copySize := pathSize(sourceFullPath, sourceStat)
+	release, err := s.reserveQuota(stackName, copySize)
+	if err != nil {
+		return err
+	}
+	defer release()
+
 	dir := filepath.Dir(targetFullPath)
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		s.logger.Error("cannot create target directory for copy",
@@ -829,8 +1009,10 @@ func (s *Service) Copy(stackName string, req CopyRequest) error {
 			zap.Bool("is_directory", isDirectory),
 			zap.Error(copyErr),
 		)
+		s.InvalidateUsage(stackName)
 		return copyErr
 	}
+	s.recordUsage(stackName, copySize)
 
 	s.logger.Info("copied successfully",
 		zap.String("operation", "copy"),
@@ -931,6 +1113,13 @@ func (s *Service) WriteUploadedFile(stackName, path string, src io.Reader, size
 		return fmt.Errorf("file too large (>%dMB)", s.maxUpload/(1024*1024))
 	}
 
+	delta := size - existingFileSize(fullPath)
+	release, err := s.reserveQuota(stackName, delta)
+	if err != nil {
+		return err
+	}
+	defer release()
+
 	dir := filepath.Dir(fullPath)
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		s.logger.Error("cannot create directory for upload",
@@ -992,54 +1181,10 @@ func (s *Service) WriteUploadedFile(stackName, path string, src io.Reader, size
 		)
 		return fmt.Errorf("cannot move file into place: %w", err)
 	}
+	s.recordUsage(stackName, delta)
 
-	if mode != nil {
-		parsed, err := parseFileMode(*mode)
-		if err != nil {
-			s.logger.Error("invalid file mode for upload",
-				zap.String("operation", "upload_file"),
-				zap.String("stack", stackName),
-				zap.String("path", path),
-				zap.String("mode", *mode),
-				zap.Error(err),
-			)
-			return fmt.Errorf("invalid file mode: %w", err)
-		}
-		if err := os.Chmod(fullPath, parsed); err != nil {
-			s.logger.Error("cannot set permissions on uploaded file",
-				zap.String("operation", "upload_file"),
-				zap.String("stack", stackName),
-				zap.String("path", path),
-				zap.String("full_path", fullPath),
-				zap.Error(err),
-			)
-			return fmt.Errorf("cannot set file permissions: %w", err)
-		}
-	}
-
-	if ownerID != nil || groupID != nil {
-		uid := -1
-		gid := -1
-
-		if ownerID != nil {
-			uid = int(*ownerID)
-		}
-		if groupID != nil {
-			gid = int(*groupID)
-		}
-
-		if err := os.Chown(fullPath, uid, gid); err != nil {
-			s.logger.Error("cannot change ownership of uploaded file",
-				zap.String("operation", "upload_file"),
-				zap.String("stack", stackName),
-				zap.String("path", path),
-				zap.String("full_path", fullPath),
-				zap.Int("uid", uid),
-				zap.Int("gid", gid),
-				zap.Error(err),
-			)
-			return fmt.Errorf("cannot change ownership: %w", err)
-		}
+	if err := s.applyUploadAttributes(stackName, path, fullPath, mode, ownerID, groupID); err != nil {
+		return err
 	}
 
 	s.logger.Info("file uploaded successfully",
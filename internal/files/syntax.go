@@ -0,0 +1,293 @@
+package files
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/compose-spec/compose-go/v2/dotenv"
+	"github.com/pelletier/go-toml/v2"
+	"github.com/pelletier/go-toml/v2/unstable"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	SyntaxFormatYAML    = "yaml"
+	SyntaxFormatCompose = "compose"
+	SyntaxFormatJSON    = "json"
+	SyntaxFormatTOML    = "toml"
+	SyntaxFormatDotEnv  = "dotenv"
+)
+
+var (
+	syntaxLineRegex       = regexp.MustCompile(`line (\d+)`)
+	syntaxLinePrefixRegex = regexp.MustCompile(`^line \d+:\s*`)
+)
+
+var composeFileNames = map[string]bool{
+	"docker-compose.yml":  true,
+	"docker-compose.yaml": true,
+	"compose.yml":         true,
+	"compose.yaml":        true,
+}
+
+func (e *SyntaxError) Error() string {
+	switch {
+	case e.Line > 0 && e.Column > 0:
+		return fmt.Sprintf("invalid %s at line %d, column %d: %s", e.Format, e.Line, e.Column, e.Message)
+	case e.Line > 0:
+		return fmt.Sprintf("invalid %s at line %d: %s", e.Format, e.Line, e.Message)
+	default:
+		return fmt.Sprintf("invalid %s: %s", e.Format, e.Message)
+	}
+}
+
+func syntaxFormat(path string) string {
+	name := strings.ToLower(filepath.Base(path))
+
+	switch {
+	case composeFileNames[name]:
+		return SyntaxFormatCompose
+	case name == ".env" || strings.HasPrefix(name, ".env.") || strings.HasSuffix(name, ".env"):
+		return SyntaxFormatDotEnv
+	}
+
+	switch filepath.Ext(name) {
+	case ".yml", ".yaml":
+		return SyntaxFormatYAML
+	case ".json":
+		return SyntaxFormatJSON
+	case ".toml":
+		return SyntaxFormatTOML
+	}
+
+	return ""
+}
+
+func validateSyntax(format string, content []byte) *SyntaxError {
+	switch format {
+	case SyntaxFormatYAML:
+		return validateYAML(content)
+	case SyntaxFormatCompose:
+		return validateComposeSyntax(content)
+	case SyntaxFormatJSON:
+		return validateJSON(content)
+	case SyntaxFormatTOML:
+		return validateTOML(content)
+	case SyntaxFormatDotEnv:
+		return validateDotEnv(content)
+	}
+	return nil
+}
+
+func validateYAML(content []byte) *SyntaxError {
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	for {
+		var doc yaml.Node
+		err := decoder.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return lineSyntaxError(SyntaxFormatYAML, strings.TrimPrefix(err.Error(), "yaml: "))
+		}
+	}
+}
+
+func validateComposeSyntax(content []byte) *SyntaxError {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return lineSyntaxError(SyntaxFormatCompose, strings.TrimPrefix(err.Error(), "yaml: "))
+	}
+
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return &SyntaxError{Format: SyntaxFormatCompose, Message: "top-level object must be a mapping"}
+	}
+
+	root := doc.Content[0]
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, value := root.Content[i], root.Content[i+1]
+		switch key.Value {
+		case "services", "networks", "volumes", "secrets", "configs":
+			if value.Kind != yaml.MappingNode && value.Tag != "!!null" {
+				return &SyntaxError{
+					Format:  SyntaxFormatCompose,
+					Message: fmt.Sprintf("%s must be a mapping", key.Value),
+					Line:    value.Line,
+					Column:  value.Column,
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func validateJSON(content []byte) *SyntaxError {
+	var value any
+	err := json.Unmarshal(content, &value)
+	if err == nil {
+		return nil
+	}
+
+	result := &SyntaxError{Format: SyntaxFormatJSON, Message: err.Error()}
+
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		result.Line, result.Column = lineAndColumn(content, int(syntaxErr.Offset))
+	} else if len(bytes.TrimSpace(content)) == 0 {
+		result.Message = "document is empty"
+	}
+
+	return result
+}
+
+func validateDotEnv(content []byte) *SyntaxError {
+	if _, err := dotenv.UnmarshalBytesWithLookup(content, nil); err != nil {
+		result := lineSyntaxError(SyntaxFormatDotEnv, err.Error())
+		if strings.HasPrefix(result.Message, "unterminated quoted value") {
+			if line := unterminatedDotEnvQuoteLine(content); line > 0 {
+				result.Line = line
+			}
+		}
+		return result
+	}
+	return nil
+}
+
+func unterminatedDotEnvQuoteLine(content []byte) int {
+	lines := strings.Split(string(content), "\n")
+	for i := 0; i < len(lines); i++ {
+		trimmed := strings.TrimSpace(lines[i])
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		separator := strings.IndexAny(trimmed, "=:")
+		if separator < 0 {
+			continue
+		}
+		value := strings.TrimLeft(trimmed[separator+1:], " \t")
+		if value == "" || (value[0] != '"' && value[0] != '\'') {
+			continue
+		}
+
+		quote, text, start := value[0], value[1:], i
+		for !dotEnvQuoteClosed(text, quote) {
+			i++
+			if i >= len(lines) {
+				return start + 1
+			}
+			text = lines[i]
+		}
+	}
+	return 0
+}
+
+func dotEnvQuoteClosed(text string, quote byte) bool {
+	escaped := false
+	for i := 0; i < len(text); i++ {
+		switch {
+		case escaped:
+			escaped = false
+		case text[i] == '\\' && quote == '"':
+			escaped = true
+		case text[i] == quote:
+			return true
+		}
+	}
+	return false
+}
+
+func lineSyntaxError(format, message string) *SyntaxError {
+	result := &SyntaxError{Format: format, Message: message}
+	if match := syntaxLineRegex.FindStringSubmatch(message); match != nil {
+		result.Line, _ = strconv.Atoi(match[1])
+		result.Message = syntaxLinePrefixRegex.ReplaceAllString(message, "")
+	}
+	return result
+}
+
+func lineAndColumn(content []byte, offset int) (int, int) {
+	if offset > len(content) {
+		offset = len(content)
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	before := content[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := offset - bytes.LastIndexByte(before, '\n')
+	return line, column
+}
+
+func validateTOML(content []byte) *SyntaxError {
+	var value map[string]any
+	err := toml.Unmarshal(content, &value)
+	if err == nil {
+		return nil
+	}
+
+	result := &SyntaxError{Format: SyntaxFormatTOML, Message: strings.TrimPrefix(err.Error(), "toml: ")}
+
+	var decodeErr *toml.DecodeError
+	if errors.As(err, &decodeErr) {
+		result.Line, result.Column = decodeErr.Position()
+	} else {
+		result.Line, result.Column = tomlRedefinitionPosition(content)
+	}
+
+	return result
+}
+
+func tomlRedefinitionPosition(content []byte) (int, int) {
+	var parser unstable.Parser
+	parser.Reset(content)
+
+	seen := make(map[string]bool)
+	table := ""
+	arrayTables := make(map[string]int)
+	for parser.NextExpression() {
+		expr := parser.Expression()
+
+		var parts []string
+		var first *unstable.Node
+		for it := expr.Key(); it.Next(); {
+			if first == nil {
+				first = it.Node()
+			}
+			parts = append(parts, string(it.Node().Data))
+		}
+		if first == nil {
+			continue
+		}
+		key := strings.Join(parts, "\x00")
+
+		var path string
+		switch expr.Kind {
+		case unstable.Table:
+			table = key
+			path = "table:" + key
+		case unstable.ArrayTable:
+			arrayTables[key]++
+			table = key + "\x00#" + strconv.Itoa(arrayTables[key])
+			continue
+		case unstable.KeyValue:
+			path = "key:" + table + "\x00" + key
+		default:
+			continue
+		}
+
+		if seen[path] {
+			shape := parser.Shape(first.Raw)
+			return shape.Start.Line, shape.Start.Column
+		}
+		seen[path] = true
+	}
+	return 0, 0
+}
@@ -0,0 +1,473 @@
+package files
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/tech-arch1tect/berth-agent/internal/revisions"
+
+	"go.uber.org/zap"
+)
+
+const (
+	trashDirName      = ".berth/trash"
+	trashMetadataFile = "meta.json"
+	trashDataName     = "data"
+)
+
+const (
+	RestoreConflictFail      = "fail"
+	RestoreConflictRename    = "rename"
+	RestoreConflictOverwrite = "overwrite"
+)
+
+var (
+	ErrTrashItemNotFound = errors.New("trash item not found")
+	ErrRestoreConflict   = errors.New("restore target already exists")
+	ErrNotDeletable      = errors.New("the stack root and the .berth directory cannot be deleted")
+)
+
+var trashIDPattern = regexp.MustCompile(`^[0-9]{8}T[0-9]{6}Z-[0-9a-f]{8}$`)
+
+func (s *Service) Start() {
+	s.recoverChangesets()
+	s.expireTrash()
+	s.expireUploads()
+
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-s.ctx.Done():
+				return
+			case <-ticker.C:
+				s.expireTrash()
+				s.expireUploads()
+			}
+		}
+	}()
+}
+
+func (s *Service) Stop() {
+	s.cancel()
+}
+
+func (s *Service) moveToTrashLocked(stackName, realStackPath, fullPath string, info os.FileInfo, actor revisions.Actor) (*TrashItem, error) {
+	originalPath, err := filepath.Rel(realStackPath, fullPath)
+	if err != nil {
+		return nil, fmt.Errorf("cannot determine original path: %w", err)
+	}
+
+	id, err := newTrashID()
+	if err != nil {
+		return nil, err
+	}
+
+	item := &TrashItem{
+		ID:           id,
+		OriginalPath: filepath.ToSlash(originalPath),
+		Name:         info.Name(),
+		IsDirectory:  info.IsDir(),
+		Size:         pathSize(fullPath, info),
+		Mode:         fmt.Sprintf("%04o", info.Mode().Perm()),
+		DeletedAt:    time.Now().UTC(),
+		DeletedBy:    actor.Name,
+	}
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		item.OwnerID = stat.Uid
+		item.GroupID = stat.Gid
+	}
+
+	s.withExpiry(item)
+
+	itemDir := filepath.Join(realStackPath, trashDirName, id)
+	if err := os.MkdirAll(itemDir, 0700); err != nil {
+		return nil, fmt.Errorf("cannot create trash directory: %w", err)
+	}
+
+	if err := writeTrashMetadata(itemDir, item); err != nil {
+		_ = os.RemoveAll(itemDir)
+		return nil, err
+	}
+
+	if err := os.Rename(fullPath, filepath.Join(itemDir, trashDataName)); err != nil {
+		_ = os.RemoveAll(itemDir)
+		return nil, fmt.Errorf("cannot move to trash: %w", err)
+	}
+
+	s.logger.Info("moved to trash",
+		zap.String("operation", "trash"),
+		zap.String("stack", stackName),
+		zap.String("path", item.OriginalPath),
+		zap.String("trash_id", id),
+		zap.Bool("is_directory", item.IsDirectory),
+	)
+
+	return item, nil
+}
+
+func (s *Service) ListTrash(stackName string) (*TrashListing, error) {
+	realStackPath, err := s.validateStackPath(stackName, "")
+	if err != nil {
+		return nil, err
+	}
+
+	s.trashMu.Lock()
+	defer s.trashMu.Unlock()
+
+	s.expireStackTrash(stackName, realStackPath)
+
+	listing := &TrashListing{
+		StackName:     stackName,
+		RetentionDays: int(s.trashRetention / (24 * time.Hour)),
+		Items:         []TrashItem{},
+	}
+
+	entries, err := os.ReadDir(filepath.Join(realStackPath, trashDirName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return listing, nil
+		}
+		return nil, fmt.Errorf("cannot read trash: %w", err)
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() || !trashIDPattern.MatchString(entry.Name()) {
+			continue
+		}
+		item, err := readTrashMetadata(filepath.Join(realStackPath, trashDirName, entry.Name()))
+		if err != nil {
+			s.logger.Warn("skipping unreadable trash item",
+				zap.String("stack", stackName),
+				zap.String("trash_id", entry.Name()),
+				zap.Error(err),
+			)
+			continue
+		}
+		s.withExpiry(item)
+		listing.Items = append(listing.Items, *item)
+		listing.TotalSize += item.Size
+	}
+
+	sort.Slice(listing.Items, func(i, j int) bool {
+		return listing.Items[i].DeletedAt.After(listing.Items[j].DeletedAt)
+	})
+
+	return listing, nil
+}
+
+func (s *Service) RestoreTrash(stackName, id string, req RestoreTrashRequest, actor revisions.Actor) (*RestoreTrashResult, error) {
+	realStackPath, err := s.validateStackPath(stackName, "")
+	if err != nil {
+		return nil, err
+	}
+	if !trashIDPattern.MatchString(id) {
+		return nil, fmt.Errorf("%w: %s", ErrTrashItemNotFound, id)
+	}
+
+	conflict := req.Conflict
+	if conflict == "" {
+		conflict = RestoreConflictFail
+	}
+	if conflict != RestoreConflictFail && conflict != RestoreConflictRename && conflict != RestoreConflictOverwrite {
+		return nil, fmt.Errorf("invalid conflict strategy '%s': expected fail, rename or overwrite", conflict)
+	}
+
+	s.trashMu.Lock()
+	defer s.trashMu.Unlock()
+
+	itemDir := filepath.Join(realStackPath, trashDirName, id)
+	item, err := readTrashMetadata(itemDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%w: %s", ErrTrashItemNotFound, id)
+		}
+		return nil, err
+	}
+
+	targetPath := item.OriginalPath
+	if req.TargetPath != "" {
+		targetPath = req.TargetPath
+	}
+	fullPath, err := s.validateStackPath(stackName, targetPath)
+	if err != nil {
+		return nil, fmt.Errorf("invalid restore path: %w", err)
+	}
+	if !s.trashable(realStackPath, fullPath) {
+		return nil, errors.New("cannot restore over the stack root or the .berth directory")
+	}
+
+	result := &RestoreTrashResult{ID: id}
+
+	if _, err := os.Lstat(fullPath); err == nil {
+		switch conflict {
+		case RestoreConflictFail:
+			return nil, fmt.Errorf("%w: %s", ErrRestoreConflict, targetPath)
+		case RestoreConflictRename:
+			fullPath, err = availableRestorePath(fullPath)
+			if err != nil {
+				return nil, err
+			}
+			result.Renamed = true
+		case RestoreConflictOverwrite:
+			info, err := os.Stat(fullPath)
+			if err != nil {
+				return nil, fmt.Errorf("cannot access restore target: %w", err)
+			}
+			replaced, err := s.moveToTrashLocked(stackName, realStackPath, fullPath, info, actor)
+			if err != nil {
+				return nil, fmt.Errorf("cannot move existing target to trash: %w", err)
+			}
+			result.ReplacedTrashID = replaced.ID
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("cannot access restore target: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return nil, fmt.Errorf("cannot create parent directories: %w", err)
+	}
+
+	if err := os.Rename(filepath.Join(itemDir, trashDataName), fullPath); err != nil {
+		return nil, fmt.Errorf("cannot restore from trash: %w", err)
+	}
+	if err := os.RemoveAll(itemDir); err != nil {
+		s.logger.Warn("failed to remove restored trash entry",
+			zap.String("stack", stackName),
+			zap.String("trash_id", id),
+			zap.Error(err),
+		)
+	}
+
+	restoredPath, err := filepath.Rel(realStackPath, fullPath)
+	if err != nil {
+		return nil, fmt.Errorf("cannot determine restored path: %w", err)
+	}
+	result.Path = filepath.ToSlash(restoredPath)
+
+	s.logger.Info("restored from trash",
+		zap.String("operation", "restore"),
+		zap.String("stack", stackName),
+		zap.String("trash_id", id),
+		zap.String("original_path", item.OriginalPath),
+		zap.String("path", result.Path),
+	)
+
+	return result, nil
+}
+
+func (s *Service) PurgeTrash(stackName, id string) (int, error) {
+	realStackPath, err := s.validateStackPath(stackName, "")
+	if err != nil {
+		return 0, err
+	}
+
+	s.trashMu.Lock()
+	defer s.trashMu.Unlock()
+
+	trashPath := filepath.Join(realStackPath, trashDirName)
+
+	if id != "" {
+		itemDir := filepath.Join(trashPath, id)
+		if !trashIDPattern.MatchString(id) {
+			return 0, fmt.Errorf("%w: %s", ErrTrashItemNotFound, id)
+		}
+		if _, err := os.Stat(itemDir); os.IsNotExist(err) {
+			return 0, fmt.Errorf("%w: %s", ErrTrashItemNotFound, id)
+		}
+		if err := os.RemoveAll(itemDir); err != nil {
+			return 0, fmt.Errorf("cannot purge trash item: %w", err)
+		}
+		s.InvalidateUsage(stackName)
+		s.logger.Info("purged trash item",
+			zap.String("operation", "purge"),
+			zap.String("stack", stackName),
+			zap.String("trash_id", id),
+		)
+		return 1, nil
+	}
+
+	entries, err := os.ReadDir(trashPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("cannot read trash: %w", err)
+	}
+
+	purged := 0
+	defer s.InvalidateUsage(stackName)
+	for _, entry := range entries {
+		if !entry.IsDir() || !trashIDPattern.MatchString(entry.Name()) {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(trashPath, entry.Name())); err != nil {
+			return purged, fmt.Errorf("cannot purge trash item %s: %w", entry.Name(), err)
+		}
+		purged++
+	}
+
+	s.logger.Info("emptied trash",
+		zap.String("operation", "purge"),
+		zap.String("stack", stackName),
+		zap.Int("purged", purged),
+	)
+
+	return purged, nil
+}
+
+func (s *Service) expireTrash() {
+	entries, err := os.ReadDir(s.stackLocation)
+	if err != nil {
+		s.logger.Warn("failed to list stacks for trash expiry", zap.Error(err))
+		return
+	}
+
+	s.trashMu.Lock()
+	defer s.trashMu.Unlock()
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		s.expireStackTrash(entry.Name(), filepath.Join(s.stackLocation, entry.Name()))
+	}
+}
+
+func (s *Service) expireStackTrash(stackName, stackPath string) {
+	trashPath := filepath.Join(stackPath, trashDirName)
+	entries, err := os.ReadDir(trashPath)
+	if err != nil {
+		return
+	}
+
+	cutoff := time.Now().Add(-s.trashRetention)
+	expired := 0
+	for _, entry := range entries {
+		if !entry.IsDir() || !trashIDPattern.MatchString(entry.Name()) {
+			continue
+		}
+		itemDir := filepath.Join(trashPath, entry.Name())
+		item, err := readTrashMetadata(itemDir)
+		if err != nil || !item.DeletedAt.Before(cutoff) {
+			continue
+		}
+		if err := os.RemoveAll(itemDir); err != nil {
+			s.logger.Warn("failed to remove expired trash item",
+				zap.String("stack", stackName),
+				zap.String("trash_id", entry.Name()),
+				zap.Error(err),
+			)
+			continue
+		}
+		expired++
+	}
+
+	if expired > 0 {
+		s.InvalidateUsage(stackName)
+		s.logger.Info("expired trash items",
+			zap.String("stack", stackName),
+			zap.Int("expired", expired),
+		)
+	}
+}
+
+func (s *Service) withExpiry(item *TrashItem) {
+	item.ExpiresAt = item.DeletedAt.Add(s.trashRetention)
+}
+
+func (s *Service) trashable(realStackPath, fullPath string) bool {
+	berthPath := filepath.Join(realStackPath, ".berth")
+	return fullPath != realStackPath &&
+		!isWithinDirectory(berthPath, fullPath) &&
+		!isWithinDirectory(fullPath, berthPath)
+}
+
+func newTrashID() (string, error) {
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", fmt.Errorf("cannot generate trash id: %w", err)
+	}
+	return time.Now().UTC().Format("20060102T150405Z") + "-" + hex.EncodeToString(suffix), nil
+}
+
+func availableRestorePath(fullPath string) (string, error) {
+	dir := filepath.Dir(fullPath)
+	base := filepath.Base(fullPath)
+	ext := filepath.Ext(base)
+	stem := strings.TrimSuffix(base, ext)
+	if stem == "" {
+		stem, ext = base, ""
+	}
+
+	for i := 1; i <= 1000; i++ {
+		suffix := ".restored"
+		if i > 1 {
+			suffix += "-" + strconv.Itoa(i)
+		}
+		candidate := filepath.Join(dir, stem+suffix+ext)
+		if _, err := os.Lstat(candidate); os.IsNotExist(err) {
+			return candidate, nil
+		}
+	}
+
+	return "", fmt.Errorf("%w: no free name for %s", ErrRestoreConflict, base)
+}
+
+func pathSize(path string, info os.FileInfo) int64 {
+	if !info.IsDir() {
+		return info.Size()
+	}
+
+	var size int64
+	_ = filepath.WalkDir(path, func(_ string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if entry.Type().IsRegular() {
+			if entryInfo, err := entry.Info(); err == nil {
+				size += entryInfo.Size()
+			}
+		}
+		return nil
+	})
+	return size
+}
+
+func writeTrashMetadata(itemDir string, item *TrashItem) error {
+	data, err := json.MarshalIndent(item, "", "  ")
+	if err != nil {
+		return fmt.Errorf("cannot encode trash metadata: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(itemDir, trashMetadataFile), data, 0600); err != nil {
+		return fmt.Errorf("cannot write trash metadata: %w", err)
+	}
+	return nil
+}
+
+func readTrashMetadata(itemDir string) (*TrashItem, error) {
+	data, err := os.ReadFile(filepath.Join(itemDir, trashMetadataFile))
+	if err != nil {
+		return nil, err
+	}
+
+	var item TrashItem
+	if err := json.Unmarshal(data, &item); err != nil {
+		return nil, fmt.Errorf("cannot parse trash metadata: %w", err)
+	}
+	return &item, nil
+}
@@ -0,0 +1,608 @@
+package files
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/tech-arch1tect/berth-agent/internal/revisions"
+
+	"go.uber.org/zap"
+)
+
+const (
+	uploadsDirName      = ".berth/uploads"
+	uploadSessionFile   = "session.json"
+	uploadDataName      = "data"
+	uploadChecksumAlgo  = "sha256"
+	uploadChecksumBytes = sha256.Size
+)
+
+var (
+	ErrUploadNotFound       = errors.New("upload session not found")
+	ErrUploadBusy           = errors.New("upload session is busy")
+	ErrUploadOffsetMismatch = errors.New("chunk offset does not match received bytes")
+	ErrUploadIncomplete     = errors.New("upload is incomplete")
+	ErrChecksumMismatch     = errors.New("checksum mismatch")
+	ErrInsufficientStorage  = errors.New("insufficient disk space")
+)
+
+var uploadIDPattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+func (s *Service) CreateUpload(stackName string, req CreateUploadRequest, actor revisions.Actor) (*UploadSession, error) {
+	realStackPath, err := s.validateStackPath(stackName, "")
+	if err != nil {
+		return nil, err
+	}
+	fullPath, err := s.validateStackPath(stackName, req.Path)
+	if err != nil {
+		return nil, err
+	}
+	if fullPath == realStackPath || isWithinDirectory(fullPath, filepath.Join(realStackPath, ".berth")) {
+		return nil, errors.New("cannot upload to the stack root or the .berth directory")
+	}
+	if info, err := os.Stat(fullPath); err == nil && info.IsDir() {
+		return nil, fmt.Errorf("path is a directory: %s", req.Path)
+	}
+
+	if req.Size < 0 {
+		return nil, errors.New("size must not be negative")
+	}
+	if s.maxChunkedUpload > 0 && req.Size > s.maxChunkedUpload {
+		return nil, fmt.Errorf("file too large (>%dMB)", s.maxChunkedUpload/(1024*1024))
+	}
+	if req.Mode != nil {
+		if _, err := parseFileMode(*req.Mode); err != nil {
+			return nil, fmt.Errorf("invalid file mode: %w", err)
+		}
+	}
+
+	var fsStat syscall.Statfs_t
+	if err := syscall.Statfs(realStackPath, &fsStat); err == nil {
+		available := int64(fsStat.Bavail) * int64(fsStat.Bsize)
+		if req.Size > available {
+			return nil, fmt.Errorf("%w: %d bytes requested, %d bytes available", ErrInsufficientStorage, req.Size, available)
+		}
+	}
+
+	release, err := s.reserveQuota(stackName, req.Size+s.pendingUploadBytes(stackName))
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	id, err := newUploadID()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	session := &UploadSession{
+		ID:        id,
+		Path:      req.Path,
+		Size:      req.Size,
+		Mode:      req.Mode,
+		OwnerID:   req.OwnerID,
+		GroupID:   req.GroupID,
+		CreatedAt: now,
+		UpdatedAt: now,
+		CreatedBy: actor.Name,
+	}
+
+	sessionDir := filepath.Join(realStackPath, uploadsDirName, id)
+	if err := os.MkdirAll(sessionDir, 0700); err != nil {
+		return nil, fmt.Errorf("cannot create upload directory: %w", err)
+	}
+
+	dataFile, err := os.OpenFile(filepath.Join(sessionDir, uploadDataName), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		_ = os.RemoveAll(sessionDir)
+		return nil, fmt.Errorf("cannot create upload file: %w", err)
+	}
+	_ = dataFile.Close()
+
+	if err := writeUploadSession(sessionDir, session); err != nil {
+		_ = os.RemoveAll(sessionDir)
+		return nil, err
+	}
+
+	s.logger.Info("upload session created",
+		zap.String("operation", "create_upload"),
+		zap.String("stack", stackName),
+		zap.String("path", req.Path),
+		zap.String("upload_id", id),
+		zap.Int64("size", req.Size),
+	)
+
+	s.describeUpload(session, 0)
+	return session, nil
+}
+
+func (s *Service) GetUpload(stackName, id string) (*UploadSession, error) {
+	sessionDir, err := s.uploadSessionDir(stackName, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.loadUpload(sessionDir, id)
+}
+
+func (s *Service) ListUploads(stackName string) ([]UploadSession, error) {
+	realStackPath, err := s.validateStackPath(stackName, "")
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := []UploadSession{}
+	entries, err := os.ReadDir(filepath.Join(realStackPath, uploadsDirName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return sessions, nil
+		}
+		return nil, fmt.Errorf("cannot read uploads: %w", err)
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() || !uploadIDPattern.MatchString(entry.Name()) {
+			continue
+		}
+		session, err := s.loadUpload(filepath.Join(realStackPath, uploadsDirName, entry.Name()), entry.Name())
+		if err != nil {
+			continue
+		}
+		sessions = append(sessions, *session)
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].CreatedAt.Before(sessions[j].CreatedAt)
+	})
+
+	return sessions, nil
+}
+
+func (s *Service) WriteUploadChunk(stackName, id string, offset int64, src io.Reader) (*UploadSession, error) {
+	sessionDir, err := s.uploadSessionDir(stackName, id)
+	if err != nil {
+		return nil, err
+	}
+
+	release, err := s.acquireUpload(id)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	session, err := s.loadUpload(sessionDir, id)
+	if err != nil {
+		return nil, err
+	}
+	if offset != session.Offset {
+		return session, fmt.Errorf("%w: expected offset %d", ErrUploadOffsetMismatch, session.Offset)
+	}
+
+	limit := session.Size - session.Offset
+	if session.MaxChunkSize > 0 {
+		limit = min(limit, session.MaxChunkSize)
+	}
+
+	dataPath := filepath.Join(sessionDir, uploadDataName)
+	dataFile, err := os.OpenFile(dataPath, os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open upload file: %w", err)
+	}
+	defer func() { _ = dataFile.Close() }()
+
+	if _, err := dataFile.Seek(offset, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("cannot seek upload file: %w", err)
+	}
+
+	written, err := io.Copy(dataFile, io.LimitReader(src, limit+1))
+	if err == nil && written > limit {
+		err = fmt.Errorf("chunk exceeds the %d bytes allowed at offset %d", limit, offset)
+	}
+	if err == nil {
+		err = dataFile.Sync()
+	}
+	if err != nil {
+		if truncateErr := dataFile.Truncate(offset); truncateErr != nil {
+			s.logger.Error("cannot roll back failed upload chunk",
+				zap.String("operation", "upload_chunk"),
+				zap.String("stack", stackName),
+				zap.String("upload_id", id),
+				zap.Error(truncateErr),
+			)
+		}
+		s.logger.Warn("upload chunk rejected",
+			zap.String("operation", "upload_chunk"),
+			zap.String("stack", stackName),
+			zap.String("upload_id", id),
+			zap.Int64("offset", offset),
+			zap.Error(err),
+		)
+		return nil, fmt.Errorf("cannot write chunk: %w", err)
+	}
+
+	s.recordUsage(stackName, written)
+
+	session.UpdatedAt = time.Now().UTC()
+	if err := writeUploadSession(sessionDir, session); err != nil {
+		return nil, err
+	}
+
+	s.logger.Debug("upload chunk received",
+		zap.String("operation", "upload_chunk"),
+		zap.String("stack", stackName),
+		zap.String("upload_id", id),
+		zap.Int64("offset", offset),
+		zap.Int64("length", written),
+	)
+
+	s.describeUpload(session, offset+written)
+	return session, nil
+}
+
+func (s *Service) CompleteUpload(stackName, id string, req CompleteUploadRequest) (*UploadSession, error) {
+	sessionDir, err := s.uploadSessionDir(stackName, id)
+	if err != nil {
+		return nil, err
+	}
+
+	expected, err := parseUploadChecksum(req.Checksum)
+	if err != nil {
+		return nil, err
+	}
+
+	release, err := s.acquireUpload(id)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	session, err := s.loadUpload(sessionDir, id)
+	if err != nil {
+		return nil, err
+	}
+	if session.Offset != session.Size {
+		return session, fmt.Errorf("%w: received %d of %d bytes", ErrUploadIncomplete, session.Offset, session.Size)
+	}
+
+	dataPath := filepath.Join(sessionDir, uploadDataName)
+	actual, err := fileChecksum(dataPath)
+	if err != nil {
+		return nil, err
+	}
+	if actual != expected {
+		s.logger.Warn("upload checksum mismatch",
+			zap.String("operation", "complete_upload"),
+			zap.String("stack", stackName),
+			zap.String("upload_id", id),
+			zap.String("expected", expected),
+			zap.String("actual", actual),
+		)
+		return nil, fmt.Errorf("%w: expected %s:%s, got %s:%s", ErrChecksumMismatch, uploadChecksumAlgo, expected, uploadChecksumAlgo, actual)
+	}
+
+	fullPath, err := s.validateStackPath(stackName, session.Path)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(fullPath); err == nil && info.IsDir() {
+		return nil, fmt.Errorf("path is a directory: %s", session.Path)
+	}
+
+	dir := filepath.Dir(fullPath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("cannot create directory: %w", err)
+	}
+
+	if session.Mode == nil {
+		if err := os.Chmod(dataPath, 0644); err != nil {
+			return nil, fmt.Errorf("cannot set file permissions: %w", err)
+		}
+	}
+	if err := s.applyUploadAttributes(stackName, session.Path, dataPath, session.Mode, session.OwnerID, session.GroupID); err != nil {
+		return nil, err
+	}
+
+	replaced := existingFileSize(fullPath)
+	if err := os.Rename(dataPath, fullPath); err != nil {
+		s.logger.Error("cannot move uploaded file into place",
+			zap.String("operation", "complete_upload"),
+			zap.String("stack", stackName),
+			zap.String("path", session.Path),
+			zap.String("full_path", fullPath),
+			zap.Error(err),
+		)
+		return nil, fmt.Errorf("cannot move file into place: %w", err)
+	}
+
+	s.recordUsage(stackName, -replaced)
+
+	if err := os.RemoveAll(sessionDir); err != nil {
+		s.logger.Warn("failed to remove completed upload session",
+			zap.String("stack", stackName),
+			zap.String("upload_id", id),
+			zap.Error(err),
+		)
+	}
+
+	s.logger.Info("file uploaded successfully",
+		zap.String("operation", "complete_upload"),
+		zap.String("stack", stackName),
+		zap.String("path", session.Path),
+		zap.String("full_path", fullPath),
+		zap.String("upload_id", id),
+		zap.Int64("size", session.Size),
+	)
+
+	session.Checksum = uploadChecksumAlgo + ":" + actual
+	return session, nil
+}
+
+func (s *Service) AbortUpload(stackName, id string) error {
+	sessionDir, err := s.uploadSessionDir(stackName, id)
+	if err != nil {
+		return err
+	}
+
+	release, err := s.acquireUpload(id)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	if _, err := os.Stat(sessionDir); os.IsNotExist(err) {
+		return fmt.Errorf("%w: %s", ErrUploadNotFound, id)
+	}
+	staged := existingFileSize(filepath.Join(sessionDir, uploadDataName))
+	if err := os.RemoveAll(sessionDir); err != nil {
+		return fmt.Errorf("cannot remove upload session: %w", err)
+	}
+	s.recordUsage(stackName, -staged)
+
+	s.logger.Info("upload session aborted",
+		zap.String("operation", "abort_upload"),
+		zap.String("stack", stackName),
+		zap.String("upload_id", id),
+	)
+
+	return nil
+}
+
+func (s *Service) expireUploads() {
+	entries, err := os.ReadDir(s.stackLocation)
+	if err != nil {
+		s.logger.Warn("failed to list stacks for upload expiry", zap.Error(err))
+		return
+	}
+
+	cutoff := time.Now().Add(-s.uploadTTL)
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		uploadsPath := filepath.Join(s.stackLocation, entry.Name(), uploadsDirName)
+		sessions, err := os.ReadDir(uploadsPath)
+		if err != nil {
+			continue
+		}
+
+		for _, sessionEntry := range sessions {
+			id := sessionEntry.Name()
+			if !sessionEntry.IsDir() || !uploadIDPattern.MatchString(id) {
+				continue
+			}
+			sessionDir := filepath.Join(uploadsPath, id)
+			session, err := readUploadSession(sessionDir)
+			if err != nil || !session.UpdatedAt.Before(cutoff) {
+				continue
+			}
+
+			release, err := s.acquireUpload(id)
+			if err != nil {
+				continue
+			}
+			if err := os.RemoveAll(sessionDir); err != nil {
+				s.logger.Warn("failed to remove expired upload session",
+					zap.String("stack", entry.Name()),
+					zap.String("upload_id", id),
+					zap.Error(err),
+				)
+			} else {
+				s.InvalidateUsage(entry.Name())
+				s.logger.Info("expired upload session",
+					zap.String("stack", entry.Name()),
+					zap.String("upload_id", id),
+					zap.String("path", session.Path),
+				)
+			}
+			release()
+		}
+	}
+}
+
+func (s *Service) applyUploadAttributes(stackName, path, target string, mode *string, ownerID *uint32, groupID *uint32) error {
+	if mode != nil {
+		parsed, err := parseFileMode(*mode)
+		if err != nil {
+			s.logger.Error("invalid file mode for upload",
+				zap.String("operation", "upload_file"),
+				zap.String("stack", stackName),
+				zap.String("path", path),
+				zap.String("mode", *mode),
+				zap.Error(err),
+			)
+			return fmt.Errorf("invalid file mode: %w", err)
+		}
+		if err := os.Chmod(target, parsed); err != nil {
+			s.logger.Error("cannot set permissions on uploaded file",
+				zap.String("operation", "upload_file"),
+				zap.String("stack", stackName),
+				zap.String("path", path),
+				zap.String("full_path", target),
+				zap.Error(err),
+			)
+			return fmt.Errorf("cannot set file permissions: %w", err)
+		}
+	}
+
+	if ownerID != nil || groupID != nil {
+		uid := -1
+		gid := -1
+
+		if ownerID != nil {
+			uid = int(*ownerID)
+		}
+		if groupID != nil {
+			gid = int(*groupID)
+		}
+
+		if err := os.Chown(target, uid, gid); err != nil {
+			s.logger.Error("cannot change ownership of uploaded file",
+				zap.String("operation", "upload_file"),
+				zap.String("stack", stackName),
+				zap.String("path", path),
+				zap.String("full_path", target),
+				zap.Int("uid", uid),
+				zap.Int("gid", gid),
+				zap.Error(err),
+			)
+			return fmt.Errorf("cannot change ownership: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (s *Service) uploadSessionDir(stackName, id string) (string, error) {
+	realStackPath, err := s.validateStackPath(stackName, "")
+	if err != nil {
+		return "", err
+	}
+	if !uploadIDPattern.MatchString(id) {
+		return "", fmt.Errorf("%w: %s", ErrUploadNotFound, id)
+	}
+	return filepath.Join(realStackPath, uploadsDirName, id), nil
+}
+
+func (s *Service) loadUpload(sessionDir, id string) (*UploadSession, error) {
+	session, err := readUploadSession(sessionDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%w: %s", ErrUploadNotFound, id)
+		}
+		return nil, err
+	}
+
+	info, err := os.Stat(filepath.Join(sessionDir, uploadDataName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%w: %s", ErrUploadNotFound, id)
+		}
+		return nil, fmt.Errorf("cannot access upload file: %w", err)
+	}
+
+	s.describeUpload(session, info.Size())
+	return session, nil
+}
+
+func (s *Service) describeUpload(session *UploadSession, offset int64) {
+	session.Offset = offset
+	session.ExpiresAt = session.UpdatedAt.Add(s.uploadTTL)
+	session.MaxChunkSize = s.maxChunk
+}
+
+func (s *Service) acquireUpload(id string) (func(), error) {
+	s.uploadMu.Lock()
+	defer s.uploadMu.Unlock()
+
+	if s.uploadsBusy[id] {
+		return nil, fmt.Errorf("%w: %s", ErrUploadBusy, id)
+	}
+	s.uploadsBusy[id] = true
+
+	return func() {
+		s.uploadMu.Lock()
+		delete(s.uploadsBusy, id)
+		s.uploadMu.Unlock()
+	}, nil
+}
+
+func newUploadID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("cannot generate upload id: %w", err)
+	}
+	return hex.EncodeToString(id), nil
+}
+
+func parseUploadChecksum(checksum string) (string, error) {
+	checksum = strings.ToLower(strings.TrimSpace(checksum))
+	if checksum == "" {
+		return "", errors.New("checksum is required")
+	}
+	if algorithm, value, found := strings.Cut(checksum, ":"); found {
+		if algorithm != uploadChecksumAlgo {
+			return "", fmt.Errorf("unsupported checksum algorithm '%s': expected %s", algorithm, uploadChecksumAlgo)
+		}
+		checksum = value
+	}
+	if decoded, err := hex.DecodeString(checksum); err != nil || len(decoded) != uploadChecksumBytes {
+		return "", fmt.Errorf("checksum must be a hex-encoded %s digest", uploadChecksumAlgo)
+	}
+	return checksum, nil
+}
+
+func fileChecksum(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("cannot open upload file: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", fmt.Errorf("cannot checksum upload file: %w", err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func writeUploadSession(sessionDir string, session *UploadSession) error {
+	data, err := json.MarshalIndent(session, "", "  ")
+	if err != nil {
+		return fmt.Errorf("cannot encode upload session: %w", err)
+	}
+
+	tempPath := filepath.Join(sessionDir, uploadSessionFile+".tmp")
+	if err := os.WriteFile(tempPath, data, 0600); err != nil {
+		return fmt.Errorf("cannot write upload session: %w", err)
+	}
+	if err := os.Rename(tempPath, filepath.Join(sessionDir, uploadSessionFile)); err != nil {
+		_ = os.Remove(tempPath)
+		return fmt.Errorf("cannot write upload session: %w", err)
+	}
+	return nil
+}
+
+func readUploadSession(sessionDir string) (*UploadSession, error) {
+	data, err := os.ReadFile(filepath.Join(sessionDir, uploadSessionFile))
+	if err != nil {
+		return nil, err
+	}
+
+	var session UploadSession
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, fmt.Errorf("cannot parse upload session: %w", err)
+	}
+	return &session, nil
+}
@@ -0,0 +1,320 @@
+package files
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tech-arch1tect/berth-agent/internal/docker"
+
+	"go.uber.org/zap"
+)
+
+var ErrQuotaExceeded = errors.New("stack disk quota exceeded")
+
+type cachedUsage struct {
+	bytes      int64
+	computedAt time.Time
+}
+
+func (s *Service) GetStackUsage(ctx context.Context, stackName string, refresh bool) (*StackUsage, error) {
+	realStackPath, err := s.validateStackPath(stackName, "")
+	if err != nil {
+		return nil, err
+	}
+	return s.measureUsage(ctx, stackName, realStackPath, refresh, refresh), nil
+}
+
+func (s *Service) GetUsageReport(ctx context.Context, refresh bool) (*UsageReport, error) {
+	entries, err := os.ReadDir(s.stackLocation)
+	if err != nil {
+		return nil, fmt.Errorf("cannot list stacks: %w", err)
+	}
+
+	if refresh && s.quotaVolumes {
+		s.volumeBytes(ctx, "", true)
+	}
+
+	report := &UsageReport{
+		Stacks:          []StackUsage{},
+		VolumesIncluded: s.quotaVolumes,
+		GeneratedAt:     time.Now().UTC(),
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		realStackPath, err := s.validateStackPath(entry.Name(), "")
+		if err != nil {
+			continue
+		}
+
+		usage := s.measureUsage(ctx, entry.Name(), realStackPath, refresh, false)
+		report.TotalBytes += usage.UsedBytes
+		if usage.Exceeded {
+			report.ExceededCount++
+		}
+		report.Stacks = append(report.Stacks, *usage)
+	}
+
+	return report, nil
+}
+
+func (s *Service) QuotaRemaining(stackName string) (int64, bool, error) {
+	quota := s.stackQuota(stackName)
+	if quota <= 0 {
+		return 0, false, nil
+	}
+
+	realStackPath, err := s.validateStackPath(stackName, "")
+	if err != nil {
+		return 0, true, err
+	}
+
+	usage := s.measureUsage(s.ctx, stackName, realStackPath, false, false)
+
+	s.usageMu.Lock()
+	defer s.usageMu.Unlock()
+
+	return quota - s.committedUsageLocked(stackName, usage), true, nil
+}
+
+func (s *Service) InvalidateUsage(stackName string) {
+	s.usageMu.Lock()
+	defer s.usageMu.Unlock()
+
+	delete(s.usage, stackName)
+}
+
+func (s *Service) reserveQuota(stackName string, additional int64) (func(), error) {
+	release := func() {}
+	if additional <= 0 {
+		return release, nil
+	}
+
+	quota := s.stackQuota(stackName)
+	if quota <= 0 {
+		return release, nil
+	}
+
+	realStackPath, err := s.validateStackPath(stackName, "")
+	if err != nil {
+		return release, err
+	}
+	usage := s.measureUsage(s.ctx, stackName, realStackPath, false, false)
+
+	s.usageMu.Lock()
+	defer s.usageMu.Unlock()
+
+	used := s.committedUsageLocked(stackName, usage)
+	if additional > quota-used {
+		s.logger.Warn("stack quota exceeded",
+			zap.String("operation", "quota"),
+			zap.String("stack", stackName),
+			zap.Int64("requested", additional),
+			zap.Int64("used", used),
+			zap.Int64("quota", quota),
+		)
+		return release, fmt.Errorf("%w: %d bytes requested, %d of %d bytes used", ErrQuotaExceeded, additional, used, quota)
+	}
+
+	s.reserved[stackName] += additional
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.usageMu.Lock()
+			defer s.usageMu.Unlock()
+
+			s.reserved[stackName] -= additional
+			if s.reserved[stackName] <= 0 {
+				delete(s.reserved, stackName)
+			}
+		})
+	}, nil
+}
+
+func (s *Service) committedUsageLocked(stackName string, usage *StackUsage) int64 {
+	used := usage.UsedBytes
+	if cached, ok := s.usage[stackName]; ok {
+		used = cached.bytes + usage.VolumeBytes
+	}
+	return used + s.reserved[stackName]
+}
+
+func (s *Service) recordUsage(stackName string, delta int64) {
+	if delta == 0 {
+		return
+	}
+
+	s.usageMu.Lock()
+	defer s.usageMu.Unlock()
+
+	if cached, ok := s.usage[stackName]; ok {
+		cached.bytes = max(cached.bytes+delta, 0)
+		s.usage[stackName] = cached
+	}
+}
+
+func (s *Service) recordVolumeUsage(stackName string, delta int64) {
+	if delta == 0 || !s.quotaVolumes {
+		return
+	}
+
+	s.usageMu.Lock()
+	defer s.usageMu.Unlock()
+
+	if s.volumeUsageBytes != nil {
+		s.volumeUsageBytes[stackName] = max(s.volumeUsageBytes[stackName]+delta, 0)
+	}
+}
+
+func (s *Service) stackQuota(stackName string) int64 {
+	if quota, ok := s.quotaOverrides[stackName]; ok {
+		return quota
+	}
+	return s.quotaDefault
+}
+
+func (s *Service) measureUsage(ctx context.Context, stackName, realStackPath string, refreshStack, refreshVolumes bool) *StackUsage {
+	s.usageMu.Lock()
+	cached, ok := s.usage[stackName]
+	s.usageMu.Unlock()
+
+	if !ok || refreshStack || time.Since(cached.computedAt) > s.usageTTL {
+		cached = cachedUsage{computedAt: time.Now().UTC()}
+		cached.bytes = stackUsageBytes(realStackPath)
+
+		s.usageMu.Lock()
+		s.usage[stackName] = cached
+		s.usageMu.Unlock()
+
+		s.logger.Debug("measured stack usage",
+			zap.String("operation", "quota"),
+			zap.String("stack", stackName),
+			zap.Int64("bytes", cached.bytes),
+		)
+	}
+
+	usage := &StackUsage{
+		StackName:       stackName,
+		StackBytes:      cached.bytes,
+		VolumesIncluded: s.quotaVolumes,
+		QuotaBytes:      s.stackQuota(stackName),
+		ComputedAt:      cached.computedAt,
+	}
+	if s.quotaVolumes {
+		usage.VolumeBytes = s.volumeBytes(ctx, stackName, refreshVolumes)
+	}
+
+	usage.UsedBytes = usage.StackBytes + usage.VolumeBytes
+	if usage.QuotaBytes > 0 {
+		usage.UsagePercent = float64(usage.UsedBytes) * 100 / float64(usage.QuotaBytes)
+		usage.Exceeded = usage.UsedBytes > usage.QuotaBytes
+	}
+
+	return usage
+}
+
+func (s *Service) volumeBytes(ctx context.Context, stackName string, refresh bool) int64 {
+	if !refresh {
+		if bytes, ok := s.cachedVolumeBytes(stackName); ok {
+			return bytes
+		}
+	}
+	if s.dockerClient == nil {
+		return 0
+	}
+
+	s.volumeRefreshMu.Lock()
+	defer s.volumeRefreshMu.Unlock()
+
+	if !refresh {
+		if bytes, ok := s.cachedVolumeBytes(stackName); ok {
+			return bytes
+		}
+	}
+
+	volumes, err := s.dockerClient.VolumeDiskUsage(ctx)
+
+	s.usageMu.Lock()
+	defer s.usageMu.Unlock()
+
+	if err != nil {
+		s.logger.Warn("cannot measure volume usage",
+			zap.String("operation", "quota"),
+			zap.Error(err),
+		)
+		if s.volumeUsageBytes == nil {
+			s.volumeUsageBytes = make(map[string]int64)
+		}
+		s.volumeUsageAt = time.Now()
+		return s.volumeUsageBytes[stackName]
+	}
+
+	usage := make(map[string]int64)
+	for _, vol := range volumes {
+		project := vol.Labels[docker.LabelComposeProject]
+		if project == "" || vol.UsageData == nil || vol.UsageData.Size < 0 {
+			continue
+		}
+		usage[project] += vol.UsageData.Size
+	}
+
+	s.volumeUsageBytes = usage
+	s.volumeUsageAt = time.Now()
+	return usage[stackName]
+}
+
+func (s *Service) cachedVolumeBytes(stackName string) (int64, bool) {
+	s.usageMu.Lock()
+	defer s.usageMu.Unlock()
+
+	if s.volumeUsageBytes == nil || time.Since(s.volumeUsageAt) > s.usageTTL {
+		return 0, false
+	}
+	return s.volumeUsageBytes[stackName], true
+}
+
+func (s *Service) pendingUploadBytes(stackName string) int64 {
+	sessions, err := s.ListUploads(stackName)
+	if err != nil {
+		return 0
+	}
+
+	var pending int64
+	for _, session := range sessions {
+		pending += max(session.Size-session.Offset, 0)
+	}
+	return pending
+}
+
+func stackUsageBytes(realStackPath string) int64 {
+	var size int64
+	_ = filepath.WalkDir(realStackPath, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if entry.Type().IsRegular() {
+			if info, err := entry.Info(); err == nil {
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+	return size
+}
+
+func existingFileSize(path string) int64 {
+	if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+		return info.Size()
+	}
+	return 0
+}
@@ -0,0 +1,744 @@
+package files
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/docker/docker/api/types/mount"
+	"github.com/tech-arch1tect/berth-agent/internal/docker"
+
+	"go.uber.org/zap"
+)
+
+const (
+	volumeMountPath     = "/volume"
+	volumeStagingPath   = "/berth-upload"
+	volumeReadLimit     = 10 * 1024 * 1024
+	volumeListingLimit  = 32 * 1024 * 1024
+	volumeStderrLimit   = 4096
+	volumeHeaderLimit   = 256
+	volumeDefaultMode   = "0644"
+	volumeFileHeaderTag = "BERTH_FILE"
+)
+
+const (
+	volumeExitNotFound     = 10
+	volumeExitOutside      = 11
+	volumeExitNotDirectory = 12
+	volumeExitIsDirectory  = 13
+	volumeExitTooLarge     = 14
+	volumeExitNotRegular   = 15
+)
+
+var ErrVolumeNotFound = errors.New("volume not found")
+
+const volumeScriptPrelude = `resolve() {
+  r=$(readlink -f "$1" 2>/dev/null) && [ -n "$r" ] || exit 10
+  case "$r" in
+    /volume|/volume/*) ;;
+    *) exit 11 ;;
+  esac
+}
+`
+
+const volumeListScript = `resolve "$1"
+[ -e "$r" ] || exit 10
+[ -d "$r" ] || exit 12
+cd "$r" || exit 1
+for f in * .[!.]* ..?*; do
+  [ -e "$f" ] || [ -L "$f" ] || continue
+  stat -c '%f %s %Y %u %g' "./$f" || continue
+  printf '%s\0' "$f"
+done
+`
+
+const volumeReadScript = `resolve "$1"
+[ -e "$r" ] || exit 10
+[ -d "$r" ] && exit 13
+[ -f "$r" ] || exit 15
+size=$(stat -c %s "$r") || exit 1
+if [ "$2" -gt 0 ] && [ "$size" -gt "$2" ]; then exit 14; fi
+stat -c 'BERTH_FILE %s %Y' "$r" || exit 1
+exec cat "$r"
+`
+
+const volumeWriteScript = `dir=$(dirname "$1")
+p="$dir"
+while [ ! -e "$p" ] && [ ! -L "$p" ]; do p=$(dirname "$p"); done
+resolve "$p"
+mkdir -p "$dir" || exit 1
+resolve "$dir"
+[ -d "$r" ] || exit 12
+t="$r/$(basename "$1")"
+if [ -L "$t" ]; then
+  resolve "$t"
+  t="$r"
+fi
+[ -d "$t" ] && exit 13
+tmp="$(dirname "$t")/.berth-upload.$$"
+if cp /berth-upload "$tmp" && chmod "$2" "$tmp" && { [ -z "$3" ] || chown "$3" "$tmp"; } && mv -f "$tmp" "$t"; then
+  exit 0
+fi
+rm -f "$tmp"
+exit 1
+`
+
+const volumeSizeScript = `[ -e "$1" ] || [ -L "$1" ] || exit 0
+resolve "$1"
+[ -f "$r" ] && stat -c %s "$r"
+exit 0
+`
+
+const volumeDeleteScript = `resolve "$(dirname "$1")"
+t="$r/$(basename "$1")"
+[ "$t" = /volume ] && exit 11
+[ -e "$t" ] || [ -L "$t" ] || exit 10
+rm -rf -- "$t"
+`
+
+type VolumeFileInfo struct {
+	Path    string
+	Size    int64
+	ModTime time.Time
+}
+
+func (s *Service) ListVolumeDirectory(ctx context.Context, stackName, volumeName, path string) (*DirectoryListing, error) {
+	target, err := volumeTargetPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	stdout := &cappedBuffer{limit: volumeListingLimit}
+	if err := s.runVolumeHelper(ctx, stackName, volumeName, true, volumeListScript, []string{target}, stdout, path, 0); err != nil {
+		s.logger.Error("cannot list volume directory",
+			zap.String("operation", "list_volume_directory"),
+			zap.String("stack", stackName),
+			zap.String("volume", volumeName),
+			zap.String("path", path),
+			zap.Error(err),
+		)
+		return nil, err
+	}
+	if stdout.overflow {
+		return nil, errors.New("directory listing too large")
+	}
+
+	entries, err := parseVolumeListing(stdout.buf.Bytes(), path)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].IsDirectory != entries[j].IsDirectory {
+			return entries[i].IsDirectory
+		}
+		return entries[i].Name < entries[j].Name
+	})
+
+	s.logger.Debug("listing volume directory",
+		zap.String("operation", "list_volume_directory"),
+		zap.String("stack", stackName),
+		zap.String("volume", volumeName),
+		zap.String("path", path),
+		zap.Int("entry_count", len(entries)),
+	)
+
+	return &DirectoryListing{
+		Path:    path,
+		Entries: entries,
+	}, nil
+}
+
+func (s *Service) ReadVolumeFile(ctx context.Context, stackName, volumeName, path string) (*FileContent, error) {
+	content := &cappedBuffer{limit: volumeReadLimit}
+	info, err := s.streamVolumeFile(ctx, stackName, volumeName, path, volumeReadLimit, func(VolumeFileInfo) (io.Writer, error) {
+		return content, nil
+	})
+	if err != nil {
+		s.logger.Error("cannot read volume file",
+			zap.String("operation", "read_volume_file"),
+			zap.String("stack", stackName),
+			zap.String("volume", volumeName),
+			zap.String("path", path),
+			zap.Error(err),
+		)
+		return nil, err
+	}
+
+	encoding := "utf-8"
+	contentStr := ""
+
+	if utf8.Valid(content.buf.Bytes()) {
+		contentStr = content.buf.String()
+	} else {
+		encoding = "base64"
+		contentStr = base64.StdEncoding.EncodeToString(content.buf.Bytes())
+	}
+
+	s.logger.Info("volume file read successfully",
+		zap.String("operation", "read_volume_file"),
+		zap.String("stack", stackName),
+		zap.String("volume", volumeName),
+		zap.String("path", path),
+		zap.Int64("size", info.Size),
+		zap.String("encoding", encoding),
+	)
+
+	return &FileContent{
+		Path:     path,
+		Content:  contentStr,
+		Size:     info.Size,
+		Encoding: encoding,
+	}, nil
+}
+
+func (s *Service) DownloadVolumeFile(ctx context.Context, stackName, volumeName, path string, start func(VolumeFileInfo) (io.Writer, error)) error {
+	info, err := s.streamVolumeFile(ctx, stackName, volumeName, path, s.maxDownload, start)
+	if err != nil {
+		s.logger.Error("cannot download volume file",
+			zap.String("operation", "download_volume_file"),
+			zap.String("stack", stackName),
+			zap.String("volume", volumeName),
+			zap.String("path", path),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	s.logger.Info("volume file downloaded",
+		zap.String("operation", "download_volume_file"),
+		zap.String("stack", stackName),
+		zap.String("volume", volumeName),
+		zap.String("path", path),
+		zap.Int64("size", info.Size),
+	)
+	return nil
+}
+
+func (s *Service) WriteVolumeFile(ctx context.Context, stackName, volumeName string, req WriteFileRequest) error {
+	content := []byte(req.Content)
+	if req.Encoding == "base64" {
+		decoded, err := base64.StdEncoding.DecodeString(req.Content)
+		if err != nil {
+			return fmt.Errorf("invalid base64 content: %w", err)
+		}
+		content = decoded
+	}
+
+	delta, release, err := s.reserveVolumeWrite(ctx, stackName, volumeName, req.Path, int64(len(content)))
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	if err := s.writeVolumeFile(ctx, stackName, volumeName, req.Path, bytes.NewReader(content), int64(len(content)), req.Mode, req.OwnerID, req.GroupID); err != nil {
+		return err
+	}
+	s.recordVolumeUsage(stackName, delta)
+	return nil
+}
+
+func (s *Service) WriteVolumeUploadedFile(ctx context.Context, stackName, volumeName, path string, src io.Reader, size int64, mode *string, ownerID *uint32, groupID *uint32) error {
+	if s.maxUpload > 0 && size > s.maxUpload {
+		s.logger.Error("uploaded file too large",
+			zap.String("operation", "upload_volume_file"),
+			zap.String("stack", stackName),
+			zap.String("volume", volumeName),
+			zap.String("path", path),
+			zap.Int64("size", size),
+			zap.Int64("max_size", s.maxUpload),
+		)
+		return fmt.Errorf("file too large (>%dMB)", s.maxUpload/(1024*1024))
+	}
+
+	delta, release, err := s.reserveVolumeWrite(ctx, stackName, volumeName, path, size)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	if err := s.writeVolumeFile(ctx, stackName, volumeName, path, src, size, mode, ownerID, groupID); err != nil {
+		return err
+	}
+	s.recordVolumeUsage(stackName, delta)
+	return nil
+}
+
+func (s *Service) reserveVolumeWrite(ctx context.Context, stackName, volumeName, path string, size int64) (int64, func(), error) {
+	if !s.quotaVolumes {
+		return 0, func() {}, nil
+	}
+
+	replaced, err := s.volumeFileSize(ctx, stackName, volumeName, path)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	delta := size - replaced
+	release, err := s.reserveQuota(stackName, delta)
+	if err != nil {
+		return 0, nil, err
+	}
+	return delta, release, nil
+}
+
+func (s *Service) volumeFileSize(ctx context.Context, stackName, volumeName, path string) (int64, error) {
+	target, err := volumeTargetPath(path)
+	if err != nil {
+		return 0, err
+	}
+	if target == volumeMountPath {
+		return 0, nil
+	}
+
+	var output bytes.Buffer
+	if err := s.runVolumeHelper(ctx, stackName, volumeName, true, volumeSizeScript, []string{target}, &output, path, 0); err != nil {
+		return 0, err
+	}
+
+	size := strings.TrimSpace(output.String())
+	if size == "" {
+		return 0, nil
+	}
+	return strconv.ParseInt(size, 10, 64)
+}
+
+func (s *Service) DeleteVolumePath(ctx context.Context, stackName, volumeName, path string) error {
+	target, err := volumeTargetPath(path)
+	if err != nil {
+		return err
+	}
+	if target == volumeMountPath {
+		return errors.New("cannot delete the volume root")
+	}
+
+	if err := s.runVolumeHelper(ctx, stackName, volumeName, false, volumeDeleteScript, []string{target}, io.Discard, path, 0); err != nil {
+		s.logger.Error("cannot delete volume path",
+			zap.String("operation", "delete_volume_path"),
+			zap.String("stack", stackName),
+			zap.String("volume", volumeName),
+			zap.String("path", path),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	s.logger.Info("volume path deleted",
+		zap.String("operation", "delete_volume_path"),
+		zap.String("stack", stackName),
+		zap.String("volume", volumeName),
+		zap.String("path", path),
+	)
+	return nil
+}
+
+func (s *Service) writeVolumeFile(ctx context.Context, stackName, volumeName, path string, src io.Reader, size int64, mode *string, ownerID *uint32, groupID *uint32) error {
+	target, err := volumeTargetPath(path)
+	if err != nil {
+		return err
+	}
+	if target == volumeMountPath {
+		return fmt.Errorf("path is a directory, not a file: %s", path)
+	}
+
+	fileMode := volumeDefaultMode
+	if mode != nil {
+		parsed, err := parseFileMode(*mode)
+		if err != nil {
+			return fmt.Errorf("invalid file mode: %w", err)
+		}
+		fileMode = fmt.Sprintf("%04o", parsed)
+	}
+
+	owner := ""
+	switch {
+	case ownerID != nil && groupID != nil:
+		owner = fmt.Sprintf("%d:%d", *ownerID, *groupID)
+	case ownerID != nil:
+		owner = strconv.FormatUint(uint64(*ownerID), 10)
+	case groupID != nil:
+		owner = fmt.Sprintf(":%d", *groupID)
+	}
+
+	spec, err := s.volumeHelperSpec(ctx, stackName, volumeName, false, volumeWriteScript, []string{target, fileMode, owner})
+	if err != nil {
+		return err
+	}
+
+	containerID, err := s.dockerClient.CreateContainer(ctx, spec)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := s.dockerClient.ContainerRemove(context.WithoutCancel(ctx), containerID, false, false, true); err != nil {
+			s.logger.Warn("failed to remove volume helper container",
+				zap.String("container_id", containerID),
+				zap.Error(err),
+			)
+		}
+	}()
+
+	staged, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(writeSingleFileTar(writer, strings.TrimPrefix(volumeStagingPath, "/"), 0600, src, size))
+	}()
+	err = s.dockerClient.CopyToContainer(ctx, containerID, "/", staged, false)
+	_ = staged.CloseWithError(io.ErrClosedPipe)
+	if err != nil {
+		s.logger.Error("cannot stage volume file",
+			zap.String("operation", "write_volume_file"),
+			zap.String("stack", stackName),
+			zap.String("volume", volumeName),
+			zap.String("path", path),
+			zap.Error(err),
+		)
+		return fmt.Errorf("cannot write file: %w", err)
+	}
+
+	stderr := &cappedBuffer{limit: volumeStderrLimit}
+	exitCode, err := s.dockerClient.RunCreatedContainer(ctx, containerID, io.Discard, stderr)
+	if err == nil {
+		err = volumeHelperError(exitCode, path, 0, stderr.buf.String())
+	}
+	if err != nil {
+		s.logger.Error("cannot write volume file",
+			zap.String("operation", "write_volume_file"),
+			zap.String("stack", stackName),
+			zap.String("volume", volumeName),
+			zap.String("path", path),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	s.logger.Info("volume file written successfully",
+		zap.String("operation", "write_volume_file"),
+		zap.String("stack", stackName),
+		zap.String("volume", volumeName),
+		zap.String("path", path),
+		zap.Int64("size", size),
+	)
+	return nil
+}
+
+func writeSingleFileTar(out io.Writer, name string, mode os.FileMode, src io.Reader, size int64) error {
+	archive := tar.NewWriter(out)
+	if err := archive.WriteHeader(&tar.Header{
+		Name:     name,
+		Mode:     int64(mode.Perm()),
+		Size:     size,
+		ModTime:  time.Now(),
+		Typeflag: tar.TypeReg,
+	}); err != nil {
+		return err
+	}
+	if _, err := io.CopyN(archive, src, size); err != nil {
+		return err
+	}
+	return archive.Close()
+}
+
+func (s *Service) streamVolumeFile(ctx context.Context, stackName, volumeName, path string, limit int64, start func(VolumeFileInfo) (io.Writer, error)) (VolumeFileInfo, error) {
+	target, err := volumeTargetPath(path)
+	if err != nil {
+		return VolumeFileInfo{}, err
+	}
+
+	stdout := &volumeFileWriter{path: path, start: start}
+	if err := s.runVolumeHelper(ctx, stackName, volumeName, true, volumeReadScript, []string{target, strconv.FormatInt(limit, 10)}, stdout, path, limit); err != nil {
+		return stdout.info, err
+	}
+	if stdout.err != nil {
+		return stdout.info, stdout.err
+	}
+	if !stdout.started {
+		return stdout.info, errors.New("volume helper returned no file header")
+	}
+	if stdout.remaining > 0 {
+		return stdout.info, errors.New("file changed while it was being read")
+	}
+	return stdout.info, nil
+}
+
+func (s *Service) runVolumeHelper(ctx context.Context, stackName, volumeName string, readOnly bool, script string, args []string, stdout io.Writer, path string, limit int64) error {
+	spec, err := s.volumeHelperSpec(ctx, stackName, volumeName, readOnly, script, args)
+	if err != nil {
+		return err
+	}
+
+	stderr := &cappedBuffer{limit: volumeStderrLimit}
+	exitCode, err := s.dockerClient.RunContainer(ctx, spec, stdout, stderr)
+	if err != nil {
+		return err
+	}
+	return volumeHelperError(exitCode, path, limit, stderr.buf.String())
+}
+
+func (s *Service) volumeHelperSpec(ctx context.Context, stackName, volumeName string, readOnly bool, script string, args []string) (docker.ContainerRunSpec, error) {
+	if err := s.stackVolume(ctx, stackName, volumeName); err != nil {
+		return docker.ContainerRunSpec{}, err
+	}
+
+	image, err := s.helperImage(ctx)
+	if err != nil {
+		return docker.ContainerRunSpec{}, err
+	}
+
+	return docker.ContainerRunSpec{
+		Image:      image,
+		Entrypoint: append([]string{"/bin/sh", "-c", volumeScriptPrelude + script, "sh"}, args...),
+		Env:        []string{},
+		Mounts: []mount.Mount{
+			{Type: mount.TypeVolume, Source: volumeName, Target: volumeMountPath, ReadOnly: readOnly},
+		},
+		Labels: map[string]string{
+			"berth.files.stack":  stackName,
+			"berth.files.volume": volumeName,
+		},
+	}, nil
+}
+
+func (s *Service) stackVolume(ctx context.Context, stackName, volumeName string) error {
+	if _, err := s.validateStackPath(stackName, ""); err != nil {
+		return err
+	}
+	if volumeName == "" {
+		return fmt.Errorf("%w: volume name is required", ErrVolumeNotFound)
+	}
+
+	vol, err := s.dockerClient.InspectVolume(ctx, volumeName)
+	if err != nil {
+		s.logger.Debug("volume lookup failed",
+			zap.String("stack", stackName),
+			zap.String("volume", volumeName),
+			zap.Error(err),
+		)
+		return fmt.Errorf("%w: %s", ErrVolumeNotFound, volumeName)
+	}
+
+	if vol.Labels[docker.LabelComposeProject] != stackName {
+		s.logger.Warn("volume outside stack project requested",
+			zap.String("stack", stackName),
+			zap.String("volume", volumeName),
+			zap.String("project", vol.Labels[docker.LabelComposeProject]),
+		)
+		return fmt.Errorf("%w: %s", ErrVolumeNotFound, volumeName)
+	}
+
+	return nil
+}
+
+func (s *Service) helperImage(ctx context.Context) (string, error) {
+	return s.dockerClient.HelperImage(ctx, s.volumeHelperImage, "VOLUME_HELPER_IMAGE")
+}
+
+func volumeTargetPath(path string) (string, error) {
+	if strings.ContainsRune(path, 0) {
+		return "", errors.New("invalid path")
+	}
+
+	relativePath := strings.TrimPrefix(path, "/")
+	if relativePath == "" {
+		return volumeMountPath, nil
+	}
+
+	cleanPath := filepath.Clean(relativePath)
+	if strings.Contains(cleanPath, "..") {
+		return "", errors.New("path traversal not allowed")
+	}
+	if cleanPath == "." {
+		return volumeMountPath, nil
+	}
+
+	return volumeMountPath + "/" + filepath.ToSlash(cleanPath), nil
+}
+
+func volumeHelperError(exitCode int, path string, limit int64, stderr string) error {
+	switch exitCode {
+	case 0:
+		return nil
+	case volumeExitNotFound:
+		return fmt.Errorf("path not found: %s", path)
+	case volumeExitOutside:
+		return errors.New("path resolves outside volume")
+	case volumeExitNotDirectory:
+		return fmt.Errorf("path is not a directory: %s", path)
+	case volumeExitIsDirectory:
+		return fmt.Errorf("path is a directory, not a file: %s", path)
+	case volumeExitTooLarge:
+		return fmt.Errorf("file too large (>%dMB)", limit/(1024*1024))
+	case volumeExitNotRegular:
+		return fmt.Errorf("path is not a regular file: %s", path)
+	default:
+		return fmt.Errorf("volume helper failed with exit code %d: %s", exitCode, strings.TrimSpace(stderr))
+	}
+}
+
+func parseVolumeListing(output []byte, path string) ([]FileEntry, error) {
+	var entries []FileEntry
+	for _, record := range bytes.Split(output, []byte{0}) {
+		if len(bytes.TrimSpace(record)) == 0 {
+			continue
+		}
+
+		statLine, name, found := bytes.Cut(record, []byte{'\n'})
+		if !found {
+			return nil, errors.New("unexpected volume helper output")
+		}
+
+		fields := strings.Fields(string(statLine))
+		if len(fields) != 5 {
+			return nil, errors.New("unexpected volume helper output")
+		}
+		rawMode, err := strconv.ParseUint(fields[0], 16, 32)
+		if err != nil {
+			return nil, errors.New("unexpected volume helper output")
+		}
+		size, _ := strconv.ParseInt(fields[1], 10, 64)
+		modTime, _ := strconv.ParseInt(fields[2], 10, 64)
+		uid, _ := strconv.ParseUint(fields[3], 10, 32)
+		gid, _ := strconv.ParseUint(fields[4], 10, 32)
+
+		mode := fileModeFromRaw(uint32(rawMode))
+		entry := FileEntry{
+			Name:        string(name),
+			Path:        filepath.Join(path, string(name)),
+			Size:        size,
+			IsDirectory: mode.IsDir(),
+			ModTime:     time.Unix(modTime, 0),
+			Mode:        mode.String(),
+			OwnerID:     uint32(uid),
+			GroupID:     uint32(gid),
+		}
+		if path == "" {
+			entry.Path = entry.Name
+		}
+		if !entry.IsDirectory {
+			if ext := filepath.Ext(entry.Name); ext != "" {
+				entry.Extension = strings.ToLower(ext[1:])
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func fileModeFromRaw(raw uint32) os.FileMode {
+	mode := os.FileMode(raw & 0777)
+	switch raw & 0170000 {
+	case 0040000:
+		mode |= os.ModeDir
+	case 0120000:
+		mode |= os.ModeSymlink
+	case 0010000:
+		mode |= os.ModeNamedPipe
+	case 0140000:
+		mode |= os.ModeSocket
+	case 0020000:
+		mode |= os.ModeDevice | os.ModeCharDevice
+	case 0060000:
+		mode |= os.ModeDevice
+	}
+	if raw&04000 != 0 {
+		mode |= os.ModeSetuid
+	}
+	if raw&02000 != 0 {
+		mode |= os.ModeSetgid
+	}
+	if raw&01000 != 0 {
+		mode |= os.ModeSticky
+	}
+	return mode
+}
+
+type cappedBuffer struct {
+	buf      bytes.Buffer
+	limit    int
+	overflow bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.buf.Len(); len(p) > room {
+		b.overflow = true
+		b.buf.Write(p[:max(room, 0)])
+		return len(p), nil
+	}
+	b.buf.Write(p)
+	return len(p), nil
+}
+
+type volumeFileWriter struct {
+	path      string
+	start     func(VolumeFileInfo) (io.Writer, error)
+	header    []byte
+	started   bool
+	info      VolumeFileInfo
+	remaining int64
+	dest      io.Writer
+	err       error
+}
+
+func (w *volumeFileWriter) Write(p []byte) (int, error) {
+	total := len(p)
+	if w.err != nil {
+		return total, nil
+	}
+
+	if !w.started {
+		index := bytes.IndexByte(p, '\n')
+		if index < 0 {
+			w.header = append(w.header, p...)
+			if len(w.header) > volumeHeaderLimit {
+				w.err = errors.New("unexpected volume helper output")
+			}
+			return total, nil
+		}
+		w.header = append(w.header, p[:index]...)
+		p = p[index+1:]
+
+		fields := strings.Fields(string(w.header))
+		if len(fields) != 3 || fields[0] != volumeFileHeaderTag {
+			w.err = errors.New("unexpected volume helper output")
+			return total, nil
+		}
+		size, sizeErr := strconv.ParseInt(fields[1], 10, 64)
+		modTime, timeErr := strconv.ParseInt(fields[2], 10, 64)
+		if sizeErr != nil || timeErr != nil {
+			w.err = errors.New("unexpected volume helper output")
+			return total, nil
+		}
+
+		w.started = true
+		w.info = VolumeFileInfo{Path: w.path, Size: size, ModTime: time.Unix(modTime, 0)}
+		w.remaining = size
+		if w.dest, w.err = w.start(w.info); w.err != nil {
+			return total, nil
+		}
+	}
+
+	if int64(len(p)) > w.remaining {
+		p = p[:w.remaining]
+	}
+	if len(p) == 0 {
+		return total, nil
+	}
+
+	written, err := w.dest.Write(p)
+	w.remaining -= int64(written)
+	if err != nil {
+		w.err = err
+	}
+	return total, nil
+}
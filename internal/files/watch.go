@@ -0,0 +1,506 @@
+package files
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/fsnotify/fsnotify"
+	"go.uber.org/zap"
+)
+
+const (
+	WatchEventReady     = "ready"
+	WatchEventChange    = "change"
+	WatchEventAppend    = "append"
+	WatchEventTruncate  = "truncate"
+	WatchEventRotate    = "rotate"
+	WatchEventHeartbeat = "heartbeat"
+	WatchEventError     = "error"
+)
+
+const (
+	maxWatchSessions       = 32
+	maxWatchDirectories    = 1024
+	watchDefaultTailLines  = 10
+	watchMaxTailLines      = 10000
+	watchTailReadLimit     = 1024 * 1024
+	watchChunkSize         = 64 * 1024
+	watchCoalesceWindow    = 500 * time.Millisecond
+	watchPollInterval      = 2 * time.Second
+	watchHeartbeatInterval = 30 * time.Second
+)
+
+var ErrTooManyWatchers = errors.New("too many active file watchers")
+
+type Watch struct {
+	service     *Service
+	stackName   string
+	boundary    string
+	target      string
+	isDirectory bool
+	recursive   bool
+	tailLines   int
+	watcher     *fsnotify.Watcher
+	release     func()
+	watched     int
+}
+
+func (s *Service) OpenWatch(stackName string, req WatchRequest) (*Watch, error) {
+	realStackPath, err := s.validateStackPath(stackName, "")
+	if err != nil {
+		return nil, err
+	}
+	fullPath, err := s.validateStackPath(stackName, req.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	stat, err := os.Stat(fullPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("path not found: %s", req.Path)
+		}
+		return nil, fmt.Errorf("cannot access path: %w", err)
+	}
+
+	tailLines := watchDefaultTailLines
+	if req.Lines != nil {
+		tailLines = *req.Lines
+	}
+	if tailLines < 0 || tailLines > watchMaxTailLines {
+		return nil, fmt.Errorf("lines must be between 0 and %d", watchMaxTailLines)
+	}
+
+	select {
+	case s.watchSlots <- struct{}{}:
+	default:
+		return nil, fmt.Errorf("%w (max %d)", ErrTooManyWatchers, maxWatchSessions)
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		<-s.watchSlots
+		return nil, fmt.Errorf("failed to create file watcher: %w", err)
+	}
+
+	w := &Watch{
+		service:     s,
+		stackName:   stackName,
+		boundary:    realStackPath,
+		target:      fullPath,
+		isDirectory: stat.IsDir(),
+		recursive:   req.Recursive && stat.IsDir(),
+		tailLines:   tailLines,
+		watcher:     watcher,
+		release:     func() { <-s.watchSlots },
+	}
+
+	if w.isDirectory {
+		err = w.addDirectory(fullPath)
+	} else {
+		err = watcher.Add(filepath.Dir(fullPath))
+	}
+	if err != nil {
+		w.Close()
+		return nil, err
+	}
+
+	return w, nil
+}
+
+func (w *Watch) IsDirectory() bool {
+	return w.isDirectory
+}
+
+func (w *Watch) Close() {
+	if w.watcher != nil {
+		_ = w.watcher.Close()
+		w.watcher = nil
+	}
+	if w.release != nil {
+		w.release()
+		w.release = nil
+	}
+}
+
+func (w *Watch) Run(ctx context.Context, send func(WatchEvent) error) error {
+	defer w.Close()
+
+	emit := func(event WatchEvent) error {
+		event.Timestamp = time.Now().UTC()
+		return send(event)
+	}
+
+	w.service.logger.Debug("file watch started",
+		zap.String("operation", "watch"),
+		zap.String("stack", w.stackName),
+		zap.String("path", w.relative(w.target)),
+		zap.Bool("is_directory", w.isDirectory),
+	)
+
+	var err error
+	if w.isDirectory {
+		err = w.runDirectory(ctx, emit)
+	} else {
+		err = w.runTail(ctx, emit)
+	}
+
+	w.service.logger.Debug("file watch ended",
+		zap.String("operation", "watch"),
+		zap.String("stack", w.stackName),
+		zap.String("path", w.relative(w.target)),
+	)
+
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+	return err
+}
+
+func (w *Watch) runDirectory(ctx context.Context, emit func(WatchEvent) error) error {
+	if err := emit(WatchEvent{Type: WatchEventReady, Path: w.relative(w.target), IsDirectory: true}); err != nil {
+		return err
+	}
+
+	heartbeat := time.NewTicker(watchHeartbeatInterval)
+	defer heartbeat.Stop()
+	coalesce := time.NewTicker(watchCoalesceWindow)
+	defer coalesce.Stop()
+
+	lastModified := make(map[string]time.Time)
+	pending := make(map[string]bool)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+
+		case <-heartbeat.C:
+			if err := emit(WatchEvent{Type: WatchEventHeartbeat}); err != nil {
+				return err
+			}
+
+		case <-coalesce.C:
+			for path := range pending {
+				delete(pending, path)
+				lastModified[path] = time.Now()
+				if err := emit(WatchEvent{Type: WatchEventChange, Op: "modify", Path: path}); err != nil {
+					return err
+				}
+			}
+			for path, at := range lastModified {
+				if time.Since(at) > watchCoalesceWindow {
+					delete(lastModified, path)
+				}
+			}
+
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return nil
+			}
+			if emitErr := emit(WatchEvent{Type: WatchEventError, Data: err.Error()}); emitErr != nil {
+				return emitErr
+			}
+
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return nil
+			}
+			if !isWithinDirectory(event.Name, w.boundary) {
+				continue
+			}
+
+			path := w.relative(event.Name)
+			op := watchOp(event.Op)
+			if op == "" {
+				continue
+			}
+
+			if op == "modify" {
+				if at, seen := lastModified[path]; seen && time.Since(at) < watchCoalesceWindow {
+					pending[path] = true
+					continue
+				}
+				lastModified[path] = time.Now()
+			}
+
+			change := WatchEvent{Type: WatchEventChange, Op: op, Path: path}
+			if op == "create" {
+				if info, err := os.Lstat(event.Name); err == nil && info.IsDir() {
+					change.IsDirectory = true
+					if w.recursive && filepath.Base(event.Name) != ".berth" {
+						if err := w.addDirectory(event.Name); err != nil {
+							if emitErr := emit(WatchEvent{Type: WatchEventError, Data: err.Error()}); emitErr != nil {
+								return emitErr
+							}
+						}
+					}
+				}
+			}
+			delete(pending, path)
+
+			if err := emit(change); err != nil {
+				return err
+			}
+
+			if event.Name == w.target && (op == "delete" || op == "rename") {
+				return emit(WatchEvent{Type: WatchEventError, Data: "watched directory was removed"})
+			}
+		}
+	}
+}
+
+func (w *Watch) runTail(ctx context.Context, emit func(WatchEvent) error) error {
+	file, offset, err := w.openTail()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if file != nil {
+			_ = file.Close()
+		}
+	}()
+
+	if err := emit(WatchEvent{Type: WatchEventReady, Path: w.relative(w.target), Offset: offset}); err != nil {
+		return err
+	}
+
+	if w.tailLines > 0 && offset > 0 {
+		data, err := lastLines(file, offset, w.tailLines)
+		if err != nil {
+			return err
+		}
+		if len(data) > 0 {
+			if err := emit(appendEvent(data, offset-int64(len(data)))); err != nil {
+				return err
+			}
+		}
+	}
+
+	heartbeat := time.NewTicker(watchHeartbeatInterval)
+	defer heartbeat.Stop()
+	poll := time.NewTicker(watchPollInterval)
+	defer poll.Stop()
+
+	drain := func() error {
+		info, err := file.Stat()
+		if err != nil {
+			return nil
+		}
+		if info.Size() < offset {
+			offset = 0
+			if err := emit(WatchEvent{Type: WatchEventTruncate, Path: w.relative(w.target)}); err != nil {
+				return err
+			}
+		}
+
+		for offset < info.Size() {
+			chunk := make([]byte, min(int64(watchChunkSize), info.Size()-offset))
+			read, err := file.ReadAt(chunk, offset)
+			if read > 0 {
+				if emitErr := emit(appendEvent(chunk[:read], offset)); emitErr != nil {
+					return emitErr
+				}
+				offset += int64(read)
+			}
+			if err != nil {
+				break
+			}
+		}
+		return nil
+	}
+
+	check := func() error {
+		info, err := os.Stat(w.target)
+		if err != nil {
+			return nil
+		}
+
+		if file != nil {
+			current, err := file.Stat()
+			if err == nil && os.SameFile(current, info) {
+				return drain()
+			}
+			if err := drain(); err != nil {
+				return err
+			}
+			_ = file.Close()
+			file = nil
+		}
+
+		reopened, err := os.Open(w.target)
+		if err != nil {
+			return nil
+		}
+		file, offset = reopened, 0
+		if err := emit(WatchEvent{Type: WatchEventRotate, Path: w.relative(w.target)}); err != nil {
+			return err
+		}
+		return drain()
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+
+		case <-heartbeat.C:
+			if err := emit(WatchEvent{Type: WatchEventHeartbeat, Offset: offset}); err != nil {
+				return err
+			}
+
+		case <-poll.C:
+			if err := check(); err != nil {
+				return err
+			}
+
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return nil
+			}
+			if emitErr := emit(WatchEvent{Type: WatchEventError, Data: err.Error()}); emitErr != nil {
+				return emitErr
+			}
+
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return nil
+			}
+			if event.Name != w.target {
+				continue
+			}
+
+			if event.Op&(fsnotify.Remove|fsnotify.Rename) != 0 {
+				if err := emit(WatchEvent{Type: WatchEventChange, Op: watchOp(event.Op), Path: w.relative(w.target)}); err != nil {
+					return err
+				}
+				if file != nil {
+					if err := drain(); err != nil {
+						return err
+					}
+					_ = file.Close()
+					file = nil
+				}
+				if err := check(); err != nil {
+					return err
+				}
+				continue
+			}
+
+			if err := check(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (w *Watch) openTail() (*os.File, int64, error) {
+	file, err := os.Open(w.target)
+	if err != nil {
+		return nil, 0, fmt.Errorf("cannot open file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return nil, 0, fmt.Errorf("cannot access file: %w", err)
+	}
+	if !info.Mode().IsRegular() {
+		_ = file.Close()
+		return nil, 0, fmt.Errorf("path is not a regular file")
+	}
+	return file, info.Size(), nil
+}
+
+func (w *Watch) addDirectory(root string) error {
+	if !w.recursive {
+		if err := w.watcher.Add(root); err != nil {
+			return fmt.Errorf("cannot watch directory: %w", err)
+		}
+		w.watched++
+		return nil
+	}
+
+	return filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.IsDir() {
+			return nil
+		}
+		if path != root && searchSkippedDirectories[entry.Name()] {
+			return filepath.SkipDir
+		}
+		if w.watched >= maxWatchDirectories {
+			return fmt.Errorf("too many directories to watch recursively (max %d)", maxWatchDirectories)
+		}
+		if err := w.watcher.Add(path); err != nil {
+			return fmt.Errorf("cannot watch directory %s: %w", w.relative(path), err)
+		}
+		w.watched++
+		return nil
+	})
+}
+
+func (w *Watch) relative(path string) string {
+	relative, err := filepath.Rel(w.boundary, path)
+	if err != nil {
+		return path
+	}
+	return filepath.ToSlash(relative)
+}
+
+func watchOp(op fsnotify.Op) string {
+	switch {
+	case op.Has(fsnotify.Create):
+		return "create"
+	case op.Has(fsnotify.Remove):
+		return "delete"
+	case op.Has(fsnotify.Rename):
+		return "rename"
+	case op.Has(fsnotify.Write):
+		return "modify"
+	case op.Has(fsnotify.Chmod):
+		return "chmod"
+	default:
+		return ""
+	}
+}
+
+func appendEvent(data []byte, offset int64) WatchEvent {
+	event := WatchEvent{Type: WatchEventAppend, Offset: offset}
+	if utf8.Valid(data) {
+		event.Data = string(data)
+		event.Encoding = "utf-8"
+	} else {
+		event.Data = base64.StdEncoding.EncodeToString(data)
+		event.Encoding = "base64"
+	}
+	return event
+}
+
+func lastLines(file *os.File, size int64, lines int) ([]byte, error) {
+	start := max(size-watchTailReadLimit, 0)
+	data := make([]byte, size-start)
+	if _, err := file.ReadAt(data, start); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("cannot read file: %w", err)
+	}
+
+	trimmed := strings.TrimSuffix(string(data), "\n")
+	index := len(trimmed)
+	for i := 0; i < lines; i++ {
+		index = strings.LastIndexByte(trimmed[:index], '\n')
+		if index < 0 {
+			if start > 0 {
+				return data[min(len(data), strings.IndexByte(string(data), '\n')+1):], nil
+			}
+			return data, nil
+		}
+	}
+	return data[index+1:], nil
+}
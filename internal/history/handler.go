@@ -0,0 +1,60 @@
+package history
+
+import (
+	"strconv"
+
+	"github.com/tech-arch1tect/berth-agent/internal/audit"
+	"github.com/tech-arch1tect/berth-agent/internal/common"
+	"github.com/tech-arch1tect/berth-agent/internal/validation"
+
+	"github.com/labstack/echo/v4"
+)
+
+type Handler struct {
+	service      *Service
+	auditService *audit.Service
+}
+
+func NewHandler(service *Service, auditService *audit.Service) *Handler {
+	return &Handler{
+		service:      service,
+		auditService: auditService,
+	}
+}
+
+func (h *Handler) GetStackHistory(c echo.Context) error {
+	stackName := c.Param("name")
+	if stackName == "" {
+		return common.SendBadRequest(c, "stack name is required")
+	}
+
+	if err := validation.ValidateStackName(stackName); err != nil {
+		return common.SendBadRequest(c, "invalid stack name: "+err.Error())
+	}
+
+	window, err := ParseWindow(c.QueryParam("window"))
+	if err != nil {
+		return common.SendBadRequest(c, err.Error())
+	}
+
+	limit := 500
+	if value := c.QueryParam("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			return common.SendBadRequest(c, "limit must be a positive integer")
+		}
+		limit = parsed
+	}
+
+	history, err := h.service.GetStackHistory(stackName, window, limit)
+	if err != nil {
+		h.auditService.LogStackEvent(audit.EventStackGetHistory, c.RealIP(), stackName, false, err.Error(), nil)
+		return common.SendInternalError(c, err.Error())
+	}
+
+	h.auditService.LogStackEvent(audit.EventStackGetHistory, c.RealIP(), stackName, true, "", map[string]any{
+		"window": window.String(),
+	})
+
+	return common.SendSuccess(c, history)
+}
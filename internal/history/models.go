@@ -0,0 +1,60 @@
+package history
+
+import "time"
+
+const (
+	KindStack     = "stack"
+	KindContainer = "container"
+)
+
+type Transition struct {
+	Time        time.Time `json:"t"`
+	Kind        string    `json:"k"`
+	Service     string    `json:"svc,omitempty"`
+	Container   string    `json:"c,omitempty"`
+	ContainerID string    `json:"id,omitempty"`
+	Status      string    `json:"s"`
+	Health      string    `json:"h,omitempty"`
+	ExitCode    *int      `json:"exit,omitempty"`
+	OOMKilled   bool      `json:"oom,omitempty"`
+	Running     int       `json:"run,omitempty"`
+	Stopped     int       `json:"stop,omitempty"`
+}
+
+type StackHistory struct {
+	StackName       string             `json:"stack_name"`
+	From            time.Time          `json:"from"`
+	To              time.Time          `json:"to"`
+	UptimePercent   float64            `json:"uptime_percent"`
+	TrackedSeconds  int64              `json:"tracked_seconds"`
+	StatusDurations map[string]int64   `json:"status_durations"`
+	Containers      []ContainerHistory `json:"containers"`
+	Timeline        []TimelineEntry    `json:"timeline"`
+	Truncated       bool               `json:"truncated"`
+}
+
+type ContainerHistory struct {
+	Container       string           `json:"container"`
+	Service         string           `json:"service"`
+	UptimePercent   float64          `json:"uptime_percent"`
+	TrackedSeconds  int64            `json:"tracked_seconds"`
+	StatusDurations map[string]int64 `json:"status_durations"`
+	Starts          int              `json:"starts"`
+	Failures        int              `json:"failures"`
+	OOMKills        int              `json:"oom_kills"`
+	LastExitCode    *int             `json:"last_exit_code,omitempty"`
+	LastStatus      string           `json:"last_status"`
+}
+
+type TimelineEntry struct {
+	Time      time.Time `json:"time"`
+	Kind      string    `json:"kind"`
+	Service   string    `json:"service,omitempty"`
+	Container string    `json:"container,omitempty"`
+	Status    string    `json:"status"`
+	Health    string    `json:"health,omitempty"`
+	ExitCode  *int      `json:"exit_code,omitempty"`
+	OOMKilled bool      `json:"oom_killed,omitempty"`
+	Running   int       `json:"running,omitempty"`
+	Stopped   int       `json:"stopped,omitempty"`
+}
@@ -0,0 +1,26 @@
+package history
+
+import (
+	"context"
+
+	"go.uber.org/fx"
+)
+
+var Module = fx.Options(
+	fx.Provide(NewService),
+	fx.Provide(NewHandler),
+	fx.Invoke(StartRetention),
+)
+
+func StartRetention(lc fx.Lifecycle, service *Service) {
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			service.Start()
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			service.Stop()
+			return nil
+		},
+	})
+}
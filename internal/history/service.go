@@ -0,0 +1,368 @@
+package history
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/tech-arch1tect/berth-agent/config"
+	"github.com/tech-arch1tect/berth-agent/internal/logging"
+	"github.com/tech-arch1tect/berth-agent/internal/websocket"
+
+	"go.uber.org/zap"
+)
+
+type Service struct {
+	store     *Store
+	retention time.Duration
+	logger    *logging.Logger
+	mu        sync.Mutex
+	last      map[string]map[string]Transition
+	ctx       context.Context
+	cancel    context.CancelFunc
+}
+
+func NewService(cfg *config.Config, logger *logging.Logger) (*Service, error) {
+	serviceLogger := logger.With(zap.String("component", "history"))
+
+	store, err := NewStore(cfg.HistoryPersistenceDir, serviceLogger)
+	if err != nil {
+		return nil, err
+	}
+
+	retentionDays := cfg.HistoryRetentionDays
+	if retentionDays <= 0 {
+		retentionDays = 30
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Service{
+		store:     store,
+		retention: time.Duration(retentionDays) * 24 * time.Hour,
+		logger:    serviceLogger,
+		last:      make(map[string]map[string]Transition),
+		ctx:       ctx,
+		cancel:    cancel,
+	}, nil
+}
+
+func (s *Service) Start() {
+	s.prune()
+
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-s.ctx.Done():
+				return
+			case <-ticker.C:
+				s.prune()
+			}
+		}
+	}()
+}
+
+func (s *Service) Stop() {
+	s.cancel()
+}
+
+func (s *Service) prune() {
+	cutoff := time.Now().Add(-s.retention)
+	if err := s.store.Prune(cutoff); err != nil {
+		s.logger.Warn("failed to prune stack history", zap.Error(err))
+	}
+}
+
+func (s *Service) RecordContainerStatus(event websocket.ContainerStatusEvent, exitCode *int, oomKilled bool) {
+	s.record(event.StackName, Transition{
+		Time:        parseEventTime(event.Timestamp),
+		Kind:        KindContainer,
+		Service:     event.ServiceName,
+		Container:   event.ContainerName,
+		ContainerID: shortContainerID(event.ContainerID),
+		Status:      event.Status,
+		Health:      event.Health,
+		ExitCode:    exitCode,
+		OOMKilled:   oomKilled,
+	})
+}
+
+func (s *Service) RecordStackStatus(event websocket.StackStatusEvent) {
+	s.record(event.StackName, Transition{
+		Time:    parseEventTime(event.Timestamp),
+		Kind:    KindStack,
+		Status:  event.Status,
+		Running: event.Running,
+		Stopped: event.Stopped,
+	})
+}
+
+func (s *Service) record(stackName string, transition Transition) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lastStates, err := s.lastStatesFor(stackName)
+	if err != nil {
+		s.logger.Warn("failed to load stack history",
+			zap.String("stack_name", stackName),
+			zap.Error(err),
+		)
+		return
+	}
+
+	key := transitionKey(transition)
+	if previous, ok := lastStates[key]; ok && !isStateChange(previous, transition) {
+		return
+	}
+
+	if err := s.store.Append(stackName, transition); err != nil {
+		s.logger.Warn("failed to persist status transition",
+			zap.String("stack_name", stackName),
+			zap.String("kind", transition.Kind),
+			zap.String("status", transition.Status),
+			zap.Error(err),
+		)
+		return
+	}
+
+	lastStates[key] = transition
+
+	s.logger.Debug("recorded status transition",
+		zap.String("stack_name", stackName),
+		zap.String("kind", transition.Kind),
+		zap.String("container_name", transition.Container),
+		zap.String("status", transition.Status),
+		zap.String("health", transition.Health),
+	)
+}
+
+func (s *Service) lastStatesFor(stackName string) (map[string]Transition, error) {
+	if lastStates, ok := s.last[stackName]; ok {
+		return lastStates, nil
+	}
+
+	transitions, err := s.store.Load(stackName)
+	if err != nil {
+		return nil, err
+	}
+
+	lastStates := make(map[string]Transition)
+	for _, transition := range transitions {
+		lastStates[transitionKey(transition)] = transition
+	}
+	s.last[stackName] = lastStates
+
+	return lastStates, nil
+}
+
+func isStateChange(previous, current Transition) bool {
+	if previous.Status != current.Status || previous.Health != current.Health {
+		return true
+	}
+	if current.Kind == KindStack {
+		return previous.Running != current.Running || previous.Stopped != current.Stopped
+	}
+	if previous.ContainerID != current.ContainerID {
+		return true
+	}
+	return current.ExitCode != nil || current.OOMKilled
+}
+
+func (s *Service) GetStackHistory(stackName string, window time.Duration, limit int) (*StackHistory, error) {
+	transitions, err := s.store.Load(stackName)
+	if err != nil {
+		return nil, err
+	}
+
+	if window <= 0 || window > s.retention {
+		window = s.retention
+	}
+
+	to := time.Now().UTC()
+	from := to.Add(-window)
+
+	history := &StackHistory{
+		StackName:       stackName,
+		From:            from,
+		To:              to,
+		StatusDurations: map[string]int64{},
+		Containers:      []ContainerHistory{},
+		Timeline:        []TimelineEntry{},
+	}
+
+	var stackTransitions []Transition
+	containerTransitions := make(map[string][]Transition)
+
+	for _, transition := range transitions {
+		if transition.Kind == KindContainer {
+			containerTransitions[transition.Container] = append(containerTransitions[transition.Container], transition)
+		} else {
+			stackTransitions = append(stackTransitions, transition)
+		}
+
+		if !transition.Time.Before(from) {
+			history.Timeline = append(history.Timeline, timelineEntryFrom(transition))
+		}
+	}
+
+	history.StatusDurations, history.TrackedSeconds = statusDurations(stackTransitions, from, to, stackStateLabel)
+	history.UptimePercent = uptimePercent(history.StatusDurations["running"], history.TrackedSeconds)
+
+	for containerName, entries := range containerTransitions {
+		durations, tracked := statusDurations(entries, from, to, containerStateLabel)
+		if tracked == 0 && entries[len(entries)-1].Time.Before(from) && entries[len(entries)-1].Status == "not created" {
+			continue
+		}
+
+		containerHistory := ContainerHistory{
+			Container:       containerName,
+			Service:         entries[len(entries)-1].Service,
+			StatusDurations: durations,
+			TrackedSeconds:  tracked,
+			UptimePercent:   uptimePercent(durations["running"], tracked),
+			LastStatus:      containerStateLabel(entries[len(entries)-1]),
+		}
+
+		for _, entry := range entries {
+			if entry.ExitCode != nil {
+				containerHistory.LastExitCode = entry.ExitCode
+			}
+			if entry.Time.Before(from) {
+				continue
+			}
+			if entry.Status == "running" && entry.Health == "" {
+				containerHistory.Starts++
+			}
+			if entry.ExitCode != nil && *entry.ExitCode != 0 {
+				containerHistory.Failures++
+			}
+			if entry.OOMKilled {
+				containerHistory.OOMKills++
+			}
+		}
+
+		history.Containers = append(history.Containers, containerHistory)
+	}
+
+	sort.Slice(history.Containers, func(i, j int) bool {
+		return history.Containers[i].Container < history.Containers[j].Container
+	})
+
+	sort.SliceStable(history.Timeline, func(i, j int) bool {
+		return history.Timeline[i].Time.After(history.Timeline[j].Time)
+	})
+
+	if limit > 0 && len(history.Timeline) > limit {
+		history.Timeline = history.Timeline[:limit]
+		history.Truncated = true
+	}
+
+	return history, nil
+}
+
+func statusDurations(transitions []Transition, from, to time.Time, label func(Transition) string) (map[string]int64, int64) {
+	durations := make(map[string]int64)
+	var tracked int64
+
+	sorted := make([]Transition, len(transitions))
+	copy(sorted, transitions)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Time.Before(sorted[j].Time) })
+
+	for i, transition := range sorted {
+		start := transition.Time
+		end := to
+		if i+1 < len(sorted) {
+			end = sorted[i+1].Time
+		}
+
+		if start.Before(from) {
+			start = from
+		}
+		if end.After(to) {
+			end = to
+		}
+		if !end.After(start) {
+			continue
+		}
+
+		seconds := int64(end.Sub(start).Seconds())
+		durations[label(transition)] += seconds
+		tracked += seconds
+	}
+
+	return durations, tracked
+}
+
+func stackStateLabel(transition Transition) string {
+	return transition.Status
+}
+
+func containerStateLabel(transition Transition) string {
+	if transition.Status == "running" && transition.Health == "unhealthy" {
+		return "unhealthy"
+	}
+	return transition.Status
+}
+
+func uptimePercent(up, tracked int64) float64 {
+	if tracked == 0 {
+		return 0
+	}
+	return math.Round(float64(up)/float64(tracked)*10000) / 100
+}
+
+func timelineEntryFrom(transition Transition) TimelineEntry {
+	return TimelineEntry{
+		Time:      transition.Time,
+		Kind:      transition.Kind,
+		Service:   transition.Service,
+		Container: transition.Container,
+		Status:    transition.Status,
+		Health:    transition.Health,
+		ExitCode:  transition.ExitCode,
+		OOMKilled: transition.OOMKilled,
+		Running:   transition.Running,
+		Stopped:   transition.Stopped,
+	}
+}
+
+func parseEventTime(timestamp string) time.Time {
+	if parsed, err := time.Parse(time.RFC3339, timestamp); err == nil {
+		return parsed.UTC()
+	}
+	return time.Now().UTC()
+}
+
+func shortContainerID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
+
+func ParseWindow(value string) (time.Duration, error) {
+	if value == "" {
+		return 24 * time.Hour, nil
+	}
+
+	var days int
+	if _, err := fmt.Sscanf(value, "%dd", &days); err == nil && fmt.Sprintf("%dd", days) == value {
+		if days <= 0 {
+			return 0, fmt.Errorf("invalid window '%s'", value)
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration <= 0 {
+		return 0, fmt.Errorf("invalid window '%s'", value)
+	}
+
+	return duration, nil
+}
@@ -0,0 +1,259 @@
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tech-arch1tect/berth-agent/internal/logging"
+	"github.com/tech-arch1tect/berth-agent/internal/validation"
+
+	"go.uber.org/zap"
+)
+
+type Store struct {
+	persistenceDir string
+	logger         *logging.Logger
+	mu             sync.Mutex
+}
+
+func NewStore(persistenceDir string, logger *logging.Logger) (*Store, error) {
+	if err := os.MkdirAll(persistenceDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create history persistence directory: %w", err)
+	}
+
+	return &Store{
+		persistenceDir: persistenceDir,
+		logger:         logger,
+	}, nil
+}
+
+func (st *Store) Append(stackName string, transition Transition) error {
+	filename, err := st.stackFilename(stackName)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(transition)
+	if err != nil {
+		return fmt.Errorf("failed to marshal transition: %w", err)
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	file, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open history file: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write history file: %w", err)
+	}
+
+	return nil
+}
+
+func (st *Store) Load(stackName string) ([]Transition, error) {
+	filename, err := st.stackFilename(stackName)
+	if err != nil {
+		return nil, err
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	return st.readFile(filename)
+}
+
+func (st *Store) Stacks() ([]string, error) {
+	entries, err := os.ReadDir(st.persistenceDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []string{}, nil
+		}
+		return nil, fmt.Errorf("failed to read history directory: %w", err)
+	}
+
+	var stacks []string
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".jsonl") {
+			continue
+		}
+		stacks = append(stacks, strings.TrimSuffix(entry.Name(), ".jsonl"))
+	}
+
+	return stacks, nil
+}
+
+func (st *Store) Prune(cutoff time.Time) error {
+	stacks, err := st.Stacks()
+	if err != nil {
+		return err
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	for _, stackName := range stacks {
+		filename := filepath.Join(st.persistenceDir, stackName+".jsonl")
+
+		transitions, err := st.readFile(filename)
+		if err != nil {
+			st.logger.Warn("failed to read history file for pruning",
+				zap.String("stack_name", stackName),
+				zap.Error(err),
+			)
+			continue
+		}
+
+		keep := retainedTransitions(transitions, cutoff)
+		if len(keep) == len(transitions) {
+			continue
+		}
+
+		if len(keep) == 0 {
+			if err := os.Remove(filename); err != nil && !os.IsNotExist(err) {
+				st.logger.Warn("failed to remove expired history file",
+					zap.String("stack_name", stackName),
+					zap.Error(err),
+				)
+			}
+			continue
+		}
+
+		if err := st.writeFile(filename, keep); err != nil {
+			st.logger.Warn("failed to rewrite history file",
+				zap.String("stack_name", stackName),
+				zap.Error(err),
+			)
+			continue
+		}
+
+		st.logger.Debug("pruned stack history",
+			zap.String("stack_name", stackName),
+			zap.Int("removed", len(transitions)-len(keep)),
+		)
+	}
+
+	return nil
+}
+
+func retainedTransitions(transitions []Transition, cutoff time.Time) []Transition {
+	latestBeforeCutoff := make(map[string]int)
+	keep := make([]Transition, 0, len(transitions))
+
+	for i, transition := range transitions {
+		if transition.Time.Before(cutoff) {
+			latestBeforeCutoff[transitionKey(transition)] = i
+		}
+	}
+
+	for i, transition := range transitions {
+		if transition.Time.Before(cutoff) && latestBeforeCutoff[transitionKey(transition)] != i {
+			continue
+		}
+		keep = append(keep, transition)
+	}
+
+	for _, transition := range keep {
+		if !transition.Time.Before(cutoff) || !isTerminalStatus(transition.Status) {
+			return keep
+		}
+	}
+
+	return nil
+}
+
+func isTerminalStatus(status string) bool {
+	return status == "down" || status == "not created"
+}
+
+func (st *Store) readFile(filename string) ([]Transition, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []Transition{}, nil
+		}
+		return nil, fmt.Errorf("failed to open history file: %w", err)
+	}
+	defer file.Close()
+
+	var transitions []Transition
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		var transition Transition
+		if err := json.Unmarshal(line, &transition); err != nil {
+			continue
+		}
+		transitions = append(transitions, transition)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read history file: %w", err)
+	}
+
+	return transitions, nil
+}
+
+func (st *Store) writeFile(filename string, transitions []Transition) error {
+	temp := filename + ".tmp"
+	file, err := os.OpenFile(temp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create history file: %w", err)
+	}
+
+	writer := bufio.NewWriter(file)
+	for _, transition := range transitions {
+		data, err := json.Marshal(transition)
+		if err != nil {
+			file.Close()
+			os.Remove(temp)
+			return fmt.Errorf("failed to marshal transition: %w", err)
+		}
+		writer.Write(data)
+		writer.WriteByte('\n')
+	}
+
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		os.Remove(temp)
+		return fmt.Errorf("failed to write history file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(temp)
+		return fmt.Errorf("failed to write history file: %w", err)
+	}
+
+	if err := os.Rename(temp, filename); err != nil {
+		os.Remove(temp)
+		return fmt.Errorf("failed to write history file: %w", err)
+	}
+
+	return nil
+}
+
+func (st *Store) stackFilename(stackName string) (string, error) {
+	if err := validation.ValidateStackName(stackName); err != nil {
+		return "", fmt.Errorf("invalid stack name '%s': %w", stackName, err)
+	}
+	return filepath.Join(st.persistenceDir, stackName+".jsonl"), nil
+}
+
+func transitionKey(transition Transition) string {
+	if transition.Kind == KindContainer {
+		return KindContainer + ":" + transition.Container
+	}
+	return KindStack
+}
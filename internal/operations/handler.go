@@ -1,6 +1,7 @@
 package operations
 
 import (
+	"fmt"
 	"github.com/tech-arch1tect/berth-agent/internal/agentsign"
 	"github.com/tech-arch1tect/berth-agent/internal/audit"
 	"github.com/tech-arch1tect/berth-agent/internal/common"
@@ -50,6 +51,29 @@ func (h *Handler) StartOperation(c echo.Context) error {
 		c.Set("operation_options", req.Options)
 	}
 
+	if req.PreflightPorts {
+		conflicts, err := h.service.CheckPortConflicts(stackName, req.Services)
+		if err != nil {
+			h.auditService.LogOperationEvent(audit.EventOperationStarted, c.RealIP(), stackName, "", req.Command, false, err.Error(), 0, map[string]any{
+				"services": req.Services,
+				"options":  req.Options,
+			})
+			return common.SendInternalError(c, err.Error())
+		}
+
+		if len(conflicts) > 0 {
+			h.auditService.LogOperationEvent(audit.EventOperationStarted, c.RealIP(), stackName, "", req.Command, false, "port conflicts detected", 0, map[string]any{
+				"services":  req.Services,
+				"options":   req.Options,
+				"conflicts": len(conflicts),
+			})
+			return c.JSON(http.StatusConflict, PortConflictResponse{
+				Error:     fmt.Sprintf("refusing to start stack '%s': %d port conflict(s) detected", stackName, len(conflicts)),
+				Conflicts: conflicts,
+			})
+		}
+	}
+
 	operationID, err := h.service.StartOperation(c.Request().Context(), stackName, req)
 	if err != nil {
 		h.auditService.LogOperationEvent(audit.EventOperationStarted, c.RealIP(), stackName, "", req.Command, false, err.Error(), 0, map[string]any{
@@ -1,6 +1,10 @@
 package operations
 
-import "time"
+import (
+	"time"
+
+	"github.com/tech-arch1tect/berth-agent/internal/stack"
+)
 
 type RegistryCredential struct {
 	Registry string `json:"registry"`
@@ -14,12 +18,18 @@ type OperationRequest struct {
 	Services            []string             `json:"services"`
 	RegistryCredentials []RegistryCredential `json:"registry_credentials,omitempty"`
 	BackupPassword      string               `json:"backup_password,omitempty"`
+	PreflightPorts      bool                 `json:"preflight_ports,omitempty"`
 }
 
 type OperationResponse struct {
 	OperationID string `json:"operationId"`
 }
 
+type PortConflictResponse struct {
+	Error     string               `json:"error"`
+	Conflicts []stack.PortConflict `json:"conflicts"`
+}
+
 type StreamMessage struct {
 	Type      string    `json:"type"`
 	Data      string    `json:"data"`
@@ -4,7 +4,10 @@ import (
 	"github.com/tech-arch1tect/berth-agent/config"
 	"github.com/tech-arch1tect/berth-agent/internal/audit"
 	"github.com/tech-arch1tect/berth-agent/internal/backup"
+	"github.com/tech-arch1tect/berth-agent/internal/files"
 	"github.com/tech-arch1tect/berth-agent/internal/logging"
+	"github.com/tech-arch1tect/berth-agent/internal/secrets"
+	"github.com/tech-arch1tect/berth-agent/internal/stack"
 
 	"go.uber.org/fx"
 )
@@ -14,6 +17,6 @@ var Module = fx.Options(
 	fx.Provide(NewHandler),
 )
 
-func NewServiceWithConfig(cfg *config.Config, logger *logging.Logger, auditService *audit.Service, backupService *backup.Service) *Service {
-	return NewService(cfg.StackLocation, cfg.AccessToken, logger, auditService, backupService)
+func NewServiceWithConfig(cfg *config.Config, logger *logging.Logger, auditService *audit.Service, backupService *backup.Service, stackService *stack.Service, secretsService *secrets.Service, filesService *files.Service) *Service {
+	return NewService(cfg.StackLocation, cfg.AccessToken, logger, auditService, backupService, stackService, secretsService, filesService)
 }
@@ -6,13 +6,16 @@ import (
 	"context"
 	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/tech-arch1tect/berth-agent/internal/agentsign"
 	"github.com/tech-arch1tect/berth-agent/internal/archive"
 	"github.com/tech-arch1tect/berth-agent/internal/audit"
 	"github.com/tech-arch1tect/berth-agent/internal/backup"
 	"github.com/tech-arch1tect/berth-agent/internal/logging"
+	"github.com/tech-arch1tect/berth-agent/internal/secrets"
 	"github.com/tech-arch1tect/berth-agent/internal/sidecar"
+	"github.com/tech-arch1tect/berth-agent/internal/stack"
 	"github.com/tech-arch1tect/berth-agent/internal/validation"
 	"io"
 	"net/http"
@@ -36,9 +39,21 @@ type Service struct {
 	backupService    *backup.Service
 	logger           *logging.Logger
 	auditService     *audit.Service
+	ports            portChecker
+	secrets          *secrets.Service
+	quota            quotaTracker
 }
 
-func NewService(stackLocation, accessToken string, logger *logging.Logger, auditService *audit.Service, backupService *backup.Service) *Service {
+type portChecker interface {
+	CheckStackPortConflicts(stackName string, services []string) ([]stack.PortConflict, error)
+}
+
+type quotaTracker interface {
+	QuotaRemaining(stackName string) (int64, bool, error)
+	InvalidateUsage(stackName string)
+}
+
+func NewService(stackLocation, accessToken string, logger *logging.Logger, auditService *audit.Service, backupService *backup.Service, ports portChecker, secretsService *secrets.Service, quota quotaTracker) *Service {
 	logger.Debug("operations service initialized",
 		zap.String("stack_location", stackLocation),
 	)
@@ -51,9 +66,29 @@ func NewService(stackLocation, accessToken string, logger *logging.Logger, audit
 		backupService:    backupService,
 		logger:           logger,
 		auditService:     auditService,
+		ports:            ports,
+		secrets:          secretsService,
+		quota:            quota,
 	}
 }
 
+func (s *Service) CheckPortConflicts(stackName string, services []string) ([]stack.PortConflict, error) {
+	if s.ports == nil {
+		return []stack.PortConflict{}, nil
+	}
+
+	conflicts, err := s.ports.CheckStackPortConflicts(stackName, services)
+	if err != nil {
+		s.logger.Error("port pre-flight check failed",
+			zap.String("stack_name", stackName),
+			zap.Error(err),
+		)
+		return nil, fmt.Errorf("port pre-flight check failed: %w", err)
+	}
+
+	return conflicts, nil
+}
+
 func (s *Service) StartOperation(ctx context.Context, stackName string, req OperationRequest) (string, error) {
 	s.logger.Debug("starting operation request",
 		zap.String("stack_name", stackName),
@@ -186,6 +221,8 @@ func (s *Service) runOperation(operation *Operation) {
 		s.handleArchiveOperationWithBroadcast(ctx, operation, stackPath)
 	case "create-backup", "restore-backup":
 		s.handleBackupOperationWithBroadcast(ctx, operation, stackPath)
+	case "export-stack", "import-stack":
+		s.handleBundleOperationWithBroadcast(ctx, operation, stackPath)
 	default:
 		s.runComposeOperation(ctx, operation, stackPath)
 	}
@@ -206,7 +243,21 @@ func (s *Service) runComposeOperation(ctx context.Context, operation *Operation,
 		defer os.RemoveAll(tempDockerConfig)
 	}
 
-	cmd := s.buildCommand(operation.Request, stackPath)
+	var composeFileArgs []string
+	if s.secrets != nil {
+		var err error
+		composeFileArgs, err = s.secrets.Materialise(operation.StackName, stackPath)
+		if err != nil {
+			s.updateOperationStatus(operationID, "failed", nil)
+			operation.Broadcaster.BroadcastError(fmt.Sprintf("Failed to prepare stack secrets: %v", err))
+			return
+		}
+		if len(composeFileArgs) > 0 {
+			operation.Broadcaster.Broadcast(StreamTypeProgress, "Stack secrets prepared from the agent secret store")
+		}
+	}
+
+	cmd := s.buildCommand(operation.Request, stackPath, composeFileArgs)
 	cmd.Dir = stackPath
 	operation.Broadcaster.Broadcast(StreamTypeStdout, "Running: "+strings.Join(cmd.Args, " "))
 
@@ -301,6 +352,10 @@ func (s *Service) runComposeOperation(ctx context.Context, operation *Operation,
 		s.updateOperationStatus(operationID, "completed", &exitCode)
 		operation.Broadcaster.BroadcastComplete(true, exitCode)
 
+		if s.secrets != nil && operation.Request.Command == "down" && len(operation.Request.Services) == 0 {
+			s.secrets.Release(operation.StackName)
+		}
+
 		s.auditService.LogOperationEvent(audit.EventOperationCompleted, "", operation.StackName, operationID, operation.Request.Command, true, "", duration.Milliseconds(), map[string]any{
 			"exit_code": 0,
 			"services":  operation.Request.Services,
@@ -538,6 +593,72 @@ func (s *Service) handleBackupOperationWithBroadcast(ctx context.Context, operat
 	return nil
 }
 
+func (s *Service) handleBundleOperationWithBroadcast(ctx context.Context, operation *Operation, stackPath string) error {
+	progressWriter := NewBroadcasterProgressWriter(operation.Broadcaster)
+	options := operation.Request.Options
+
+	var err error
+	switch operation.Request.Command {
+	case "export-stack":
+		opts := backup.ExportOptions{}
+		compress := false
+		for i := 0; i < len(options); i++ {
+			switch options[i] {
+			case "--output":
+				if i+1 < len(options) {
+					i++
+					opts.OutputPath = options[i]
+				}
+			case "--compress":
+				compress = true
+			case "--stop":
+				opts.StopStack = true
+			case "--skip-images":
+				opts.SkipImages = true
+			case "--skip-volumes":
+				opts.SkipVolumes = true
+			}
+		}
+		if opts.OutputPath == "" {
+			opts.OutputPath = backup.DefaultBundleName(operation.StackName, compress)
+		}
+		err = s.backupService.ExportStack(ctx, operation.StackName, stackPath, opts, progressWriter)
+	case "import-stack":
+		opts := backup.ImportOptions{}
+		for i := 0; i < len(options); i++ {
+			switch options[i] {
+			case "--bundle":
+				if i+1 < len(options) {
+					i++
+					opts.BundlePath = options[i]
+				}
+			case "--overwrite":
+				opts.Overwrite = true
+			case "--skip-images":
+				opts.SkipImages = true
+			case "--skip-volumes":
+				opts.SkipVolumes = true
+			}
+		}
+		err = s.backupService.ImportStack(ctx, operation.StackName, stackPath, opts, progressWriter)
+	default:
+		err = fmt.Errorf("unknown bundle command: %s", operation.Request.Command)
+	}
+
+	if err != nil {
+		s.updateOperationStatus(operation.ID, "failed", nil)
+		operation.Broadcaster.BroadcastError(fmt.Sprintf("Bundle operation failed: %v", err))
+		operation.Broadcaster.BroadcastComplete(false, 1)
+		return err
+	}
+
+	exitCode := 0
+	s.updateOperationStatus(operation.ID, "completed", &exitCode)
+	operation.Broadcaster.BroadcastComplete(true, exitCode)
+
+	return nil
+}
+
 func (s *Service) createTempDockerConfigWithBroadcast(ctx context.Context, credentials []RegistryCredential, broadcaster *Broadcaster) (string, error) {
 	tempDir, err := os.MkdirTemp("", "berth-docker-config-*")
 	if err != nil {
@@ -578,9 +699,11 @@ func (s *Service) createTempDockerConfigWithBroadcast(ctx context.Context, crede
 	return tempDir, nil
 }
 
-func (s *Service) buildCommand(req OperationRequest, stackPath string) *exec.Cmd {
+func (s *Service) buildCommand(req OperationRequest, stackPath string, composeFileArgs []string) *exec.Cmd {
 
-	args := []string{"compose", req.Command}
+	args := []string{"compose"}
+	args = append(args, composeFileArgs...)
+	args = append(args, req.Command)
 
 	filteredOptions := make([]string, 0, len(req.Options))
 	for _, option := range req.Options {
@@ -666,7 +789,25 @@ func (s *Service) handleExtractArchive(ctx context.Context, operation *Operation
 		}
 	}
 
-	return s.archiveService.ExtractArchive(ctx, stackPath, opts, writer)
+	if s.quota != nil {
+		remaining, limited, err := s.quota.QuotaRemaining(operation.StackName)
+		if err != nil {
+			return err
+		}
+		if limited {
+			if remaining <= 0 {
+				return fmt.Errorf("stack disk quota exceeded")
+			}
+			opts.MaxBytes = remaining
+		}
+		defer s.quota.InvalidateUsage(operation.StackName)
+	}
+
+	err := s.archiveService.ExtractArchive(ctx, stackPath, opts, writer)
+	if errors.Is(err, archive.ErrSizeLimitExceeded) {
+		return fmt.Errorf("stack disk quota exceeded: %w", err)
+	}
+	return err
 }
 
 const completedOperationRetention = 30 * time.Minute
@@ -29,6 +29,8 @@ var validCommands = map[string]bool{
 	"extract-archive": true,
 	"create-backup":   true,
 	"restore-backup":  true,
+	"export-stack":    true,
+	"import-stack":    true,
 }
 
 var validOptions = map[string]map[string]bool{
@@ -118,6 +120,10 @@ func ValidateOperationRequest(req OperationRequest) error {
 		return fmt.Errorf("%w: %s does not accept a backup password", ErrInvalidOption, req.Command)
 	}
 
+	if req.PreflightPorts && req.Command != "up" {
+		return fmt.Errorf("%w: preflight_ports is only supported for up", ErrInvalidOption)
+	}
+
 	// Handle archive commands separately
 	if req.Command == "create-archive" {
 		return archive.ValidateCreateOptions(req.Options)
@@ -131,6 +137,12 @@ func ValidateOperationRequest(req OperationRequest) error {
 	if req.Command == "restore-backup" {
 		return validateRestoreBackupRequest(req)
 	}
+	if req.Command == "export-stack" {
+		return validateExportStackRequest(req)
+	}
+	if req.Command == "import-stack" {
+		return validateImportStackRequest(req)
+	}
 
 	// Handle Docker commands
 	commandOptions, exists := validOptions[req.Command]
@@ -233,6 +245,77 @@ func validateRestoreBackupRequest(req OperationRequest) error {
 	return nil
 }
 
+func validBundlePath(path string) bool {
+	if path == "" || containsDangerousChars(path) || strings.HasPrefix(path, "/") || strings.Contains(path, "..") {
+		return false
+	}
+	lower := strings.ToLower(path)
+	return strings.HasSuffix(lower, ".tar") || strings.HasSuffix(lower, ".tar.gz")
+}
+
+func validateExportStackRequest(req OperationRequest) error {
+	if len(req.Services) > 0 {
+		return fmt.Errorf("%w: export-stack applies to the whole stack and accepts no service arguments", ErrInvalidOption)
+	}
+
+	output := ""
+	compress := false
+	options := req.Options
+	for i := 0; i < len(options); i++ {
+		switch options[i] {
+		case "--output":
+			if i+1 >= len(options) {
+				return fmt.Errorf("%w: --output requires a value", ErrInvalidOption)
+			}
+			i++
+			if !validBundlePath(options[i]) {
+				return fmt.Errorf("%w: --output must be a relative path within the stack ending in .tar or .tar.gz", ErrInvalidOption)
+			}
+			output = options[i]
+		case "--compress":
+			compress = true
+		case "--stop", "--skip-images", "--skip-volumes":
+		default:
+			return fmt.Errorf("%w: %s", ErrInvalidOption, options[i])
+		}
+	}
+
+	if compress && output != "" && !strings.HasSuffix(strings.ToLower(output), ".tar.gz") {
+		return fmt.Errorf("%w: --compress requires an --output ending in .tar.gz", ErrInvalidOption)
+	}
+	return nil
+}
+
+func validateImportStackRequest(req OperationRequest) error {
+	if len(req.Services) > 0 {
+		return fmt.Errorf("%w: import-stack applies to the whole stack and accepts no service arguments", ErrInvalidOption)
+	}
+
+	bundle := ""
+	options := req.Options
+	for i := 0; i < len(options); i++ {
+		switch options[i] {
+		case "--bundle":
+			if i+1 >= len(options) {
+				return fmt.Errorf("%w: --bundle requires a value", ErrInvalidOption)
+			}
+			i++
+			if !validBundlePath(options[i]) {
+				return fmt.Errorf("%w: --bundle must be a relative path within the stack ending in .tar or .tar.gz", ErrInvalidOption)
+			}
+			bundle = options[i]
+		case "--overwrite", "--skip-images", "--skip-volumes":
+		default:
+			return fmt.Errorf("%w: %s", ErrInvalidOption, options[i])
+		}
+	}
+
+	if bundle == "" {
+		return fmt.Errorf("%w: import-stack requires --bundle", ErrInvalidOption)
+	}
+	return nil
+}
+
 func validateOptions(options []string, validOpts map[string]bool) error {
 	i := 0
 	for i < len(options) {
@@ -0,0 +1,192 @@
+package revisions
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/tech-arch1tect/berth-agent/internal/audit"
+	"github.com/tech-arch1tect/berth-agent/internal/common"
+	"github.com/tech-arch1tect/berth-agent/internal/validation"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	ActorHeader       = "X-Berth-Actor"
+	maxActorNameBytes = 128
+)
+
+type Handler struct {
+	service      *Service
+	auditService *audit.Service
+}
+
+func NewHandler(service *Service, auditService *audit.Service) *Handler {
+	return &Handler{
+		service:      service,
+		auditService: auditService,
+	}
+}
+
+func ActorFromContext(c echo.Context) Actor {
+	name := strings.TrimSpace(c.Request().Header.Get(ActorHeader))
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, name)
+	if len(name) > maxActorNameBytes {
+		name = strings.ToValidUTF8(name[:maxActorNameBytes], "")
+	}
+
+	return Actor{
+		Name:     name,
+		ClientIP: c.RealIP(),
+	}
+}
+
+func (h *Handler) ListRevisions(c echo.Context) error {
+	stackName, err := stackNameParam(c)
+	if err != nil {
+		return common.SendBadRequest(c, err.Error())
+	}
+	file := c.QueryParam("file")
+
+	revisions, err := h.service.List(stackName, file)
+	if err != nil {
+		h.auditService.LogStackEvent(audit.EventStackListRevisions, c.RealIP(), stackName, false, err.Error(), nil)
+		return common.SendBadRequest(c, err.Error())
+	}
+
+	h.auditService.LogStackEvent(audit.EventStackListRevisions, c.RealIP(), stackName, true, "", map[string]any{
+		"file":  file,
+		"count": len(revisions.Revisions),
+	})
+
+	return common.SendSuccess(c, revisions)
+}
+
+func (h *Handler) GetRevision(c echo.Context) error {
+	stackName, err := stackNameParam(c)
+	if err != nil {
+		return common.SendBadRequest(c, err.Error())
+	}
+	id, err := strconv.Atoi(c.Param("revisionId"))
+	if err != nil || id <= 0 {
+		return common.SendBadRequest(c, "revision id must be a positive integer")
+	}
+
+	reveal := validation.ParseRevealParam(c.QueryParam("reveal"))
+
+	revision, err := h.service.Get(stackName, id, reveal)
+	if err != nil {
+		h.auditService.LogStackEvent(audit.EventStackGetRevision, c.RealIP(), stackName, false, err.Error(), map[string]any{
+			"revision": id,
+		})
+		return revisionError(c, err)
+	}
+
+	h.auditService.LogStackEvent(audit.EventStackGetRevision, c.RealIP(), stackName, true, "", map[string]any{
+		"revision": id,
+		"file":     revision.File,
+	})
+	h.logReveal(c, stackName, revision.Revealed, map[string]any{
+		"revision": id,
+	})
+
+	return common.SendSuccess(c, revision)
+}
+
+func (h *Handler) DiffRevisions(c echo.Context) error {
+	stackName, err := stackNameParam(c)
+	if err != nil {
+		return common.SendBadRequest(c, err.Error())
+	}
+	from := c.QueryParam("from")
+	to := c.QueryParam("to")
+	if to == "" {
+		to = currentVersion
+	}
+
+	reveal := validation.ParseRevealParam(c.QueryParam("reveal"))
+
+	diff, err := h.service.Diff(stackName, from, to, c.QueryParam("file"), reveal)
+	if err != nil {
+		h.auditService.LogStackEvent(audit.EventStackDiffRevisions, c.RealIP(), stackName, false, err.Error(), map[string]any{
+			"from": from,
+			"to":   to,
+		})
+		return revisionError(c, err)
+	}
+
+	h.auditService.LogStackEvent(audit.EventStackDiffRevisions, c.RealIP(), stackName, true, "", map[string]any{
+		"from": from,
+		"to":   to,
+	})
+	h.logReveal(c, stackName, diff.Revealed, map[string]any{
+		"from": from,
+		"to":   to,
+	})
+
+	return common.SendSuccess(c, diff)
+}
+
+func (h *Handler) RevertRevision(c echo.Context) error {
+	stackName, err := stackNameParam(c)
+	if err != nil {
+		return common.SendBadRequest(c, err.Error())
+	}
+	id, err := strconv.Atoi(c.Param("revisionId"))
+	if err != nil || id <= 0 {
+		return common.SendBadRequest(c, "revision id must be a positive integer")
+	}
+
+	actor := ActorFromContext(c)
+	result, err := h.service.Revert(stackName, id, actor)
+	if err != nil {
+		h.auditService.LogStackEvent(audit.EventStackRevertRevision, c.RealIP(), stackName, false, err.Error(), map[string]any{
+			"revision": id,
+			"actor":    actor.Name,
+		})
+		return revisionError(c, err)
+	}
+
+	h.auditService.LogStackEvent(audit.EventStackRevertRevision, c.RealIP(), stackName, true, "", map[string]any{
+		"revision":     id,
+		"file":         result.Revision.File,
+		"new_revision": result.Revision.ID,
+		"actor":        actor.Name,
+	})
+
+	return common.SendSuccess(c, result)
+}
+
+func (h *Handler) logReveal(c echo.Context, stackName string, revealed []string, metadata map[string]any) {
+	if len(revealed) == 0 {
+		return
+	}
+	metadata["variables"] = revealed
+	metadata["actor"] = ActorFromContext(c).Name
+	h.auditService.LogStackEvent(audit.EventStackRevealDotEnv, c.RealIP(), stackName, true, "", metadata)
+}
+
+func stackNameParam(c echo.Context) (string, error) {
+	stackName := c.Param("name")
+	if stackName == "" {
+		return "", errors.New("stack name is required")
+	}
+	if err := validation.ValidateStackName(stackName); err != nil {
+		return "", errors.New("invalid stack name: " + err.Error())
+	}
+	return stackName, nil
+}
+
+func revisionError(c echo.Context, err error) error {
+	if errors.Is(err, ErrRevisionNotFound) {
+		return common.SendNotFound(c, err.Error())
+	}
+	return common.SendBadRequest(c, err.Error())
+}
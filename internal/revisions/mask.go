@@ -0,0 +1,181 @@
+package revisions
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/tech-arch1tect/berth-agent/internal/validation"
+)
+
+const (
+	dotEnvFile         = ".env"
+	changedSecretLabel = " (changed)"
+)
+
+type envAssignment struct {
+	start  int
+	end    int
+	prefix string
+	key    string
+	value  string
+}
+
+type secretMasker struct {
+	revealAll  bool
+	revealKeys map[string]bool
+	masked     map[string]bool
+	revealed   map[string]bool
+}
+
+func newSecretMasker(reveal []string) *secretMasker {
+	m := &secretMasker{
+		revealKeys: make(map[string]bool),
+		masked:     make(map[string]bool),
+		revealed:   make(map[string]bool),
+	}
+	for _, key := range reveal {
+		if key == validation.RevealAll {
+			m.revealAll = true
+		}
+		m.revealKeys[key] = true
+	}
+	return m
+}
+
+func (m *secretMasker) mask(file string, content []byte, changed map[string]bool) []byte {
+	if file != dotEnvFile || len(content) == 0 {
+		return content
+	}
+
+	lines := strings.Split(string(content), "\n")
+	var out []string
+	next := 0
+	for _, assignment := range parseEnvAssignments(lines) {
+		if assignment.value == "" || !validation.IsSecretVariableName(assignment.key) {
+			continue
+		}
+		if m.revealAll || m.revealKeys[assignment.key] {
+			m.revealed[assignment.key] = true
+			continue
+		}
+
+		m.masked[assignment.key] = true
+		line := assignment.prefix + validation.SecretMask
+		if changed[assignment.key] {
+			line += changedSecretLabel
+		}
+		out = append(out, lines[next:assignment.start]...)
+		out = append(out, line)
+		next = assignment.end
+	}
+	out = append(out, lines[next:]...)
+
+	return []byte(strings.Join(out, "\n"))
+}
+
+func (m *secretMasker) maskedKeys() []string {
+	return sortedKeys(m.masked)
+}
+
+func (m *secretMasker) revealedKeys() []string {
+	return sortedKeys(m.revealed)
+}
+
+func changedSecrets(file string, from, to []byte) map[string]bool {
+	changed := make(map[string]bool)
+	if file != dotEnvFile {
+		return changed
+	}
+
+	fromValues := secretValues(from)
+	toValues := secretValues(to)
+	for key, value := range toValues {
+		if previous, ok := fromValues[key]; ok && previous != value {
+			changed[key] = true
+		}
+	}
+	return changed
+}
+
+func secretValues(content []byte) map[string]string {
+	values := make(map[string]string)
+	for _, assignment := range parseEnvAssignments(strings.Split(string(content), "\n")) {
+		if validation.IsSecretVariableName(assignment.key) {
+			values[assignment.key] = assignment.value
+		}
+	}
+	return values
+}
+
+func parseEnvAssignments(lines []string) []envAssignment {
+	var assignments []envAssignment
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		separator := strings.IndexAny(line, "=:")
+		if separator < 0 {
+			continue
+		}
+		key := strings.TrimSpace(line[:separator])
+		key = strings.TrimSpace(strings.TrimPrefix(key, "export "))
+
+		assignment := envAssignment{start: i, end: i + 1, key: key}
+		rest := line[separator+1:]
+		value := strings.TrimLeft(rest, " \t")
+		assignment.prefix = line[:len(line)-len(value)]
+
+		if value != "" && (value[0] == '"' || value[0] == '\'') {
+			quote := value[0]
+			text := value[1:]
+			raw := value
+			for j := i; ; j++ {
+				if closingQuote(text, quote) >= 0 || j+1 >= len(lines) {
+					assignment.end = j + 1
+					break
+				}
+				text = lines[j+1]
+				raw += "\n" + text
+			}
+			i = assignment.end - 1
+			value = raw
+		} else if comment := strings.Index(value, " #"); comment >= 0 {
+			value = value[:comment]
+		}
+
+		value = strings.TrimSpace(value)
+		if value == `""` || value == "''" {
+			value = ""
+		}
+		assignment.value = value
+		assignments = append(assignments, assignment)
+	}
+	return assignments
+}
+
+func closingQuote(text string, quote byte) int {
+	escaped := false
+	for i := 0; i < len(text); i++ {
+		switch {
+		case escaped:
+			escaped = false
+		case text[i] == '\\':
+			escaped = true
+		case text[i] == quote:
+			return i
+		}
+	}
+	return -1
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,59 @@
+package revisions
+
+import "time"
+
+const (
+	SourceComposeEditor = "compose_editor"
+	SourceFileEditor    = "file_editor"
+	SourceEnvEditor     = "env_editor"
+	SourceRevert        = "revert"
+	SourceExternal      = "external"
+)
+
+type Actor struct {
+	Name     string
+	ClientIP string
+}
+
+type Revision struct {
+	ID           int       `json:"id"`
+	File         string    `json:"file"`
+	Time         time.Time `json:"time"`
+	Actor        string    `json:"actor,omitempty"`
+	ClientIP     string    `json:"client_ip,omitempty"`
+	Source       string    `json:"source"`
+	Hash         string    `json:"hash"`
+	Size         int64     `json:"size"`
+	RevertedFrom int       `json:"reverted_from,omitempty"`
+}
+
+type RevisionList struct {
+	StackName string     `json:"stack_name"`
+	File      string     `json:"file,omitempty"`
+	Revisions []Revision `json:"revisions"`
+}
+
+type RevisionContent struct {
+	Revision
+	Content  string   `json:"content"`
+	Masked   []string `json:"masked"`
+	Revealed []string `json:"revealed"`
+}
+
+type RevisionDiff struct {
+	From      string   `json:"from"`
+	To        string   `json:"to"`
+	FromFile  string   `json:"from_file"`
+	ToFile    string   `json:"to_file"`
+	FromHash  string   `json:"from_hash"`
+	ToHash    string   `json:"to_hash"`
+	Identical bool     `json:"identical"`
+	Diff      string   `json:"diff"`
+	Masked    []string `json:"masked"`
+	Revealed  []string `json:"revealed"`
+}
+
+type RevertResult struct {
+	Revision     Revision `json:"revision"`
+	RevertedFrom int      `json:"reverted_from"`
+}
@@ -0,0 +1,12 @@
+package revisions
+
+import (
+	"go.uber.org/fx"
+)
+
+var Module = fx.Options(
+	fx.Provide(
+		NewService,
+		NewHandler,
+	),
+)
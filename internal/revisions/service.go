@@ -0,0 +1,645 @@
+package revisions
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tech-arch1tect/berth-agent/config"
+	"github.com/tech-arch1tect/berth-agent/internal/logging"
+	"github.com/tech-arch1tect/berth-agent/internal/validation"
+
+	"go.uber.org/zap"
+)
+
+const (
+	historyDirName = ".berth/history"
+	indexFileName  = "index.jsonl"
+	objectsDirName = "objects"
+	currentVersion = "current"
+)
+
+var ErrRevisionNotFound = errors.New("revision not found")
+
+var trackedFiles = map[string]bool{
+	"docker-compose.yml":  true,
+	"docker-compose.yaml": true,
+	"compose.yml":         true,
+	"compose.yaml":        true,
+	".env":                true,
+}
+
+func IsTracked(relativePath string) bool {
+	return trackedFiles[filepath.Clean(strings.TrimPrefix(relativePath, "/"))]
+}
+
+type Service struct {
+	stackLocation string
+	maxVersions   int
+	logger        *logging.Logger
+	mu            sync.Mutex
+	fileLocksMu   sync.Mutex
+	fileLocks     map[string]*sync.Mutex
+}
+
+func NewService(cfg *config.Config, logger *logging.Logger) *Service {
+	maxVersions := cfg.FileHistoryMaxVersions
+	if maxVersions <= 0 {
+		maxVersions = 50
+	}
+
+	return &Service{
+		stackLocation: cfg.StackLocation,
+		maxVersions:   maxVersions,
+		logger:        logger.With(zap.String("service", "revisions")),
+		fileLocks:     make(map[string]*sync.Mutex),
+	}
+}
+
+func (s *Service) LockFiles(stackName string, files ...string) func() {
+	keys := make([]string, 0, len(files))
+	seen := make(map[string]bool)
+	for _, file := range files {
+		file = filepath.Clean(strings.TrimPrefix(file, "/"))
+		key := stackName + "/" + file
+		if !trackedFiles[file] || seen[key] {
+			continue
+		}
+		seen[key] = true
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	locks := make([]*sync.Mutex, 0, len(keys))
+	s.fileLocksMu.Lock()
+	for _, key := range keys {
+		lock, ok := s.fileLocks[key]
+		if !ok {
+			lock = &sync.Mutex{}
+			s.fileLocks[key] = lock
+		}
+		locks = append(locks, lock)
+	}
+	s.fileLocksMu.Unlock()
+
+	for _, lock := range locks {
+		lock.Lock()
+	}
+	return func() {
+		for i := len(locks) - 1; i >= 0; i-- {
+			locks[i].Unlock()
+		}
+	}
+}
+
+func (s *Service) FindContent(stackName, file, hash string) ([]byte, error) {
+	stackPath, err := s.stackPath(stackName)
+	if err != nil {
+		return nil, err
+	}
+	file = filepath.Clean(strings.TrimPrefix(file, "/"))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	revisions, err := s.readIndex(stackPath)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(revisions) - 1; i >= 0; i-- {
+		if revisions[i].File != file || revisions[i].Hash != hash {
+			continue
+		}
+		_, content, err := s.loadRevision(stackPath, revisions[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		return content, nil
+	}
+
+	return nil, fmt.Errorf("%w: no revision of %s has hash %s", ErrRevisionNotFound, file, hash)
+}
+
+func (s *Service) Capture(stackName, file string) error {
+	stackPath, err := s.stackPath(stackName)
+	if err != nil {
+		return err
+	}
+	file = filepath.Clean(strings.TrimPrefix(file, "/"))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.captureLocked(stackPath, file)
+}
+
+func (s *Service) Record(stackName, file string, content []byte, actor Actor, source string) (*Revision, error) {
+	stackPath, err := s.stackPath(stackName)
+	if err != nil {
+		return nil, err
+	}
+	file = filepath.Clean(strings.TrimPrefix(file, "/"))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.recordLocked(stackPath, Revision{
+		File:     file,
+		Time:     time.Now().UTC(),
+		Actor:    actor.Name,
+		ClientIP: actor.ClientIP,
+		Source:   source,
+	}, content)
+}
+
+func (s *Service) List(stackName, file string) (*RevisionList, error) {
+	stackPath, err := s.stackPath(stackName)
+	if err != nil {
+		return nil, err
+	}
+	if file != "" {
+		file = filepath.Clean(strings.TrimPrefix(file, "/"))
+		if !trackedFiles[file] {
+			return nil, fmt.Errorf("file '%s' is not versioned", file)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	revisions, err := s.readIndex(stackPath)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &RevisionList{
+		StackName: stackName,
+		File:      file,
+		Revisions: []Revision{},
+	}
+	for i := len(revisions) - 1; i >= 0; i-- {
+		if file == "" || revisions[i].File == file {
+			result.Revisions = append(result.Revisions, revisions[i])
+		}
+	}
+
+	return result, nil
+}
+
+func (s *Service) Get(stackName string, id int, reveal []string) (*RevisionContent, error) {
+	stackPath, err := s.stackPath(stackName)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	revision, content, err := s.loadRevision(stackPath, id)
+	if err != nil {
+		return nil, err
+	}
+
+	masker := newSecretMasker(reveal)
+	content = masker.mask(revision.File, content, nil)
+
+	return &RevisionContent{
+		Revision: *revision,
+		Content:  string(content),
+		Masked:   masker.maskedKeys(),
+		Revealed: masker.revealedKeys(),
+	}, nil
+}
+
+func (s *Service) Diff(stackName, from, to, file string, reveal []string) (*RevisionDiff, error) {
+	stackPath, err := s.stackPath(stackName)
+	if err != nil {
+		return nil, err
+	}
+	if from == "" || to == "" {
+		return nil, errors.New("both from and to are required")
+	}
+	if from == currentVersion && to == currentVersion {
+		return nil, errors.New("at least one side of the diff must be a revision")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	type side struct {
+		label   string
+		file    string
+		content []byte
+	}
+	sides := map[string]*side{}
+
+	for _, spec := range []string{from, to} {
+		if spec == currentVersion || sides[spec] != nil {
+			continue
+		}
+		id, err := strconv.Atoi(spec)
+		if err != nil {
+			return nil, fmt.Errorf("invalid revision '%s': expected a revision id or '%s'", spec, currentVersion)
+		}
+		revision, content, err := s.loadRevision(stackPath, id)
+		if err != nil {
+			return nil, err
+		}
+		sides[spec] = &side{
+			label:   fmt.Sprintf("%s@%d", revision.File, revision.ID),
+			file:    revision.File,
+			content: content,
+		}
+	}
+
+	if from == currentVersion || to == currentVersion {
+		if file == "" {
+			for _, resolved := range sides {
+				file = resolved.file
+			}
+		}
+		file = filepath.Clean(strings.TrimPrefix(file, "/"))
+		if !trackedFiles[file] {
+			return nil, fmt.Errorf("file '%s' is not versioned", file)
+		}
+		content, err := os.ReadFile(filepath.Join(stackPath, file))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		sides[currentVersion] = &side{
+			label:   file + "@" + currentVersion,
+			file:    file,
+			content: content,
+		}
+	}
+
+	fromSide, toSide := sides[from], sides[to]
+	identical := bytes.Equal(fromSide.content, toSide.content)
+
+	masker := newSecretMasker(reveal)
+	changed := map[string]bool{}
+	if fromSide.file == toSide.file {
+		changed = changedSecrets(toSide.file, fromSide.content, toSide.content)
+	}
+	diff := unifiedDiff(fromSide.label, toSide.label,
+		masker.mask(fromSide.file, fromSide.content, nil),
+		masker.mask(toSide.file, toSide.content, changed))
+
+	return &RevisionDiff{
+		From:      from,
+		To:        to,
+		FromFile:  fromSide.file,
+		ToFile:    toSide.file,
+		FromHash:  contentHash(fromSide.content),
+		ToHash:    contentHash(toSide.content),
+		Identical: identical,
+		Diff:      diff,
+		Masked:    masker.maskedKeys(),
+		Revealed:  masker.revealedKeys(),
+	}, nil
+}
+
+func (s *Service) Revert(stackName string, id int, actor Actor) (*RevertResult, error) {
+	stackPath, err := s.stackPath(stackName)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	target, _, err := s.loadRevision(stackPath, id)
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	unlock := s.LockFiles(stackName, target.File)
+	defer unlock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	target, content, err := s.loadRevision(stackPath, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.captureLocked(stackPath, target.File); err != nil {
+		return nil, fmt.Errorf("failed to preserve the current version of %s: %w", target.File, err)
+	}
+
+	fullPath := filepath.Join(stackPath, target.File)
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(fullPath); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if err := os.WriteFile(fullPath, content, mode); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", target.File, err)
+	}
+
+	revision, err := s.recordLocked(stackPath, Revision{
+		File:         target.File,
+		Time:         time.Now().UTC(),
+		Actor:        actor.Name,
+		ClientIP:     actor.ClientIP,
+		Source:       SourceRevert,
+		RevertedFrom: target.ID,
+	}, content)
+	if err != nil {
+		return nil, fmt.Errorf("reverted %s but failed to record the revision: %w", target.File, err)
+	}
+
+	s.logger.Info("reverted file to a previous revision",
+		zap.String("stack", stackName),
+		zap.String("file", target.File),
+		zap.Int("revision", target.ID),
+	)
+
+	return &RevertResult{
+		Revision:     *revision,
+		RevertedFrom: target.ID,
+	}, nil
+}
+
+func (s *Service) stackPath(stackName string) (string, error) {
+	stackPath, err := validation.SanitizeStackPath(s.stackLocation, stackName)
+	if err != nil {
+		return "", fmt.Errorf("invalid stack name '%s': %w", stackName, err)
+	}
+
+	if _, err := os.Stat(stackPath); os.IsNotExist(err) {
+		return "", fmt.Errorf("stack '%s' not found", stackName)
+	}
+
+	return stackPath, nil
+}
+
+func (s *Service) captureLocked(stackPath, file string) error {
+	fullPath := filepath.Join(stackPath, file)
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	content, err := os.ReadFile(fullPath)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.recordLocked(stackPath, Revision{
+		File:   file,
+		Time:   info.ModTime().UTC(),
+		Source: SourceExternal,
+	}, content)
+	return err
+}
+
+func (s *Service) recordLocked(stackPath string, revision Revision, content []byte) (*Revision, error) {
+	revisions, err := s.readIndex(stackPath)
+	if err != nil {
+		return nil, err
+	}
+
+	revision.Hash = contentHash(content)
+	revision.Size = int64(len(content))
+
+	for i := len(revisions) - 1; i >= 0; i-- {
+		if revisions[i].File != revision.File {
+			continue
+		}
+		if revisions[i].Hash == revision.Hash {
+			return &revisions[i], nil
+		}
+		break
+	}
+
+	for _, existing := range revisions {
+		if existing.ID >= revision.ID {
+			revision.ID = existing.ID + 1
+		}
+	}
+	if revision.ID == 0 {
+		revision.ID = 1
+	}
+
+	if err := s.writeObject(stackPath, revision.Hash, content); err != nil {
+		return nil, err
+	}
+
+	revisions = append(revisions, revision)
+	pruned := s.pruneRevisions(revisions, revision.File)
+	if len(pruned) == len(revisions) {
+		err = s.appendIndex(stackPath, revision)
+	} else {
+		err = s.rewriteIndex(stackPath, pruned)
+		if err == nil {
+			s.removeUnreferencedObjects(stackPath, pruned)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &revision, nil
+}
+
+func (s *Service) pruneRevisions(revisions []Revision, file string) []Revision {
+	count := 0
+	for _, revision := range revisions {
+		if revision.File == file {
+			count++
+		}
+	}
+	if count <= s.maxVersions {
+		return revisions
+	}
+
+	drop := count - s.maxVersions
+	pruned := make([]Revision, 0, len(revisions)-drop)
+	for _, revision := range revisions {
+		if revision.File == file && drop > 0 {
+			drop--
+			continue
+		}
+		pruned = append(pruned, revision)
+	}
+	return pruned
+}
+
+func (s *Service) loadRevision(stackPath string, id int) (*Revision, []byte, error) {
+	revisions, err := s.readIndex(stackPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for i := range revisions {
+		if revisions[i].ID != id {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(stackPath, historyDirName, objectsDirName, revisions[i].Hash))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read revision %d: %w", id, err)
+		}
+		if contentHash(content) != revisions[i].Hash {
+			return nil, nil, fmt.Errorf("revision %d is corrupt: content hash mismatch", id)
+		}
+		return &revisions[i], content, nil
+	}
+
+	return nil, nil, fmt.Errorf("%w: %d", ErrRevisionNotFound, id)
+}
+
+func (s *Service) readIndex(stackPath string) ([]Revision, error) {
+	file, err := os.Open(filepath.Join(stackPath, historyDirName, indexFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to open revision index: %w", err)
+	}
+	defer file.Close()
+
+	var revisions []Revision
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var revision Revision
+		if err := json.Unmarshal(line, &revision); err != nil {
+			s.logger.Warn("skipping malformed revision index entry",
+				zap.String("path", stackPath),
+				zap.Error(err),
+			)
+			continue
+		}
+		revisions = append(revisions, revision)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read revision index: %w", err)
+	}
+
+	sort.SliceStable(revisions, func(i, j int) bool {
+		return revisions[i].ID < revisions[j].ID
+	})
+
+	return revisions, nil
+}
+
+func (s *Service) appendIndex(stackPath string, revision Revision) error {
+	data, err := json.Marshal(revision)
+	if err != nil {
+		return fmt.Errorf("failed to marshal revision: %w", err)
+	}
+
+	file, err := os.OpenFile(filepath.Join(stackPath, historyDirName, indexFileName), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open revision index: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write revision index: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Service) rewriteIndex(stackPath string, revisions []Revision) error {
+	var buf strings.Builder
+	for _, revision := range revisions {
+		data, err := json.Marshal(revision)
+		if err != nil {
+			return fmt.Errorf("failed to marshal revision: %w", err)
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+
+	return writeFileAtomic(filepath.Join(stackPath, historyDirName, indexFileName), []byte(buf.String()))
+}
+
+func (s *Service) writeObject(stackPath, hash string, content []byte) error {
+	objectsDir := filepath.Join(stackPath, historyDirName, objectsDirName)
+	if err := os.MkdirAll(objectsDir, 0700); err != nil {
+		return fmt.Errorf("failed to create revision store: %w", err)
+	}
+
+	objectPath := filepath.Join(objectsDir, hash)
+	if _, err := os.Stat(objectPath); err == nil {
+		return nil
+	}
+
+	if err := writeFileAtomic(objectPath, content); err != nil {
+		return fmt.Errorf("failed to store revision: %w", err)
+	}
+	return nil
+}
+
+func (s *Service) removeUnreferencedObjects(stackPath string, revisions []Revision) {
+	referenced := make(map[string]bool, len(revisions))
+	for _, revision := range revisions {
+		referenced[revision.Hash] = true
+	}
+
+	objectsDir := filepath.Join(stackPath, historyDirName, objectsDirName)
+	entries, err := os.ReadDir(objectsDir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if referenced[entry.Name()] {
+			continue
+		}
+		if err := os.Remove(filepath.Join(objectsDir, entry.Name())); err != nil {
+			s.logger.Warn("failed to remove pruned revision",
+				zap.String("path", stackPath),
+				zap.String("object", entry.Name()),
+				zap.Error(err),
+			)
+		}
+	}
+}
+
+func writeFileAtomic(path string, content []byte) error {
+	temp, err := os.CreateTemp(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return err
+	}
+	tempPath := temp.Name()
+
+	if _, err := temp.Write(content); err != nil {
+		temp.Close()
+		os.Remove(tempPath)
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+	if err := os.Chmod(tempPath, 0600); err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+	return os.Rename(tempPath, path)
+}
+
+func contentHash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
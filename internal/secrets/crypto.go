@@ -0,0 +1,93 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const (
+	masterKeyBytes = 32
+	storeKeyInfo   = "berth-agent secrets store v1"
+)
+
+func loadOrCreateMasterKey(path string) ([]byte, bool, error) {
+	key, err := os.ReadFile(path)
+	if err == nil {
+		if len(key) < masterKeyBytes {
+			return nil, false, fmt.Errorf("secrets key file %s must contain at least %d bytes", path, masterKeyBytes)
+		}
+		return key, false, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, false, fmt.Errorf("failed to read secrets key file: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, false, fmt.Errorf("failed to create secrets key directory: %w", err)
+	}
+
+	key = make([]byte, masterKeyBytes)
+	if _, err := rand.Read(key); err != nil {
+		return nil, false, fmt.Errorf("failed to generate secrets key: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return loadOrCreateMasterKey(path)
+		}
+		return nil, false, fmt.Errorf("failed to create secrets key file: %w", err)
+	}
+	if _, err := file.Write(key); err != nil {
+		file.Close()
+		os.Remove(path)
+		return nil, false, fmt.Errorf("failed to write secrets key file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(path)
+		return nil, false, fmt.Errorf("failed to write secrets key file: %w", err)
+	}
+
+	return key, true, nil
+}
+
+func newStoreCipher(masterKey []byte) (cipher.AEAD, error) {
+	key, err := hkdf.Key(sha256.New, masterKey, nil, storeKeyInfo, 32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive secrets store key: %w", err)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialise secrets cipher: %w", err)
+	}
+
+	return cipher.NewGCM(block)
+}
+
+func secretAdditionalData(stackName, name string) []byte {
+	return []byte("berth-agent/secrets/" + stackName + "/" + name)
+}
+
+func (s *Service) seal(stackName, name string, value []byte) ([]byte, []byte, error) {
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return nonce, s.aead.Seal(nil, nonce, value, secretAdditionalData(stackName, name)), nil
+}
+
+func (s *Service) open(stackName string, secret storedSecret) ([]byte, error) {
+	value, err := s.aead.Open(nil, secret.Nonce, secret.Ciphertext, secretAdditionalData(stackName, secret.Name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt secret %s: the store may have been written with a different key", secret.Name)
+	}
+	return value, nil
+}
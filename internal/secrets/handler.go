@@ -0,0 +1,145 @@
+package secrets
+
+import (
+	"errors"
+
+	"github.com/tech-arch1tect/berth-agent/internal/audit"
+	"github.com/tech-arch1tect/berth-agent/internal/common"
+	"github.com/tech-arch1tect/berth-agent/internal/revisions"
+	"github.com/tech-arch1tect/berth-agent/internal/validation"
+
+	"github.com/labstack/echo/v4"
+)
+
+type Handler struct {
+	service      *Service
+	auditService *audit.Service
+}
+
+func NewHandler(service *Service, auditService *audit.Service) *Handler {
+	return &Handler{
+		service:      service,
+		auditService: auditService,
+	}
+}
+
+func (h *Handler) ListSecrets(c echo.Context) error {
+	stackName, err := stackNameParam(c)
+	if err != nil {
+		return common.SendBadRequest(c, err.Error())
+	}
+
+	list, err := h.service.List(stackName)
+	if err != nil {
+		h.auditService.LogStackEvent(audit.EventStackListSecrets, c.RealIP(), stackName, false, err.Error(), nil)
+		return secretError(c, err)
+	}
+
+	h.auditService.LogStackEvent(audit.EventStackListSecrets, c.RealIP(), stackName, true, "", map[string]any{
+		"count": len(list.Secrets),
+	})
+
+	return common.SendSuccess(c, list)
+}
+
+func (h *Handler) GetSecret(c echo.Context) error {
+	stackName, err := stackNameParam(c)
+	if err != nil {
+		return common.SendBadRequest(c, err.Error())
+	}
+	name := c.Param("secretName")
+
+	metadata, err := h.service.Get(stackName, name)
+	if err != nil {
+		h.auditService.LogStackEvent(audit.EventStackGetSecret, c.RealIP(), stackName, false, err.Error(), map[string]any{
+			"secret": name,
+		})
+		return secretError(c, err)
+	}
+
+	h.auditService.LogStackEvent(audit.EventStackGetSecret, c.RealIP(), stackName, true, "", map[string]any{
+		"secret": name,
+	})
+
+	return common.SendSuccess(c, metadata)
+}
+
+func (h *Handler) SetSecret(c echo.Context) error {
+	stackName, err := stackNameParam(c)
+	if err != nil {
+		return common.SendBadRequest(c, err.Error())
+	}
+	name := c.Param("secretName")
+
+	var req SetSecretRequest
+	if err := c.Bind(&req); err != nil {
+		return common.SendBadRequest(c, "invalid request body")
+	}
+
+	actor := revisions.ActorFromContext(c)
+	metadata, created, err := h.service.Set(stackName, name, req, actor)
+	if err != nil {
+		h.auditService.LogStackEvent(audit.EventStackSetSecret, c.RealIP(), stackName, false, err.Error(), map[string]any{
+			"secret": name,
+			"actor":  actor.Name,
+		})
+		return secretError(c, err)
+	}
+
+	h.auditService.LogStackEvent(audit.EventStackSetSecret, c.RealIP(), stackName, true, "", map[string]any{
+		"secret":  name,
+		"created": created,
+		"version": metadata.Version,
+		"actor":   actor.Name,
+	})
+
+	if created {
+		return common.SendCreated(c, metadata)
+	}
+	return common.SendSuccess(c, metadata)
+}
+
+func (h *Handler) DeleteSecret(c echo.Context) error {
+	stackName, err := stackNameParam(c)
+	if err != nil {
+		return common.SendBadRequest(c, err.Error())
+	}
+	name := c.Param("secretName")
+
+	actor := revisions.ActorFromContext(c)
+	if err := h.service.Delete(stackName, name); err != nil {
+		h.auditService.LogStackEvent(audit.EventStackDeleteSecret, c.RealIP(), stackName, false, err.Error(), map[string]any{
+			"secret": name,
+			"actor":  actor.Name,
+		})
+		return secretError(c, err)
+	}
+
+	h.auditService.LogStackEvent(audit.EventStackDeleteSecret, c.RealIP(), stackName, true, "", map[string]any{
+		"secret": name,
+		"actor":  actor.Name,
+	})
+
+	return common.SendMessage(c, "secret deleted")
+}
+
+func stackNameParam(c echo.Context) (string, error) {
+	stackName := c.Param("name")
+	if stackName == "" {
+		return "", errors.New("stack name is required")
+	}
+	if err := validation.ValidateStackName(stackName); err != nil {
+		return "", errors.New("invalid stack name: " + err.Error())
+	}
+	return stackName, nil
+}
+
+func secretError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, ErrSecretNotFound), errors.Is(err, ErrStackNotFound):
+		return common.SendNotFound(c, err.Error())
+	case errors.Is(err, ErrInvalidSecret):
+		return common.SendBadRequest(c, err.Error())
+	}
+	return common.SendInternalError(c, err.Error())
+}
@@ -0,0 +1,45 @@
+package secrets
+
+import "time"
+
+type SecretMetadata struct {
+	Name         string    `json:"name"`
+	Size         int       `json:"size"`
+	Version      int       `json:"version"`
+	UID          *int      `json:"uid,omitempty"`
+	GID          *int      `json:"gid,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	UpdatedBy    string    `json:"updated_by,omitempty"`
+	Referenced   bool      `json:"referenced"`
+	Services     []string  `json:"services"`
+	Materialised bool      `json:"materialised"`
+}
+
+type SecretList struct {
+	StackName string           `json:"stack_name"`
+	Secrets   []SecretMetadata `json:"secrets"`
+}
+
+type SetSecretRequest struct {
+	Value string `json:"value"`
+	UID   *int   `json:"uid,omitempty"`
+	GID   *int   `json:"gid,omitempty"`
+}
+
+type storedSecret struct {
+	Name       string    `json:"name"`
+	Nonce      []byte    `json:"nonce"`
+	Ciphertext []byte    `json:"ciphertext"`
+	Size       int       `json:"size"`
+	Version    int       `json:"version"`
+	UID        *int      `json:"uid,omitempty"`
+	GID        *int      `json:"gid,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	UpdatedBy  string    `json:"updated_by,omitempty"`
+}
+
+type stackSecrets struct {
+	Secrets []storedSecret `json:"secrets"`
+}
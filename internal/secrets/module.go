@@ -0,0 +1,22 @@
+package secrets
+
+import (
+	"context"
+
+	"go.uber.org/fx"
+)
+
+var Module = fx.Options(
+	fx.Provide(NewService),
+	fx.Provide(NewHandler),
+	fx.Invoke(RestoreRuntimeSecrets),
+)
+
+func RestoreRuntimeSecrets(lc fx.Lifecycle, service *Service) {
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			service.Start()
+			return nil
+		},
+	})
+}
@@ -0,0 +1,295 @@
+package secrets
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/compose-spec/compose-go/v2/cli"
+	"github.com/compose-spec/compose-go/v2/dotenv"
+	"github.com/tech-arch1tect/berth-agent/internal/validation"
+
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	runtimeOverrideFile = "compose.secrets.yaml"
+	tmpfsMagic          = 0x01021994
+)
+
+func (s *Service) Start() {
+	restored := 0
+	for _, stackName := range s.storedStacks() {
+		stackPath, err := validation.SanitizeStackPath(s.stackLocation, stackName)
+		if err != nil || !fileExists(stackPath) {
+			continue
+		}
+		args, err := s.Materialise(stackName, stackPath)
+		if err != nil {
+			s.logger.Warn("failed to restore materialised secrets",
+				zap.String("stack", stackName),
+				zap.Error(err),
+			)
+			continue
+		}
+		if len(args) > 0 {
+			restored++
+		}
+	}
+
+	if restored > 0 {
+		s.logger.Info("restored materialised secrets", zap.Int("stacks", restored))
+	}
+}
+
+func (s *Service) Materialise(stackName, stackPath string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	store, err := s.readStore(stackName)
+	if err != nil {
+		return nil, err
+	}
+	if len(store.Secrets) == 0 {
+		return nil, nil
+	}
+
+	files := composeFiles(stackPath)
+	references := referencedSecretsIn(files)
+
+	var wired []storedSecret
+	for _, secret := range store.Secrets {
+		if _, ok := references[secret.Name]; ok {
+			wired = append(wired, secret)
+		}
+	}
+	if len(wired) == 0 {
+		return nil, nil
+	}
+
+	if err := os.MkdirAll(s.runtimeDir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create secrets runtime directory: %w", err)
+	}
+	s.warnIfNotTmpfs()
+
+	dir := s.stackRuntimeDir(stackName)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create secrets runtime directory: %w", err)
+	}
+	if err := os.Chmod(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to secure secrets runtime directory: %w", err)
+	}
+
+	secretsNode := &yaml.Node{Kind: yaml.MappingNode}
+	for _, secret := range wired {
+		value, err := s.open(stackName, secret)
+		if err != nil {
+			return nil, err
+		}
+
+		path := s.runtimeSecretPath(stackName, secret.Name)
+		if err := writeRuntimeSecret(path, value, secret.UID, secret.GID); err != nil {
+			return nil, fmt.Errorf("failed to materialise secret %s: %w", secret.Name, err)
+		}
+
+		secretsNode.Content = append(secretsNode.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Value: secret.Name},
+			&yaml.Node{Kind: yaml.MappingNode, Tag: "!override", Content: []*yaml.Node{
+				{Kind: yaml.ScalarNode, Value: "file"},
+				{Kind: yaml.ScalarNode, Value: path},
+			}},
+		)
+	}
+
+	override, err := yaml.Marshal(&yaml.Node{Kind: yaml.MappingNode, Content: []*yaml.Node{
+		{Kind: yaml.ScalarNode, Value: "secrets"},
+		secretsNode,
+	}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode secrets override: %w", err)
+	}
+
+	overridePath := filepath.Join(dir, runtimeOverrideFile)
+	if err := writeRuntimeSecret(overridePath, override, nil, nil); err != nil {
+		return nil, fmt.Errorf("failed to write secrets override: %w", err)
+	}
+
+	args := make([]string, 0, 2*len(files)+2)
+	for _, file := range files {
+		args = append(args, "-f", file)
+	}
+	args = append(args, "-f", overridePath)
+
+	s.logger.Debug("materialised stack secrets",
+		zap.String("stack", stackName),
+		zap.Int("secrets", len(wired)),
+	)
+
+	return args, nil
+}
+
+func (s *Service) Release(stackName string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.RemoveAll(s.stackRuntimeDir(stackName)); err != nil {
+		s.logger.Warn("failed to remove materialised secrets",
+			zap.String("stack", stackName),
+			zap.Error(err),
+		)
+	}
+}
+
+func (s *Service) referencedSecrets(stackPath string) map[string][]string {
+	return referencedSecretsIn(composeFiles(stackPath))
+}
+
+func (s *Service) stackRuntimeDir(stackName string) string {
+	return filepath.Join(s.runtimeDir, stackName)
+}
+
+func (s *Service) runtimeSecretPath(stackName, name string) string {
+	return filepath.Join(s.stackRuntimeDir(stackName), name)
+}
+
+func (s *Service) warnIfNotTmpfs() {
+	s.tmpfsCheck.Do(func() {
+		var stat syscall.Statfs_t
+		if err := syscall.Statfs(s.runtimeDir, &stat); err != nil {
+			return
+		}
+		if int64(stat.Type) != tmpfsMagic {
+			s.logger.Warn("secrets runtime directory is not on a tmpfs; materialised secrets are protected by 0600 permissions only",
+				zap.String("path", s.runtimeDir),
+			)
+		}
+	})
+}
+
+func composeFiles(stackPath string) []string {
+	envPath := filepath.Join(stackPath, ".env")
+	if fileExists(envPath) {
+		values, err := dotenv.GetEnvFromFile(map[string]string{}, []string{envPath})
+		if err == nil && values["COMPOSE_FILE"] != "" {
+			separator := values["COMPOSE_PATH_SEPARATOR"]
+			if separator == "" {
+				separator = string(os.PathListSeparator)
+			}
+			var files []string
+			for _, file := range strings.Split(values["COMPOSE_FILE"], separator) {
+				if file = strings.TrimSpace(file); file == "" {
+					continue
+				}
+				if !filepath.IsAbs(file) {
+					file = filepath.Join(stackPath, file)
+				}
+				files = append(files, file)
+			}
+			return files
+		}
+	}
+
+	var files []string
+	for _, name := range cli.DefaultFileNames {
+		if path := filepath.Join(stackPath, name); fileExists(path) {
+			files = append(files, path)
+			break
+		}
+	}
+	if len(files) == 0 {
+		return nil
+	}
+	for _, name := range cli.DefaultOverrideFileNames {
+		if path := filepath.Join(stackPath, name); fileExists(path) {
+			files = append(files, path)
+			break
+		}
+	}
+	return files
+}
+
+func referencedSecretsIn(files []string) map[string][]string {
+	references := make(map[string][]string)
+
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		var doc struct {
+			Secrets  map[string]any `yaml:"secrets"`
+			Services map[string]struct {
+				Secrets []any `yaml:"secrets"`
+			} `yaml:"services"`
+		}
+		if err := yaml.Unmarshal(content, &doc); err != nil {
+			continue
+		}
+
+		for name := range doc.Secrets {
+			if _, ok := references[name]; !ok {
+				references[name] = []string{}
+			}
+		}
+
+		for serviceName, service := range doc.Services {
+			for _, item := range service.Secrets {
+				var source string
+				switch v := item.(type) {
+				case string:
+					source = v
+				case map[string]any:
+					source, _ = v["source"].(string)
+				}
+				if source == "" {
+					continue
+				}
+				if !slices.Contains(references[source], serviceName) {
+					references[source] = append(references[source], serviceName)
+				}
+			}
+		}
+	}
+
+	for name := range references {
+		sort.Strings(references[name])
+	}
+	return references
+}
+
+func writeRuntimeSecret(path string, content []byte, uid, gid *int) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(content); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		return err
+	}
+
+	if uid != nil || gid != nil {
+		owner, group := -1, -1
+		if uid != nil {
+			owner = *uid
+		}
+		if gid != nil {
+			group = *gid
+		}
+		if err := os.Chown(path, owner, group); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,383 @@
+package secrets
+
+import (
+	"crypto/cipher"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tech-arch1tect/berth-agent/config"
+	"github.com/tech-arch1tect/berth-agent/internal/logging"
+	"github.com/tech-arch1tect/berth-agent/internal/revisions"
+	"github.com/tech-arch1tect/berth-agent/internal/validation"
+
+	"go.uber.org/zap"
+)
+
+const (
+	maxSecretValueBytes = 64 * 1024
+	storeFileExtension  = ".json"
+)
+
+var (
+	ErrSecretNotFound = errors.New("secret not found")
+	ErrStackNotFound  = errors.New("stack not found")
+	ErrInvalidSecret  = errors.New("invalid secret")
+)
+
+var secretNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]{0,63}$`)
+
+type Service struct {
+	stackLocation  string
+	persistenceDir string
+	runtimeDir     string
+	aead           cipher.AEAD
+	logger         *logging.Logger
+	mu             sync.Mutex
+	tmpfsCheck     sync.Once
+}
+
+func NewService(cfg *config.Config, logger *logging.Logger) (*Service, error) {
+	serviceLogger := logger.With(zap.String("component", "secrets"))
+
+	masterKey, created, err := loadOrCreateMasterKey(cfg.SecretsKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	if created {
+		serviceLogger.Info("generated new secrets store key", zap.String("key_file", cfg.SecretsKeyFile))
+	}
+
+	aead, err := newStoreCipher(masterKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(cfg.SecretsPersistenceDir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create secrets persistence directory: %w", err)
+	}
+
+	for _, path := range []string{cfg.SecretsKeyFile, cfg.SecretsPersistenceDir, cfg.SecretsRuntimeDir} {
+		if isWithin(cfg.StackLocation, path) {
+			serviceLogger.Warn("secrets path is inside the stack location and can be read through the files API",
+				zap.String("path", path),
+			)
+		}
+	}
+
+	return &Service{
+		stackLocation:  cfg.StackLocation,
+		persistenceDir: cfg.SecretsPersistenceDir,
+		runtimeDir:     cfg.SecretsRuntimeDir,
+		aead:           aead,
+		logger:         serviceLogger,
+	}, nil
+}
+
+func (s *Service) List(stackName string) (*SecretList, error) {
+	stackPath, err := s.stackPath(stackName)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	store, err := s.readStore(stackName)
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	references := s.referencedSecrets(stackPath)
+
+	list := &SecretList{
+		StackName: stackName,
+		Secrets:   make([]SecretMetadata, 0, len(store.Secrets)),
+	}
+	for _, secret := range store.Secrets {
+		list.Secrets = append(list.Secrets, s.metadata(stackName, secret, references))
+	}
+
+	return list, nil
+}
+
+func (s *Service) Get(stackName, name string) (*SecretMetadata, error) {
+	stackPath, err := s.stackPath(stackName)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	store, err := s.readStore(stackName)
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, secret := range store.Secrets {
+		if secret.Name == name {
+			metadata := s.metadata(stackName, secret, s.referencedSecrets(stackPath))
+			return &metadata, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrSecretNotFound, name)
+}
+
+func (s *Service) Set(stackName, name string, req SetSecretRequest, actor revisions.Actor) (*SecretMetadata, bool, error) {
+	stackPath, err := s.stackPath(stackName)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if !secretNamePattern.MatchString(name) {
+		return nil, false, fmt.Errorf("%w: name must start with a letter or digit and contain only letters, digits, '.', '_' or '-' (max 64 characters)", ErrInvalidSecret)
+	}
+	if req.Value == "" {
+		return nil, false, fmt.Errorf("%w: value is required", ErrInvalidSecret)
+	}
+	if len(req.Value) > maxSecretValueBytes {
+		return nil, false, fmt.Errorf("%w: value exceeds %d bytes", ErrInvalidSecret, maxSecretValueBytes)
+	}
+	if (req.UID != nil && *req.UID < 0) || (req.GID != nil && *req.GID < 0) {
+		return nil, false, fmt.Errorf("%w: uid and gid must not be negative", ErrInvalidSecret)
+	}
+
+	nonce, ciphertext, err := s.seal(stackName, name, []byte(req.Value))
+	if err != nil {
+		return nil, false, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	store, err := s.readStore(stackName)
+	if err != nil {
+		return nil, false, err
+	}
+
+	now := time.Now().UTC()
+	created := true
+	secret := storedSecret{
+		Name:      name,
+		CreatedAt: now,
+	}
+	index := -1
+	for i, existing := range store.Secrets {
+		if existing.Name == name {
+			secret = existing
+			index = i
+			created = false
+			break
+		}
+	}
+
+	secret.Nonce = nonce
+	secret.Ciphertext = ciphertext
+	secret.Size = len(req.Value)
+	secret.Version++
+	secret.UID = req.UID
+	secret.GID = req.GID
+	secret.UpdatedAt = now
+	secret.UpdatedBy = actor.Name
+
+	if index >= 0 {
+		store.Secrets[index] = secret
+	} else {
+		store.Secrets = append(store.Secrets, secret)
+		sort.Slice(store.Secrets, func(i, j int) bool {
+			return store.Secrets[i].Name < store.Secrets[j].Name
+		})
+	}
+
+	if err := s.writeStore(stackName, store); err != nil {
+		return nil, false, err
+	}
+
+	if path := s.runtimeSecretPath(stackName, name); fileExists(path) {
+		if err := writeRuntimeSecret(path, []byte(req.Value), secret.UID, secret.GID); err != nil {
+			s.logger.Warn("failed to refresh materialised secret",
+				zap.String("stack", stackName),
+				zap.String("secret", name),
+				zap.Error(err),
+			)
+		}
+	}
+
+	metadata := s.metadata(stackName, secret, s.referencedSecrets(stackPath))
+	return &metadata, created, nil
+}
+
+func (s *Service) Delete(stackName, name string) error {
+	if _, err := s.stackPath(stackName); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	store, err := s.readStore(stackName)
+	if err != nil {
+		return err
+	}
+
+	index := -1
+	for i, secret := range store.Secrets {
+		if secret.Name == name {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return fmt.Errorf("%w: %s", ErrSecretNotFound, name)
+	}
+	store.Secrets = append(store.Secrets[:index], store.Secrets[index+1:]...)
+
+	if len(store.Secrets) == 0 {
+		if err := os.Remove(s.storePath(stackName)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove secrets store: %w", err)
+		}
+	} else if err := s.writeStore(stackName, store); err != nil {
+		return err
+	}
+
+	if err := os.Remove(s.runtimeSecretPath(stackName, name)); err != nil && !os.IsNotExist(err) {
+		s.logger.Warn("failed to remove materialised secret",
+			zap.String("stack", stackName),
+			zap.String("secret", name),
+			zap.Error(err),
+		)
+	}
+
+	return nil
+}
+
+func (s *Service) metadata(stackName string, secret storedSecret, references map[string][]string) SecretMetadata {
+	services, referenced := references[secret.Name]
+	if services == nil {
+		services = []string{}
+	}
+
+	return SecretMetadata{
+		Name:         secret.Name,
+		Size:         secret.Size,
+		Version:      secret.Version,
+		UID:          secret.UID,
+		GID:          secret.GID,
+		CreatedAt:    secret.CreatedAt,
+		UpdatedAt:    secret.UpdatedAt,
+		UpdatedBy:    secret.UpdatedBy,
+		Referenced:   referenced,
+		Services:     services,
+		Materialised: fileExists(s.runtimeSecretPath(stackName, secret.Name)),
+	}
+}
+
+func (s *Service) stackPath(stackName string) (string, error) {
+	stackPath, err := validation.SanitizeStackPath(s.stackLocation, stackName)
+	if err != nil {
+		return "", fmt.Errorf("invalid stack name: %w", err)
+	}
+	if _, err := os.Stat(stackPath); os.IsNotExist(err) {
+		return "", fmt.Errorf("%w: %s", ErrStackNotFound, stackName)
+	}
+	return stackPath, nil
+}
+
+func (s *Service) storePath(stackName string) string {
+	return filepath.Join(s.persistenceDir, stackName+storeFileExtension)
+}
+
+func (s *Service) readStore(stackName string) (*stackSecrets, error) {
+	data, err := os.ReadFile(s.storePath(stackName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &stackSecrets{}, nil
+		}
+		return nil, fmt.Errorf("failed to read secrets store: %w", err)
+	}
+
+	var store stackSecrets
+	if err := json.Unmarshal(data, &store); err != nil {
+		return nil, fmt.Errorf("failed to parse secrets store: %w", err)
+	}
+	return &store, nil
+}
+
+func (s *Service) writeStore(stackName string, store *stackSecrets) error {
+	data, err := json.MarshalIndent(store, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode secrets store: %w", err)
+	}
+
+	temp, err := os.CreateTemp(s.persistenceDir, ".tmp-")
+	if err != nil {
+		return fmt.Errorf("failed to write secrets store: %w", err)
+	}
+	tempPath := temp.Name()
+
+	if _, err := temp.Write(data); err != nil {
+		temp.Close()
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to write secrets store: %w", err)
+	}
+	if err := temp.Close(); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to write secrets store: %w", err)
+	}
+	if err := os.Chmod(tempPath, 0600); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to write secrets store: %w", err)
+	}
+	if err := os.Rename(tempPath, s.storePath(stackName)); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to write secrets store: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Service) storedStacks() []string {
+	entries, err := os.ReadDir(s.persistenceDir)
+	if err != nil {
+		s.logger.Warn("failed to list secrets stores", zap.Error(err))
+		return nil
+	}
+
+	var stacks []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, storeFileExtension) || strings.HasPrefix(name, ".") {
+			continue
+		}
+		stacks = append(stacks, strings.TrimSuffix(name, storeFileExtension))
+	}
+	return stacks
+}
+
+func isWithin(base, path string) bool {
+	if base == "" || path == "" {
+		return false
+	}
+	absBase, err := filepath.Abs(base)
+	if err != nil {
+		return false
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(absBase, absPath)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, "../")
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
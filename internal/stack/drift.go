@@ -0,0 +1,464 @@
+package stack
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tech-arch1tect/berth-agent/internal/docker"
+	"github.com/tech-arch1tect/berth-agent/internal/validation"
+
+	dockercontainer "github.com/docker/docker/api/types/container"
+	"go.uber.org/zap"
+)
+
+type StackDrift struct {
+	Name             string           `json:"name"`
+	Deployed         bool             `json:"deployed"`
+	HasDrift         bool             `json:"has_drift"`
+	Services         []ServiceDrift   `json:"services"`
+	Orphans          []ContainerDrift `json:"orphans"`
+	DriftedServices  []string         `json:"drifted_services"`
+	RedeployRequired bool             `json:"redeploy_required"`
+	HashUnavailable  bool             `json:"hash_unavailable"`
+}
+
+type ServiceDrift struct {
+	Name               string           `json:"name"`
+	Image              string           `json:"image,omitempty"`
+	ExpectedImageID    string           `json:"expected_image_id,omitempty"`
+	ExpectedConfigHash string           `json:"expected_config_hash,omitempty"`
+	ExpectedScale      int              `json:"expected_scale"`
+	ActualScale        int              `json:"actual_scale"`
+	MissingContainers  int              `json:"missing_containers"`
+	ExtraContainers    int              `json:"extra_containers"`
+	ScaleChanged       bool             `json:"scale_changed"`
+	ConfigHashMismatch bool             `json:"config_hash_mismatch"`
+	ImageOutdated      bool             `json:"image_outdated"`
+	HasDrift           bool             `json:"has_drift"`
+	Reasons            []string         `json:"reasons"`
+	Containers         []ContainerDrift `json:"containers"`
+}
+
+type ContainerDrift struct {
+	Name               string `json:"name"`
+	Service            string `json:"service"`
+	State              string `json:"state"`
+	ImageID            string `json:"image_id,omitempty"`
+	ConfigHash         string `json:"config_hash,omitempty"`
+	ConfigHashMismatch bool   `json:"config_hash_mismatch"`
+	ImageOutdated      bool   `json:"image_outdated"`
+}
+
+type StackDriftSummary struct {
+	HasDrift        bool     `json:"has_drift"`
+	DriftedServices []string `json:"drifted_services"`
+	OrphanCount     int      `json:"orphan_count"`
+	HashUnavailable bool     `json:"hash_unavailable"`
+}
+
+type composeServiceSpec struct {
+	Image      string
+	Scale      int
+	ConfigHash string
+	HashErr    error
+	EnvFiles   []string
+}
+
+type cachedDriftSummary struct {
+	fingerprint string
+	summary     *StackDriftSummary
+	computedAt  time.Time
+}
+
+const driftSummaryTTL = 30 * time.Second
+
+func loadComposeServiceSpecs(ctx context.Context, stackPath string) (map[string]composeServiceSpec, error) {
+	cmd := exec.CommandContext(ctx, "docker", "compose", "config", "--format", "json")
+	cmd.Dir = stackPath
+
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("docker compose config failed: %w", err)
+	}
+
+	var config ComposeConfig
+	if err := json.Unmarshal(output, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse compose config: %w", err)
+	}
+
+	specs := make(map[string]composeServiceSpec, len(config.Services))
+	for serviceName, rawService := range config.Services {
+		spec := composeServiceSpec{Scale: 1}
+
+		if serviceConfig, ok := rawService.(map[string]any); ok {
+			if image, ok := serviceConfig["image"].(string); ok {
+				spec.Image = image
+			}
+
+			if deploy, ok := serviceConfig["deploy"].(map[string]any); ok {
+				if replicas, ok := deploy["replicas"].(float64); ok {
+					spec.Scale = int(replicas)
+				}
+			}
+
+			if scale, ok := serviceConfig["scale"].(float64); ok {
+				spec.Scale = int(scale)
+			}
+
+			spec.EnvFiles = composeEnvFiles(serviceConfig["env_file"])
+		}
+
+		specs[serviceName] = spec
+	}
+
+	hashCmd := exec.CommandContext(ctx, "docker", "compose", "config", "--hash", "*")
+	hashCmd.Dir = stackPath
+
+	hashOutput, err := hashCmd.Output()
+	if err != nil {
+		hashErr := fmt.Errorf("docker compose config --hash failed: %w", err)
+		for serviceName, spec := range specs {
+			spec.HashErr = hashErr
+			specs[serviceName] = spec
+		}
+		return specs, nil
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(hashOutput))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		if spec, ok := specs[fields[0]]; ok {
+			spec.ConfigHash = fields[1]
+			specs[fields[0]] = spec
+		}
+	}
+
+	return specs, nil
+}
+
+func composeHashError(specs map[string]composeServiceSpec) error {
+	for _, spec := range specs {
+		if spec.HashErr != nil {
+			return spec.HashErr
+		}
+	}
+	return nil
+}
+
+func (s *Service) GetStackDrift(name string) (*StackDrift, error) {
+	s.logger.Info("Checking stack drift", zap.String("stack", name))
+
+	stackPath, err := validation.SanitizeStackPath(s.stackLocation, name)
+	if err != nil {
+		s.logger.Error("Invalid stack name", zap.String("stack", name), zap.Error(err))
+		return nil, fmt.Errorf("invalid stack name '%s': %w", name, err)
+	}
+
+	if _, err := os.Stat(stackPath); os.IsNotExist(err) {
+		s.logger.Error("Stack not found", zap.String("stack", name), zap.String("path", stackPath))
+		return nil, fmt.Errorf("stack '%s' not found", name)
+	}
+
+	composeFiles := []string{
+		"docker-compose.yml",
+		"docker-compose.yaml",
+		"compose.yml",
+		"compose.yaml",
+	}
+
+	var hasComposeFile bool
+	for _, filename := range composeFiles {
+		if _, err := os.Stat(filepath.Join(stackPath, filename)); err == nil {
+			hasComposeFile = true
+			break
+		}
+	}
+
+	if !hasComposeFile {
+		s.logger.Error("No compose file found", zap.String("stack", name), zap.String("path", stackPath))
+		return nil, fmt.Errorf("no compose file found in stack '%s'", name)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	specs, err := loadComposeServiceSpecs(ctx, stackPath)
+	if err != nil {
+		s.logger.Error("Failed to load compose services", zap.String("stack", name), zap.Error(err))
+		return nil, fmt.Errorf("failed to load compose services: %w", err)
+	}
+	if err := composeHashError(specs); err != nil {
+		s.logger.Warn("Config hashes unavailable; config drift was not checked", zap.String("stack", name), zap.Error(err))
+	}
+
+	drift, err := s.computeStackDrift(ctx, name, specs)
+	if err != nil {
+		s.logger.Error("Failed to compute stack drift", zap.String("stack", name), zap.Error(err))
+		return nil, err
+	}
+
+	s.logger.Info("Stack drift check completed",
+		zap.String("stack", name),
+		zap.Bool("has_drift", drift.HasDrift),
+		zap.Strings("drifted_services", drift.DriftedServices),
+		zap.Int("orphans", len(drift.Orphans)),
+		zap.Bool("hash_unavailable", drift.HashUnavailable))
+
+	return drift, nil
+}
+
+func (s *Service) computeStackDrift(ctx context.Context, stackName string, specs map[string]composeServiceSpec) (*StackDrift, error) {
+	summaries, err := s.dockerClient.ContainerList(ctx, map[string][]string{
+		"label": {fmt.Sprintf("%s=%s", docker.LabelComposeProject, stackName)},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers for stack '%s': %w", stackName, err)
+	}
+
+	byService := make(map[string][]dockercontainer.Summary)
+	for _, summary := range summaries {
+		if strings.EqualFold(summary.Labels[docker.LabelComposeOneoff], "true") {
+			continue
+		}
+		service := summary.Labels[docker.LabelComposeService]
+		if service == "" {
+			continue
+		}
+		byService[service] = append(byService[service], summary)
+	}
+
+	drift := &StackDrift{
+		Name:            stackName,
+		Deployed:        len(byService) > 0,
+		Services:        []ServiceDrift{},
+		Orphans:         []ContainerDrift{},
+		DriftedServices: []string{},
+		HashUnavailable: composeHashError(specs) != nil,
+	}
+
+	serviceNames := make([]string, 0, len(specs))
+	for serviceName := range specs {
+		serviceNames = append(serviceNames, serviceName)
+	}
+	sort.Strings(serviceNames)
+
+	for _, serviceName := range serviceNames {
+		spec := specs[serviceName]
+		serviceDrift := s.computeServiceDrift(ctx, stackName, serviceName, spec, byService[serviceName])
+
+		if serviceDrift.HasDrift {
+			drift.HasDrift = true
+			drift.DriftedServices = append(drift.DriftedServices, serviceName)
+		}
+		drift.Services = append(drift.Services, serviceDrift)
+	}
+
+	for service, containers := range byService {
+		if _, ok := specs[service]; ok {
+			continue
+		}
+		for _, summary := range containers {
+			drift.Orphans = append(drift.Orphans, containerDriftFrom(summary, service))
+		}
+	}
+
+	sort.Slice(drift.Orphans, func(i, j int) bool { return drift.Orphans[i].Name < drift.Orphans[j].Name })
+
+	if len(drift.Orphans) > 0 {
+		drift.HasDrift = true
+	}
+	if !drift.Deployed {
+		drift.HasDrift = false
+		drift.DriftedServices = []string{}
+	}
+	drift.RedeployRequired = drift.HasDrift
+
+	return drift, nil
+}
+
+func (s *Service) computeServiceDrift(ctx context.Context, stackName, serviceName string, spec composeServiceSpec, containers []dockercontainer.Summary) ServiceDrift {
+	serviceDrift := ServiceDrift{
+		Name:               serviceName,
+		Image:              spec.Image,
+		ExpectedConfigHash: spec.ConfigHash,
+		ExpectedScale:      spec.Scale,
+		ActualScale:        len(containers),
+		Reasons:            []string{},
+		Containers:         []ContainerDrift{},
+	}
+
+	imageRef := spec.Image
+	if imageRef == "" {
+		imageRef = fmt.Sprintf("%s-%s", stackName, serviceName)
+	}
+
+	if imageInfo, err := s.dockerClient.ImageInspect(ctx, imageRef); err == nil {
+		serviceDrift.ExpectedImageID = imageInfo.ID
+	} else {
+		s.logger.Debug("Unable to resolve service image locally",
+			zap.String("stack", stackName),
+			zap.String("service", serviceName),
+			zap.String("image", imageRef),
+			zap.Error(err))
+	}
+
+	sort.Slice(containers, func(i, j int) bool {
+		return containerSummaryName(containers[i]) < containerSummaryName(containers[j])
+	})
+
+	for _, summary := range containers {
+		containerDrift := containerDriftFrom(summary, serviceName)
+
+		if spec.ConfigHash != "" && containerDrift.ConfigHash != spec.ConfigHash {
+			containerDrift.ConfigHashMismatch = true
+			serviceDrift.ConfigHashMismatch = true
+		}
+
+		if serviceDrift.ExpectedImageID != "" && containerDrift.ImageID != "" && containerDrift.ImageID != serviceDrift.ExpectedImageID {
+			containerDrift.ImageOutdated = true
+			serviceDrift.ImageOutdated = true
+		}
+
+		serviceDrift.Containers = append(serviceDrift.Containers, containerDrift)
+	}
+
+	if serviceDrift.ActualScale < serviceDrift.ExpectedScale {
+		serviceDrift.MissingContainers = serviceDrift.ExpectedScale - serviceDrift.ActualScale
+		serviceDrift.Reasons = append(serviceDrift.Reasons, fmt.Sprintf("%d container(s) missing", serviceDrift.MissingContainers))
+	}
+	if serviceDrift.ActualScale > serviceDrift.ExpectedScale {
+		serviceDrift.ExtraContainers = serviceDrift.ActualScale - serviceDrift.ExpectedScale
+		serviceDrift.Reasons = append(serviceDrift.Reasons, fmt.Sprintf("%d extra container(s)", serviceDrift.ExtraContainers))
+	}
+	if serviceDrift.ActualScale > 0 && serviceDrift.ActualScale != serviceDrift.ExpectedScale {
+		serviceDrift.ScaleChanged = true
+	}
+	if serviceDrift.ConfigHashMismatch {
+		serviceDrift.Reasons = append(serviceDrift.Reasons, "compose configuration changed since containers were created")
+	}
+	if serviceDrift.ImageOutdated {
+		serviceDrift.Reasons = append(serviceDrift.Reasons, fmt.Sprintf("image %s now resolves to a different local image", imageRef))
+	}
+
+	serviceDrift.HasDrift = len(serviceDrift.Reasons) > 0
+
+	return serviceDrift
+}
+
+func containerDriftFrom(summary dockercontainer.Summary, service string) ContainerDrift {
+	return ContainerDrift{
+		Name:       containerSummaryName(summary),
+		Service:    service,
+		State:      summary.State,
+		ImageID:    summary.ImageID,
+		ConfigHash: summary.Labels[docker.LabelComposeConfigHash],
+	}
+}
+
+func containerSummaryName(summary dockercontainer.Summary) string {
+	if len(summary.Names) == 0 {
+		return ""
+	}
+	return strings.TrimPrefix(summary.Names[0], "/")
+}
+
+func (s *Service) getStackDriftSummary(stackName string) *StackDriftSummary {
+	specs, fingerprint, exists := s.serviceCache.GetServiceSpecs(stackName)
+	if !exists {
+		return nil
+	}
+
+	s.driftMu.Lock()
+	cached, ok := s.driftSummaries[stackName]
+	s.driftMu.Unlock()
+	if ok && cached.fingerprint == fingerprint && time.Since(cached.computedAt) < driftSummaryTTL {
+		return cached.summary
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	drift, err := s.computeStackDrift(ctx, stackName, specs)
+	if err != nil {
+		s.logger.Debug("Failed to compute drift summary",
+			zap.String("stack", stackName), zap.Error(err))
+		return nil
+	}
+
+	summary := &StackDriftSummary{
+		HasDrift:        drift.HasDrift,
+		DriftedServices: drift.DriftedServices,
+		OrphanCount:     len(drift.Orphans),
+		HashUnavailable: drift.HashUnavailable,
+	}
+
+	s.driftMu.Lock()
+	s.driftSummaries[stackName] = cachedDriftSummary{
+		fingerprint: fingerprint,
+		summary:     summary,
+		computedAt:  time.Now(),
+	}
+	s.driftMu.Unlock()
+
+	return summary
+}
+
+func composeEnvFiles(value any) []string {
+	var files []string
+	switch v := value.(type) {
+	case string:
+		files = append(files, v)
+	case []any:
+		for _, item := range v {
+			switch entry := item.(type) {
+			case string:
+				files = append(files, entry)
+			case map[string]any:
+				if path, ok := entry["path"].(string); ok {
+					files = append(files, path)
+				}
+			}
+		}
+	}
+	return files
+}
+
+func stackConfigFingerprint(stackPath string, specs map[string]composeServiceSpec) string {
+	paths := []string{"docker-compose.yml", "docker-compose.yaml", "compose.yml", "compose.yaml", ".env"}
+	for _, spec := range specs {
+		paths = append(paths, spec.EnvFiles...)
+	}
+
+	seen := make(map[string]bool, len(paths))
+	resolved := make([]string, 0, len(paths))
+	for _, path := range paths {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(stackPath, path)
+		}
+		if !seen[path] {
+			seen[path] = true
+			resolved = append(resolved, path)
+		}
+	}
+	sort.Strings(resolved)
+
+	var fingerprint strings.Builder
+	for _, path := range resolved {
+		if info, err := os.Stat(path); err == nil {
+			fmt.Fprintf(&fingerprint, "%s:%d:%d;", path, info.ModTime().UnixNano(), info.Size())
+		}
+	}
+	return fingerprint.String()
+}
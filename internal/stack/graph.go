@@ -0,0 +1,361 @@
+package stack
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/compose-spec/compose-go/v2/cli"
+	"github.com/compose-spec/compose-go/v2/types"
+	"go.uber.org/zap"
+)
+
+const (
+	GraphNodeStack   = "stack"
+	GraphNodeService = "service"
+	GraphNodeNetwork = "network"
+	GraphNodeVolume  = "volume"
+)
+
+const (
+	GraphEdgeContains      = "contains"
+	GraphEdgeDependsOn     = "depends_on"
+	GraphEdgeUsesNetwork   = "uses_network"
+	GraphEdgeUsesVolume    = "uses_volume"
+	GraphEdgeProvides      = "provides"
+	GraphEdgeSharesNetwork = "shares_network"
+	GraphEdgeSharesVolume  = "shares_volume"
+	GraphEdgeRequiresStack = "requires_stack"
+)
+
+type StackGraph struct {
+	Nodes  []GraphNode  `json:"nodes"`
+	Edges  []GraphEdge  `json:"edges"`
+	Errors []GraphError `json:"errors,omitempty"`
+}
+
+type GraphNode struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Name     string `json:"name"`
+	Stack    string `json:"stack,omitempty"`
+	External bool   `json:"external,omitempty"`
+	Owner    string `json:"owner,omitempty"`
+}
+
+type GraphEdge struct {
+	Source    string `json:"source"`
+	Target    string `json:"target"`
+	Type      string `json:"type"`
+	Condition string `json:"condition,omitempty"`
+	Via       string `json:"via,omitempty"`
+}
+
+type GraphError struct {
+	Stack string `json:"stack"`
+	Error string `json:"error"`
+}
+
+type graphResourceRef struct {
+	Name     string
+	External bool
+}
+
+type stackGraphData struct {
+	Name      string
+	Services  []string
+	DependsOn map[string]map[string]string
+	Networks  map[string][]graphResourceRef
+	Volumes   map[string][]graphResourceRef
+	Owned     map[string]string
+}
+
+func (s *Service) GetStackGraph() (*StackGraph, error) {
+	s.logger.Info("Building stack graph", zap.String("location", s.stackLocation))
+
+	entries, err := os.ReadDir(s.stackLocation)
+	if err != nil {
+		s.logger.Error("Failed to read stack location", zap.String("location", s.stackLocation), zap.Error(err))
+		return nil, err
+	}
+
+	composeFiles := []string{
+		"docker-compose.yml",
+		"docker-compose.yaml",
+		"compose.yml",
+		"compose.yaml",
+	}
+
+	graph := &StackGraph{
+		Nodes:  []GraphNode{},
+		Edges:  []GraphEdge{},
+		Errors: []GraphError{},
+	}
+
+	var stacks []stackGraphData
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		stackPath := filepath.Join(s.stackLocation, entry.Name())
+
+		hasComposeFile := false
+		for _, filename := range composeFiles {
+			if _, err := os.Stat(filepath.Join(stackPath, filename)); err == nil {
+				hasComposeFile = true
+				break
+			}
+		}
+		if !hasComposeFile {
+			continue
+		}
+
+		data, err := s.loadStackGraphData(entry.Name())
+		if err != nil {
+			s.logger.Warn("Failed to load stack for graph", zap.String("stack", entry.Name()), zap.Error(err))
+			graph.Errors = append(graph.Errors, GraphError{Stack: entry.Name(), Error: err.Error()})
+			continue
+		}
+
+		stacks = append(stacks, *data)
+	}
+
+	sort.Slice(stacks, func(i, j int) bool { return stacks[i].Name < stacks[j].Name })
+
+	networkOwners := make(map[string]string)
+	volumeOwners := make(map[string]string)
+	for _, data := range stacks {
+		for resolved, kind := range data.Owned {
+			switch kind {
+			case GraphNodeNetwork:
+				networkOwners[resolved] = data.Name
+			case GraphNodeVolume:
+				volumeOwners[resolved] = data.Name
+			}
+		}
+	}
+
+	addedNodes := make(map[string]bool)
+	addNode := func(node GraphNode) {
+		if addedNodes[node.ID] {
+			return
+		}
+		addedNodes[node.ID] = true
+		graph.Nodes = append(graph.Nodes, node)
+	}
+
+	addedEdges := make(map[string]bool)
+	addEdge := func(edge GraphEdge) {
+		key := edge.Source + "|" + edge.Target + "|" + edge.Type + "|" + edge.Via
+		if addedEdges[key] {
+			return
+		}
+		addedEdges[key] = true
+		graph.Edges = append(graph.Edges, edge)
+	}
+
+	networkUsers := make(map[string][]string)
+	volumeUsers := make(map[string][]string)
+
+	for _, data := range stacks {
+		stackID := graphStackID(data.Name)
+		addNode(GraphNode{ID: stackID, Type: GraphNodeStack, Name: data.Name})
+
+		for _, service := range data.Services {
+			serviceID := graphServiceID(data.Name, service)
+			addNode(GraphNode{ID: serviceID, Type: GraphNodeService, Name: service, Stack: data.Name})
+			addEdge(GraphEdge{Source: stackID, Target: serviceID, Type: GraphEdgeContains})
+
+			dependencies := make([]string, 0, len(data.DependsOn[service]))
+			for dependency := range data.DependsOn[service] {
+				dependencies = append(dependencies, dependency)
+			}
+			sort.Strings(dependencies)
+
+			for _, dependency := range dependencies {
+				addEdge(GraphEdge{
+					Source:    serviceID,
+					Target:    graphServiceID(data.Name, dependency),
+					Type:      GraphEdgeDependsOn,
+					Condition: data.DependsOn[service][dependency],
+				})
+			}
+
+			for _, ref := range data.Networks[service] {
+				if !ref.External {
+					continue
+				}
+				networkID := graphNetworkID(ref.Name)
+				addNode(GraphNode{ID: networkID, Type: GraphNodeNetwork, Name: ref.Name, External: true, Owner: networkOwners[ref.Name]})
+				addEdge(GraphEdge{Source: serviceID, Target: networkID, Type: GraphEdgeUsesNetwork})
+				networkUsers[ref.Name] = appendUniqueString(networkUsers[ref.Name], data.Name)
+			}
+
+			for _, ref := range data.Volumes[service] {
+				if !ref.External {
+					continue
+				}
+				volumeID := graphVolumeID(ref.Name)
+				addNode(GraphNode{ID: volumeID, Type: GraphNodeVolume, Name: ref.Name, External: true, Owner: volumeOwners[ref.Name]})
+				addEdge(GraphEdge{Source: serviceID, Target: volumeID, Type: GraphEdgeUsesVolume})
+				volumeUsers[ref.Name] = appendUniqueString(volumeUsers[ref.Name], data.Name)
+			}
+		}
+	}
+
+	linkSharedResources(networkUsers, networkOwners, graphNetworkID, GraphEdgeSharesNetwork, addEdge)
+	linkSharedResources(volumeUsers, volumeOwners, graphVolumeID, GraphEdgeSharesVolume, addEdge)
+
+	s.logger.Info("Stack graph built",
+		zap.Int("stacks", len(stacks)),
+		zap.Int("nodes", len(graph.Nodes)),
+		zap.Int("edges", len(graph.Edges)),
+		zap.Int("errors", len(graph.Errors)))
+
+	return graph, nil
+}
+
+func linkSharedResources(users map[string][]string, owners map[string]string, resourceID func(string) string, shareType string, addEdge func(GraphEdge)) {
+	names := make([]string, 0, len(users))
+	for name := range users {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		stacks := users[name]
+		sort.Strings(stacks)
+
+		if owner, ok := owners[name]; ok {
+			addEdge(GraphEdge{Source: graphStackID(owner), Target: resourceID(name), Type: GraphEdgeProvides})
+			for _, stack := range stacks {
+				if stack == owner {
+					continue
+				}
+				addEdge(GraphEdge{Source: graphStackID(stack), Target: graphStackID(owner), Type: GraphEdgeRequiresStack, Via: resourceID(name)})
+			}
+		}
+
+		for i := 0; i < len(stacks); i++ {
+			for j := i + 1; j < len(stacks); j++ {
+				addEdge(GraphEdge{Source: graphStackID(stacks[i]), Target: graphStackID(stacks[j]), Type: shareType, Via: resourceID(name)})
+			}
+		}
+	}
+}
+
+func (s *Service) loadStackGraphData(stackName string) (*stackGraphData, error) {
+	stackPath := filepath.Join(s.stackLocation, stackName)
+
+	options, err := cli.NewProjectOptions(nil,
+		cli.WithWorkingDirectory(stackPath),
+		cli.WithOsEnv,
+		cli.WithEnvFiles(),
+		cli.WithDotEnv,
+		cli.WithConfigFileEnv,
+		cli.WithDefaultConfigPath,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure compose project: %w", err)
+	}
+
+	project, err := cli.ProjectFromOptions(context.Background(), options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load compose project: %w", err)
+	}
+
+	data := &stackGraphData{
+		Name:      stackName,
+		DependsOn: make(map[string]map[string]string),
+		Networks:  make(map[string][]graphResourceRef),
+		Volumes:   make(map[string][]graphResourceRef),
+		Owned:     make(map[string]string),
+	}
+
+	resolveNetwork := func(key string) graphResourceRef {
+		ref := graphResourceRef{Name: key}
+		if network, ok := project.Networks[key]; ok {
+			if network.Name != "" {
+				ref.Name = network.Name
+			}
+			ref.External = bool(network.External)
+		}
+		return ref
+	}
+	resolveVolume := func(key string) graphResourceRef {
+		ref := graphResourceRef{Name: key}
+		if volume, ok := project.Volumes[key]; ok {
+			if volume.Name != "" {
+				ref.Name = volume.Name
+			}
+			ref.External = bool(volume.External)
+		}
+		return ref
+	}
+
+	for key := range project.Networks {
+		if ref := resolveNetwork(key); !ref.External {
+			data.Owned[ref.Name] = GraphNodeNetwork
+		}
+	}
+	for key := range project.Volumes {
+		if ref := resolveVolume(key); !ref.External {
+			data.Owned[ref.Name] = GraphNodeVolume
+		}
+	}
+
+	for serviceName, service := range project.Services {
+		data.Services = append(data.Services, serviceName)
+
+		if len(service.DependsOn) > 0 {
+			data.DependsOn[serviceName] = make(map[string]string, len(service.DependsOn))
+			for dependency, options := range service.DependsOn {
+				data.DependsOn[serviceName][dependency] = options.Condition
+			}
+		}
+
+		for key := range service.Networks {
+			data.Networks[serviceName] = append(data.Networks[serviceName], resolveNetwork(key))
+		}
+
+		for _, volume := range service.Volumes {
+			if volume.Type != types.VolumeTypeVolume || volume.Source == "" {
+				continue
+			}
+			data.Volumes[serviceName] = append(data.Volumes[serviceName], resolveVolume(volume.Source))
+		}
+	}
+
+	sort.Strings(data.Services)
+
+	return data, nil
+}
+
+func appendUniqueString(values []string, value string) []string {
+	for _, existing := range values {
+		if existing == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
+
+func graphStackID(stack string) string {
+	return "stack:" + stack
+}
+
+func graphServiceID(stack, service string) string {
+	return "service:" + stack + "/" + service
+}
+
+func graphNetworkID(name string) string {
+	return "network:" + name
+}
+
+func graphVolumeID(name string) string {
+	return "volume:" + name
+}
@@ -28,8 +28,16 @@ func (h *Handler) ListStacks(c echo.Context) error {
 		return common.SendInternalError(c, err.Error())
 	}
 
+	var tags []string
+	if tagsParam := c.QueryParam("tags"); tagsParam != "" {
+		tags = strings.Split(tagsParam, ",")
+	}
+	tags = append(tags, c.QueryParams()["tag"]...)
+	stacks = FilterStacksByTags(stacks, tags)
+
 	h.auditService.LogStackEvent(audit.EventStackList, c.RealIP(), "", true, "", map[string]any{
 		"count": len(stacks),
+		"tags":  tags,
 	})
 
 	return common.SendSuccess(c, stacks)
@@ -84,6 +92,34 @@ func (h *Handler) GetStackDetails(c echo.Context) error {
 	return common.SendSuccess(c, stackDetails)
 }
 
+func (h *Handler) GetStackDrift(c echo.Context) error {
+	stackName := c.Param("name")
+	if stackName == "" {
+		return common.SendBadRequest(c, "stack name is required")
+	}
+
+	if err := validation.ValidateStackName(stackName); err != nil {
+		return common.SendBadRequest(c, "invalid stack name: "+err.Error())
+	}
+
+	drift, err := h.service.GetStackDrift(stackName)
+	if err != nil {
+		h.auditService.LogStackEvent(audit.EventStackGetDrift, c.RealIP(), stackName, false, err.Error(), nil)
+		if strings.Contains(err.Error(), "not found") {
+			return common.SendNotFound(c, err.Error())
+		}
+		return common.SendInternalError(c, err.Error())
+	}
+
+	h.auditService.LogStackEvent(audit.EventStackGetDrift, c.RealIP(), stackName, true, "", map[string]any{
+		"has_drift":        drift.HasDrift,
+		"drifted_services": drift.DriftedServices,
+		"orphans":          len(drift.Orphans),
+	})
+
+	return common.SendSuccess(c, drift)
+}
+
 func (h *Handler) GetStackNetworks(c echo.Context) error {
 	stackName := c.Param("name")
 	if stackName == "" {
@@ -153,6 +189,36 @@ func (h *Handler) GetStackEnvironmentVariables(c echo.Context) error {
 	return common.SendSuccess(c, envVars)
 }
 
+func (h *Handler) GetStackGraph(c echo.Context) error {
+	graph, err := h.service.GetStackGraph()
+	if err != nil {
+		h.auditService.LogStackEvent(audit.EventStackGetGraph, c.RealIP(), "", false, err.Error(), nil)
+		return common.SendInternalError(c, err.Error())
+	}
+
+	h.auditService.LogStackEvent(audit.EventStackGetGraph, c.RealIP(), "", true, "", map[string]any{
+		"nodes": len(graph.Nodes),
+		"edges": len(graph.Edges),
+	})
+
+	return common.SendSuccess(c, graph)
+}
+
+func (h *Handler) GetPortInventory(c echo.Context) error {
+	inventory, err := h.service.GetPortInventory()
+	if err != nil {
+		h.auditService.LogStackEvent(audit.EventStackGetPorts, c.RealIP(), "", false, err.Error(), nil)
+		return common.SendInternalError(c, err.Error())
+	}
+
+	h.auditService.LogStackEvent(audit.EventStackGetPorts, c.RealIP(), "", true, "", map[string]any{
+		"bindings":  len(inventory.Bindings),
+		"conflicts": len(inventory.Conflicts),
+	})
+
+	return common.SendSuccess(c, inventory)
+}
+
 func (h *Handler) GetStacksSummary(c echo.Context) error {
 	patternsParam := c.QueryParam("patterns")
 	var patterns []string
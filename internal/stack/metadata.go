@@ -0,0 +1,224 @@
+package stack
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	MetadataExtensionKey = "x-berth"
+	MetadataFileName     = ".berth.yml"
+)
+
+var validCriticalityTiers = map[string]bool{
+	"critical": true,
+	"high":     true,
+	"medium":   true,
+	"low":      true,
+}
+
+type StackMetadata struct {
+	Description string      `json:"description,omitempty" yaml:"description"`
+	Owner       string      `json:"owner,omitempty" yaml:"owner"`
+	Tags        []string    `json:"tags,omitempty" yaml:"tags"`
+	Links       []StackLink `json:"links,omitempty" yaml:"-"`
+	Tier        string      `json:"tier,omitempty" yaml:"tier"`
+	Source      []string    `json:"source,omitempty" yaml:"-"`
+}
+
+type StackLink struct {
+	Name string `json:"name" yaml:"name"`
+	URL  string `json:"url" yaml:"url"`
+}
+
+type rawStackMetadata struct {
+	StackMetadata `yaml:",inline"`
+	RawLinks      yaml.Node `yaml:"links"`
+}
+
+func (s *Service) loadStackMetadata(stackPath, composeFile string) *StackMetadata {
+	metadata := &StackMetadata{}
+
+	if composeFile != "" {
+		extension, err := readComposeMetadataExtension(filepath.Join(stackPath, composeFile))
+		if err != nil {
+			s.logger.Warn("Failed to read x-berth metadata",
+				zap.String("path", stackPath), zap.String("compose_file", composeFile), zap.Error(err))
+		} else if extension != nil {
+			mergeStackMetadata(metadata, extension)
+			metadata.Source = append(metadata.Source, MetadataExtensionKey)
+		}
+	}
+
+	fileMetadata, err := readStackMetadataFile(filepath.Join(stackPath, MetadataFileName))
+	if err != nil {
+		s.logger.Warn("Failed to read stack metadata file",
+			zap.String("path", stackPath), zap.Error(err))
+	} else if fileMetadata != nil {
+		mergeStackMetadata(metadata, fileMetadata)
+		metadata.Source = append(metadata.Source, MetadataFileName)
+	}
+
+	if len(metadata.Source) == 0 {
+		return nil
+	}
+
+	return metadata
+}
+
+func readComposeMetadataExtension(composePath string) (*StackMetadata, error) {
+	content, err := os.ReadFile(composePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var document yaml.Node
+	if err := yaml.Unmarshal(content, &document); err != nil {
+		return nil, fmt.Errorf("failed to parse compose file: %w", err)
+	}
+
+	if len(document.Content) == 0 || document.Content[0].Kind != yaml.MappingNode {
+		return nil, nil
+	}
+
+	root := document.Content[0]
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value == MetadataExtensionKey {
+			return decodeStackMetadata(root.Content[i+1])
+		}
+	}
+
+	return nil, nil
+}
+
+func readStackMetadataFile(metadataPath string) (*StackMetadata, error) {
+	content, err := os.ReadFile(metadataPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var document yaml.Node
+	if err := yaml.Unmarshal(content, &document); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", MetadataFileName, err)
+	}
+
+	if len(document.Content) == 0 {
+		return nil, nil
+	}
+
+	return decodeStackMetadata(document.Content[0])
+}
+
+func decodeStackMetadata(node *yaml.Node) (*StackMetadata, error) {
+	if node.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("stack metadata must be a mapping")
+	}
+
+	var raw rawStackMetadata
+	if err := node.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("invalid stack metadata: %w", err)
+	}
+
+	metadata := raw.StackMetadata
+
+	switch raw.RawLinks.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(raw.RawLinks.Content); i += 2 {
+			metadata.Links = append(metadata.Links, StackLink{
+				Name: raw.RawLinks.Content[i].Value,
+				URL:  raw.RawLinks.Content[i+1].Value,
+			})
+		}
+	case yaml.SequenceNode:
+		var links []StackLink
+		if err := raw.RawLinks.Decode(&links); err != nil {
+			return nil, fmt.Errorf("invalid stack metadata links: %w", err)
+		}
+		metadata.Links = links
+	}
+
+	metadata.Tier = strings.ToLower(strings.TrimSpace(metadata.Tier))
+	if metadata.Tier != "" && !validCriticalityTiers[metadata.Tier] {
+		return nil, fmt.Errorf("invalid criticality tier '%s'", metadata.Tier)
+	}
+
+	metadata.Tags = normaliseTags(metadata.Tags)
+
+	return &metadata, nil
+}
+
+func mergeStackMetadata(target, source *StackMetadata) {
+	if source.Description != "" {
+		target.Description = source.Description
+	}
+	if source.Owner != "" {
+		target.Owner = source.Owner
+	}
+	if source.Tier != "" {
+		target.Tier = source.Tier
+	}
+	if len(source.Links) > 0 {
+		target.Links = source.Links
+	}
+	target.Tags = normaliseTags(append(target.Tags, source.Tags...))
+}
+
+func normaliseTags(tags []string) []string {
+	seen := make(map[string]bool)
+	var normalised []string
+
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalised = append(normalised, tag)
+	}
+
+	sort.Strings(normalised)
+
+	return normalised
+}
+
+func FilterStacksByTags(stacks []Stack, tags []string) []Stack {
+	required := normaliseTags(tags)
+	if len(required) == 0 {
+		return stacks
+	}
+
+	filtered := []Stack{}
+	for _, stack := range stacks {
+		if stack.Metadata == nil {
+			continue
+		}
+
+		stackTags := make(map[string]bool, len(stack.Metadata.Tags))
+		for _, tag := range stack.Metadata.Tags {
+			stackTags[tag] = true
+		}
+
+		matches := true
+		for _, tag := range required {
+			if !stackTags[tag] {
+				matches = false
+				break
+			}
+		}
+
+		if matches {
+			filtered = append(filtered, stack)
+		}
+	}
+
+	return filtered
+}
@@ -0,0 +1,598 @@
+package stack
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tech-arch1tect/berth-agent/internal/docker"
+	"github.com/tech-arch1tect/berth-agent/internal/validation"
+
+	"go.uber.org/zap"
+)
+
+const (
+	PortSourceCompose   = "compose"
+	PortSourceContainer = "container"
+	PortSourceHost      = "host"
+)
+
+const (
+	procNetPath     = "/proc/net"
+	hostProcNetPath = "/proc/1/net"
+	hostProcMount   = "/proc"
+)
+
+type PortBinding struct {
+	Source     string `json:"source"`
+	Stack      string `json:"stack,omitempty"`
+	Service    string `json:"service,omitempty"`
+	Container  string `json:"container,omitempty"`
+	HostIP     string `json:"host_ip,omitempty"`
+	HostPort   int    `json:"host_port"`
+	TargetPort int    `json:"target_port,omitempty"`
+	Protocol   string `json:"protocol"`
+	Mapping    string `json:"mapping,omitempty"`
+}
+
+type PortConflict struct {
+	Protocol string        `json:"protocol"`
+	HostPort int           `json:"host_port"`
+	Reason   string        `json:"reason"`
+	Bindings []PortBinding `json:"bindings"`
+}
+
+type PortInventory struct {
+	Bindings          []PortBinding        `json:"bindings"`
+	Conflicts         []PortConflict       `json:"conflicts"`
+	Errors            []PortInventoryError `json:"errors,omitempty"`
+	HostSocketsLoaded bool                 `json:"host_sockets_loaded"`
+	HostSocketsError  string               `json:"host_sockets_error,omitempty"`
+}
+
+type PortInventoryError struct {
+	Stack string `json:"stack"`
+	Error string `json:"error"`
+}
+
+func (s *Service) GetPortInventory() (*PortInventory, error) {
+	s.logger.Info("Building host port inventory", zap.String("location", s.stackLocation))
+
+	entries, err := os.ReadDir(s.stackLocation)
+	if err != nil {
+		s.logger.Error("Failed to read stack location", zap.String("location", s.stackLocation), zap.Error(err))
+		return nil, err
+	}
+
+	inventory := &PortInventory{
+		Bindings:  []PortBinding{},
+		Conflicts: []PortConflict{},
+		Errors:    []PortInventoryError{},
+	}
+
+	composeFiles := []string{
+		"docker-compose.yml",
+		"docker-compose.yaml",
+		"compose.yml",
+		"compose.yaml",
+	}
+
+	var declared []PortBinding
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		stackPath := filepath.Join(s.stackLocation, entry.Name())
+		hasComposeFile := false
+		for _, filename := range composeFiles {
+			if _, err := os.Stat(filepath.Join(stackPath, filename)); err == nil {
+				hasComposeFile = true
+				break
+			}
+		}
+		if !hasComposeFile {
+			continue
+		}
+
+		bindings, err := s.composePortBindings(entry.Name(), nil)
+		if err != nil {
+			s.logger.Warn("Failed to read published ports", zap.String("stack", entry.Name()), zap.Error(err))
+			inventory.Errors = append(inventory.Errors, PortInventoryError{Stack: entry.Name(), Error: err.Error()})
+			continue
+		}
+		declared = append(declared, bindings...)
+	}
+
+	held, hostErr, err := s.heldPortBindings()
+	if err != nil {
+		return nil, err
+	}
+	inventory.HostSocketsLoaded = hostErr == ""
+	inventory.HostSocketsError = hostErr
+
+	inventory.Bindings = append(inventory.Bindings, declared...)
+	inventory.Bindings = append(inventory.Bindings, held...)
+	sortPortBindings(inventory.Bindings)
+
+	inventory.Conflicts = findPortConflicts(declared, held, "")
+
+	s.logger.Info("Host port inventory built",
+		zap.Int("bindings", len(inventory.Bindings)),
+		zap.Int("conflicts", len(inventory.Conflicts)))
+
+	return inventory, nil
+}
+
+func (s *Service) CheckStackPortConflicts(stackName string, services []string) ([]PortConflict, error) {
+	if _, err := validation.SanitizeStackPath(s.stackLocation, stackName); err != nil {
+		return nil, fmt.Errorf("invalid stack name '%s': %w", stackName, err)
+	}
+
+	declared, err := s.composePortBindings(stackName, services)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(declared) == 0 {
+		return []PortConflict{}, nil
+	}
+
+	held, hostErr, err := s.heldPortBindings()
+	if err != nil {
+		return nil, err
+	}
+	if hostErr != "" {
+		s.logger.Warn("Port pre-flight check cannot see host processes", zap.String("stack", stackName), zap.String("reason", hostErr))
+	}
+
+	conflicts := findPortConflicts(declared, held, stackName)
+
+	s.logger.Info("Port pre-flight check completed",
+		zap.String("stack", stackName),
+		zap.Strings("services", services),
+		zap.Int("conflicts", len(conflicts)))
+
+	return conflicts, nil
+}
+
+func (s *Service) composePortBindings(stackName string, services []string) ([]PortBinding, error) {
+	cmd, err := s.commandExec.ExecuteComposeCommand(stackName, "config", "--format", "json")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create compose command: %w", err)
+	}
+
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get compose config: %w", err)
+	}
+
+	var config struct {
+		Services map[string]map[string]any `json:"services"`
+	}
+	if err := json.Unmarshal(output, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse compose config: %w", err)
+	}
+
+	selected := make(map[string]bool, len(services))
+	for _, service := range services {
+		selected[service] = true
+	}
+
+	var bindings []PortBinding
+	for serviceName, serviceConfig := range config.Services {
+		if len(selected) > 0 && !selected[serviceName] {
+			continue
+		}
+
+		rawPorts, ok := serviceConfig["ports"].([]any)
+		if !ok {
+			continue
+		}
+
+		for _, entry := range rawPorts {
+			mapping, ok := parseComposePortEntry(entry)
+			if !ok {
+				continue
+			}
+
+			for _, binding := range expandPortMapping(mapping) {
+				binding.Source = PortSourceCompose
+				binding.Stack = stackName
+				binding.Service = serviceName
+				bindings = append(bindings, binding)
+			}
+		}
+	}
+
+	return bindings, nil
+}
+
+func expandPortMapping(mapping string) []PortBinding {
+	protocol := "tcp"
+	spec := mapping
+	if idx := strings.LastIndex(spec, "/"); idx != -1 {
+		protocol = strings.ToLower(spec[idx+1:])
+		spec = spec[:idx]
+	}
+
+	parts := strings.Split(spec, ":")
+	if len(parts) < 2 {
+		return nil
+	}
+
+	targetStart, targetEnd, ok := parsePortRange(parts[len(parts)-1])
+	if !ok {
+		return nil
+	}
+
+	publishedStart, publishedEnd, ok := parsePortRange(parts[len(parts)-2])
+	if !ok {
+		return nil
+	}
+
+	hostIP := strings.Trim(strings.Join(parts[:len(parts)-2], ":"), "[]")
+
+	var bindings []PortBinding
+	for offset := 0; publishedStart+offset <= publishedEnd; offset++ {
+		target := targetStart
+		if targetEnd > targetStart {
+			target = targetStart + offset
+		}
+		bindings = append(bindings, PortBinding{
+			HostIP:     normaliseHostIP(hostIP),
+			HostPort:   publishedStart + offset,
+			TargetPort: target,
+			Protocol:   protocol,
+			Mapping:    mapping,
+		})
+	}
+
+	return bindings
+}
+
+func parsePortRange(value string) (int, int, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, 0, false
+	}
+
+	startValue, endValue, isRange := strings.Cut(value, "-")
+	start, err := strconv.Atoi(startValue)
+	if err != nil || start <= 0 {
+		return 0, 0, false
+	}
+
+	if !isRange {
+		return start, start, true
+	}
+
+	end, err := strconv.Atoi(endValue)
+	if err != nil || end < start {
+		return 0, 0, false
+	}
+
+	return start, end, true
+}
+
+func (s *Service) heldPortBindings() ([]PortBinding, string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	summaries, err := s.dockerClient.ContainerList(ctx, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to list containers: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	var held []PortBinding
+
+	for _, summary := range summaries {
+		if summary.State != "running" {
+			continue
+		}
+
+		name := ""
+		if len(summary.Names) > 0 {
+			name = strings.TrimPrefix(summary.Names[0], "/")
+		}
+
+		for _, port := range summary.Ports {
+			if port.PublicPort == 0 {
+				continue
+			}
+
+			binding := PortBinding{
+				Source:     PortSourceContainer,
+				Stack:      summary.Labels[docker.LabelComposeProject],
+				Service:    summary.Labels[docker.LabelComposeService],
+				Container:  name,
+				HostIP:     normaliseHostIP(port.IP),
+				HostPort:   int(port.PublicPort),
+				TargetPort: int(port.PrivatePort),
+				Protocol:   strings.ToLower(port.Type),
+			}
+
+			key := fmt.Sprintf("%s|%s|%d|%s", name, binding.HostIP, binding.HostPort, binding.Protocol)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			held = append(held, binding)
+		}
+	}
+
+	listeners, hostErr := s.hostListeningSockets(ctx)
+	for _, listener := range listeners {
+		attributed := false
+		for _, binding := range held {
+			if binding.Source == PortSourceContainer && binding.HostPort == listener.HostPort &&
+				binding.Protocol == listener.Protocol && hostIPsOverlap(binding.HostIP, listener.HostIP) {
+				attributed = true
+				break
+			}
+		}
+		if attributed {
+			continue
+		}
+
+		key := fmt.Sprintf("host|%s|%d|%s", listener.HostIP, listener.HostPort, listener.Protocol)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		held = append(held, listener)
+	}
+
+	return held, hostErr, nil
+}
+
+func (s *Service) hostProcNetDir(ctx context.Context) (string, string) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return procNetPath, ""
+	}
+
+	info, err := s.dockerClient.ContainerInspect(ctx, hostname)
+	if err != nil {
+		return procNetPath, ""
+	}
+
+	if info.HostConfig != nil {
+		if info.HostConfig.NetworkMode.IsHost() {
+			return procNetPath, ""
+		}
+		if info.HostConfig.PidMode.IsHost() {
+			return hostProcNetPath, ""
+		}
+	}
+	for _, mount := range info.Mounts {
+		if mount.Source == hostProcMount && mount.Destination == hostProcMount {
+			return hostProcNetPath, ""
+		}
+	}
+
+	return "", "host listeners cannot be detected: run the agent with network_mode: host, pid: host or a /proc:/proc bind mount"
+}
+
+func (s *Service) hostListeningSockets(ctx context.Context) ([]PortBinding, string) {
+	netDir, reason := s.hostProcNetDir(ctx)
+	if netDir == "" {
+		return nil, reason
+	}
+
+	var listeners []PortBinding
+	loaded := 0
+
+	for _, table := range []struct {
+		file     string
+		protocol string
+	}{
+		{"tcp", "tcp"},
+		{"tcp6", "tcp"},
+		{"udp", "udp"},
+		{"udp6", "udp"},
+	} {
+		entries, err := readProcNetTable(filepath.Join(netDir, table.file), table.protocol)
+		if err != nil {
+			s.logger.Debug("Failed to read host socket table", zap.String("table", table.file), zap.Error(err))
+			continue
+		}
+		loaded++
+		listeners = append(listeners, entries...)
+	}
+
+	if loaded == 0 {
+		return nil, fmt.Sprintf("host listeners cannot be detected: no socket table in %s is readable", netDir)
+	}
+	return listeners, ""
+}
+
+func readProcNetTable(path, protocol string) ([]PortBinding, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var bindings []PortBinding
+	scanner := bufio.NewScanner(file)
+	scanner.Scan()
+
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 {
+			continue
+		}
+
+		state := fields[3]
+		if protocol == "tcp" && state != "0A" {
+			continue
+		}
+		if protocol == "udp" && state != "07" {
+			continue
+		}
+
+		address, portHex, ok := strings.Cut(fields[1], ":")
+		if !ok {
+			continue
+		}
+
+		port, err := strconv.ParseUint(portHex, 16, 16)
+		if err != nil || port == 0 {
+			continue
+		}
+
+		ip, err := decodeProcNetAddress(address)
+		if err != nil {
+			continue
+		}
+
+		bindings = append(bindings, PortBinding{
+			Source:   PortSourceHost,
+			HostIP:   normaliseHostIP(ip.String()),
+			HostPort: int(port),
+			Protocol: protocol,
+		})
+	}
+
+	return bindings, scanner.Err()
+}
+
+func decodeProcNetAddress(address string) (net.IP, error) {
+	raw, err := hex.DecodeString(address)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(raw) != net.IPv4len && len(raw) != net.IPv6len {
+		return nil, fmt.Errorf("unexpected address length %d", len(raw))
+	}
+
+	ip := make(net.IP, len(raw))
+	for word := 0; word < len(raw); word += 4 {
+		for i := 0; i < 4; i++ {
+			ip[word+i] = raw[word+3-i]
+		}
+	}
+
+	return ip, nil
+}
+
+func normaliseHostIP(ip string) string {
+	switch ip {
+	case "", "0.0.0.0", "::", "*":
+		return ""
+	}
+	if parsed := net.ParseIP(ip); parsed != nil {
+		if v4 := parsed.To4(); v4 != nil {
+			return v4.String()
+		}
+		return parsed.String()
+	}
+	return ip
+}
+
+func hostIPsOverlap(a, b string) bool {
+	return a == "" || b == "" || a == b
+}
+
+func findPortConflicts(declared, held []PortBinding, stackName string) []PortConflict {
+	type portKey struct {
+		protocol string
+		port     int
+	}
+
+	grouped := make(map[portKey][]PortBinding)
+	for _, binding := range declared {
+		key := portKey{binding.Protocol, binding.HostPort}
+		grouped[key] = append(grouped[key], binding)
+	}
+
+	var conflicts []PortConflict
+
+	for key, bindings := range grouped {
+		for i := 0; i < len(bindings); i++ {
+			for j := i + 1; j < len(bindings); j++ {
+				a, b := bindings[i], bindings[j]
+				if a.Stack == b.Stack && a.Service == b.Service {
+					continue
+				}
+				if !hostIPsOverlap(a.HostIP, b.HostIP) {
+					continue
+				}
+				reason := fmt.Sprintf("%s/%d is published by both %s/%s and %s/%s", key.protocol, key.port, a.Stack, a.Service, b.Stack, b.Service)
+				conflicts = append(conflicts, PortConflict{Protocol: key.protocol, HostPort: key.port, Reason: reason, Bindings: []PortBinding{a, b}})
+			}
+		}
+
+		for _, binding := range bindings {
+			for _, holder := range held {
+				if holder.Protocol != key.protocol || holder.HostPort != key.port || !hostIPsOverlap(binding.HostIP, holder.HostIP) {
+					continue
+				}
+
+				var reason string
+				switch holder.Source {
+				case PortSourceContainer:
+					if stackName != "" && holder.Stack == stackName {
+						continue
+					}
+					if holder.Stack == binding.Stack && holder.Service == binding.Service {
+						continue
+					}
+					reason = fmt.Sprintf("%s/%d for %s/%s is already held by container %s", key.protocol, key.port, binding.Stack, binding.Service, holder.Container)
+				case PortSourceHost:
+					reason = fmt.Sprintf("%s/%d for %s/%s is already in use by a host process", key.protocol, key.port, binding.Stack, binding.Service)
+				default:
+					continue
+				}
+
+				conflicts = append(conflicts, PortConflict{Protocol: key.protocol, HostPort: key.port, Reason: reason, Bindings: []PortBinding{binding, holder}})
+			}
+		}
+	}
+
+	sort.Slice(conflicts, func(i, j int) bool {
+		if conflicts[i].HostPort != conflicts[j].HostPort {
+			return conflicts[i].HostPort < conflicts[j].HostPort
+		}
+		if conflicts[i].Protocol != conflicts[j].Protocol {
+			return conflicts[i].Protocol < conflicts[j].Protocol
+		}
+		return conflicts[i].Reason < conflicts[j].Reason
+	})
+
+	if conflicts == nil {
+		conflicts = []PortConflict{}
+	}
+
+	return conflicts
+}
+
+func sortPortBindings(bindings []PortBinding) {
+	sort.Slice(bindings, func(i, j int) bool {
+		if bindings[i].HostPort != bindings[j].HostPort {
+			return bindings[i].HostPort < bindings[j].HostPort
+		}
+		if bindings[i].Protocol != bindings[j].Protocol {
+			return bindings[i].Protocol < bindings[j].Protocol
+		}
+		if bindings[i].Source != bindings[j].Source {
+			return bindings[i].Source < bindings[j].Source
+		}
+		if bindings[i].Stack != bindings[j].Stack {
+			return bindings[i].Stack < bindings[j].Stack
+		}
+		return bindings[i].Service < bindings[j].Service
+	})
+}
@@ -2,11 +2,9 @@ package stack
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"log"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 	"sync"
@@ -18,6 +16,8 @@ import (
 type ServiceCountCache struct {
 	mu            sync.RWMutex
 	counts        map[string]int
+	specs         map[string]map[string]composeServiceSpec
+	fingerprints  map[string]string
 	stackLocation string
 	watcher       *fsnotify.Watcher
 	ctx           context.Context
@@ -33,6 +33,8 @@ func NewServiceCountCache(stackLocation string) *ServiceCountCache {
 
 	cache := &ServiceCountCache{
 		counts:        make(map[string]int),
+		specs:         make(map[string]map[string]composeServiceSpec),
+		fingerprints:  make(map[string]string),
 		stackLocation: stackLocation,
 		ctx:           ctx,
 		cancel:        cancel,
@@ -77,6 +79,30 @@ func (c *ServiceCountCache) GetServiceCount(stackName string) (int, bool) {
 	return count, exists
 }
 
+func (c *ServiceCountCache) GetServiceSpecs(stackName string) (map[string]composeServiceSpec, string, bool) {
+	c.mu.RLock()
+	specs, exists := c.specs[stackName]
+	fingerprint := c.fingerprints[stackName]
+	c.mu.RUnlock()
+	if !exists {
+		return nil, "", false
+	}
+
+	if stackConfigFingerprint(filepath.Join(c.stackLocation, stackName), specs) == fingerprint {
+		return specs, fingerprint, true
+	}
+
+	if err := c.loadStackCount(stackName); err != nil {
+		log.Printf("Failed to reload stale service specs for %s: %v", stackName, err)
+		return specs, fingerprint, true
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	specs, exists = c.specs[stackName]
+	return specs, c.fingerprints[stackName], exists
+}
+
 func (c *ServiceCountCache) loadAllStackCounts() error {
 	entries, err := os.ReadDir(c.stackLocation)
 	if err != nil {
@@ -120,41 +146,37 @@ func (c *ServiceCountCache) loadStackCount(stackName string) error {
 
 		c.mu.Lock()
 		delete(c.counts, stackName)
+		delete(c.specs, stackName)
+		delete(c.fingerprints, stackName)
 		c.mu.Unlock()
 		return nil
 	}
 
-	count, err := c.getServiceCountFromCompose(stackPath)
+	specs, err := c.getServiceSpecsFromCompose(stackPath)
 	if err != nil {
 		return fmt.Errorf("failed to get service count: %w", err)
 	}
+	if err := composeHashError(specs); err != nil {
+		log.Printf("Warning: config hashes unavailable for stack %s; config drift will not be checked: %v", stackName, err)
+	}
+	count := len(specs)
+	fingerprint := stackConfigFingerprint(stackPath, specs)
 
 	c.mu.Lock()
 	c.counts[stackName] = count
+	c.specs[stackName] = specs
+	c.fingerprints[stackName] = fingerprint
 	c.mu.Unlock()
 
 	log.Printf("Loaded service count for stack %s: %d services", stackName, count)
 	return nil
 }
 
-func (c *ServiceCountCache) getServiceCountFromCompose(stackPath string) (int, error) {
+func (c *ServiceCountCache) getServiceSpecsFromCompose(stackPath string) (map[string]composeServiceSpec, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	cmd := exec.CommandContext(ctx, "docker", "compose", "config", "--format", "json")
-	cmd.Dir = stackPath
-
-	output, err := cmd.Output()
-	if err != nil {
-		return 0, fmt.Errorf("docker compose config failed: %w", err)
-	}
-
-	var config ComposeConfig
-	if err := json.Unmarshal(output, &config); err != nil {
-		return 0, fmt.Errorf("failed to parse compose config: %w", err)
-	}
-
-	return len(config.Services), nil
+	return loadComposeServiceSpecs(ctx, stackPath)
 }
 
 func (c *ServiceCountCache) setupWatchers() error {
@@ -218,7 +240,7 @@ func (c *ServiceCountCache) handleFileEvent(event fsnotify.Event) {
 		}
 	}
 
-	if !strings.HasSuffix(event.Name, ".yml") && !strings.HasSuffix(event.Name, ".yaml") {
+	if !strings.HasSuffix(event.Name, ".yml") && !strings.HasSuffix(event.Name, ".yaml") && filepath.Base(event.Name) != ".env" {
 		return
 	}
 
@@ -11,6 +11,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/tech-arch1tect/berth-agent/config"
@@ -32,14 +33,16 @@ type Stack struct {
 	TotalContainers   int                 `json:"total_containers"`
 	RunningContainers int                 `json:"running_containers"`
 	HealthDetails     *StackHealthDetails `json:"health_details,omitempty"`
+	Metadata          *StackMetadata      `json:"metadata,omitempty"`
 }
 
 type StackHealthDetails struct {
-	Percentage     int      `json:"percentage"`
-	HealthyCount   int      `json:"healthy_count"`
-	UnhealthyCount int      `json:"unhealthy_count"`
-	StoppedCount   int      `json:"stopped_count"`
-	Reasons        []string `json:"reasons"`
+	Percentage     int                `json:"percentage"`
+	HealthyCount   int                `json:"healthy_count"`
+	UnhealthyCount int                `json:"unhealthy_count"`
+	StoppedCount   int                `json:"stopped_count"`
+	Reasons        []string           `json:"reasons"`
+	Drift          *StackDriftSummary `json:"drift,omitempty"`
 }
 
 type StackDetails struct {
@@ -47,6 +50,7 @@ type StackDetails struct {
 	Path        string           `json:"path"`
 	ComposeFile string           `json:"compose_file"`
 	Services    []ComposeService `json:"services"`
+	Metadata    *StackMetadata   `json:"metadata,omitempty"`
 }
 
 type ComposeService struct {
@@ -276,22 +280,25 @@ type ImageHistoryLayer struct {
 }
 
 type Service struct {
-	stackLocation string
-	commandExec   *docker.CommandExecutor
-	dockerClient  *docker.Client
-	serviceCache  *ServiceCountCache
-	logger        *logging.Logger
+	stackLocation  string
+	commandExec    *docker.CommandExecutor
+	dockerClient   *docker.Client
+	serviceCache   *ServiceCountCache
+	logger         *logging.Logger
+	driftMu        sync.Mutex
+	driftSummaries map[string]cachedDriftSummary
 }
 
 func NewService(cfg *config.Config, dockerClient *docker.Client, logger *logging.Logger) *Service {
 	cache := NewServiceCountCache(cfg.StackLocation)
 
 	service := &Service{
-		stackLocation: cfg.StackLocation,
-		commandExec:   docker.NewCommandExecutor(cfg.StackLocation),
-		dockerClient:  dockerClient,
-		serviceCache:  cache,
-		logger:        logger.With(zap.String("component", "stack")),
+		stackLocation:  cfg.StackLocation,
+		commandExec:    docker.NewCommandExecutor(cfg.StackLocation),
+		dockerClient:   dockerClient,
+		serviceCache:   cache,
+		logger:         logger.With(zap.String("component", "stack")),
+		driftSummaries: make(map[string]cachedDriftSummary),
 	}
 
 	if err := cache.Start(); err != nil {
@@ -350,6 +357,7 @@ func (s *Service) ListStacks() ([]Stack, error) {
 					TotalContainers:   totalContainers,
 					RunningContainers: runningContainers,
 					HealthDetails:     healthDetails,
+					Metadata:          s.loadStackMetadata(stackPath, filename),
 				}
 				stacks = append(stacks, stack)
 				break
@@ -520,6 +528,7 @@ func (s *Service) GetStackDetails(name string) (*StackDetails, error) {
 		Path:        stackPath,
 		ComposeFile: composeFile,
 		Services:    services,
+		Metadata:    s.loadStackMetadata(stackPath, composeFile),
 	}, nil
 }
 
@@ -2005,12 +2014,23 @@ func (s *Service) getStackHealthDetails(stackName string) *StackHealthDetails {
 		reasons = append(reasons, fmt.Sprintf("%d container(s) failing health check", unhealthy))
 	}
 
+	drift := s.getStackDriftSummary(stackName)
+	if drift != nil && drift.HasDrift {
+		if len(drift.DriftedServices) > 0 {
+			reasons = append(reasons, fmt.Sprintf("%d service(s) drifted from compose file", len(drift.DriftedServices)))
+		}
+		if drift.OrphanCount > 0 {
+			reasons = append(reasons, fmt.Sprintf("%d orphaned container(s)", drift.OrphanCount))
+		}
+	}
+
 	healthDetails := &StackHealthDetails{
 		Percentage:     percentage,
 		HealthyCount:   healthy,
 		UnhealthyCount: unhealthy,
 		StoppedCount:   stopped,
 		Reasons:        reasons,
+		Drift:          drift,
 	}
 
 	s.logger.Debug("Stack health details calculated",
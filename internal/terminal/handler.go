@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/tech-arch1tect/berth-agent/internal/agentsign"
+	"github.com/tech-arch1tect/berth-agent/internal/docker"
 	"github.com/tech-arch1tect/berth-agent/internal/logging"
 	"strings"
 	"time"
@@ -348,17 +349,7 @@ func (h *Handler) validateContainerStackMatch(containerID, expectedStackName str
 		return fmt.Errorf("failed to inspect container: %w", err)
 	}
 
-	actualStackName, exists := inspect.Config.Labels["com.docker.compose.project"]
-	if !exists {
-		return fmt.Errorf("container has no Docker Compose project label")
-	}
-
-	if actualStackName != expectedStackName {
-		return fmt.Errorf("stack mismatch: container belongs to '%s' but claimed stack is '%s'",
-			actualStackName, expectedStackName)
-	}
-
-	return nil
+	return docker.ValidateContainerStack(inspect, expectedStackName)
 }
 
 func (h *Handler) logTerminalSession(c echo.Context, req TerminalRequest, sessionID string, success bool, errorMsg string) {
@@ -0,0 +1,34 @@
+package validation
+
+import (
+	"regexp"
+	"strings"
+)
+
+const (
+	SecretMask = "********"
+	RevealAll  = "*"
+)
+
+var secretVariableNamePattern = regexp.MustCompile(`(?i)(PASSWORD|PASSWD|SECRET|TOKEN|API_?KEY|PRIVATE_?KEY|ACCESS_?KEY|CREDENTIALS?)`)
+
+func IsSecretVariableName(name string) bool {
+	return secretVariableNamePattern.MatchString(name)
+}
+
+func ParseRevealParam(value string) []string {
+	switch value {
+	case "", "false":
+		return nil
+	case "true", "all":
+		return []string{RevealAll}
+	}
+
+	var keys []string
+	for _, key := range strings.Split(value, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
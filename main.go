@@ -12,11 +12,14 @@ import (
 	"github.com/tech-arch1tect/berth-agent/internal/files"
 	"github.com/tech-arch1tect/berth-agent/internal/grypescanner"
 	"github.com/tech-arch1tect/berth-agent/internal/health"
+	"github.com/tech-arch1tect/berth-agent/internal/history"
 	"github.com/tech-arch1tect/berth-agent/internal/images"
 	"github.com/tech-arch1tect/berth-agent/internal/logging"
 	"github.com/tech-arch1tect/berth-agent/internal/logs"
 	"github.com/tech-arch1tect/berth-agent/internal/maintenance"
 	"github.com/tech-arch1tect/berth-agent/internal/operations"
+	"github.com/tech-arch1tect/berth-agent/internal/revisions"
+	"github.com/tech-arch1tect/berth-agent/internal/secrets"
 	"github.com/tech-arch1tect/berth-agent/internal/sidecar"
 	"github.com/tech-arch1tect/berth-agent/internal/socketproxy"
 	"github.com/tech-arch1tect/berth-agent/internal/ssl"
@@ -72,6 +75,9 @@ func runAgent() {
 		images.Module,
 		composeeditor.Module,
 		vulnscan.Module,
+		history.Module,
+		revisions.Module,
+		secrets.Module,
 		fx.Provide(NewEcho),
 		fx.Provide(NewWebSocketHandler),
 		fx.Provide(NewEventMonitorWithConfig),
@@ -121,6 +127,9 @@ func RegisterRoutes(
 	composeEditorHandler *composeeditor.Handler,
 	vulnscanHandler *vulnscan.Handler,
 	backupHandler *backup.Handler,
+	historyHandler *history.Handler,
+	revisionsHandler *revisions.Handler,
+	secretsHandler *secrets.Handler,
 	logger *logging.Logger,
 ) {
 	verifier, responder, err := agentsign.LoadMaterial(ssl.CertDir)
@@ -139,13 +148,30 @@ func RegisterRoutes(
 	api.GET("/stacks", stackHandler.ListStacks)
 	api.POST("/stacks", stackHandler.CreateStack)
 	api.GET("/stacks/summary", stackHandler.GetStacksSummary)
+	api.GET("/stacks/graph", stackHandler.GetStackGraph)
+	api.GET("/ports", stackHandler.GetPortInventory)
+	api.GET("/usage", filesHandler.GetUsageReport)
 	api.GET("/stacks/:name", stackHandler.GetStackDetails)
 	api.GET("/stacks/:name/networks", stackHandler.GetStackNetworks)
 	api.GET("/stacks/:name/volumes", stackHandler.GetStackVolumes)
 	api.GET("/stacks/:name/environment", stackHandler.GetStackEnvironmentVariables)
 	api.GET("/stacks/:name/images", stackHandler.GetContainerImageDetails)
+	api.GET("/stacks/:name/drift", stackHandler.GetStackDrift)
+	api.GET("/stacks/:name/history", historyHandler.GetStackHistory)
 	api.GET("/stacks/:name/compose", composeEditorHandler.GetComposeConfig)
 	api.PATCH("/stacks/:name/compose", composeEditorHandler.UpdateCompose)
+	api.POST("/stacks/:name/compose/validate", composeEditorHandler.ValidateCompose)
+	api.GET("/stacks/:name/lint", composeEditorHandler.LintCompose)
+	api.GET("/stacks/:name/dotenv", composeEditorHandler.GetDotEnv)
+	api.PATCH("/stacks/:name/dotenv", composeEditorHandler.UpdateDotEnv)
+	api.GET("/stacks/:name/revisions", revisionsHandler.ListRevisions)
+	api.GET("/stacks/:name/revisions/diff", revisionsHandler.DiffRevisions)
+	api.GET("/stacks/:name/revisions/:revisionId", revisionsHandler.GetRevision)
+	api.POST("/stacks/:name/revisions/:revisionId/revert", revisionsHandler.RevertRevision)
+	api.GET("/stacks/:name/secrets", secretsHandler.ListSecrets)
+	api.GET("/stacks/:name/secrets/:secretName", secretsHandler.GetSecret)
+	api.PUT("/stacks/:name/secrets/:secretName", secretsHandler.SetSecret)
+	api.DELETE("/stacks/:name/secrets/:secretName", secretsHandler.DeleteSecret)
 	api.GET("/stacks/:name/stats", statsHandler.GetStackStats)
 	api.GET("/stacks/:stackName/logs", logsHandler.GetStackLogs)
 	api.GET("/stacks/:stackName/containers/:containerName/logs", logsHandler.GetContainerLogs)
@@ -164,14 +190,40 @@ func RegisterRoutes(
 	api.GET("/stacks/:stackName/files/read", filesHandler.ReadFile)
 	api.POST("/stacks/:stackName/files/write", filesHandler.WriteFile)
 	api.POST("/stacks/:stackName/files/upload", filesHandler.UploadFile)
+	api.GET("/stacks/:stackName/files/uploads", filesHandler.ListUploads)
+	api.POST("/stacks/:stackName/files/uploads", filesHandler.CreateUpload)
+	api.GET("/stacks/:stackName/files/uploads/:uploadId", filesHandler.GetUpload)
+	api.PUT("/stacks/:stackName/files/uploads/:uploadId", filesHandler.WriteUploadChunk)
+	api.POST("/stacks/:stackName/files/uploads/:uploadId/complete", filesHandler.CompleteUpload)
+	api.DELETE("/stacks/:stackName/files/uploads/:uploadId", filesHandler.AbortUpload)
 	api.POST("/stacks/:stackName/files/mkdir", filesHandler.CreateDirectory)
 	api.DELETE("/stacks/:stackName/files/delete", filesHandler.Delete)
 	api.POST("/stacks/:stackName/files/rename", filesHandler.Rename)
 	api.POST("/stacks/:stackName/files/copy", filesHandler.Copy)
+	api.POST("/stacks/:stackName/files/changeset", filesHandler.ApplyChangeset)
 	api.POST("/stacks/:stackName/files/chmod", filesHandler.Chmod)
 	api.POST("/stacks/:stackName/files/chown", filesHandler.Chown)
 	api.GET("/stacks/:stackName/files/download", filesHandler.DownloadFile)
+	api.GET("/stacks/:stackName/files/download-archive", filesHandler.DownloadArchive)
 	api.GET("/stacks/:stackName/files/stats", filesHandler.GetDirectoryStats)
+	api.GET("/stacks/:stackName/files/usage", filesHandler.GetStackUsage)
+	api.GET("/stacks/:stackName/files/search", filesHandler.SearchFiles)
+	api.GET("/stacks/:stackName/files/watch", filesHandler.WatchFiles)
+	api.GET("/stacks/:stackName/files/trash", filesHandler.ListTrash)
+	api.POST("/stacks/:stackName/files/trash/:trashId/restore", filesHandler.RestoreTrash)
+	api.DELETE("/stacks/:stackName/files/trash/:trashId", filesHandler.PurgeTrash)
+	api.DELETE("/stacks/:stackName/files/trash", filesHandler.PurgeTrash)
+	api.GET("/stacks/:stackName/volumes/:volumeName/files", filesHandler.ListVolumeDirectory)
+	api.GET("/stacks/:stackName/volumes/:volumeName/files/read", filesHandler.ReadVolumeFile)
+	api.GET("/stacks/:stackName/volumes/:volumeName/files/download", filesHandler.DownloadVolumeFile)
+	api.POST("/stacks/:stackName/volumes/:volumeName/files/write", filesHandler.WriteVolumeFile)
+	api.POST("/stacks/:stackName/volumes/:volumeName/files/upload", filesHandler.UploadVolumeFile)
+	api.DELETE("/stacks/:stackName/volumes/:volumeName/files/delete", filesHandler.DeleteVolumePath)
+	api.GET("/stacks/:stackName/containers/:containerName/files", filesHandler.ListContainerDirectory)
+	api.GET("/stacks/:stackName/containers/:containerName/files/download", filesHandler.DownloadContainerFile)
+	api.POST("/stacks/:stackName/containers/:containerName/files/upload", filesHandler.UploadContainerFile)
+	api.POST("/stacks/:stackName/containers/:containerName/files/copy-to-stack", filesHandler.CopyContainerToStack)
+	api.POST("/stacks/:stackName/containers/:containerName/files/copy-from-stack", filesHandler.CopyStackToContainer)
 
 	api.POST("/images/check-updates", imagesHandler.CheckImageUpdates)
 	api.GET("/images/running", imagesHandler.ListRunningContainerImages)
@@ -196,8 +248,8 @@ func NewWebSocketHandler(hub *websocket.Hub) *websocket.Handler {
 	return websocket.NewHandler(hub)
 }
 
-func NewEventMonitorWithConfig(hub *websocket.Hub, cfg *config.Config, logger *logging.Logger) *docker.EventMonitor {
-	return docker.NewEventMonitor(hub, cfg.StackLocation, logger)
+func NewEventMonitorWithConfig(hub *websocket.Hub, cfg *config.Config, historyService *history.Service, logger *logging.Logger) *docker.EventMonitor {
+	return docker.NewEventMonitor(hub, cfg.StackLocation, historyService, logger)
 }
 
 func LogAgentStartup(logger *logging.Logger, cfg *config.Config) {
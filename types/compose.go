@@ -2,6 +2,7 @@ package types
 
 type RawComposeConfig struct {
 	ComposeFile string         `json:"compose_file"`
+	ETag        string         `json:"etag,omitempty"`
 	Services    map[string]any `json:"services"`
 	Networks    map[string]any `json:"networks,omitempty"`
 	Volumes     map[string]any `json:"volumes,omitempty"`
@@ -42,6 +43,59 @@ type ServiceChanges struct {
 	Deploy      *DeployConfig                    `json:"deploy,omitempty"`
 	Build       *BuildConfig                     `json:"build,omitempty"`
 	Networks    map[string]*ServiceNetworkConfig `json:"networks,omitempty"`
+
+	CapAdd          *[]string                `json:"cap_add,omitempty"`
+	CapDrop         *[]string                `json:"cap_drop,omitempty"`
+	Ulimits         map[string]*UlimitConfig `json:"ulimits,omitempty"`
+	Logging         *LoggingConfig           `json:"logging,omitempty"`
+	ExtraHosts      *[]ExtraHost             `json:"extra_hosts,omitempty"`
+	Devices         *[]DeviceMapping         `json:"devices,omitempty"`
+	Sysctls         map[string]*string       `json:"sysctls,omitempty"`
+	User            *string                  `json:"user,omitempty"`
+	WorkingDir      *string                  `json:"working_dir,omitempty"`
+	EnvFile         *[]EnvFileConfig         `json:"env_file,omitempty"`
+	SecurityOpt     *[]string                `json:"security_opt,omitempty"`
+	Tmpfs           *[]string                `json:"tmpfs,omitempty"`
+	StopGracePeriod *string                  `json:"stop_grace_period,omitempty"`
+	Profiles        *[]string                `json:"profiles,omitempty"`
+	Secrets         *[]ServiceFileReference  `json:"secrets,omitempty"`
+	Configs         *[]ServiceFileReference  `json:"configs,omitempty"`
+}
+
+type UlimitConfig struct {
+	Single *int `json:"single,omitempty"`
+	Soft   *int `json:"soft,omitempty"`
+	Hard   *int `json:"hard,omitempty"`
+}
+
+type LoggingConfig struct {
+	Driver  string            `json:"driver,omitempty"`
+	Options map[string]string `json:"options,omitempty"`
+}
+
+type ExtraHost struct {
+	Hostname string `json:"hostname"`
+	IP       string `json:"ip"`
+}
+
+type DeviceMapping struct {
+	Source      string `json:"source"`
+	Target      string `json:"target,omitempty"`
+	Permissions string `json:"permissions,omitempty"`
+}
+
+type EnvFileConfig struct {
+	Path     string `json:"path"`
+	Required *bool  `json:"required,omitempty"`
+	Format   string `json:"format,omitempty"`
+}
+
+type ServiceFileReference struct {
+	Source string  `json:"source"`
+	Target string  `json:"target,omitempty"`
+	UID    string  `json:"uid,omitempty"`
+	GID    string  `json:"gid,omitempty"`
+	Mode   *uint32 `json:"mode,omitempty"`
 }
 
 type ServiceNetworkConfig struct {
@@ -192,4 +246,127 @@ type UpdateComposeResponse struct {
 	Message      string `json:"message,omitempty"`
 	OriginalYaml string `json:"original_yaml,omitempty"`
 	ModifiedYaml string `json:"modified_yaml,omitempty"`
+	ETag         string `json:"etag,omitempty"`
+	Merged       bool   `json:"merged,omitempty"`
+}
+
+type ComposeConflict struct {
+	Path      string `json:"path"`
+	Base      any    `json:"base"`
+	Current   any    `json:"current"`
+	Requested any    `json:"requested"`
+}
+
+type ComposeConflictResponse struct {
+	Error       string            `json:"error"`
+	CurrentETag string            `json:"current_etag"`
+	Conflicts   []ComposeConflict `json:"conflicts"`
+}
+
+type ValidateComposeRequest struct {
+	Content *string `json:"content,omitempty"`
+}
+
+type ComposeDiagnostic struct {
+	Severity string `json:"severity"`
+	Code     string `json:"code"`
+	Message  string `json:"message"`
+	Path     string `json:"path,omitempty"`
+	Line     int    `json:"line,omitempty"`
+	Column   int    `json:"column,omitempty"`
+}
+
+type ValidateComposeResponse struct {
+	Valid       bool                `json:"valid"`
+	ComposeFile string              `json:"compose_file"`
+	Source      string              `json:"source"`
+	Errors      []ComposeDiagnostic `json:"errors"`
+	Warnings    []ComposeDiagnostic `json:"warnings"`
+}
+
+type LintFinding struct {
+	Rule     string `json:"rule"`
+	Severity string `json:"severity"`
+	Service  string `json:"service,omitempty"`
+	Message  string `json:"message"`
+	Path     string `json:"path,omitempty"`
+	Line     int    `json:"line,omitempty"`
+	Column   int    `json:"column,omitempty"`
+}
+
+type LintComposeResponse struct {
+	ComposeFile string         `json:"compose_file"`
+	Passed      bool           `json:"passed"`
+	FailOn      string         `json:"fail_on"`
+	Summary     map[string]int `json:"summary"`
+	Findings    []LintFinding  `json:"findings"`
+	Suppressed  []LintFinding  `json:"suppressed"`
+}
+
+type RenderedComposeConfig struct {
+	ComposeFile  string              `json:"compose_file"`
+	ProjectName  string              `json:"project_name"`
+	Rendered     map[string]any      `json:"rendered"`
+	RenderedYaml string              `json:"rendered_yaml"`
+	Variables    []RenderedVariable  `json:"variables"`
+	Interpolated []InterpolatedValue `json:"interpolated"`
+}
+
+type RenderedVariable struct {
+	Name   string   `json:"name"`
+	Value  string   `json:"value"`
+	Source string   `json:"source"`
+	Masked bool     `json:"masked,omitempty"`
+	UsedBy []string `json:"used_by"`
+}
+
+type InterpolatedValue struct {
+	Path      string   `json:"path"`
+	Template  string   `json:"template,omitempty"`
+	Value     string   `json:"value"`
+	Variables []string `json:"variables,omitempty"`
+	Source    string   `json:"source"`
+	File      string   `json:"file,omitempty"`
+	Masked    bool     `json:"masked,omitempty"`
+}
+
+type DotEnvConfig struct {
+	File             string                  `json:"file"`
+	Exists           bool                    `json:"exists"`
+	Entries          []DotEnvEntry           `json:"entries"`
+	TrailingComments []string                `json:"trailing_comments,omitempty"`
+	Missing          []DotEnvMissingVariable `json:"missing"`
+	Unused           []string                `json:"unused"`
+	Revealed         []string                `json:"revealed,omitempty"`
+}
+
+type DotEnvEntry struct {
+	Line          int      `json:"line"`
+	Key           string   `json:"key"`
+	Value         string   `json:"value"`
+	Exported      bool     `json:"exported,omitempty"`
+	Comments      []string `json:"comments,omitempty"`
+	InlineComment string   `json:"inline_comment,omitempty"`
+	Masked        bool     `json:"masked,omitempty"`
+	Duplicate     bool     `json:"duplicate,omitempty"`
+	Unused        bool     `json:"unused,omitempty"`
+	UsedBy        []string `json:"used_by"`
+}
+
+type DotEnvMissingVariable struct {
+	Name         string   `json:"name"`
+	Required     bool     `json:"required"`
+	DefaultValue string   `json:"default_value,omitempty"`
+	UsedBy       []string `json:"used_by"`
+}
+
+type DotEnvOperation struct {
+	Op     string `json:"op"`
+	Key    string `json:"key"`
+	Value  string `json:"value,omitempty"`
+	NewKey string `json:"new_key,omitempty"`
+}
+
+type UpdateDotEnvRequest struct {
+	Operations []DotEnvOperation `json:"operations"`
 }